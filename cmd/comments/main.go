@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -10,12 +11,17 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/weberc2/auth/pkg/client"
 	"github.com/weberc2/comments/pkg/comments"
+	"github.com/weberc2/comments/pkg/comments/events"
+	"github.com/weberc2/comments/pkg/comments/jobs"
 	"github.com/weberc2/comments/pkg/comments/types"
+	"github.com/weberc2/comments/pkg/comments/webhooks"
 	"github.com/weberc2/comments/pkg/pgcommentsstore"
 	pz "github.com/weberc2/httpeasy"
 )
@@ -55,6 +61,11 @@ func main() {
 		log.Fatal("missing required env var: COOKIE_ENCRYPTION_KEY")
 	}
 
+	csrfKey := os.Getenv("CSRF_KEY")
+	if csrfKey == "" {
+		log.Fatal("missing required env var: CSRF_KEY")
+	}
+
 	accessTokenPublicKey := os.Getenv("ACCESS_KEY")
 	if accessTokenPublicKey == "" {
 		log.Fatal("missing required env var: ACCESS_KEY")
@@ -64,18 +75,164 @@ func main() {
 		log.Fatalf("decoding ACCESS_KEY: %v", err)
 	}
 
+	premoderate := false
+	if s := os.Getenv("PREMODERATE"); s != "" {
+		if premoderate, err = strconv.ParseBool(s); err != nil {
+			log.Fatalf("error parsing `PREMODERATE` env var: %v", err)
+		}
+	}
+
+	// `REPORT_THRESHOLD` is the number of reports at which a comment is
+	// hidden pending moderator review; `0` disables hiding.
+	reportThreshold := 3
+	if s := os.Getenv("REPORT_THRESHOLD"); s != "" {
+		if reportThreshold, err = strconv.Atoi(s); err != nil ||
+			reportThreshold < 0 {
+			log.Fatalf("invalid `REPORT_THRESHOLD` env var: %s", s)
+		}
+	}
+
 	commentsStore, err := pgcommentsstore.OpenEnv()
 	if err != nil {
 		log.Fatalf("creating postgres comments store client: %v", err)
 	}
 
 	if err := commentsStore.EnsureTable(); err != nil {
-		log.Fatalf("ensuring comments table exists: %v", err)
+		log.Fatalf("ensuring comments tables exist: %v", err)
 	}
 
+	// `ADMINS` and `MODERATORS` are comma-separated lists of users whose roles
+	// are assigned on startup. Roles can also be assigned by admins via the
+	// API.
+	for _, env := range []struct {
+		name string
+		role types.Role
+	}{
+		{"ADMINS", types.RoleAdmin},
+		{"MODERATORS", types.RoleModerator},
+	} {
+		for _, user := range strings.Split(os.Getenv(env.name), ",") {
+			if user = strings.TrimSpace(user); user == "" {
+				continue
+			}
+			if err := commentsStore.PutUserRole(&types.UserRole{
+				User: types.UserID(user),
+				Role: env.role,
+			}); err != nil {
+				log.Fatalf("assigning roles from `%s`: %v", env.name, err)
+			}
+		}
+	}
+
+	// `RATE_LIMIT_CREATE`, `RATE_LIMIT_EDIT`, and `RATE_LIMIT_DELETE` override
+	// the default limits for each action; they take the form
+	// `<count>/<duration>` (e.g., `5/1m`), and a count of `0` disables the
+	// limit.
+	limits := map[types.Action]types.Limit{}
+	for action, limit := range comments.DefaultRateLimits {
+		limits[action] = limit
+		env := "RATE_LIMIT_" + strings.ToUpper(string(action))
+		if s := os.Getenv(env); s != "" {
+			if limits[action], err = types.ParseLimit(s); err != nil {
+				log.Fatalf("error parsing `%s` env var: %v", env, err)
+			}
+		}
+	}
+
+	// Rate limits are stored in postgres by default so that they hold
+	// across replicas. `RATE_LIMIT_STORE=memory` keeps them in memory
+	// instead, which is only appropriate for a single replica.
+	var rateLimitStore types.RateLimitStore = commentsStore
+	switch s := os.Getenv("RATE_LIMIT_STORE"); s {
+	case "", "postgres":
+	case "memory":
+		rateLimitStore = &comments.MemoryRateLimitStore{}
+	default:
+		log.Fatalf("invalid `RATE_LIMIT_STORE` env var: %s", s)
+	}
+	rateLimiter := &comments.RateLimiter{
+		Store:    rateLimitStore,
+		Limits:   limits,
+		TimeFunc: time.Now,
+	}
+
+	// `TRUSTED_PROXIES` is required: it's a comma-separated list of the
+	// networks (e.g., `10.0.0.0/8`) of the reverse proxies in front of the
+	// server, whose `X-Forwarded-For` entries identify clients, or `none` if
+	// clients connect directly. Rate limits are keyed on client IPs, so if
+	// the proxies aren't listed, every client shares the proxies' limits.
+	trustedProxies, err := comments.ParseTrustedProxies(
+		os.Getenv("TRUSTED_PROXIES"),
+	)
+	if err != nil {
+		log.Fatalf("error parsing `TRUSTED_PROXIES` env var: %v", err)
+	}
+
+	// `EMBED_ORIGINS` is a comma-separated list of the origins (e.g.,
+	// `https://blog.example.org`) which may embed the replies page.
+	embedOrigins, err := comments.ParseOrigins(os.Getenv("EMBED_ORIGINS"))
+	if err != nil {
+		log.Fatalf("error parsing `EMBED_ORIGINS` env var: %v", err)
+	}
+
+	spamCheckers, err := spamCheckersEnv(baseURLString)
+	if err != nil {
+		log.Fatalf("configuring spam checkers: %v", err)
+	}
+
+	replyNotifier, mailQueue, err := replyNotifierEnv(
+		baseURLString,
+		commentsStore,
+	)
+	if err != nil {
+		log.Fatalf("configuring reply notifications: %v", err)
+	}
+	var notifier comments.ReplyNotifier
+	var unsubscribeTokens *comments.UnsubscribeTokens
+	if replyNotifier != nil {
+		go mailQueue.Run(context.Background())
+		notifier = replyNotifier
+		unsubscribeTokens = replyNotifier.UnsubscribeTokens
+	}
+
+	// webhooks are registered via the API, so the dispatcher always runs.
+	// Deliveries are queued in Postgres so that events which the relay has
+	// acknowledged survive restarts.
+	dispatcher := webhooks.Dispatcher{
+		Store: commentsStore,
+		Jobs:  jobs.Queue{Store: commentsStore},
+	}
+	go dispatcher.Run(context.Background())
+
+	// Live updates: the relay notifies every replica (including this one)
+	// via Postgres, and each replica fans the events out to its own replies
+	// pages.
+	var bus events.Bus
+	listener, err := pgcommentsstore.ListenEnv(&bus)
+	if err != nil {
+		log.Fatalf("listening for comment events: %v", err)
+	}
+	go listener.Run(context.Background())
+
 	commentsService := comments.CommentsService{
 		Comments: comments.CommentsModel{
-			CommentsStore: commentsStore,
+			CommentsStore:     commentsStore,
+			VotesStore:        commentsStore,
+			PostSettingsStore: commentsStore,
+			Premoderate:       premoderate,
+			Roles:             commentsStore,
+			RevisionsStore:    commentsStore,
+			ReportsStore:      commentsStore,
+			ReportThreshold:   reportThreshold,
+			SanctionsStore:    commentsStore,
+			SpamCheckers:      spamCheckers,
+			ReplyNotifier:     notifier,
+
+			NotificationSettingsStore: commentsStore,
+			SubscriptionsStore:        commentsStore,
+			InboxStore:                commentsStore,
+			WebhooksStore:             commentsStore,
+			WebhookReplayer:           &dispatcher,
 			IDFunc: func() types.CommentID {
 				return types.CommentID(uuid.NewString())
 			},
@@ -83,6 +240,26 @@ func main() {
 		},
 	}
 
+	// reply notifications are queued in Postgres by the relay, so they're
+	// sent whether or not the replica which stored the reply survives
+	replyNotifications := comments.ReplyNotifications{
+		Comments: &commentsService.Comments,
+		Jobs:     jobs.Queue{Store: commentsStore},
+	}
+	go replyNotifications.Run(context.Background())
+
+	// comment events are recorded in the outbox by the store and relayed to
+	// the dispatcher, the reply notifications and the other replicas
+	relay := pgcommentsstore.Relay{
+		Store: commentsStore,
+		Sink: events.Sinks{
+			&dispatcher,
+			&replyNotifications,
+			&pgcommentsstore.Notifier{Store: commentsStore},
+		},
+	}
+	go relay.Run(context.Background())
+
 	webServerAuth := client.AuthTypeWebServer{
 		WebServerApp: client.WebServerApp{
 			Client:          client.DefaultClient(authBaseURL),
@@ -101,14 +278,23 @@ func main() {
 			BaseURL:          baseURLString,
 			Comments:         commentsService.Comments,
 			AuthCallbackPath: "/auth/callback",
+			CSRF:             &comments.CSRF{Key: []byte(csrfKey)},
+			RateLimiter:      rateLimiter,
+
+			UnsubscribeTokens: unsubscribeTokens,
+			Bus:               &bus,
+			EmbedOrigins:      embedOrigins,
 		},
 		AuthType:      &webServerAuth,
 		Authenticator: a,
 	}
 
 	apiAuth := client.AuthTypeClientProgram{}
+	putComment := rateLimiter.Limit(types.ActionCreate, commentsService.Put)
+	updateComment := rateLimiter.Limit(types.ActionEdit, commentsService.Update)
+	deleteComment := rateLimiter.Limit(types.ActionDelete, commentsService.Delete)
 
-	if err := http.ListenAndServe(addr, pz.Register(
+	handler := pz.Register(
 		pz.JSONLog(os.Stderr),
 		append(
 			webServer.Routes(),
@@ -117,24 +303,176 @@ func main() {
 			pz.Route{
 				Method:  "GET",
 				Path:    "/api/posts/{post-id}/comments/{comment-id}/replies",
-				Handler: commentsService.Replies,
+				Handler: a.Optional(apiAuth, commentsService.Replies),
 			},
 			pz.Route{
 				Method:  "POST",
 				Path:    "/api/posts/{post-id}/comments",
-				Handler: a.Auth(apiAuth, commentsService.Put),
+				Handler: a.Auth(apiAuth, putComment),
 			},
 			pz.Route{
 				Method:  "GET",
 				Path:    "/api/posts/{post-id}/comments/{comment-id}",
-				Handler: commentsService.Get,
+				Handler: a.Optional(apiAuth, commentsService.Get),
 			},
 			pz.Route{
 				Method:  "PATCH",
 				Path:    "/api/posts/{post-id}/comments/{comment-id}",
-				Handler: a.Auth(apiAuth, commentsService.Update),
+				Handler: a.Auth(apiAuth, updateComment),
+			},
+			pz.Route{
+				Method:  "DELETE",
+				Path:    "/api/posts/{post-id}/comments/{comment-id}",
+				Handler: a.Auth(apiAuth, deleteComment),
+			},
+			pz.Route{
+				Method:  "GET",
+				Path:    "/api/posts/{post-id}/comments/{comment-id}/revisions",
+				Handler: a.Optional(apiAuth, commentsService.Revisions),
+			},
+			pz.Route{
+				Method:  "PUT",
+				Path:    "/api/posts/{post-id}/comments/{comment-id}/vote",
+				Handler: a.Auth(apiAuth, commentsService.Vote),
+			},
+			pz.Route{
+				Method:  "DELETE",
+				Path:    "/api/posts/{post-id}/comments/{comment-id}/vote",
+				Handler: a.Auth(apiAuth, commentsService.Unvote),
+			},
+			pz.Route{
+				Method:  "GET",
+				Path:    "/api/moderation/queue",
+				Handler: a.Auth(apiAuth, commentsService.Queue),
+			},
+			pz.Route{
+				Method:  "POST",
+				Path:    "/api/posts/{post-id}/comments/{comment-id}/approve",
+				Handler: a.Auth(apiAuth, commentsService.Approve),
+			},
+			pz.Route{
+				Method:  "POST",
+				Path:    "/api/posts/{post-id}/comments/{comment-id}/reject",
+				Handler: a.Auth(apiAuth, commentsService.Reject),
+			},
+			pz.Route{
+				Method:  "POST",
+				Path:    "/api/posts/{post-id}/comments/{comment-id}/report",
+				Handler: a.Auth(apiAuth, commentsService.Report),
+			},
+			pz.Route{
+				Method:  "GET",
+				Path:    "/api/moderation/reports",
+				Handler: a.Auth(apiAuth, commentsService.Reports),
+			},
+			pz.Route{
+				Method:  "GET",
+				Path:    "/api/posts/{post-id}/settings",
+				Handler: commentsService.PostSettings,
+			},
+			pz.Route{
+				Method:  "PUT",
+				Path:    "/api/posts/{post-id}/settings",
+				Handler: a.Auth(apiAuth, commentsService.PutPostSettings),
+			},
+			pz.Route{
+				Method:  "PUT",
+				Path:    "/api/users/{user-id}/role",
+				Handler: a.Auth(apiAuth, commentsService.PutUserRole),
+			},
+			pz.Route{
+				Method:  "GET",
+				Path:    "/api/notifications/settings",
+				Handler: a.Auth(apiAuth, commentsService.NotificationSettings),
+			},
+			pz.Route{
+				Method: "PUT",
+				Path:   "/api/notifications/settings",
+				Handler: a.Auth(
+					apiAuth,
+					commentsService.PutNotificationSettings,
+				),
+			},
+			pz.Route{
+				Method:  "PUT",
+				Path:    "/api/posts/{post-id}/comments/{comment-id}/subscription",
+				Handler: a.Auth(apiAuth, commentsService.Subscribe),
+			},
+			pz.Route{
+				Method:  "DELETE",
+				Path:    "/api/posts/{post-id}/comments/{comment-id}/subscription",
+				Handler: a.Auth(apiAuth, commentsService.Unsubscribe),
+			},
+			pz.Route{
+				Method:  "GET",
+				Path:    "/api/subscriptions",
+				Handler: a.Auth(apiAuth, commentsService.Subscriptions),
+			},
+			pz.Route{
+				Method:  "GET",
+				Path:    "/api/notifications",
+				Handler: a.Auth(apiAuth, commentsService.Inbox),
+			},
+			pz.Route{
+				Method:  "POST",
+				Path:    "/api/notifications/read",
+				Handler: a.Auth(apiAuth, commentsService.MarkAllRead),
+			},
+			pz.Route{
+				Method:  "POST",
+				Path:    "/api/notifications/{post-id}/{comment-id}/read",
+				Handler: a.Auth(apiAuth, commentsService.MarkRead),
+			},
+			pz.Route{
+				Method:  "GET",
+				Path:    "/api/webhooks",
+				Handler: a.Auth(apiAuth, commentsService.Webhooks),
+			},
+			pz.Route{
+				Method:  "POST",
+				Path:    "/api/webhooks",
+				Handler: a.Auth(apiAuth, commentsService.PutWebhook),
+			},
+			pz.Route{
+				Method:  "DELETE",
+				Path:    "/api/webhooks/{webhook-id}",
+				Handler: a.Auth(apiAuth, commentsService.DeleteWebhook),
+			},
+			pz.Route{
+				Method:  "GET",
+				Path:    "/api/webhook-deliveries",
+				Handler: a.Auth(apiAuth, commentsService.FailedDeliveries),
+			},
+			pz.Route{
+				Method:  "POST",
+				Path:    "/api/webhook-deliveries/{delivery-id}/replay",
+				Handler: a.Auth(apiAuth, commentsService.ReplayDelivery),
+			},
+			pz.Route{
+				Method:  "GET",
+				Path:    "/api/sanctions",
+				Handler: a.Auth(apiAuth, commentsService.Sanctions),
+			},
+			pz.Route{
+				Method:  "GET",
+				Path:    "/api/users/{user-id}/sanctions",
+				Handler: a.Auth(apiAuth, commentsService.UserSanctions),
+			},
+			pz.Route{
+				Method:  "PUT",
+				Path:    "/api/users/{user-id}/sanctions/{kind}",
+				Handler: a.Auth(apiAuth, commentsService.PutSanction),
+			},
+			pz.Route{
+				Method:  "DELETE",
+				Path:    "/api/users/{user-id}/sanctions/{kind}",
+				Handler: a.Auth(apiAuth, commentsService.DeleteSanction),
 			},
 		)...,
+	)
+	if err := http.ListenAndServe(addr, comments.ClientIP(
+		comments.StripUserHeader(handler),
+		trustedProxies,
 	)); err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"os"
+
+	"github.com/weberc2/auth/pkg/pguserstore"
+	authtypes "github.com/weberc2/auth/pkg/types"
+	"github.com/weberc2/comments/pkg/comments"
+	"github.com/weberc2/comments/pkg/comments/jobs"
+	"github.com/weberc2/comments/pkg/comments/mail"
+	"github.com/weberc2/comments/pkg/comments/types"
+	"github.com/weberc2/comments/pkg/pgcommentsstore"
+)
+
+// replyNotifierEnv configures reply emails from env vars. They're disabled
+// (and the returned notifier and queue are nil) unless a sender is set:
+//
+//   - `SMTP_ADDR` (`host:port`) sends emails via SMTP, authenticating with
+//     `SMTP_USERNAME` and `SMTP_PASSWORD` if they're set.
+//   - `MAIL_LOG` writes emails to a file instead (or stderr if it's `-`), for
+//     local testing.
+//   - `MAIL_FROM` is the sender's address.
+//   - `UNSUBSCRIBE_KEY` signs the emails' unsubscribe links.
+//
+// Email addresses are looked up in the auth service's `users` table, which
+// must be in the same database as the comments tables. Queued emails are kept
+// in the comments store so that they survive restarts.
+func replyNotifierEnv(
+	baseURL string,
+	store *pgcommentsstore.PGCommentsStore,
+) (*comments.EmailNotifier, *mail.Queue, error) {
+	from := os.Getenv("MAIL_FROM")
+	var sender types.EmailSender
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid `SMTP_ADDR` env var: %w", err)
+		}
+		var auth smtp.Auth
+		if username := os.Getenv("SMTP_USERNAME"); username != "" {
+			auth = smtp.PlainAuth(
+				"",
+				username,
+				os.Getenv("SMTP_PASSWORD"),
+				host,
+			)
+		}
+		sender = &mail.SMTPSender{Addr: addr, From: from, Auth: auth}
+	} else if path := os.Getenv("MAIL_LOG"); path != "" {
+		var w io.Writer = os.Stderr
+		if path != "-" {
+			f, err := os.OpenFile(
+				path,
+				os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+				0600,
+			)
+			if err != nil {
+				return nil, nil, fmt.Errorf("opening `MAIL_LOG`: %w", err)
+			}
+			w = f
+		}
+		sender = &mail.LogSender{Writer: w, From: from}
+	} else {
+		return nil, nil, nil
+	}
+
+	if from == "" {
+		return nil, nil, fmt.Errorf("missing required env var: MAIL_FROM")
+	}
+	key := os.Getenv("UNSUBSCRIBE_KEY")
+	if key == "" {
+		return nil, nil, fmt.Errorf(
+			"missing required env var: UNSUBSCRIBE_KEY",
+		)
+	}
+
+	queue := &mail.Queue{Sender: sender, Jobs: jobs.Queue{Store: store}}
+	return &comments.EmailNotifier{
+		Settings: store,
+		Addresses: authUsers{
+			(*pguserstore.PGUserStore)((*sql.DB)(store)),
+		},
+		Sender:            queue,
+		UnsubscribeTokens: &comments.UnsubscribeTokens{Key: []byte(key)},
+		BaseURL:           baseURL,
+	}, queue, nil
+}
+
+// authUsers looks up email addresses in the auth service's user store.
+type authUsers struct {
+	users *pguserstore.PGUserStore
+}
+
+func (au authUsers) EmailAddress(user types.UserID) (string, error) {
+	entry, err := au.users.Get(authtypes.UserID(user))
+	if err != nil {
+		if errors.Is(err, authtypes.ErrUserNotFound) {
+			return "", types.ErrAddressNotFound
+		}
+		return "", fmt.Errorf("fetching user from auth user store: %w", err)
+	}
+	if entry.Email == "" {
+		return "", types.ErrAddressNotFound
+	}
+	return entry.Email, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/spam"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// spamCheckersEnv configures the spam checkers from env vars. Every checker is
+// disabled unless its env vars are set:
+//
+//   - `SPAM_MAX_LINKS` holds comments with more links than the max.
+//   - `SPAM_BANNED_WORDS` is a comma-separated list of words or phrases;
+//     comments which contain any of them are held.
+//   - `SPAM_REPEAT_WINDOW` is a duration (e.g., `10m`); comments which repeat
+//     another comment from the same author or IP within the window are held.
+//   - `SPAM_CORPUS` is the path to a corpus for the naive Bayes classifier (see
+//     `spam.Bayes.TrainCorpus()`).
+//   - `AKISMET_KEY` enables the Akismet checker. `AKISMET_BLOG` defaults to
+//     `blog` and `AKISMET_URL` (for Akismet-compatible services) defaults to
+//     Akismet's own API.
+func spamCheckersEnv(blog string) ([]types.SpamChecker, error) {
+	var checkers []types.SpamChecker
+
+	if s := os.Getenv("SPAM_MAX_LINKS"); s != "" {
+		max, err := strconv.Atoi(s)
+		if err != nil || max < 0 {
+			return nil, fmt.Errorf("invalid `SPAM_MAX_LINKS` env var: %s", s)
+		}
+		checkers = append(checkers, &spam.LinkCount{Max: max})
+	}
+
+	var words []string
+	for _, word := range strings.Split(os.Getenv("SPAM_BANNED_WORDS"), ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	if len(words) > 0 {
+		checkers = append(checkers, &spam.BannedWords{Words: words})
+	}
+
+	if s := os.Getenv("SPAM_REPEAT_WINDOW"); s != "" {
+		window, err := time.ParseDuration(s)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf(
+				"invalid `SPAM_REPEAT_WINDOW` env var: %s",
+				s,
+			)
+		}
+		checkers = append(
+			checkers,
+			&spam.RepeatedPosts{Window: window, TimeFunc: time.Now},
+		)
+	}
+
+	if path := os.Getenv("SPAM_CORPUS"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening `SPAM_CORPUS`: %w", err)
+		}
+		var bayes spam.Bayes
+		err = bayes.TrainCorpus(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("`SPAM_CORPUS`: %w", err)
+		}
+		checkers = append(checkers, &bayes)
+	}
+
+	if key := os.Getenv("AKISMET_KEY"); key != "" {
+		akismet := spam.Akismet{
+			URL:    os.Getenv("AKISMET_URL"),
+			Key:    key,
+			Blog:   os.Getenv("AKISMET_BLOG"),
+			Client: &http.Client{Timeout: 5 * time.Second},
+		}
+		if akismet.Blog == "" {
+			akismet.Blog = blog
+		}
+		checkers = append(checkers, &akismet)
+	}
+
+	return checkers, nil
+}
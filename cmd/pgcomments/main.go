@@ -1,19 +1,81 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"time"
 
-	"github.com/weberc2/auth/pkg/pgutil/cli"
+	"github.com/urfave/cli/v2"
+	pgutilcli "github.com/weberc2/auth/pkg/pgutil/cli"
 	"github.com/weberc2/comments/pkg/pgcommentsstore"
 )
 
 func main() {
-	app, err := cli.New(&pgcommentsstore.Table)
+	app, err := pgutilcli.New(&pgcommentsstore.Table)
 	if err != nil {
 		log.Fatal(err)
 	}
+	app.Commands = append(
+		app.Commands,
+		unescapeBodiesCommand,
+		pruneRateLimitsCommand,
+		sanctionsCommand,
+	)
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// unescapeBodiesCommand is a one-off migration for comments whose bodies were
+// HTML-escaped before they were stored (see
+// `PGCommentsStore.UnescapeBodies()`). Since it isn't idempotent, the caller
+// must pass the time at which the fixed version was deployed.
+var unescapeBodiesCommand = &cli.Command{
+	Name:  "unescape-bodies",
+	Usage: "unescape comment bodies which were HTML-escaped before storage",
+	Flags: []cli.Flag{&cli.TimestampFlag{
+		Name:     "before",
+		Usage:    "only unescape comments created before this RFC3339 time",
+		Layout:   time.RFC3339,
+		Required: true,
+	}},
+	Action: func(ctx *cli.Context) error {
+		store, err := pgcommentsstore.OpenEnv()
+		if err != nil {
+			return err
+		}
+		n, err := store.UnescapeBodies(*ctx.Timestamp("before"))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("unescaped %d comment bodies\n", n)
+		return err
+	},
+}
+
+// pruneRateLimitsCommand deletes stale rate limit buckets (see
+// `PGCommentsStore.PruneRateLimits()`). It's safe to run periodically.
+var pruneRateLimitsCommand = &cli.Command{
+	Name:  "prune-rate-limits",
+	Usage: "delete rate limit buckets which haven't been used recently",
+	Flags: []cli.Flag{&cli.DurationFlag{
+		Name:  "older-than",
+		Usage: "only delete buckets unused for this long",
+		Value: 24 * time.Hour,
+	}},
+	Action: func(ctx *cli.Context) error {
+		store, err := pgcommentsstore.OpenEnv()
+		if err != nil {
+			return err
+		}
+		n, err := store.PruneRateLimits(
+			time.Now().Add(-ctx.Duration("older-than")),
+		)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("pruned %d rate limit buckets\n", n)
+		return err
+	},
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"github.com/weberc2/comments/pkg/comments/types"
+	"github.com/weberc2/comments/pkg/pgcommentsstore"
+)
+
+// sanctionsCommand manages users' bans, mutes and shadow-bans directly in the
+// database, e.g., before any admins have been assigned.
+var sanctionsCommand = &cli.Command{
+	Name:  "sanctions",
+	Usage: "manage users' bans, mutes and shadow-bans",
+	Subcommands: []*cli.Command{
+		listSanctionsCommand,
+		addSanctionCommand,
+		removeSanctionCommand,
+	},
+}
+
+var listSanctionsCommand = &cli.Command{
+	Name:  "list",
+	Usage: "list sanctions, including expired ones",
+	Flags: []cli.Flag{&cli.StringFlag{
+		Name:  "user",
+		Usage: "only list this user's sanctions",
+	}},
+	Action: func(ctx *cli.Context) error {
+		store, err := pgcommentsstore.OpenEnv()
+		if err != nil {
+			return err
+		}
+		var sanctions []*types.Sanction
+		if user := ctx.String("user"); user != "" {
+			sanctions, err = store.UserSanctions(types.UserID(user))
+		} else {
+			sanctions, err = store.Sanctions()
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, s := range sanctions {
+			expires := "never"
+			if s.Expires != nil {
+				expires = s.Expires.Format(time.RFC3339)
+			}
+			status := "active"
+			if !s.Active(now) {
+				status = "expired"
+			}
+			if _, err := fmt.Printf(
+				"%s\t%s\t%s\texpires=%s\tissuer=%s\t%s\n",
+				s.User,
+				s.Kind,
+				status,
+				expires,
+				s.Issuer,
+				s.Reason,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var addSanctionCommand = &cli.Command{
+	Name:  "add",
+	Usage: "impose a sanction on a user, replacing one of the same kind",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "user", Required: true},
+		&cli.StringFlag{
+			Name:     "kind",
+			Usage:    "one of `ban`, `mute` or `shadow-ban`",
+			Required: true,
+		},
+		&cli.StringFlag{Name: "reason"},
+		&cli.DurationFlag{
+			Name:  "duration",
+			Usage: "lift the sanction after this long (required for mutes)",
+		},
+		&cli.StringFlag{
+			Name:  "issuer",
+			Usage: "the user recorded as imposing the sanction",
+			Value: "pgcomments",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		kind, err := types.ParseSanctionKind(ctx.String("kind"))
+		if err != nil {
+			return fmt.Errorf("parsing `--kind`: %w", err)
+		}
+		now := time.Now()
+		sanction := types.Sanction{
+			User:    types.UserID(ctx.String("user")),
+			Kind:    kind,
+			Reason:  ctx.String("reason"),
+			Issuer:  types.UserID(ctx.String("issuer")),
+			Created: now,
+		}
+		if duration := ctx.Duration("duration"); duration > 0 {
+			expires := now.Add(duration)
+			sanction.Expires = &expires
+		} else if kind == types.SanctionMute {
+			return fmt.Errorf("mutes require a positive `--duration`")
+		}
+
+		store, err := pgcommentsstore.OpenEnv()
+		if err != nil {
+			return err
+		}
+		if err := store.PutSanction(&sanction); err != nil {
+			return err
+		}
+		_, err = fmt.Printf("imposed %s on %s\n", sanction.Kind, sanction.User)
+		return err
+	},
+}
+
+var removeSanctionCommand = &cli.Command{
+	Name:  "remove",
+	Usage: "lift a user's sanction",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "user", Required: true},
+		&cli.StringFlag{Name: "kind", Required: true},
+	},
+	Action: func(ctx *cli.Context) error {
+		kind, err := types.ParseSanctionKind(ctx.String("kind"))
+		if err != nil {
+			return fmt.Errorf("parsing `--kind`: %w", err)
+		}
+		store, err := pgcommentsstore.OpenEnv()
+		if err != nil {
+			return err
+		}
+		user := types.UserID(ctx.String("user"))
+		if err := store.DeleteSanction(user, kind); err != nil {
+			return err
+		}
+		_, err = fmt.Printf("lifted %s on %s\n", kind, user)
+		return err
+	},
+}
@@ -15,12 +15,16 @@ func (aws *AuthWebServer) RepliesRoute() pz.Route {
 	return aws.optional(aws.WebServer.RepliesRoute())
 }
 
+func (aws *AuthWebServer) PermalinkRoute() pz.Route {
+	return aws.optional(aws.WebServer.PermalinkRoute())
+}
+
 func (aws *AuthWebServer) DeleteConfirmRoute() pz.Route {
 	return aws.auth(aws.WebServer.DeleteConfirmRoute())
 }
 
 func (aws *AuthWebServer) DeleteRoute() pz.Route {
-	return aws.auth(aws.WebServer.DeleteRoute())
+	return aws.auth(aws.csrf(aws.WebServer.DeleteRoute()))
 }
 
 func (aws *AuthWebServer) ReplyFormRoute() pz.Route {
@@ -28,7 +32,11 @@ func (aws *AuthWebServer) ReplyFormRoute() pz.Route {
 }
 
 func (aws *AuthWebServer) ReplyRoute() pz.Route {
-	return aws.auth(aws.WebServer.ReplyRoute())
+	return aws.auth(aws.csrf(aws.WebServer.ReplyRoute()))
+}
+
+func (aws *AuthWebServer) VoteRoute() pz.Route {
+	return aws.auth(aws.csrf(aws.WebServer.VoteRoute()))
 }
 
 func (aws *AuthWebServer) EditFormRoute() pz.Route {
@@ -36,19 +44,66 @@ func (aws *AuthWebServer) EditFormRoute() pz.Route {
 }
 
 func (aws *AuthWebServer) EditRoute() pz.Route {
-	return aws.auth(aws.WebServer.EditRoute())
+	return aws.auth(aws.csrf(aws.WebServer.EditRoute()))
+}
+
+func (aws *AuthWebServer) RevisionsRoute() pz.Route {
+	return aws.optional(aws.WebServer.RevisionsRoute())
+}
+
+func (aws *AuthWebServer) ReportFormRoute() pz.Route {
+	return aws.auth(aws.WebServer.ReportFormRoute())
+}
+
+func (aws *AuthWebServer) ReportRoute() pz.Route {
+	return aws.auth(aws.csrf(aws.WebServer.ReportRoute()))
+}
+
+func (aws *AuthWebServer) ThreadSubscriptionRoute() pz.Route {
+	return aws.auth(aws.csrf(aws.WebServer.ThreadSubscriptionRoute()))
+}
+
+func (aws *AuthWebServer) InboxRoute() pz.Route {
+	return aws.auth(aws.WebServer.InboxRoute())
+}
+
+func (aws *AuthWebServer) MarkReadRoute() pz.Route {
+	return aws.auth(aws.csrf(aws.WebServer.MarkReadRoute()))
+}
+
+func (aws *AuthWebServer) EventsRoute() pz.Route {
+	return aws.optional(aws.WebServer.EventsRoute())
 }
 
 func (aws *AuthWebServer) Routes() []pz.Route {
-	return []pz.Route{
+	return frameAncestorsRoutes([]pz.Route{
 		aws.RepliesRoute(),
+		aws.PermalinkRoute(),
 		aws.DeleteConfirmRoute(),
 		aws.DeleteRoute(),
 		aws.ReplyFormRoute(),
 		aws.ReplyRoute(),
+		aws.VoteRoute(),
 		aws.EditFormRoute(),
 		aws.EditRoute(),
-	}
+		aws.RevisionsRoute(),
+		aws.ReportFormRoute(),
+		aws.ReportRoute(),
+		aws.ThreadSubscriptionRoute(),
+		aws.InboxRoute(),
+		aws.MarkReadRoute(),
+		aws.EventsRoute(),
+
+		// unsubscribe links authenticate users by their tokens
+		aws.WebServer.UnsubscribeFormRoute(),
+		aws.WebServer.UnsubscribeRoute(),
+
+		// feed readers don't log in
+		aws.WebServer.PostFeedRoute(),
+		aws.WebServer.UserFeedRoute(),
+
+		aws.WebServer.EmbedLoaderRoute(),
+	})
 }
 
 func (aws *AuthWebServer) auth(r pz.Route) pz.Route {
@@ -59,6 +114,16 @@ func (aws *AuthWebServer) auth(r pz.Route) pz.Route {
 	}
 }
 
+// csrf rejects form submissions without a valid CSRF token. If the
+// `WebServer` has no `CSRF`, every submission is rejected.
+func (aws *AuthWebServer) csrf(r pz.Route) pz.Route {
+	return pz.Route{
+		Method:  r.Method,
+		Path:    r.Path,
+		Handler: aws.WebServer.CSRF.Protect(r.Handler),
+	}
+}
+
 func (aws *AuthWebServer) optional(r pz.Route) pz.Route {
 	return pz.Route{
 		Method:  r.Method,
@@ -21,6 +21,11 @@ func TestAuthWebServer(t *testing.T) {
 			method:   (*AuthWebServer).RepliesRoute,
 			optional: true,
 		},
+		{
+			name:     "permalink",
+			method:   (*AuthWebServer).PermalinkRoute,
+			optional: true,
+		},
 		{
 			name:     "delete-confirm",
 			method:   (*AuthWebServer).DeleteConfirmRoute,
@@ -41,6 +46,11 @@ func TestAuthWebServer(t *testing.T) {
 			method:   (*AuthWebServer).ReplyRoute,
 			optional: false,
 		},
+		{
+			name:     "vote",
+			method:   (*AuthWebServer).VoteRoute,
+			optional: false,
+		},
 		{
 			name:     "edit-form",
 			method:   (*AuthWebServer).EditFormRoute,
@@ -51,6 +61,11 @@ func TestAuthWebServer(t *testing.T) {
 			method:   (*AuthWebServer).EditRoute,
 			optional: false,
 		},
+		{
+			name:     "revisions",
+			method:   (*AuthWebServer).RevisionsRoute,
+			optional: true,
+		},
 	} {
 		rsp := testCase.method(&AuthWebServer{
 			WebServer: WebServer{
@@ -1,11 +1,12 @@
 package comments
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
-	"html"
-
+	"github.com/weberc2/comments/pkg/comments/markdown"
 	"github.com/weberc2/comments/pkg/comments/types"
 	pz "github.com/weberc2/httpeasy"
 )
@@ -13,16 +14,79 @@ import (
 const (
 	bodySizeMin = 8
 	bodySizeMax = 2056
+
+	repliesLimitDefault = 50
+	repliesLimitMax     = 200
 )
 
 var (
-	ErrInvalidPost  = &pz.HTTPError{Status: 400, Message: "invalid post"}
-	ErrBodyTooShort = &pz.HTTPError{Status: 400, Message: "body too short"}
-	ErrBodyTooLong  = &pz.HTTPError{Status: 400, Message: "body too long"}
+	ErrInvalidPost   = &pz.HTTPError{Status: 400, Message: "invalid post"}
+	ErrBodyTooShort  = &pz.HTTPError{Status: 400, Message: "body too short"}
+	ErrBodyTooLong   = &pz.HTTPError{Status: 400, Message: "body too long"}
+	ErrInvalidLimit  = &pz.HTTPError{Status: 400, Message: "invalid limit"}
+	ErrInvalidDepth  = &pz.HTTPError{Status: 400, Message: "invalid depth"}
+	ErrInvalidUser   = &pz.HTTPError{Status: 400, Message: "invalid user"}
+	ErrInvalidFormat = &pz.HTTPError{Status: 400, Message: "invalid format"}
 )
 
 type CommentsModel struct {
 	types.CommentsStore
+	VotesStore types.VotesStore
+
+	// PostSettingsStore holds per-post settings. It's optional: if it's nil,
+	// every post uses the default settings.
+	PostSettingsStore types.PostSettingsStore
+
+	// Premoderate puts new comments on every post into the moderation queue.
+	// Posts can also opt into premoderation individually via their settings.
+	Premoderate bool
+
+	// RevisionsStore records the prior bodies of edited comments. It's
+	// optional: if it's nil, revisions aren't recorded.
+	RevisionsStore types.RevisionsStore
+
+	// Roles holds users' roles. It's optional: if it's nil, every user is a
+	// `types.RoleUser`.
+	Roles types.RolesStore
+
+	// ReportsStore holds users' reports of abusive comments. It's optional:
+	// if it's nil, comments can't be reported.
+	ReportsStore types.ReportsStore
+
+	// ReportThreshold is the number of open reports at which a comment is
+	// hidden and put back into the moderation queue. Zero disables hiding.
+	ReportThreshold int
+
+	// SanctionsStore holds the bans, mutes and shadow-bans imposed on abusive
+	// users. It's optional: if it's nil, no one is sanctioned.
+	SanctionsStore types.SanctionsStore
+
+	// ReplyNotifier is told about new replies (once they're approved) by
+	// `NotifyReply()`. It's optional: if it's nil, no one is notified.
+	ReplyNotifier ReplyNotifier
+
+	// NotificationSettingsStore holds users' notification settings. It's
+	// optional: if it's nil, every user has the default settings.
+	NotificationSettingsStore types.NotificationSettingsStore
+
+	// SubscriptionsStore holds the posts and subthreads which users follow
+	// and InboxStore holds the notifications about new comments in them.
+	// They're optional: if either is nil, no one is notified.
+	SubscriptionsStore types.SubscriptionsStore
+	InboxStore         types.InboxStore
+
+	// WebhooksStore holds the webhooks which events are delivered to and the
+	// deliveries which failed. WebhookReplayer redelivers failed deliveries.
+	// They're optional: if either is nil, webhooks can't be managed or
+	// replayed (respectively).
+	WebhooksStore   types.WebhooksStore
+	WebhookReplayer WebhookReplayer
+
+	// SpamCheckers check new comments before they're stored. Every checker
+	// runs (unless one rejects the comment) and the most severe verdict wins.
+	// Moderators' comments aren't checked.
+	SpamCheckers []types.SpamChecker
+
 	IDFunc   func() types.CommentID
 	TimeFunc func() time.Time
 }
@@ -38,18 +102,33 @@ func validateCommentBody(body string) error {
 }
 
 func (cm *CommentsModel) Put(c *types.Comment) (*types.Comment, error) {
+	return cm.PutSubmission(&types.Submission{Comment: c})
+}
+
+// PutSubmission creates a comment like `Put()`, passing the rest of the
+// submission (the client's IP address, etc) along to the spam checkers.
+// The checkers' verdict and its reason are recorded on the comment for the
+// moderators. Comments which are held by a checker are put into the
+// moderation queue and comments which are rejected aren't stored at all (the
+// returned error wraps `types.ErrSpam`).
+func (cm *CommentsModel) PutSubmission(
+	s *types.Submission,
+) (*types.Comment, error) {
+	c := s.Comment
 	if c.Post == "" {
 		return nil, ErrInvalidPost
 	}
 	if err := validateCommentBody(c.Body); err != nil {
 		return nil, err
 	}
+	if err := cm.checkCanPost(c.Author); err != nil {
+		return nil, err
+	}
 
+	var parent *types.Comment
 	if c.Parent != "" {
-		parent, err := cm.CommentsStore.Comment(
-			c.Post,
-			c.Parent,
-		)
+		var err error
+		parent, _, err = cm.visibleComment(c.Author, c.Post, c.Parent)
 		if err != nil {
 			return nil, fmt.Errorf("fetching parent comment: %w", err)
 		}
@@ -60,38 +139,384 @@ func (cm *CommentsModel) Put(c *types.Comment) (*types.Comment, error) {
 			)
 		}
 	}
+	premoderate, err := cm.premoderate(c.Post)
+	if err != nil {
+		return nil, err
+	}
+	moderator, err := cm.IsModerator(c.Author)
+	if err != nil {
+		return nil, err
+	}
+
 	now := cm.TimeFunc()
 	cp := *c
 	cp.ID = cm.IDFunc()
 	cp.Created = now
 	cp.Modified = now
 	cp.Deleted = false
-	cp.Body = html.EscapeString(c.Body)
+	cp.Status = types.StatusApproved
+	cp.SpamVerdict = ""
+	cp.SpamReason = ""
+	if premoderate && !moderator {
+		cp.Status = types.StatusPending
+	}
+
+	if !moderator && len(cm.SpamCheckers) > 0 {
+		check := cm.checkSpam(&types.Submission{
+			Comment:   &cp,
+			IP:        s.IP,
+			UserAgent: s.UserAgent,
+			Referrer:  s.Referrer,
+		})
+		cp.SpamVerdict = check.Verdict
+		cp.SpamReason = check.Reason
+		switch check.Verdict {
+		case types.VerdictReject:
+			log.Printf(
+				"rejected comment by `%s` on post `%s` as spam: %s",
+				cp.Author,
+				cp.Post,
+				check.Reason,
+			)
+			return nil, fmt.Errorf("%w: %s", types.ErrSpam, check.Reason)
+		case types.VerdictHold:
+			cp.Status = types.StatusPending
+		}
+	}
 	if err := cm.CommentsStore.Put(&cp); err != nil {
 		return nil, err
 	}
+	if cp.Status.Visible() {
+		cm.published(&cp)
+	}
+	render(&cp)
 	return &cp, nil
 }
 
-func (cm *CommentsModel) Delete(p types.PostID, c types.CommentID) error {
+// spamCheckFailed is the reason recorded on comments which are held because a
+// spam checker failed.
+const spamCheckFailed = "spam check failed"
+
+// checkSpam runs the spam checkers against a submission and returns the most
+// severe verdict (the first, among equally severe verdicts). A checker which
+// fails holds the comment rather than letting it through unchecked.
+func (cm *CommentsModel) checkSpam(s *types.Submission) types.SpamCheck {
+	result := types.Allow
+	for _, checker := range cm.SpamCheckers {
+		check, err := checker.CheckSpam(s)
+		if err != nil {
+			log.Printf("checking comment for spam: %v", err)
+			check = types.SpamCheck{
+				Verdict: types.VerdictHold,
+				Reason:  spamCheckFailed,
+			}
+		}
+		if check.Verdict.Severity() > result.Verdict.Severity() {
+			result = check
+		}
+		if result.Verdict == types.VerdictReject {
+			break
+		}
+	}
+	return result
+}
+
+// Delete soft-deletes a comment on behalf of a user. Users may delete their
+// own comments and moderators may delete anyone's; when a moderator deletes
+// someone else's comment, the comment is marked as `Removed`. Either way, the
+// comment's open reports are resolved.
+func (cm *CommentsModel) Delete(
+	user types.UserID,
+	p types.PostID,
+	c types.CommentID,
+) error {
+	comment, err := cm.CommentsStore.Comment(p, c)
+	if err != nil {
+		return fmt.Errorf("soft-deleting comment: %w", err)
+	}
+	removed, err := cm.authorize(user, comment)
+	if err != nil {
+		return fmt.Errorf("soft-deleting comment: %w", err)
+	}
 	if err := cm.CommentsStore.Update(
-		types.NewCommentPatch(c, p).SetDeleted(true).
+		types.NewCommentPatch(c, p).SetDeleted(true).SetRemoved(removed).
 			SetModified(cm.TimeFunc()),
 	); err != nil {
 		return fmt.Errorf("soft-deleting comment: %w", err)
 	}
-	return nil
+	return cm.resolveReports(p, c)
 }
 
+// Replies fetches every reply to a comment. Comments which aren't approved
+// (and their descendants) are left out unless the viewer is their author or a
+// moderator.
 func (cm *CommentsModel) Replies(
 	post types.PostID,
 	parent types.CommentID,
+	viewer types.UserID,
 ) ([]*types.Comment, error) {
 	comments, err := cm.CommentsStore.Replies(post, parent)
 	if err != nil {
 		return nil, fmt.Errorf("fetching comment replies: %w", err)
 	}
+	q, err := cm.visibility(viewer)
+	if err != nil {
+		return nil, err
+	}
+	comments = visible(comments, parent, q)
+	redact(comments)
+	if !q.AllStatuses {
+		hideSpamChecks(comments...)
+	}
+	render(comments...)
+	return comments, nil
+}
+
+// visibility returns a query which determines which comments are visible to
+// the viewer.
+func (cm *CommentsModel) visibility(
+	viewer types.UserID,
+) (*types.RepliesQuery, error) {
+	moderator, err := cm.IsModerator(viewer)
+	if err != nil {
+		return nil, err
+	}
+	q := types.RepliesQuery{Viewer: viewer, AllStatuses: moderator}
+	if !moderator {
+		if q.ShadowBanned, err = cm.shadowBanned(); err != nil {
+			return nil, err
+		}
+	}
+	return &q, nil
+}
+
+// visible filters out the comments which aren't visible to the query, along
+// with their descendants. `root` is the parent of the toplevel comments.
+func visible(
+	comments []*types.Comment,
+	root types.CommentID,
+	q *types.RepliesQuery,
+) []*types.Comment {
+	byID := make(map[types.CommentID]*types.Comment, len(comments))
+	for _, c := range comments {
+		byID[c.ID] = c
+	}
+
+	memo := map[types.CommentID]bool{root: true}
+	var isVisible func(c *types.Comment) bool
+	isVisible = func(c *types.Comment) bool {
+		if v, found := memo[c.ID]; found {
+			return v
+		}
+		v := q.Visible(c)
+		if v && c.Parent != root {
+			parent, found := byID[c.Parent]
+			v = found && isVisible(parent)
+		}
+		memo[c.ID] = v
+		return v
+	}
+
+	out := comments[:0]
+	for _, c := range comments {
+		if isVisible(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
 
+// RepliesPage fetches a page of replies. If the query's `Limit` is zero, a
+// default limit is used; limits larger than the maximum are clamped. A
+// `MaxDepth` of zero means the depth is unlimited and an empty `Sort` means
+// `types.SortOldest`. A cursor is only valid for the sort order that produced
+// it. Comments which aren't approved are only included for their authors
+// (`Viewer`) and for moderators.
+func (cm *CommentsModel) RepliesPage(
+	q *types.RepliesQuery,
+) (*types.RepliesPage, error) {
+	if q.Limit < 0 {
+		return nil, ErrInvalidLimit
+	}
+	if q.MaxDepth < 0 {
+		return nil, ErrInvalidDepth
+	}
+	query := *q
+	if query.Limit == 0 {
+		query.Limit = repliesLimitDefault
+	}
+	if query.Limit > repliesLimitMax {
+		query.Limit = repliesLimitMax
+	}
+	sort, err := types.ParseSort(string(query.Sort))
+	if err != nil {
+		return nil, err
+	}
+	query.Sort = sort
+	if query.AllStatuses, err = cm.IsModerator(query.Viewer); err != nil {
+		return nil, err
+	}
+	if query.Cursor != nil && query.Cursor.Sort != query.Sort {
+		return nil, types.ErrInvalidCursor
+	}
+	query.ShadowBanned = nil
+	if !query.AllStatuses {
+		if query.ShadowBanned, err = cm.shadowBanned(); err != nil {
+			return nil, err
+		}
+	}
+
+	page, err := cm.CommentsStore.RepliesPage(&query)
+	if err != nil {
+		return nil, fmt.Errorf("fetching comment replies page: %w", err)
+	}
+	redact(page.Comments)
+	if !query.AllStatuses {
+		hideSpamChecks(page.Comments...)
+	}
+	render(page.Comments...)
+	return page, nil
+}
+
+// Comment fetches a comment, rendering its body. The comment is only found if
+// it and its ancestors are visible to the viewer.
+func (cm *CommentsModel) Comment(
+	viewer types.UserID,
+	post types.PostID,
+	comment types.CommentID,
+) (*types.Comment, error) {
+	c, q, err := cm.visibleComment(viewer, post, comment)
+	if err != nil {
+		return nil, err
+	}
+	redact([]*types.Comment{c})
+	if !q.AllStatuses {
+		hideSpamChecks(c)
+	}
+	render(c)
+	return c, nil
+}
+
+// visibleComment fetches a comment along with the viewer's visibility query.
+// It returns `types.ErrCommentNotFound` unless the comment and its ancestors
+// are visible to the viewer.
+func (cm *CommentsModel) visibleComment(
+	viewer types.UserID,
+	post types.PostID,
+	comment types.CommentID,
+) (*types.Comment, *types.RepliesQuery, error) {
+	q, err := cm.visibility(viewer)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := cm.CommentsStore.Comment(post, comment)
+	if err != nil {
+		return nil, nil, err
+	}
+	visible, err := cm.threadVisible(q, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !visible {
+		return nil, nil, types.ErrCommentNotFound
+	}
+	return c, q, nil
+}
+
+// threadVisible reports whether the comment and its ancestors are visible to
+// the query.
+func (cm *CommentsModel) threadVisible(
+	q *types.RepliesQuery,
+	c *types.Comment,
+) (bool, error) {
+	for {
+		if !q.Visible(c) {
+			return false, nil
+		}
+		if c.Parent == "" {
+			return true, nil
+		}
+		parent, err := cm.CommentsStore.Comment(c.Post, c.Parent)
+		if err != nil {
+			if errors.Is(err, types.ErrCommentNotFound) {
+				return false, nil
+			}
+			return false, fmt.Errorf(
+				"fetching ancestor `%s`: %w",
+				c.Parent,
+				err,
+			)
+		}
+		c = parent
+	}
+}
+
+// Thread is a comment along with the context for displaying it on its own.
+type Thread struct {
+	// Ancestors are the comment's ancestors, toplevel comment first.
+	Ancestors []*types.Comment
+	Comment   *types.Comment
+	Replies   *types.RepliesPage
+}
+
+// Thread fetches the comment identified by the query's `Post` and `Parent`
+// fields along with its ancestors and a page of its replies. The comment is
+// only found if it and its ancestors are visible to the query's `Viewer`.
+func (cm *CommentsModel) Thread(q *types.RepliesQuery) (*Thread, error) {
+	visibility, err := cm.visibility(q.Viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := cm.CommentsStore.Comment(q.Post, q.Parent)
+	if err != nil {
+		return nil, err
+	}
+	if !visibility.Visible(c) {
+		return nil, types.ErrCommentNotFound
+	}
+
+	var ancestors []*types.Comment
+	for parent := c.Parent; parent != ""; {
+		a, err := cm.CommentsStore.Comment(q.Post, parent)
+		if err != nil {
+			return nil, fmt.Errorf("fetching ancestor `%s`: %w", parent, err)
+		}
+		if !visibility.Visible(a) {
+			return nil, types.ErrCommentNotFound
+		}
+		ancestors = append([]*types.Comment{a}, ancestors...)
+		parent = a.Parent
+	}
+
+	replies, err := cm.RepliesPage(q)
+	if err != nil {
+		return nil, err
+	}
+
+	redact(ancestors)
+	redact([]*types.Comment{c})
+	if !visibility.AllStatuses {
+		hideSpamChecks(ancestors...)
+		hideSpamChecks(c)
+	}
+	render(ancestors...)
+	render(c)
+	return &Thread{Ancestors: ancestors, Comment: c, Replies: replies}, nil
+}
+
+// render renders comments' Markdown bodies into their `HTML` fields.
+func render(comments ...*types.Comment) {
+	for _, c := range comments {
+		if c.Deleted {
+			c.HTML = ""
+			continue
+		}
+		c.HTML = markdown.Render(c.Body)
+	}
+}
+
+func redact(comments []*types.Comment) {
 	for _, comment := range comments {
 		if comment.Deleted {
 			// Redact author and body fields from deleted comments
@@ -99,8 +524,48 @@ func (cm *CommentsModel) Replies(
 			comment.Body = ""
 		}
 	}
+}
 
-	return comments, nil
+// hideSpamChecks clears comments' spam verdicts, which are only for
+// moderators.
+func hideSpamChecks(comments ...*types.Comment) {
+	for _, c := range comments {
+		c.SpamVerdict = ""
+		c.SpamReason = ""
+	}
+}
+
+// Vote records a user's vote on a comment, replacing the user's previous vote
+// on the comment (if any). The vote's `Value` must be `types.Upvote` or
+// `types.Downvote`. Deleted comments and comments which aren't visible to the
+// user can't be voted on.
+func (cm *CommentsModel) Vote(v *types.Vote) error {
+	if v.Value != types.Upvote && v.Value != types.Downvote {
+		return types.ErrInvalidVote
+	}
+	c, _, err := cm.visibleComment(v.User, v.Post, v.Comment)
+	if err != nil {
+		return fmt.Errorf("voting on comment: %w", err)
+	}
+	if c.Deleted {
+		return fmt.Errorf("voting on comment: %w", types.ErrCommentNotFound)
+	}
+	if err := cm.VotesStore.PutVote(v); err != nil {
+		return fmt.Errorf("voting on comment: %w", err)
+	}
+	return nil
+}
+
+// Unvote removes a user's vote on a comment.
+func (cm *CommentsModel) Unvote(
+	post types.PostID,
+	comment types.CommentID,
+	user types.UserID,
+) error {
+	if err := cm.VotesStore.DeleteVote(post, comment, user); err != nil {
+		return fmt.Errorf("removing vote on comment: %w", err)
+	}
+	return nil
 }
 
 type CommentUpdate struct {
@@ -109,7 +574,29 @@ type CommentUpdate struct {
 	Body string          `json:"body"`
 }
 
-func (cm *CommentsModel) Update(update *CommentUpdate) error {
+// Update edits a comment's body on behalf of a user. Users may edit their own
+// comments and moderators may edit anyone's. Once the edit is stored, the
+// comment's prior body is recorded as a revision (so a failed edit leaves no
+// revision behind).
+func (cm *CommentsModel) Update(
+	user types.UserID,
+	update *CommentUpdate,
+) error {
+	return cm.UpdateSubmission(user, update, &types.Submission{})
+}
+
+// UpdateSubmission edits a comment like `Update()`, passing the rest of the
+// submission (the client's IP address, etc) along to the spam checkers. The
+// checkers see the edited comment (the submission's `Comment` is ignored) and
+// judge it like a new comment, since approved comments could otherwise be
+// edited into spam: edits which are held put the comment back into the
+// moderation queue and edits which are rejected aren't stored (the returned
+// error wraps `types.ErrSpam`). Moderators' edits aren't checked.
+func (cm *CommentsModel) UpdateSubmission(
+	user types.UserID,
+	update *CommentUpdate,
+	s *types.Submission,
+) error {
 	if err := validateCommentBody(update.Body); err != nil {
 		return fmt.Errorf("updating comment: %w", err)
 	}
@@ -121,9 +608,91 @@ func (cm *CommentsModel) Update(update *CommentUpdate) error {
 	if c.Deleted {
 		return fmt.Errorf("updating comment: %w", types.ErrCommentNotFound)
 	}
-	return cm.CommentsStore.Update(
-		types.NewCommentPatch(update.ID, update.Post).
-			SetBody(update.Body).
-			SetModified(cm.TimeFunc()),
-	)
+	if _, err := cm.authorize(user, c); err != nil {
+		return fmt.Errorf("updating comment: %w", err)
+	}
+	if err := cm.checkCanPost(user); err != nil {
+		return fmt.Errorf("updating comment: %w", err)
+	}
+
+	now := cm.TimeFunc()
+	revision := types.Revision{
+		Post:    c.Post,
+		Comment: c.ID,
+		Body:    c.Body,
+		Created: c.Modified,
+		Edited:  now,
+		Editor:  user,
+	}
+	patch := types.NewCommentPatch(update.ID, update.Post).
+		SetBody(update.Body).
+		SetModified(now)
+	if len(cm.SpamCheckers) > 0 {
+		moderator, err := cm.IsModerator(user)
+		if err != nil {
+			return fmt.Errorf("updating comment: %w", err)
+		}
+		if !moderator {
+			edited := *c
+			edited.Body = update.Body
+			checked := *s
+			checked.Comment = &edited
+			check := cm.checkSpam(&checked)
+			switch check.Verdict {
+			case types.VerdictReject:
+				log.Printf(
+					"rejected edit of comment `%s` on post `%s` as spam: %s",
+					c.ID,
+					c.Post,
+					check.Reason,
+				)
+				return fmt.Errorf(
+					"updating comment: %w: %s",
+					types.ErrSpam,
+					check.Reason,
+				)
+			case types.VerdictHold:
+				patch.SetStatus(types.StatusPending).
+					SetSpamVerdict(check.Verdict).
+					SetSpamReason(check.Reason)
+			}
+		}
+	}
+	if err := cm.CommentsStore.Update(patch); err != nil {
+		return fmt.Errorf("updating comment: %w", err)
+	}
+	if cm.RevisionsStore != nil {
+		if err := cm.RevisionsStore.PutRevision(&revision); err != nil {
+			return fmt.Errorf("updating comment: recording revision: %w", err)
+		}
+	}
+	return nil
+}
+
+// Revisions returns a comment's revisions, oldest first. The revisions of
+// deleted comments are only visible to moderators, and the revisions of
+// comments which aren't visible to the viewer (see `Comment()`) aren't found.
+func (cm *CommentsModel) Revisions(
+	viewer types.UserID,
+	post types.PostID,
+	comment types.CommentID,
+) ([]*types.Revision, error) {
+	c, visibility, err := cm.visibleComment(viewer, post, comment)
+	if err != nil {
+		return nil, fmt.Errorf("fetching revisions: %w", err)
+	}
+	if c.Deleted && !visibility.AllStatuses {
+		return nil, fmt.Errorf(
+			"fetching revisions: %w",
+			types.ErrCommentNotFound,
+		)
+	}
+	if cm.RevisionsStore == nil {
+		return []*types.Revision{}, nil
+	}
+	revisions, err := cm.RevisionsStore.Revisions(post, comment)
+	if err != nil {
+		return nil, fmt.Errorf("fetching revisions: %w", err)
+	}
+	return revisions, nil
 }
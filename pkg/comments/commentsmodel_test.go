@@ -1,6 +1,8 @@
 package comments
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -14,9 +16,33 @@ const (
 )
 
 func TestCommentsModel_Update(t *testing.T) {
+	state := func() testsupport.CommentsStoreFake {
+		return testsupport.CommentsStoreFake{
+			"post": {
+				"id": {
+					ID:       "id",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime,
+					Modified: someTime,
+					Body:     "hello, world",
+				},
+			},
+		}
+	}
+	unchanged := []*types.Comment{{
+		ID:       "id",
+		Post:     "post",
+		Author:   "author",
+		Created:  someTime,
+		Modified: someTime,
+		Body:     "hello, world",
+	}}
+
 	for _, testCase := range []struct {
 		name        string
 		state       testsupport.CommentsStoreFake
+		user        types.UserID
 		input       *CommentUpdate
 		wantedState []*types.Comment
 		wantedErr   types.WantedError
@@ -37,6 +63,7 @@ func TestCommentsModel_Update(t *testing.T) {
 					},
 				},
 			},
+			user:  "author",
 			input: &CommentUpdate{ID: "id", Post: "post", Body: "greetings"},
 			wantedState: []*types.Comment{{
 				ID:       "id",
@@ -65,6 +92,7 @@ func TestCommentsModel_Update(t *testing.T) {
 					},
 				},
 			},
+			user:  "author",
 			input: &CommentUpdate{ID: "id", Post: "post", Body: "greetings"},
 			wantedState: []*types.Comment{{
 				ID:       "id",
@@ -94,6 +122,7 @@ func TestCommentsModel_Update(t *testing.T) {
 					},
 				},
 			},
+			user:  "author",
 			input: &CommentUpdate{ID: "id", Post: "post", Body: ""},
 			wantedState: []*types.Comment{{
 				ID:       "id",
@@ -109,6 +138,7 @@ func TestCommentsModel_Update(t *testing.T) {
 		},
 		{
 			name: "errors propagate",
+			user: "author",
 			input: &CommentUpdate{
 				ID:   "not-found",
 				Post: "not-found",
@@ -116,11 +146,44 @@ func TestCommentsModel_Update(t *testing.T) {
 			},
 			wantedErr: types.ErrCommentNotFound,
 		},
+		{
+			name:        "other users can't edit",
+			state:       state(),
+			user:        "other",
+			input:       &CommentUpdate{ID: "id", Post: "post", Body: "greetings"},
+			wantedState: unchanged,
+			wantedErr:   ErrNotAuthor,
+		},
+		{
+			name:        "anonymous users can't edit",
+			state:       state(),
+			user:        "",
+			input:       &CommentUpdate{ID: "id", Post: "post", Body: "greetings"},
+			wantedState: unchanged,
+			wantedErr:   ErrUnauthorized,
+		},
+		{
+			name:  "moderators can edit",
+			state: state(),
+			user:  "moderator",
+			input: &CommentUpdate{ID: "id", Post: "post", Body: "greetings"},
+			wantedState: []*types.Comment{{
+				ID:       "id",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime,
+				Modified: now,
+				Body:     "greetings",
+			}},
+		},
 	} {
 		t.Run(testCase.name, func(t *testing.T) {
 			model := CommentsModel{
 				CommentsStore: testCase.state,
-				TimeFunc:      func() time.Time { return now },
+				Roles: testsupport.RolesStoreFake{
+					"moderator": types.RoleModerator,
+				},
+				TimeFunc: func() time.Time { return now },
 			}
 
 			if testCase.wantedErr == nil {
@@ -128,7 +191,7 @@ func TestCommentsModel_Update(t *testing.T) {
 			}
 
 			if err := testCase.wantedErr.CompareErr(
-				model.Update(testCase.input),
+				model.Update(testCase.user, testCase.input),
 			); err != nil {
 				t.Fatal(err)
 			}
@@ -149,6 +212,7 @@ func TestCommentsModel_Replies(t *testing.T) {
 		state          testsupport.CommentsStoreFake
 		post           types.PostID
 		parent         types.CommentID
+		viewer         types.UserID
 		wantedComments []*types.Comment
 		wantedErr      types.WantedError
 	}{
@@ -210,6 +274,133 @@ func TestCommentsModel_Replies(t *testing.T) {
 				Body:     "",
 			}},
 		},
+		{
+			name: "pending comments are hidden with their replies",
+			state: testsupport.CommentsStoreFake{
+				"post": {
+					"pending": {
+						ID:       "pending",
+						Post:     "post",
+						Author:   "author",
+						Created:  someTime,
+						Modified: someTime,
+						Body:     "body",
+						Status:   types.StatusPending,
+					},
+					"child": {
+						ID:       "child",
+						Post:     "post",
+						Parent:   "pending",
+						Author:   "other",
+						Created:  someTime,
+						Modified: someTime,
+						Body:     "body",
+						Status:   types.StatusApproved,
+					},
+				},
+			},
+			post:           "post",
+			viewer:         "other",
+			wantedComments: []*types.Comment{},
+		},
+		{
+			name: "pending comments are visible to their authors",
+			state: testsupport.CommentsStoreFake{
+				"post": {
+					"pending": {
+						ID:       "pending",
+						Post:     "post",
+						Author:   "author",
+						Created:  someTime,
+						Modified: someTime,
+						Body:     "body",
+						Status:   types.StatusPending,
+					},
+					"child": {
+						ID:       "child",
+						Post:     "post",
+						Parent:   "pending",
+						Author:   "other",
+						Created:  someTime,
+						Modified: someTime,
+						Body:     "body",
+						Status:   types.StatusApproved,
+					},
+				},
+			},
+			post:   "post",
+			viewer: "author",
+			wantedComments: []*types.Comment{
+				{
+					ID:       "pending",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime,
+					Modified: someTime,
+					Body:     "body",
+					Status:   types.StatusPending,
+				},
+				{
+					ID:       "child",
+					Post:     "post",
+					Parent:   "pending",
+					Author:   "other",
+					Created:  someTime,
+					Modified: someTime,
+					Body:     "body",
+					Status:   types.StatusApproved,
+				},
+			},
+		},
+		{
+			name: "pending comments are visible to moderators",
+			state: testsupport.CommentsStoreFake{
+				"post": {
+					"pending": {
+						ID:       "pending",
+						Post:     "post",
+						Author:   "author",
+						Created:  someTime,
+						Modified: someTime,
+						Body:     "body",
+						Status:   types.StatusPending,
+					},
+					"child": {
+						ID:       "child",
+						Post:     "post",
+						Parent:   "pending",
+						Author:   "other",
+						Created:  someTime,
+						Modified: someTime,
+						Body:     "body",
+						Status:   types.StatusApproved,
+					},
+				},
+			},
+			post:   "post",
+			viewer: "moderator",
+			wantedComments: []*types.Comment{
+				{
+					ID:       "pending",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime,
+					Modified: someTime,
+					Body:     "body",
+					Status:   types.StatusPending,
+				},
+				{
+					ID:       "child",
+					Post:     "post",
+					Parent:   "pending",
+					Author:   "other",
+					Created:  someTime,
+					Modified: someTime,
+					Body:     "body",
+					Status:   types.StatusApproved,
+				},
+			},
+		},
 	} {
 		t.Run(testCase.name, func(t *testing.T) {
 			if testCase.wantedErr == nil {
@@ -218,10 +409,15 @@ func TestCommentsModel_Replies(t *testing.T) {
 
 			model := CommentsModel{
 				CommentsStore: testCase.state,
+				Roles:         testsupport.RolesStoreFake{"moderator": types.RoleModerator},
 				TimeFunc:      func() time.Time { return now },
 			}
 
-			comments, err := model.Replies(testCase.post, testCase.parent)
+			comments, err := model.Replies(
+				testCase.post,
+				testCase.parent,
+				testCase.viewer,
+			)
 
 			if err := types.CompareComments(
 				testCase.wantedComments,
@@ -237,59 +433,419 @@ func TestCommentsModel_Replies(t *testing.T) {
 	}
 }
 
+func TestCommentsModel_RepliesPage(t *testing.T) {
+	state := func() testsupport.CommentsStoreFake {
+		return testsupport.CommentsStoreFake{
+			"post": {
+				"a": {
+					ID:       "a",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime,
+					Modified: someTime,
+					Body:     "body",
+				},
+				"b": {
+					ID:       "b",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime.Add(time.Hour),
+					Modified: someTime.Add(time.Hour),
+					Body:     "body",
+				},
+				"b-child": {
+					ID:       "b-child",
+					Post:     "post",
+					Parent:   "b",
+					Author:   "author",
+					Created:  someTime.Add(2 * time.Hour),
+					Modified: someTime.Add(2 * time.Hour),
+					Deleted:  true,
+					Body:     "body",
+				},
+				"c": {
+					ID:       "c",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime.Add(time.Hour),
+					Modified: someTime.Add(time.Hour),
+					Body:     "body",
+				},
+			},
+		}
+	}
+
+	for _, testCase := range []struct {
+		name           string
+		query          types.RepliesQuery
+		wantedComments []*types.Comment
+		wantedNext     *types.Cursor
+		wantedHidden   map[types.CommentID]int
+		wantedErr      types.WantedError
+	}{
+		{
+			name:  "first page",
+			query: types.RepliesQuery{Post: "post", Limit: 2},
+			wantedComments: []*types.Comment{
+				{
+					ID:       "a",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime,
+					Modified: someTime,
+					Body:     "body",
+				},
+				{
+					ID:       "b",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime.Add(time.Hour),
+					Modified: someTime.Add(time.Hour),
+					Body:     "body",
+				},
+				{
+					ID:       "b-child",
+					Post:     "post",
+					Parent:   "b",
+					Created:  someTime.Add(2 * time.Hour),
+					Modified: someTime.Add(2 * time.Hour),
+					Deleted:  true,
+				},
+			},
+			wantedNext: &types.Cursor{
+				Sort:    types.SortOldest,
+				Created: someTime.Add(time.Hour),
+				ID:      "b",
+			},
+		},
+		{
+			// `b` and `c` have the same creation time, so the cursor must
+			// break the tie by ID.
+			name: "last page",
+			query: types.RepliesQuery{
+				Post:  "post",
+				Limit: 2,
+				Cursor: &types.Cursor{
+					Sort:    types.SortOldest,
+					Created: someTime.Add(time.Hour),
+					ID:      "b",
+				},
+			},
+			wantedComments: []*types.Comment{{
+				ID:       "c",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime.Add(time.Hour),
+				Modified: someTime.Add(time.Hour),
+				Body:     "body",
+			}},
+		},
+		{
+			name:  "default limit",
+			query: types.RepliesQuery{Post: "post", Parent: "b"},
+			wantedComments: []*types.Comment{{
+				ID:       "b-child",
+				Post:     "post",
+				Parent:   "b",
+				Created:  someTime.Add(2 * time.Hour),
+				Modified: someTime.Add(2 * time.Hour),
+				Deleted:  true,
+			}},
+		},
+		{
+			name: "max depth",
+			query: types.RepliesQuery{
+				Post: "post",
+				Cursor: &types.Cursor{
+					Sort:    types.SortOldest,
+					Created: someTime,
+					ID:      "a",
+				},
+				MaxDepth: 1,
+			},
+			wantedComments: []*types.Comment{
+				{
+					ID:       "b",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime.Add(time.Hour),
+					Modified: someTime.Add(time.Hour),
+					Body:     "body",
+				},
+				{
+					ID:       "c",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime.Add(time.Hour),
+					Modified: someTime.Add(time.Hour),
+					Body:     "body",
+				},
+			},
+			wantedHidden: map[types.CommentID]int{"b": 1},
+		},
+		{
+			// `b` and `c` have the same creation time, so the tie is broken
+			// by ID.
+			name: "newest first",
+			query: types.RepliesQuery{
+				Post:     "post",
+				Limit:    1,
+				MaxDepth: 1,
+				Sort:     types.SortNewest,
+			},
+			wantedComments: []*types.Comment{{
+				ID:       "c",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime.Add(time.Hour),
+				Modified: someTime.Add(time.Hour),
+				Body:     "body",
+			}},
+			wantedNext: &types.Cursor{
+				Sort:    types.SortNewest,
+				Created: someTime.Add(time.Hour),
+				ID:      "c",
+			},
+		},
+		{
+			name: "most replies first",
+			query: types.RepliesQuery{
+				Post:     "post",
+				Limit:    1,
+				MaxDepth: 1,
+				Sort:     types.SortReplies,
+			},
+			wantedComments: []*types.Comment{{
+				ID:       "b",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime.Add(time.Hour),
+				Modified: someTime.Add(time.Hour),
+				Body:     "body",
+			}},
+			wantedNext: &types.Cursor{
+				Sort:    types.SortReplies,
+				Key:     1,
+				Created: someTime.Add(time.Hour),
+				ID:      "b",
+			},
+			wantedHidden: map[types.CommentID]int{"b": 1},
+		},
+		{
+			name: "most replies last page",
+			query: types.RepliesQuery{
+				Post:  "post",
+				Limit: 1,
+				Cursor: &types.Cursor{
+					Sort:    types.SortReplies,
+					Created: someTime,
+					ID:      "a",
+				},
+				Sort: types.SortReplies,
+			},
+			wantedComments: []*types.Comment{{
+				ID:       "c",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime.Add(time.Hour),
+				Modified: someTime.Add(time.Hour),
+				Body:     "body",
+			}},
+		},
+		{
+			name: "cursor from another sort",
+			query: types.RepliesQuery{
+				Post:   "post",
+				Cursor: &types.Cursor{Sort: types.SortOldest, ID: "a"},
+				Sort:   types.SortNewest,
+			},
+			wantedErr: types.ErrInvalidCursor,
+		},
+		{
+			name:      "invalid sort",
+			query:     types.RepliesQuery{Post: "post", Sort: "sideways"},
+			wantedErr: types.ErrInvalidSort,
+		},
+		{
+			name:      "negative limit",
+			query:     types.RepliesQuery{Post: "post", Limit: -1},
+			wantedErr: ErrInvalidLimit,
+		},
+		{
+			name:      "negative depth",
+			query:     types.RepliesQuery{Post: "post", MaxDepth: -1},
+			wantedErr: ErrInvalidDepth,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.wantedErr == nil {
+				testCase.wantedErr = types.NilError{}
+			}
+
+			model := CommentsModel{
+				CommentsStore: state(),
+				TimeFunc:      func() time.Time { return now },
+			}
+
+			page, err := model.RepliesPage(&testCase.query)
+			if err := testCase.wantedErr.CompareErr(err); err != nil {
+				t.Fatal(err)
+			}
+			if err != nil {
+				return
+			}
+
+			if err := types.CompareComments(
+				testCase.wantedComments,
+				page.Comments,
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := compareCursors(testCase.wantedNext, page.Next); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, c := range page.Comments {
+				if c.HiddenReplies != testCase.wantedHidden[c.ID] {
+					t.Fatalf(
+						"Comment[%s].HiddenReplies: wanted `%d`; found `%d`",
+						c.ID,
+						testCase.wantedHidden[c.ID],
+						c.HiddenReplies,
+					)
+				}
+			}
+		})
+	}
+}
+
+func compareCursors(wanted, found *types.Cursor) error {
+	if wanted == nil && found == nil {
+		return nil
+	}
+	if wanted == nil || found == nil {
+		return fmt.Errorf("Cursor: wanted `%v`; found `%v`", wanted, found)
+	}
+	if wanted.Sort != found.Sort ||
+		wanted.Key != found.Key ||
+		!wanted.Created.Equal(found.Created) ||
+		wanted.ID != found.ID {
+		return fmt.Errorf(
+			"Cursor: wanted `(%s, %d, %s, %s)`; found `(%s, %d, %s, %s)`",
+			wanted.Sort,
+			wanted.Key,
+			wanted.Created,
+			wanted.ID,
+			found.Sort,
+			found.Key,
+			found.Created,
+			found.ID,
+		)
+	}
+	return nil
+}
+
 func TestCommentsModel_Delete(t *testing.T) {
+	state := func() testsupport.CommentsStoreFake {
+		return testsupport.CommentsStoreFake{
+			"post": {
+				"id": {
+					ID:       "id",
+					Post:     "post",
+					Parent:   "parent",
+					Author:   "author",
+					Created:  someTime,
+					Modified: someTime,
+					Deleted:  false,
+				},
+			},
+		}
+	}
+	deleted := func(removed bool) testsupport.CommentsStoreFake {
+		return testsupport.CommentsStoreFake{
+			"post": {
+				"id": {
+					ID:       "id",
+					Post:     "post",
+					Parent:   "parent",
+					Author:   "author",
+					Created:  someTime,
+					Modified: now,
+					Deleted:  true,
+					Removed:  removed,
+				},
+			},
+		}
+	}
+
 	for _, testCase := range []struct {
 		name        string
 		state       testsupport.CommentsStoreFake
+		user        types.UserID
 		post        types.PostID
 		comment     types.CommentID
 		wantedState testsupport.CommentsStoreFake
 		wantedErr   types.WantedError
 	}{
 		{
-			name: "simple",
-			state: testsupport.CommentsStoreFake{
-				"post": {
-					"id": {
-						ID:       "id",
-						Post:     "post",
-						Parent:   "parent",
-						Author:   "author",
-						Created:  someTime,
-						Modified: someTime,
-						Deleted:  false,
-					},
-				},
-			},
-			post:    "post",
-			comment: "id",
-			wantedState: testsupport.CommentsStoreFake{
-				"post": {
-					"id": {
-						ID:       "id",
-						Post:     "post",
-						Parent:   "parent",
-						Author:   "author",
-						Created:  someTime,
-						Modified: now,
-						Deleted:  true,
-					},
-				},
-			},
+			name:        "simple",
+			state:       state(),
+			user:        "author",
+			post:        "post",
+			comment:     "id",
+			wantedState: deleted(false),
+		},
+		{
+			name:        "moderators remove other users' comments",
+			state:       state(),
+			user:        "moderator",
+			post:        "post",
+			comment:     "id",
+			wantedState: deleted(true),
+		},
+		{
+			name:        "other users can't delete",
+			state:       state(),
+			user:        "other",
+			post:        "post",
+			comment:     "id",
+			wantedState: state(),
+			wantedErr:   ErrNotAuthor,
+		},
+		{
+			name:        "anonymous users can't delete",
+			state:       state(),
+			post:        "post",
+			comment:     "id",
+			wantedState: state(),
+			wantedErr:   ErrUnauthorized,
+		},
+		{
+			name:        "not found",
+			state:       state(),
+			user:        "moderator",
+			post:        "post",
+			comment:     "missing",
+			wantedState: state(),
+			wantedErr:   types.ErrCommentNotFound,
 		},
 	} {
 		t.Run(testCase.name, func(t *testing.T) {
 			model := CommentsModel{
 				CommentsStore: testCase.state,
-				IDFunc:        func() types.CommentID { return "comment" },
-				TimeFunc:      func() time.Time { return now },
+				Roles: testsupport.RolesStoreFake{
+					"moderator": types.RoleModerator,
+				},
+				IDFunc:   func() types.CommentID { return "comment" },
+				TimeFunc: func() time.Time { return now },
 			}
 
 			if testCase.wantedErr == nil {
 				testCase.wantedErr = types.NilError{}
 			}
 			if err := testCase.wantedErr.CompareErr(
-				model.Delete(testCase.post, testCase.comment),
+				model.Delete(testCase.user, testCase.post, testCase.comment),
 			); err != nil {
 				t.Fatal(err)
 			}
@@ -307,8 +863,12 @@ func TestCommentsModel_Put(t *testing.T) {
 	for _, testCase := range []struct {
 		name          string
 		state         testsupport.CommentsStoreFake
+		settings      testsupport.PostSettingsStoreFake
+		premoderate   bool
+		checkers      []types.SpamChecker
 		input         types.Comment
 		wantedComment *types.Comment
+		wantedHTML    string
 		wantedErr     types.WantedError
 	}{
 		{
@@ -326,6 +886,7 @@ func TestCommentsModel_Put(t *testing.T) {
 				Created:  now,
 				Modified: now,
 				Body:     goodBody,
+				Status:   types.StatusApproved,
 			},
 		},
 		{
@@ -354,6 +915,7 @@ func TestCommentsModel_Put(t *testing.T) {
 				Modified: now,
 				Deleted:  false,
 				Body:     goodBody,
+				Status:   types.StatusApproved,
 			},
 		},
 		{
@@ -373,7 +935,7 @@ func TestCommentsModel_Put(t *testing.T) {
 			wantedErr: ErrBodyTooLong,
 		},
 		{
-			name:  "html escape body",
+			name:  "body is stored verbatim and rendered escaped",
 			state: testsupport.CommentsStoreFake{},
 			input: types.Comment{
 				Post:   "post",
@@ -386,39 +948,225 @@ func TestCommentsModel_Put(t *testing.T) {
 				Author:   "user",
 				Created:  now,
 				Modified: now,
-				Body:     "&lt;script&gt;&lt;/script&gt;",
+				Body:     "<script></script>",
+				Status:   types.StatusApproved,
 			},
+			wantedHTML: "<p>&lt;script&gt;&lt;/script&gt;</p>",
 		},
 		{
-			name: "reply to deleted comment",
-			state: testsupport.CommentsStoreFake{
-				"post": {
-					"id": {
-						ID:       "id",
-						Post:     "post",
-						Parent:   "",
-						Author:   "author",
-						Created:  someTime,
-						Modified: someTime,
-						Deleted:  true,
-					},
-				},
+			name:  "premoderated post",
+			state: testsupport.CommentsStoreFake{},
+			settings: testsupport.PostSettingsStoreFake{
+				"post": {Post: "post", Premoderate: true},
 			},
 			input: types.Comment{
-				ID:     "reply",
 				Post:   "post",
-				Parent: "id",
-				Author: "author",
+				Author: "user",
 				Body:   goodBody,
 			},
-			wantedErr: types.ErrCommentNotFound,
-		},
-	} {
+			wantedComment: &types.Comment{
+				Post:     "post",
+				ID:       "comment",
+				Author:   "user",
+				Created:  now,
+				Modified: now,
+				Body:     goodBody,
+				Status:   types.StatusPending,
+			},
+		},
+		{
+			name:        "premoderated globally",
+			state:       testsupport.CommentsStoreFake{},
+			premoderate: true,
+			input: types.Comment{
+				Post:   "post",
+				Author: "user",
+				Body:   goodBody,
+			},
+			wantedComment: &types.Comment{
+				Post:     "post",
+				ID:       "comment",
+				Author:   "user",
+				Created:  now,
+				Modified: now,
+				Body:     goodBody,
+				Status:   types.StatusPending,
+			},
+		},
+		{
+			name:        "moderators aren't premoderated",
+			state:       testsupport.CommentsStoreFake{},
+			premoderate: true,
+			input: types.Comment{
+				Post:   "post",
+				Author: "moderator",
+				Body:   goodBody,
+			},
+			wantedComment: &types.Comment{
+				Post:     "post",
+				ID:       "comment",
+				Author:   "moderator",
+				Created:  now,
+				Modified: now,
+				Body:     goodBody,
+				Status:   types.StatusApproved,
+			},
+		},
+		{
+			name: "reply to deleted comment",
+			state: testsupport.CommentsStoreFake{
+				"post": {
+					"id": {
+						ID:       "id",
+						Post:     "post",
+						Parent:   "",
+						Author:   "author",
+						Created:  someTime,
+						Modified: someTime,
+						Deleted:  true,
+					},
+				},
+			},
+			input: types.Comment{
+				ID:     "reply",
+				Post:   "post",
+				Parent: "id",
+				Author: "author",
+				Body:   goodBody,
+			},
+			wantedErr: types.ErrCommentNotFound,
+		},
+		{
+			name:     "allowed by spam checkers",
+			state:    testsupport.CommentsStoreFake{},
+			checkers: []types.SpamChecker{&spamCheckerFake{check: types.Allow}},
+			input: types.Comment{
+				Post:   "post",
+				Author: "user",
+				Body:   goodBody,
+			},
+			wantedComment: &types.Comment{
+				Post:        "post",
+				ID:          "comment",
+				Author:      "user",
+				Created:     now,
+				Modified:    now,
+				Body:        goodBody,
+				Status:      types.StatusApproved,
+				SpamVerdict: types.VerdictAllow,
+			},
+		},
+		{
+			name:  "held as spam",
+			state: testsupport.CommentsStoreFake{},
+			checkers: []types.SpamChecker{
+				&spamCheckerFake{check: types.Allow},
+				&spamCheckerFake{check: types.SpamCheck{
+					Verdict: types.VerdictHold,
+					Reason:  "first",
+				}},
+				&spamCheckerFake{check: types.SpamCheck{
+					Verdict: types.VerdictHold,
+					Reason:  "second",
+				}},
+			},
+			input: types.Comment{
+				Post:   "post",
+				Author: "user",
+				Body:   goodBody,
+			},
+			wantedComment: &types.Comment{
+				Post:        "post",
+				ID:          "comment",
+				Author:      "user",
+				Created:     now,
+				Modified:    now,
+				Body:        goodBody,
+				Status:      types.StatusPending,
+				SpamVerdict: types.VerdictHold,
+				SpamReason:  "first",
+			},
+		},
+		{
+			name:  "rejected as spam",
+			state: testsupport.CommentsStoreFake{},
+			checkers: []types.SpamChecker{
+				&spamCheckerFake{check: types.SpamCheck{
+					Verdict: types.VerdictHold,
+					Reason:  "suspicious",
+				}},
+				&spamCheckerFake{check: types.SpamCheck{
+					Verdict: types.VerdictReject,
+					Reason:  "blatant",
+				}},
+			},
+			input: types.Comment{
+				Post:   "post",
+				Author: "user",
+				Body:   goodBody,
+			},
+			wantedErr: types.ErrSpam,
+		},
+		{
+			name:  "failed spam check holds comment",
+			state: testsupport.CommentsStoreFake{},
+			checkers: []types.SpamChecker{
+				&spamCheckerFake{err: fmt.Errorf("service unavailable")},
+			},
+			input: types.Comment{
+				Post:   "post",
+				Author: "user",
+				Body:   goodBody,
+			},
+			wantedComment: &types.Comment{
+				Post:        "post",
+				ID:          "comment",
+				Author:      "user",
+				Created:     now,
+				Modified:    now,
+				Body:        goodBody,
+				Status:      types.StatusPending,
+				SpamVerdict: types.VerdictHold,
+				SpamReason:  spamCheckFailed,
+			},
+		},
+		{
+			name:  "moderators aren't spam checked",
+			state: testsupport.CommentsStoreFake{},
+			checkers: []types.SpamChecker{
+				&spamCheckerFake{check: types.SpamCheck{
+					Verdict: types.VerdictReject,
+					Reason:  "blatant",
+				}},
+			},
+			input: types.Comment{
+				Post:   "post",
+				Author: "moderator",
+				Body:   goodBody,
+			},
+			wantedComment: &types.Comment{
+				Post:     "post",
+				ID:       "comment",
+				Author:   "moderator",
+				Created:  now,
+				Modified: now,
+				Body:     goodBody,
+				Status:   types.StatusApproved,
+			},
+		},
+	} {
 		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.settings == nil {
+				testCase.settings = testsupport.PostSettingsStoreFake{}
+			}
 			model := CommentsModel{
-				CommentsStore: testCase.state,
-				IDFunc:        func() types.CommentID { return "comment" },
-				TimeFunc:      func() time.Time { return now },
+				CommentsStore:     testCase.state,
+				PostSettingsStore: testCase.settings,
+				Premoderate:       testCase.premoderate,
+				Roles:             testsupport.RolesStoreFake{"moderator": types.RoleModerator},
+				SpamCheckers:      testCase.checkers,
+				IDFunc:            func() types.CommentID { return "comment" },
+				TimeFunc:          func() time.Time { return now },
 			}
 
 			c, err := model.Put(&testCase.input)
@@ -433,6 +1181,13 @@ func TestCommentsModel_Put(t *testing.T) {
 			if err := testCase.wantedComment.Compare(c); err != nil {
 				t.Fatal(err)
 			}
+			if testCase.wantedHTML != "" && c.HTML != testCase.wantedHTML {
+				t.Fatalf(
+					"Comment.HTML: wanted `%s`; found `%s`",
+					testCase.wantedHTML,
+					c.HTML,
+				)
+			}
 			if testCase.wantedComment != nil {
 				if err := testCase.state.Contains(
 					testCase.wantedComment,
@@ -444,7 +1199,705 @@ func TestCommentsModel_Put(t *testing.T) {
 	}
 }
 
+func TestCommentsModel_RepliesPage_SpamChecks(t *testing.T) {
+	for _, testCase := range []struct {
+		viewer        types.UserID
+		wantedVerdict types.Verdict
+		wantedReason  string
+	}{
+		{viewer: "", wantedVerdict: "", wantedReason: ""},
+		{viewer: "user", wantedVerdict: "", wantedReason: ""},
+		{
+			viewer:        "moderator",
+			wantedVerdict: types.VerdictHold,
+			wantedReason:  "suspicious",
+		},
+	} {
+		t.Run(string(testCase.viewer), func(t *testing.T) {
+			model := CommentsModel{
+				CommentsStore: testsupport.CommentsStoreFake{"post": {
+					"comment": {
+						ID:          "comment",
+						Post:        "post",
+						Author:      "user",
+						Body:        goodBody,
+						Status:      types.StatusApproved,
+						SpamVerdict: types.VerdictHold,
+						SpamReason:  "suspicious",
+					},
+				}},
+				Roles: testsupport.RolesStoreFake{
+					"moderator": types.RoleModerator,
+				},
+			}
+
+			page, err := model.RepliesPage(&types.RepliesQuery{
+				Post:   "post",
+				Viewer: testCase.viewer,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(page.Comments) != 1 {
+				t.Fatalf("wanted `1` comment; found `%d`", len(page.Comments))
+			}
+			c := page.Comments[0]
+			if c.SpamVerdict != testCase.wantedVerdict {
+				t.Fatalf(
+					"Comment.SpamVerdict: wanted `%s`; found `%s`",
+					testCase.wantedVerdict,
+					c.SpamVerdict,
+				)
+			}
+			if c.SpamReason != testCase.wantedReason {
+				t.Fatalf(
+					"Comment.SpamReason: wanted `%s`; found `%s`",
+					testCase.wantedReason,
+					c.SpamReason,
+				)
+			}
+		})
+	}
+}
+
+func TestCommentsModel_Update_SpamChecks(t *testing.T) {
+	for _, testCase := range []struct {
+		name          string
+		editor        types.UserID
+		check         types.SpamCheck
+		wantedBody    string
+		wantedStatus  types.Status
+		wantedVerdict types.Verdict
+		wantedErr     types.WantedError
+	}{
+		{
+			name:         "allowed",
+			editor:       "author",
+			check:        types.Allow,
+			wantedBody:   "greetings",
+			wantedStatus: types.StatusApproved,
+		},
+		{
+			name:   "held",
+			editor: "author",
+			check: types.SpamCheck{
+				Verdict: types.VerdictHold,
+				Reason:  "suspicious",
+			},
+			wantedBody:    "greetings",
+			wantedStatus:  types.StatusPending,
+			wantedVerdict: types.VerdictHold,
+		},
+		{
+			name:   "rejected",
+			editor: "author",
+			check: types.SpamCheck{
+				Verdict: types.VerdictReject,
+				Reason:  "blatant",
+			},
+			wantedBody:   "hello, world",
+			wantedStatus: types.StatusApproved,
+			wantedErr:    types.ErrSpam,
+		},
+		{
+			name:   "moderators aren't checked",
+			editor: "moderator",
+			check: types.SpamCheck{
+				Verdict: types.VerdictReject,
+				Reason:  "blatant",
+			},
+			wantedBody:   "greetings",
+			wantedStatus: types.StatusApproved,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.wantedErr == nil {
+				testCase.wantedErr = types.NilError{}
+			}
+			store := testsupport.CommentsStoreFake{
+				"post": {"id": &types.Comment{
+					ID:       "id",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime,
+					Modified: someTime,
+					Body:     "hello, world",
+					Status:   types.StatusApproved,
+				}},
+			}
+			model := CommentsModel{
+				CommentsStore: store,
+				SpamCheckers: []types.SpamChecker{
+					&spamCheckerFake{check: testCase.check},
+				},
+				Roles: testsupport.RolesStoreFake{
+					"moderator": types.RoleModerator,
+				},
+				TimeFunc: func() time.Time { return now },
+			}
+
+			err := model.Update(testCase.editor, &CommentUpdate{
+				ID:   "id",
+				Post: "post",
+				Body: "greetings",
+			})
+			if err := testCase.wantedErr.CompareErr(err); err != nil {
+				t.Fatal(err)
+			}
+
+			c := store["post"]["id"]
+			if c.Body != testCase.wantedBody ||
+				c.Status != testCase.wantedStatus ||
+				c.SpamVerdict != testCase.wantedVerdict {
+				t.Fatalf(
+					"wanted body `%s`, status `%s` and verdict `%s`; "+
+						"found `%s`, `%s` and `%s`",
+					testCase.wantedBody,
+					testCase.wantedStatus,
+					testCase.wantedVerdict,
+					c.Body,
+					c.Status,
+					c.SpamVerdict,
+				)
+			}
+		})
+	}
+}
+
 var (
 	someTime = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
 	now      = time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC)
 )
+
+type spamCheckerFake struct {
+	check types.SpamCheck
+	err   error
+}
+
+func (scf *spamCheckerFake) CheckSpam(
+	*types.Submission,
+) (types.SpamCheck, error) {
+	return scf.check, scf.err
+}
+
+func TestCommentsModel_Vote(t *testing.T) {
+	for _, testCase := range []struct {
+		name        string
+		votes       map[types.UserID]int
+		vote        types.Vote
+		wantedScore int
+		wantedUp    int
+		wantedDown  int
+		wantedErr   types.WantedError
+	}{
+		{
+			name:        "upvote",
+			vote:        types.Vote{Comment: "comment", Value: types.Upvote},
+			wantedScore: 1,
+			wantedUp:    1,
+		},
+		{
+			name:        "change vote",
+			votes:       map[types.UserID]int{"user": types.Upvote},
+			vote:        types.Vote{Comment: "comment", Value: types.Downvote},
+			wantedScore: -1,
+			wantedDown:  1,
+		},
+		{
+			name:        "other users' votes are kept",
+			votes:       map[types.UserID]int{"other": types.Upvote},
+			vote:        types.Vote{Comment: "comment", Value: types.Upvote},
+			wantedScore: 2,
+			wantedUp:    2,
+		},
+		{
+			name:      "invalid value",
+			vote:      types.Vote{Comment: "comment", Value: 2},
+			wantedErr: types.ErrInvalidVote,
+		},
+		{
+			name:      "deleted comment",
+			vote:      types.Vote{Comment: "deleted", Value: types.Upvote},
+			wantedErr: types.ErrCommentNotFound,
+		},
+		{
+			name:      "missing comment",
+			vote:      types.Vote{Comment: "missing", Value: types.Upvote},
+			wantedErr: types.ErrCommentNotFound,
+		},
+		{
+			name:      "pending comment",
+			vote:      types.Vote{Comment: "pending", Value: types.Upvote},
+			wantedErr: types.ErrCommentNotFound,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.wantedErr == nil {
+				testCase.wantedErr = types.NilError{}
+			}
+
+			votes := votesStoreFake(testCase.votes)
+			model := CommentsModel{
+				CommentsStore: votes.Comments,
+				VotesStore:    votes,
+				TimeFunc:      func() time.Time { return now },
+			}
+
+			testCase.vote.Post = "post"
+			testCase.vote.User = "user"
+			if err := testCase.wantedErr.CompareErr(
+				model.Vote(&testCase.vote),
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := compareScore(
+				votes.Comments["post"]["comment"],
+				testCase.wantedScore,
+				testCase.wantedUp,
+				testCase.wantedDown,
+			); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCommentsModel_Unvote(t *testing.T) {
+	for _, testCase := range []struct {
+		name        string
+		votes       map[types.UserID]int
+		wantedScore int
+		wantedUp    int
+		wantedErr   types.WantedError
+	}{
+		{
+			name: "simple",
+			votes: map[types.UserID]int{
+				"user":  types.Downvote,
+				"other": types.Upvote,
+			},
+			wantedScore: 1,
+			wantedUp:    1,
+		},
+		{
+			name:        "not found",
+			votes:       map[types.UserID]int{"other": types.Upvote},
+			wantedScore: 1,
+			wantedUp:    1,
+			wantedErr:   types.ErrVoteNotFound,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.wantedErr == nil {
+				testCase.wantedErr = types.NilError{}
+			}
+
+			votes := votesStoreFake(testCase.votes)
+			model := CommentsModel{
+				CommentsStore: votes.Comments,
+				VotesStore:    votes,
+				TimeFunc:      func() time.Time { return now },
+			}
+
+			if err := testCase.wantedErr.CompareErr(
+				model.Unvote("post", "comment", "user"),
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := compareScore(
+				votes.Comments["post"]["comment"],
+				testCase.wantedScore,
+				testCase.wantedUp,
+				0,
+			); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// votesStoreFake returns a votes store with a `comment` and a `deleted`
+// comment on `post`. The provided votes are cast on `comment`.
+func votesStoreFake(
+	votes map[types.UserID]int,
+) *testsupport.VotesStoreFake {
+	store := testsupport.VotesStoreFake{
+		Comments: testsupport.CommentsStoreFake{
+			"post": {
+				"comment": {
+					ID:       "comment",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime,
+					Modified: someTime,
+					Body:     "body",
+				},
+				"deleted": {
+					ID:       "deleted",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime,
+					Modified: someTime,
+					Deleted:  true,
+					Body:     "body",
+				},
+				"pending": {
+					ID:       "pending",
+					Post:     "post",
+					Author:   "author",
+					Created:  someTime,
+					Modified: someTime,
+					Body:     "body",
+					Status:   types.StatusPending,
+				},
+			},
+		},
+	}
+	for user, value := range votes {
+		if err := store.PutVote(&types.Vote{
+			Post:    "post",
+			Comment: "comment",
+			User:    user,
+			Value:   value,
+		}); err != nil {
+			panic(err)
+		}
+	}
+	return &store
+}
+
+func compareScore(c *types.Comment, score, upvotes, downvotes int) error {
+	if c.Score != score || c.Upvotes != upvotes || c.Downvotes != downvotes {
+		return fmt.Errorf(
+			"Comment[%s] (Score, Upvotes, Downvotes): wanted `(%d, %d, %d)`; "+
+				"found `(%d, %d, %d)`",
+			c.ID,
+			score,
+			upvotes,
+			downvotes,
+			c.Score,
+			c.Upvotes,
+			c.Downvotes,
+		)
+	}
+	return nil
+}
+
+func TestCommentsModel_Revisions(t *testing.T) {
+	for _, testCase := range []struct {
+		name            string
+		comment         types.Comment
+		viewer          types.UserID
+		sanctions       testsupport.SanctionsStoreFake
+		wantedRevisions []*types.Revision
+		wantedErr       types.WantedError
+	}{
+		{
+			name: "edits are recorded",
+			comment: types.Comment{
+				Author: "author",
+				Status: types.StatusApproved,
+			},
+			viewer: "other",
+			wantedRevisions: []*types.Revision{{
+				Post:    "post",
+				Comment: "id",
+				Body:    "hello, world",
+				Created: someTime,
+				Edited:  now,
+				Editor:  "author",
+			}},
+		},
+		{
+			name: "deleted comments are hidden",
+			comment: types.Comment{
+				Author:  "author",
+				Status:  types.StatusApproved,
+				Deleted: true,
+			},
+			viewer:    "author",
+			wantedErr: types.ErrCommentNotFound,
+		},
+		{
+			name: "moderators see deleted comments",
+			comment: types.Comment{
+				Author:  "author",
+				Status:  types.StatusApproved,
+				Deleted: true,
+			},
+			viewer:          "moderator",
+			wantedRevisions: []*types.Revision{},
+		},
+		{
+			name: "pending comments are hidden",
+			comment: types.Comment{
+				Author: "author",
+				Status: types.StatusPending,
+			},
+			viewer:    "other",
+			wantedErr: types.ErrCommentNotFound,
+		},
+		{
+			name: "shadow-banned authors' comments are hidden",
+			comment: types.Comment{
+				Author: "author",
+				Status: types.StatusApproved,
+			},
+			viewer: "other",
+			sanctions: testsupport.Sanctions(&types.Sanction{
+				User: "author",
+				Kind: types.SanctionShadowBan,
+			}),
+			wantedErr: types.ErrCommentNotFound,
+		},
+		{
+			name: "expired shadow-bans don't hide comments",
+			comment: types.Comment{
+				Author: "author",
+				Status: types.StatusApproved,
+			},
+			viewer: "other",
+			sanctions: testsupport.Sanctions(&types.Sanction{
+				User:    "author",
+				Kind:    types.SanctionShadowBan,
+				Expires: &someTime,
+			}),
+			wantedRevisions: []*types.Revision{{
+				Post:    "post",
+				Comment: "id",
+				Body:    "hello, world",
+				Created: someTime,
+				Edited:  now,
+				Editor:  "author",
+			}},
+		},
+		{
+			name: "shadow-banned authors see their comments",
+			comment: types.Comment{
+				Author: "author",
+				Status: types.StatusApproved,
+			},
+			viewer: "author",
+			sanctions: testsupport.Sanctions(&types.Sanction{
+				User: "author",
+				Kind: types.SanctionShadowBan,
+			}),
+			wantedRevisions: []*types.Revision{{
+				Post:    "post",
+				Comment: "id",
+				Body:    "hello, world",
+				Created: someTime,
+				Edited:  now,
+				Editor:  "author",
+			}},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.wantedErr == nil {
+				testCase.wantedErr = types.NilError{}
+			}
+
+			c := testCase.comment
+			c.ID, c.Post = "id", "post"
+			c.Body = "hello, world"
+			c.Created, c.Modified = someTime, someTime
+			model := CommentsModel{
+				CommentsStore: testsupport.CommentsStoreFake{
+					"post": {"id": &c},
+				},
+				RevisionsStore: testsupport.RevisionsStoreFake{},
+				SanctionsStore: testCase.sanctions,
+				Roles: testsupport.RolesStoreFake{
+					"moderator": types.RoleModerator,
+				},
+				TimeFunc: func() time.Time { return now },
+			}
+
+			if !c.Deleted {
+				if err := model.Update("author", &CommentUpdate{
+					ID:   "id",
+					Post: "post",
+					Body: "greetings",
+				}); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			revisions, err := model.Revisions(testCase.viewer, "post", "id")
+			if err := testCase.wantedErr.CompareErr(err); err != nil {
+				t.Fatal(err)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(revisions) != len(testCase.wantedRevisions) {
+				t.Fatalf(
+					"len(revisions): wanted `%d`; found `%d`",
+					len(testCase.wantedRevisions),
+					len(revisions),
+				)
+			}
+			for i, wanted := range testCase.wantedRevisions {
+				found := revisions[i]
+				if found.Post != wanted.Post ||
+					found.Comment != wanted.Comment ||
+					found.Body != wanted.Body ||
+					!found.Created.Equal(wanted.Created) ||
+					!found.Edited.Equal(wanted.Edited) ||
+					found.Editor != wanted.Editor {
+					t.Fatalf(
+						"revisions[%d]: wanted `%+v`; found `%+v`",
+						i,
+						wanted,
+						found,
+					)
+				}
+			}
+		})
+	}
+}
+
+// failingUpdates is a comments store whose updates fail.
+type failingUpdates struct{ types.CommentsStore }
+
+func (failingUpdates) Update(*types.CommentPatch) error {
+	return errors.New("update failed")
+}
+
+func TestCommentsModel_Update_Failed(t *testing.T) {
+	revisions := testsupport.RevisionsStoreFake{}
+	model := CommentsModel{
+		CommentsStore: failingUpdates{testsupport.CommentsStoreFake{
+			"post": {"id": &types.Comment{
+				ID:       "id",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime,
+				Modified: someTime,
+				Body:     "hello, world",
+			}},
+		}},
+		RevisionsStore: revisions,
+		TimeFunc:       func() time.Time { return now },
+	}
+	if err := model.Update("author", &CommentUpdate{
+		ID:   "id",
+		Post: "post",
+		Body: "greetings",
+	}); err == nil {
+		t.Fatal("wanted an error; found `nil`")
+	}
+
+	found, err := revisions.Revisions("post", "id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) > 0 {
+		t.Fatalf("wanted no revisions; found `%+v`", found)
+	}
+}
+
+// TestCommentsModel_BodyEscaping guards against bodies being HTML-escaped on
+// their way into the store (which double-escapes them once they're rendered).
+func TestCommentsModel_BodyEscaping(t *testing.T) {
+	for _, testCase := range []struct {
+		name       string
+		body       string
+		wantedHTML string
+	}{
+		{
+			name:       "markup",
+			body:       "<script>alert(1)</script>",
+			wantedHTML: "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>",
+		},
+		{
+			name:       "ampersand",
+			body:       "salt & pepper",
+			wantedHTML: "<p>salt &amp; pepper</p>",
+		},
+		{
+			name:       "quotes",
+			body:       `"quoted" 'text'`,
+			wantedHTML: "<p>&#34;quoted&#34; &#39;text&#39;</p>",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			store := testsupport.CommentsStoreFake{}
+			model := CommentsModel{
+				CommentsStore:  store,
+				RevisionsStore: testsupport.RevisionsStoreFake{},
+				IDFunc:         func() types.CommentID { return "id" },
+				TimeFunc:       func() time.Time { return now },
+			}
+
+			checkBody := func(step string) {
+				stored, err := store.Comment("post", "id")
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %v", step, err)
+				}
+				if stored.Body != testCase.body {
+					t.Fatalf(
+						"%s: stored body: wanted `%s`; found `%s`",
+						step,
+						testCase.body,
+						stored.Body,
+					)
+				}
+
+				c, err := model.Comment("", "post", "id")
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %v", step, err)
+				}
+				replies, err := model.Replies("post", "", "")
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %v", step, err)
+				}
+				if len(replies) != 1 {
+					t.Fatalf(
+						"%s: len(replies): wanted `1`; found `%d`",
+						step,
+						len(replies),
+					)
+				}
+				for _, found := range []string{c.HTML, replies[0].HTML} {
+					if found != testCase.wantedHTML {
+						t.Fatalf(
+							"%s: rendered body: wanted `%s`; found `%s`",
+							step,
+							testCase.wantedHTML,
+							found,
+						)
+					}
+				}
+			}
+
+			if _, err := model.Put(&types.Comment{
+				Post:   "post",
+				Author: "author",
+				Body:   testCase.body,
+			}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkBody("put")
+
+			if err := model.Update("author", &CommentUpdate{
+				ID:   "id",
+				Post: "post",
+				Body: goodBody,
+			}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := model.Update("author", &CommentUpdate{
+				ID:   "id",
+				Post: "post",
+				Body: testCase.body,
+			}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkBody("update")
+		})
+	}
+}
@@ -32,7 +32,7 @@ func (cs *CommentsService) Put(r pz.Request) pz.Response {
 	c.Author = types.UserID(r.Headers.Get("User"))
 	c.Created = cs.TimeFunc().UTC()
 	c.Modified = c.Created
-	comment, err := cs.Comments.Put(&c)
+	comment, err := cs.Comments.PutSubmission(submission(r, &c))
 	if err != nil {
 		return pz.HandleError("putting comment", err)
 	}
@@ -45,23 +45,45 @@ func (cs *CommentsService) Put(r pz.Request) pz.Response {
 	})
 }
 
+// Replies returns a page of replies. By default (`format=flat`) the body is a
+// flat array of comments in which each comment follows its parent; with
+// `format=tree`, the body is an array of the page's threads in which each
+// comment's replies are nested in its `children` array.
 func (cs *CommentsService) Replies(r pz.Request) pz.Response {
-	var parent types.CommentID
-	if commentID := r.Vars["comment-id"]; commentID != "toplevel" {
-		parent = types.CommentID(commentID)
+	query, err := parseRepliesQuery(r)
+	if err != nil {
+		return pz.HandleError("parsing replies query", err)
 	}
-	comments, err := cs.Comments.Replies(
-		types.PostID(r.Vars["post-id"]),
-		parent,
-	)
+	format := queryValues(r).Get("format")
+	if format != "" && format != "flat" && format != "tree" {
+		return pz.HandleError("parsing replies query", ErrInvalidFormat)
+	}
+	query.Viewer = types.UserID(r.Headers.Get("User"))
+	page, err := cs.Comments.RepliesPage(query)
 	if err != nil {
 		return pz.HandleError("retrieving comment replies", err)
 	}
-	return pz.Ok(pz.JSON(comments))
+	var data interface{} = page.Comments
+	if format == "tree" {
+		data = Tree(page.Comments, query.Parent)
+	}
+	rsp := pz.Ok(pz.JSON(data))
+	if page.Next != nil {
+		// The body remains a plain array of comments for compatibility; the
+		// next page is advertised via the `Link` header instead.
+		rsp = rsp.WithHeaders(http.Header{"Link": []string{fmt.Sprintf(
+			"<%s>; rel=\"next\"",
+			pageURL(r.URL.Path, r, page.Next),
+		)}})
+	}
+	return rsp
 }
 
+// Get returns a comment. Comments which aren't visible to the user (see
+// `CommentsModel.Comment()`) aren't found.
 func (cs *CommentsService) Get(r pz.Request) pz.Response {
 	comment, err := cs.Comments.Comment(
+		types.UserID(r.Headers.Get("User")),
 		types.PostID(r.Vars["post-id"]),
 		types.CommentID(r.Vars["comment-id"]),
 	)
@@ -71,10 +93,52 @@ func (cs *CommentsService) Get(r pz.Request) pz.Response {
 	return pz.Ok(pz.JSON(comment))
 }
 
+// Vote records (or changes) the user's vote on a comment. The request body is
+// a JSON object with a `value` of `1` (upvote) or `-1` (downvote). The
+// response is the comment with its updated score.
+func (cs *CommentsService) Vote(r pz.Request) pz.Response {
+	var v types.Vote
+	if err := r.JSON(&v); err != nil {
+		return pz.BadRequest(
+			pz.String("Malformed `Vote` JSON"),
+			struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			},
+		)
+	}
+
+	v.Post = types.PostID(r.Vars["post-id"])
+	v.Comment = types.CommentID(r.Vars["comment-id"])
+	v.User = types.UserID(r.Headers.Get("User"))
+	if err := cs.Comments.Vote(&v); err != nil {
+		return pz.HandleError("voting on comment", err)
+	}
+	return cs.Get(r)
+}
+
+// Unvote removes the user's vote on a comment. The response is the comment
+// with its updated score.
+func (cs *CommentsService) Unvote(r pz.Request) pz.Response {
+	if err := cs.Comments.Unvote(
+		types.PostID(r.Vars["post-id"]),
+		types.CommentID(r.Vars["comment-id"]),
+		types.UserID(r.Headers.Get("User")),
+	); err != nil {
+		return pz.HandleError("removing vote on comment", err)
+	}
+	return cs.Get(r)
+}
+
 func (cs *CommentsService) Delete(r pz.Request) pz.Response {
 	post := types.PostID(r.Vars["post-id"])
 	comment := types.CommentID(r.Vars["comment-id"])
-	if err := cs.Comments.Delete(post, comment); err != nil {
+	if err := cs.Comments.Delete(
+		types.UserID(r.Headers.Get("User")),
+		post,
+		comment,
+	); err != nil {
 		return pz.HandleError("deleting comment", err)
 	}
 	rsp := DeleteCommentResponse{
@@ -168,7 +232,11 @@ func (cs *CommentsService) Update(r pz.Request) pz.Response {
 	}
 	payload.ID = types.CommentID(r.Vars["comment-id"])
 	payload.Post = types.PostID(r.Vars["post-id"])
-	if err := cs.Comments.Update(&payload); err != nil {
+	if err := cs.Comments.UpdateSubmission(
+		types.UserID(r.Headers.Get("User")),
+		&payload,
+		submission(r, nil),
+	); err != nil {
 		return pz.HandleError("updating comment", err)
 	}
 	return pz.Ok(pz.JSON(&UpdateResponseSuccess), &UpdateResponseSuccess)
@@ -201,3 +269,388 @@ func (wanted *updateResponse) CompareData(data []byte) error {
 
 	return nil
 }
+
+// Queue lists the comments which are pending moderation.
+func (cs *CommentsService) Queue(r pz.Request) pz.Response {
+	comments, err := cs.Comments.Queue(types.UserID(r.Headers.Get("User")))
+	if err != nil {
+		return pz.HandleError("retrieving moderation queue", err)
+	}
+	return pz.Ok(pz.JSON(comments))
+}
+
+// Approve approves a comment which is pending moderation (or which was
+// rejected).
+func (cs *CommentsService) Approve(r pz.Request) pz.Response {
+	return cs.moderate(r, types.StatusApproved)
+}
+
+// Reject rejects a comment, hiding it from everyone except its author and the
+// moderators.
+func (cs *CommentsService) Reject(r pz.Request) pz.Response {
+	return cs.moderate(r, types.StatusRejected)
+}
+
+func (cs *CommentsService) moderate(
+	r pz.Request,
+	status types.Status,
+) pz.Response {
+	if err := cs.Comments.Moderate(
+		types.UserID(r.Headers.Get("User")),
+		types.PostID(r.Vars["post-id"]),
+		types.CommentID(r.Vars["comment-id"]),
+		status,
+	); err != nil {
+		return pz.HandleError("moderating comment", err)
+	}
+	return cs.Get(r)
+}
+
+// Report records the user's report of a comment. The request body is a JSON
+// object with the report's `reason`.
+func (cs *CommentsService) Report(r pz.Request) pz.Response {
+	var report types.Report
+	if err := r.JSON(&report); err != nil {
+		return pz.BadRequest(
+			pz.String("Malformed `Report` JSON"),
+			struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			},
+		)
+	}
+	report.Post = types.PostID(r.Vars["post-id"])
+	report.Comment = types.CommentID(r.Vars["comment-id"])
+	report.Reporter = types.UserID(r.Headers.Get("User"))
+	created, err := cs.Comments.Report(&report)
+	if err != nil {
+		return pz.HandleError("reporting comment", err)
+	}
+	return pz.Created(pz.JSON(created), created)
+}
+
+// Reports lists the open reports grouped by comment.
+func (cs *CommentsService) Reports(r pz.Request) pz.Response {
+	reports, err := cs.Comments.Reports(types.UserID(r.Headers.Get("User")))
+	if err != nil {
+		return pz.HandleError("retrieving reports", err)
+	}
+	return pz.Ok(pz.JSON(reports))
+}
+
+func (cs *CommentsService) PostSettings(r pz.Request) pz.Response {
+	settings, err := cs.Comments.PostSettings(types.PostID(r.Vars["post-id"]))
+	if err != nil {
+		return pz.HandleError("retrieving post settings", err)
+	}
+	return pz.Ok(pz.JSON(settings))
+}
+
+func (cs *CommentsService) PutPostSettings(r pz.Request) pz.Response {
+	var settings types.PostSettings
+	if err := r.JSON(&settings); err != nil {
+		return pz.BadRequest(
+			pz.String("Malformed `PostSettings` JSON"),
+			struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			},
+		)
+	}
+
+	settings.Post = types.PostID(r.Vars["post-id"])
+	if err := cs.Comments.PutPostSettings(
+		types.UserID(r.Headers.Get("User")),
+		&settings,
+	); err != nil {
+		return pz.HandleError("putting post settings", err)
+	}
+	return pz.Ok(pz.JSON(&settings))
+}
+
+// PutUserRole assigns a role to a user. Only admins can assign roles.
+func (cs *CommentsService) PutUserRole(r pz.Request) pz.Response {
+	var userRole types.UserRole
+	if err := r.JSON(&userRole); err != nil {
+		return pz.BadRequest(
+			pz.String("Malformed `UserRole` JSON"),
+			struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			},
+		)
+	}
+
+	userRole.User = types.UserID(r.Vars["user-id"])
+	if err := cs.Comments.PutUserRole(
+		types.UserID(r.Headers.Get("User")),
+		&userRole,
+	); err != nil {
+		return pz.HandleError("assigning role", err)
+	}
+	return pz.Ok(pz.JSON(&userRole))
+}
+
+// NotificationSettings returns the user's notification settings.
+func (cs *CommentsService) NotificationSettings(r pz.Request) pz.Response {
+	settings, err := cs.Comments.NotificationSettings(
+		types.UserID(r.Headers.Get("User")),
+	)
+	if err != nil {
+		return pz.HandleError("retrieving notification settings", err)
+	}
+	return pz.Ok(pz.JSON(settings))
+}
+
+// PutNotificationSettings replaces the user's notification settings.
+func (cs *CommentsService) PutNotificationSettings(r pz.Request) pz.Response {
+	var settings types.NotificationSettings
+	if err := r.JSON(&settings); err != nil {
+		return pz.BadRequest(
+			pz.String("Malformed `NotificationSettings` JSON"),
+			struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			},
+		)
+	}
+
+	settings.User = types.UserID(r.Headers.Get("User"))
+	if err := cs.Comments.PutNotificationSettings(&settings); err != nil {
+		return pz.HandleError("putting notification settings", err)
+	}
+	return pz.Ok(pz.JSON(&settings))
+}
+
+// PutSanction imposes a sanction on a user. The sanction's kind comes from
+// the path and the request body is a JSON object with the sanction's `reason`
+// and (optionally) when it `expires`. Only admins can impose sanctions.
+func (cs *CommentsService) PutSanction(r pz.Request) pz.Response {
+	var sanction types.Sanction
+	if err := r.JSON(&sanction); err != nil {
+		return pz.BadRequest(
+			pz.String("Malformed `Sanction` JSON"),
+			struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			},
+		)
+	}
+
+	sanction.User = types.UserID(r.Vars["user-id"])
+	sanction.Kind = types.SanctionKind(r.Vars["kind"])
+	created, err := cs.Comments.PutSanction(
+		types.UserID(r.Headers.Get("User")),
+		&sanction,
+	)
+	if err != nil {
+		return pz.HandleError("imposing sanction", err)
+	}
+	return pz.Ok(pz.JSON(created))
+}
+
+// DeleteSanction lifts a user's sanction. Only admins can lift sanctions.
+func (cs *CommentsService) DeleteSanction(r pz.Request) pz.Response {
+	if err := cs.Comments.DeleteSanction(
+		types.UserID(r.Headers.Get("User")),
+		types.UserID(r.Vars["user-id"]),
+		types.SanctionKind(r.Vars["kind"]),
+	); err != nil {
+		return pz.HandleError("lifting sanction", err)
+	}
+	return pz.NoContent()
+}
+
+// UserSanctions lists a user's sanctions, including expired ones.
+func (cs *CommentsService) UserSanctions(r pz.Request) pz.Response {
+	sanctions, err := cs.Comments.UserSanctions(
+		types.UserID(r.Headers.Get("User")),
+		types.UserID(r.Vars["user-id"]),
+	)
+	if err != nil {
+		return pz.HandleError("retrieving user sanctions", err)
+	}
+	return pz.Ok(pz.JSON(sanctions))
+}
+
+// Sanctions lists every user's sanctions, including expired ones.
+func (cs *CommentsService) Sanctions(r pz.Request) pz.Response {
+	sanctions, err := cs.Comments.Sanctions(
+		types.UserID(r.Headers.Get("User")),
+	)
+	if err != nil {
+		return pz.HandleError("retrieving sanctions", err)
+	}
+	return pz.Ok(pz.JSON(sanctions))
+}
+
+// threadComment translates the `toplevel` comment ID, which refers to a
+// whole post, to the empty comment ID.
+func threadComment(r pz.Request) types.CommentID {
+	if comment := r.Vars["comment-id"]; comment != "toplevel" {
+		return types.CommentID(comment)
+	}
+	return ""
+}
+
+// Subscribe follows a post or subthread on behalf of the user.
+func (cs *CommentsService) Subscribe(r pz.Request) pz.Response {
+	subscription, err := cs.Comments.Subscribe(
+		types.UserID(r.Headers.Get("User")),
+		types.PostID(r.Vars["post-id"]),
+		threadComment(r),
+	)
+	if err != nil {
+		return pz.HandleError("subscribing to thread", err)
+	}
+	return pz.Ok(pz.JSON(subscription))
+}
+
+// Unsubscribe stops following a post or subthread on behalf of the user.
+func (cs *CommentsService) Unsubscribe(r pz.Request) pz.Response {
+	if err := cs.Comments.Unsubscribe(
+		types.UserID(r.Headers.Get("User")),
+		types.PostID(r.Vars["post-id"]),
+		threadComment(r),
+	); err != nil {
+		return pz.HandleError("unsubscribing from thread", err)
+	}
+	return pz.NoContent()
+}
+
+// Subscriptions lists the posts and subthreads which the user follows.
+func (cs *CommentsService) Subscriptions(r pz.Request) pz.Response {
+	subscriptions, err := cs.Comments.Subscriptions(
+		types.UserID(r.Headers.Get("User")),
+	)
+	if err != nil {
+		return pz.HandleError("retrieving subscriptions", err)
+	}
+	return pz.Ok(pz.JSON(subscriptions))
+}
+
+// Inbox lists the user's unread notifications about new comments in the
+// threads they follow. If the `all` query parameter is `true`, read
+// notifications are included.
+func (cs *CommentsService) Inbox(r pz.Request) pz.Response {
+	entries, err := cs.Comments.Inbox(
+		types.UserID(r.Headers.Get("User")),
+		queryValues(r).Get("all") == "true",
+	)
+	if err != nil {
+		return pz.HandleError("retrieving notifications", err)
+	}
+	return pz.Ok(pz.JSON(entries))
+}
+
+// MarkRead marks the user's notification about a comment read.
+func (cs *CommentsService) MarkRead(r pz.Request) pz.Response {
+	if err := cs.Comments.MarkRead(
+		types.UserID(r.Headers.Get("User")),
+		types.PostID(r.Vars["post-id"]),
+		types.CommentID(r.Vars["comment-id"]),
+	); err != nil {
+		return pz.HandleError("marking notification read", err)
+	}
+	return pz.NoContent()
+}
+
+// MarkAllRead marks all of the user's notifications read.
+func (cs *CommentsService) MarkAllRead(r pz.Request) pz.Response {
+	if err := cs.Comments.MarkAllRead(
+		types.UserID(r.Headers.Get("User")),
+	); err != nil {
+		return pz.HandleError("marking notifications read", err)
+	}
+	return pz.NoContent()
+}
+
+// PutWebhook registers a webhook. The request body is a JSON object with the
+// webhook's `url`, the `events` it wants (all of them if it's empty) and
+// optionally its `secret`. The response includes the webhook's ID and secret.
+// Only admins can register webhooks.
+func (cs *CommentsService) PutWebhook(r pz.Request) pz.Response {
+	var webhook types.Webhook
+	if err := r.JSON(&webhook); err != nil {
+		return pz.BadRequest(
+			pz.String("Malformed `Webhook` JSON"),
+			struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			},
+		)
+	}
+
+	created, err := cs.Comments.PutWebhook(
+		types.UserID(r.Headers.Get("User")),
+		&webhook,
+	)
+	if err != nil {
+		return pz.HandleError("registering webhook", err)
+	}
+	return pz.Created(pz.JSON(created))
+}
+
+// DeleteWebhook unregisters a webhook. Only admins can unregister webhooks.
+func (cs *CommentsService) DeleteWebhook(r pz.Request) pz.Response {
+	if err := cs.Comments.DeleteWebhook(
+		types.UserID(r.Headers.Get("User")),
+		r.Vars["webhook-id"],
+	); err != nil {
+		return pz.HandleError("deleting webhook", err)
+	}
+	return pz.NoContent()
+}
+
+// Webhooks lists the registered webhooks (without their secrets).
+func (cs *CommentsService) Webhooks(r pz.Request) pz.Response {
+	webhooks, err := cs.Comments.Webhooks(
+		types.UserID(r.Headers.Get("User")),
+	)
+	if err != nil {
+		return pz.HandleError("retrieving webhooks", err)
+	}
+	return pz.Ok(pz.JSON(webhooks))
+}
+
+// FailedDeliveries lists the webhook deliveries which failed every attempt.
+func (cs *CommentsService) FailedDeliveries(r pz.Request) pz.Response {
+	deliveries, err := cs.Comments.FailedDeliveries(
+		types.UserID(r.Headers.Get("User")),
+	)
+	if err != nil {
+		return pz.HandleError("retrieving failed deliveries", err)
+	}
+	return pz.Ok(pz.JSON(deliveries))
+}
+
+// ReplayDelivery redelivers a failed webhook delivery. The delivery is
+// queued, so a `204 No Content` response doesn't mean that it succeeded.
+func (cs *CommentsService) ReplayDelivery(r pz.Request) pz.Response {
+	if err := cs.Comments.ReplayDelivery(
+		types.UserID(r.Headers.Get("User")),
+		r.Vars["delivery-id"],
+	); err != nil {
+		return pz.HandleError("replaying delivery", err)
+	}
+	return pz.NoContent()
+}
+
+// Revisions lists a comment's revisions, oldest first.
+func (cs *CommentsService) Revisions(r pz.Request) pz.Response {
+	revisions, err := cs.Comments.Revisions(
+		types.UserID(r.Headers.Get("User")),
+		types.PostID(r.Vars["post-id"]),
+		types.CommentID(r.Vars["comment-id"]),
+	)
+	if err != nil {
+		return pz.HandleError("retrieving revisions", err)
+	}
+	return pz.Ok(pz.JSON(revisions))
+}
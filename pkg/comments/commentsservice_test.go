@@ -3,12 +3,16 @@ package comments
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/weberc2/auth/pkg/client"
 	"github.com/weberc2/comments/pkg/comments/testsupport"
 	"github.com/weberc2/comments/pkg/comments/types"
 	pz "github.com/weberc2/httpeasy"
@@ -19,6 +23,7 @@ func TestCommentsService_Delete(t *testing.T) {
 	for _, testCase := range []struct {
 		name         string
 		state        testsupport.CommentsStoreFake
+		user         types.UserID
 		post         types.PostID
 		comment      types.CommentID
 		wantedStatus int
@@ -41,6 +46,7 @@ func TestCommentsService_Delete(t *testing.T) {
 					},
 				},
 			},
+			user:         "author",
 			post:         "post",
 			comment:      "id",
 			wantedStatus: http.StatusOK,
@@ -61,6 +67,34 @@ func TestCommentsService_Delete(t *testing.T) {
 				Body:     "greetings",
 			}},
 		},
+		{
+			name: "only the author can delete",
+			state: testsupport.CommentsStoreFake{
+				"post": {
+					"id": {
+						ID:       "id",
+						Post:     "post",
+						Author:   "author",
+						Created:  someTime,
+						Modified: someTime,
+						Body:     "greetings",
+					},
+				},
+			},
+			user:         "other",
+			post:         "post",
+			comment:      "id",
+			wantedStatus: http.StatusForbidden,
+			wantedBody:   ErrNotAuthor,
+			wantedState: []*types.Comment{{
+				ID:       "id",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime,
+				Modified: someTime,
+				Body:     "greetings",
+			}},
+		},
 		{
 			name:         "errors propagate",
 			state:        testsupport.CommentsStoreFake{},
@@ -84,6 +118,7 @@ func TestCommentsService_Delete(t *testing.T) {
 					"post-id":    string(testCase.post),
 					"comment-id": string(testCase.comment),
 				},
+				Headers: http.Header{"User": []string{string(testCase.user)}},
 			})
 			if rsp.Status != testCase.wantedStatus {
 				t.Fatalf(
@@ -118,6 +153,7 @@ func TestCommentsService_Update(t *testing.T) {
 	for _, testCase := range []struct {
 		name         string
 		state        testsupport.CommentsStoreFake
+		user         types.UserID
 		post         types.PostID
 		comment      types.CommentID
 		body         string
@@ -157,6 +193,35 @@ func TestCommentsService_Update(t *testing.T) {
 				Body:     "salutations",
 			}},
 		},
+		{
+			name: "only the author can edit",
+			state: testsupport.CommentsStoreFake{
+				"post": {
+					"id": {
+						ID:       "id",
+						Post:     "post",
+						Author:   "author",
+						Created:  someTime,
+						Modified: someTime,
+						Body:     "hello, world",
+					},
+				},
+			},
+			user:         "other",
+			post:         "post",
+			comment:      "id",
+			body:         `{"body": "salutations"}`,
+			wantedStatus: http.StatusForbidden,
+			wantedBody:   ErrNotAuthor,
+			wantedState: []*types.Comment{{
+				ID:       "id",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime,
+				Modified: someTime,
+				Body:     "hello, world",
+			}},
+		},
 		{
 			// test that unmarshal errors are handled correctly.
 			name:         "unmarshal error",
@@ -183,6 +248,9 @@ func TestCommentsService_Update(t *testing.T) {
 		},
 	} {
 		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.user == "" {
+				testCase.user = "author"
+			}
 			service := CommentsService{
 				Comments: CommentsModel{
 					CommentsStore: testCase.state,
@@ -196,7 +264,8 @@ func TestCommentsService_Update(t *testing.T) {
 					"post-id":    string(testCase.post),
 					"comment-id": string(testCase.comment),
 				},
-				Body: strings.NewReader(testCase.body),
+				Headers: http.Header{"User": []string{string(testCase.user)}},
+				Body:    strings.NewReader(testCase.body),
 			})
 			if rsp.Status != testCase.wantedStatus {
 				t.Fatalf(
@@ -221,6 +290,238 @@ func TestCommentsService_Update(t *testing.T) {
 	}
 }
 
+func TestCommentsService_Replies(t *testing.T) {
+	state := testsupport.CommentsStoreFake{
+		"post": {
+			"a": {
+				ID:       "a",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime,
+				Modified: someTime,
+				Body:     "body",
+			},
+			"b": {
+				ID:       "b",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime.Add(time.Hour),
+				Modified: someTime.Add(time.Hour),
+				Body:     "body",
+			},
+		},
+	}
+
+	for _, testCase := range []struct {
+		name         string
+		query        string
+		wantedStatus int
+		wantedIDs    []types.CommentID
+		wantedLink   string
+	}{
+		{
+			name:         "first page",
+			query:        "limit=1",
+			wantedStatus: http.StatusOK,
+			wantedIDs:    []types.CommentID{"a"},
+			wantedLink: fmt.Sprintf(
+				"</api/posts/post/comments/toplevel/replies?%s>; rel=\"next\"",
+				url.Values{
+					"cursor": []string{types.CursorFor(
+						types.SortOldest,
+						state["post"]["a"],
+					).String()},
+					"limit": []string{"1"},
+				}.Encode(),
+			),
+		},
+		{
+			name: "last page",
+			query: url.Values{
+				"cursor": []string{
+					types.CursorFor(types.SortOldest, state["post"]["a"]).String(),
+				},
+				"limit": []string{"1"},
+			}.Encode(),
+			wantedStatus: http.StatusOK,
+			wantedIDs:    []types.CommentID{"b"},
+		},
+		{
+			name:         "newest first",
+			query:        "limit=1&sort=newest",
+			wantedStatus: http.StatusOK,
+			wantedIDs:    []types.CommentID{"b"},
+			wantedLink: fmt.Sprintf(
+				"</api/posts/post/comments/toplevel/replies?%s>; rel=\"next\"",
+				url.Values{
+					"cursor": []string{
+						types.CursorFor(types.SortNewest, state["post"]["b"]).String(),
+					},
+					"limit": []string{"1"},
+					"sort":  []string{"newest"},
+				}.Encode(),
+			),
+		},
+		{
+			name:         "invalid cursor",
+			query:        "cursor=garbage",
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "cursor from another sort",
+			query: url.Values{
+				"cursor": []string{
+					types.CursorFor(types.SortOldest, state["post"]["a"]).String(),
+				},
+				"sort": []string{"newest"},
+			}.Encode(),
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid sort",
+			query:        "sort=sideways",
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid limit",
+			query:        "limit=ten",
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid format",
+			query:        "format=xml",
+			wantedStatus: http.StatusBadRequest,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			service := CommentsService{
+				Comments: CommentsModel{
+					CommentsStore: state,
+					TimeFunc:      func() time.Time { return now },
+				},
+				TimeFunc: func() time.Time { return now },
+			}
+			rsp := service.Replies(pz.Request{
+				Vars: map[string]string{
+					"post-id":    "post",
+					"comment-id": "toplevel",
+				},
+				URL: &url.URL{
+					Path:     "/api/posts/post/comments/toplevel/replies",
+					RawQuery: testCase.query,
+				},
+			})
+			if rsp.Status != testCase.wantedStatus {
+				t.Fatalf(
+					"HTTP Status: wanted `%d`; found `%d`",
+					testCase.wantedStatus,
+					rsp.Status,
+				)
+			}
+			if rsp.Status != http.StatusOK {
+				return
+			}
+
+			if link := rsp.Headers.Get("Link"); link != testCase.wantedLink {
+				t.Fatalf(
+					"Link header: wanted `%s`; found `%s`",
+					testCase.wantedLink,
+					link,
+				)
+			}
+
+			data, err := readAll(rsp.Data)
+			if err != nil {
+				t.Fatalf("Response.Data: reading serializer: %v", err)
+			}
+			var comments []*types.Comment
+			if err := json.Unmarshal(data, &comments); err != nil {
+				t.Fatalf("Response.Data: unmarshaling: %v", err)
+			}
+			var ids []types.CommentID
+			for _, c := range comments {
+				ids = append(ids, c.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(testCase.wantedIDs) {
+				t.Fatalf(
+					"comment IDs: wanted `%v`; found `%v`",
+					testCase.wantedIDs,
+					ids,
+				)
+			}
+		})
+	}
+}
+
+func TestCommentsService_Replies_Tree(t *testing.T) {
+	comment := func(id, parent types.CommentID, hours int) *types.Comment {
+		return &types.Comment{
+			ID:       id,
+			Post:     "post",
+			Parent:   parent,
+			Author:   "author",
+			Created:  someTime.Add(time.Duration(hours) * time.Hour),
+			Modified: someTime.Add(time.Duration(hours) * time.Hour),
+			Body:     "body",
+		}
+	}
+	service := CommentsService{
+		Comments: CommentsModel{
+			CommentsStore: testsupport.CommentsStoreFake{"post": {
+				"a":   comment("a", "", 0),
+				"a1":  comment("a1", "a", 1),
+				"a2":  comment("a2", "a", 2),
+				"a1a": comment("a1a", "a1", 3),
+				"b":   comment("b", "", 4),
+			}},
+			TimeFunc: func() time.Time { return now },
+		},
+		TimeFunc: func() time.Time { return now },
+	}
+	rsp := service.Replies(pz.Request{
+		Vars: map[string]string{"post-id": "post", "comment-id": "toplevel"},
+		URL: &url.URL{
+			Path:     "/api/posts/post/comments/toplevel/replies",
+			RawQuery: "format=tree",
+		},
+	})
+	if rsp.Status != http.StatusOK {
+		t.Fatalf("HTTP Status: wanted `200`; found `%d`", rsp.Status)
+	}
+
+	data, err := readAll(rsp.Data)
+	if err != nil {
+		t.Fatalf("Response.Data: reading serializer: %v", err)
+	}
+	type node struct {
+		ID         types.CommentID `json:"id"`
+		ReplyCount int             `json:"replyCount"`
+		Children   []node          `json:"children"`
+	}
+	var nodes []node
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		t.Fatalf("Response.Data: unmarshaling: %v", err)
+	}
+	var format func(nodes []node) string
+	format = func(nodes []node) string {
+		parts := make([]string, len(nodes))
+		for i, n := range nodes {
+			parts[i] = fmt.Sprintf(
+				"%s(%d)%s",
+				n.ID,
+				n.ReplyCount,
+				format(n.Children),
+			)
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	}
+
+	wanted := "[a(2)[a1(1)[a1a(0)[]] a2(0)[]] b(0)[]]"
+	if found := format(nodes); found != wanted {
+		t.Fatalf("tree: wanted `%s`; found `%s`", wanted, found)
+	}
+}
+
 func TestCommentsService_Put(t *testing.T) {
 	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
 	for _, testCase := range []struct {
@@ -242,6 +543,7 @@ func TestCommentsService_Put(t *testing.T) {
 				Modified: now,
 				Deleted:  false,
 				Body:     "great comment",
+				Status:   types.StatusApproved,
 			},
 		},
 		{
@@ -269,6 +571,7 @@ func TestCommentsService_Put(t *testing.T) {
 				Created:  now,
 				Modified: now,
 				Body:     "great comment",
+				Status:   types.StatusApproved,
 			},
 		},
 		{
@@ -344,3 +647,362 @@ func readAll(s pz.Serializer) ([]byte, error) {
 type WantedData interface {
 	CompareData([]byte) error
 }
+
+func TestCommentsService_Vote(t *testing.T) {
+	for _, testCase := range []struct {
+		name         string
+		body         string
+		wantedStatus int
+		wantedScore  int
+	}{
+		{
+			name:         "upvote",
+			body:         `{"value": 1}`,
+			wantedStatus: http.StatusOK,
+			wantedScore:  1,
+		},
+		{
+			name:         "invalid value",
+			body:         `{"value": 0}`,
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "malformed json",
+			body:         `{"value":`,
+			wantedStatus: http.StatusBadRequest,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			votes := votesStoreFake(nil)
+			service := CommentsService{
+				Comments: CommentsModel{
+					CommentsStore: votes.Comments,
+					VotesStore:    votes,
+					TimeFunc:      func() time.Time { return now },
+				},
+				TimeFunc: func() time.Time { return now },
+			}
+			rsp := service.Vote(pz.Request{
+				Vars: map[string]string{
+					"post-id":    "post",
+					"comment-id": "comment",
+				},
+				Headers: http.Header{"User": []string{"user"}},
+				Body:    strings.NewReader(testCase.body),
+			})
+			if rsp.Status != testCase.wantedStatus {
+				t.Fatalf(
+					"status: wanted `%d`; found `%d`",
+					testCase.wantedStatus,
+					rsp.Status,
+				)
+			}
+			if rsp.Status != http.StatusOK {
+				return
+			}
+
+			data, err := readAll(rsp.Data)
+			if err != nil {
+				t.Fatalf("Response.Data: reading serializer: %v", err)
+			}
+			var comment types.Comment
+			if err := json.Unmarshal(data, &comment); err != nil {
+				t.Fatalf("Response.Data: unmarshaling: %v", err)
+			}
+			if comment.Score != testCase.wantedScore {
+				t.Fatalf(
+					"Comment.Score: wanted `%d`; found `%d`",
+					testCase.wantedScore,
+					comment.Score,
+				)
+			}
+		})
+	}
+}
+
+func TestCommentsService_Moderate(t *testing.T) {
+	for _, testCase := range []struct {
+		name         string
+		user         string
+		handler      func(*CommentsService) pz.Handler
+		wantedStatus int
+		wantedState  types.Status
+	}{
+		{
+			name:         "approve",
+			user:         "moderator",
+			handler:      func(cs *CommentsService) pz.Handler { return cs.Approve },
+			wantedStatus: http.StatusOK,
+			wantedState:  types.StatusApproved,
+		},
+		{
+			name:         "reject",
+			user:         "moderator",
+			handler:      func(cs *CommentsService) pz.Handler { return cs.Reject },
+			wantedStatus: http.StatusOK,
+			wantedState:  types.StatusRejected,
+		},
+		{
+			name:         "not a moderator",
+			user:         "author",
+			handler:      func(cs *CommentsService) pz.Handler { return cs.Approve },
+			wantedStatus: http.StatusForbidden,
+			wantedState:  types.StatusPending,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			state := moderationState()
+			service := CommentsService{
+				Comments: CommentsModel{
+					CommentsStore: state,
+					Roles:         testsupport.RolesStoreFake{"moderator": types.RoleModerator},
+					TimeFunc:      func() time.Time { return now },
+				},
+				TimeFunc: func() time.Time { return now },
+			}
+			rsp := testCase.handler(&service)(pz.Request{
+				Vars: map[string]string{
+					"post-id":    "post",
+					"comment-id": "pending",
+				},
+				Headers: http.Header{"User": []string{testCase.user}},
+			})
+			if rsp.Status != testCase.wantedStatus {
+				t.Fatalf(
+					"status: wanted `%d`; found `%d`",
+					testCase.wantedStatus,
+					rsp.Status,
+				)
+			}
+			found := state["post"]["pending"].Status
+			if found != testCase.wantedState {
+				t.Fatalf(
+					"Comment.Status: wanted `%s`; found `%s`",
+					testCase.wantedState,
+					found,
+				)
+			}
+		})
+	}
+}
+
+func TestCommentsService_Get(t *testing.T) {
+	for _, testCase := range []struct {
+		name         string
+		user         types.UserID
+		comment      types.CommentID
+		wantedStatus int
+	}{
+		{
+			name:         "approved",
+			comment:      "approved",
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "pending is hidden",
+			comment:      "pending",
+			wantedStatus: http.StatusNotFound,
+		},
+		{
+			name:         "pending is hidden from other users",
+			user:         "other",
+			comment:      "pending",
+			wantedStatus: http.StatusNotFound,
+		},
+		{
+			name:         "replies to pending are hidden",
+			comment:      "pending-child",
+			wantedStatus: http.StatusNotFound,
+		},
+		{
+			name:         "authors see their pending comments",
+			user:         "author",
+			comment:      "pending",
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "moderators see pending",
+			user:         "moderator",
+			comment:      "pending-child",
+			wantedStatus: http.StatusOK,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			service := CommentsService{
+				Comments: CommentsModel{
+					CommentsStore: moderationState(),
+					Roles: testsupport.RolesStoreFake{
+						"moderator": types.RoleModerator,
+					},
+					TimeFunc: func() time.Time { return now },
+				},
+				TimeFunc: func() time.Time { return now },
+			}
+
+			w := httptest.NewRecorder()
+			apiHandler(&service, testCase.user).ServeHTTP(
+				w,
+				httptest.NewRequest(
+					"GET",
+					"/api/posts/post/comments/"+string(testCase.comment),
+					nil,
+				),
+			)
+			if w.Code != testCase.wantedStatus {
+				t.Fatalf(
+					"HTTP Status: wanted `%d`; found `%d`: %s",
+					testCase.wantedStatus,
+					w.Code,
+					w.Body.String(),
+				)
+			}
+		})
+	}
+}
+
+// TestCommentsService_SpoofedUser checks that a client can't impersonate
+// another user by sending its own `User` header: the authenticator only adds
+// the authenticated user to the header, so `StripUserHeader()` deletes the
+// client's.
+func TestCommentsService_SpoofedUser(t *testing.T) {
+	for _, testCase := range []struct {
+		name         string
+		user         types.UserID
+		forged       types.UserID
+		method       string
+		path         string
+		body         string
+		wantedStatus int
+		unwanted     []string
+	}{
+		{
+			name:         "forged moderator can't edit others' comments",
+			user:         "other",
+			forged:       "moderator",
+			method:       "PATCH",
+			path:         "/api/posts/post/comments/approved",
+			body:         `{"body": "salutations"}`,
+			wantedStatus: http.StatusForbidden,
+		},
+		{
+			name:         "forged author can't edit",
+			user:         "other",
+			forged:       "author",
+			method:       "PATCH",
+			path:         "/api/posts/post/comments/approved",
+			body:         `{"body": "salutations"}`,
+			wantedStatus: http.StatusForbidden,
+		},
+		{
+			name:         "forged author can't delete",
+			user:         "other",
+			forged:       "author",
+			method:       "DELETE",
+			path:         "/api/posts/post/comments/approved",
+			wantedStatus: http.StatusForbidden,
+		},
+		{
+			name:         "forged moderator can't view the queue",
+			user:         "other",
+			forged:       "moderator",
+			method:       "GET",
+			path:         "/api/moderation/queue",
+			wantedStatus: http.StatusForbidden,
+		},
+		{
+			name:         "anonymous forged moderator can't see pending",
+			forged:       "moderator",
+			method:       "GET",
+			path:         "/api/posts/post/comments/toplevel/replies",
+			wantedStatus: http.StatusOK,
+			unwanted:     []string{`"pending"`, `"rejected"`},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			state := moderationState()
+			service := CommentsService{
+				Comments: CommentsModel{
+					CommentsStore: state,
+					Roles: testsupport.RolesStoreFake{
+						"moderator": types.RoleModerator,
+					},
+					TimeFunc: func() time.Time { return now },
+				},
+				TimeFunc: func() time.Time { return now },
+			}
+
+			r := httptest.NewRequest(
+				testCase.method,
+				testCase.path,
+				strings.NewReader(testCase.body),
+			)
+			r.Header.Set("Content-Length", fmt.Sprint(len(testCase.body)))
+			r.Header.Set("User", string(testCase.forged))
+			w := httptest.NewRecorder()
+			apiHandler(&service, testCase.user).ServeHTTP(w, r)
+
+			if w.Code != testCase.wantedStatus {
+				t.Fatalf(
+					"HTTP Status: wanted `%d`; found `%d`: %s",
+					testCase.wantedStatus,
+					w.Code,
+					w.Body.String(),
+				)
+			}
+			for _, unwanted := range testCase.unwanted {
+				if strings.Contains(w.Body.String(), unwanted) {
+					t.Fatalf(
+						"unwanted `%s` found in body: %s",
+						unwanted,
+						w.Body.String(),
+					)
+				}
+			}
+			if err := state.Contains(
+				moderationState()["post"]["approved"],
+			); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// apiHandler serves the service's routes the way the `comments` command
+// does, authenticating every request as `user` (or failing to authenticate if
+// `user` is empty).
+func apiHandler(service *CommentsService, user types.UserID) http.Handler {
+	result := client.ResultErr("unauthenticated", errors.New("no token"))
+	if user != "" {
+		result = client.ResultOK("authenticated", string(user))
+	}
+	authType := client.ConstantAuthType(result)
+	var a client.Authenticator
+	return StripUserHeader(pz.Register(
+		func(interface{}) {},
+		pz.Route{
+			Method:  "GET",
+			Path:    "/api/posts/{post-id}/comments/{comment-id}/replies",
+			Handler: a.Optional(authType, service.Replies),
+		},
+		pz.Route{
+			Method:  "GET",
+			Path:    "/api/posts/{post-id}/comments/{comment-id}",
+			Handler: a.Optional(authType, service.Get),
+		},
+		pz.Route{
+			Method:  "PATCH",
+			Path:    "/api/posts/{post-id}/comments/{comment-id}",
+			Handler: a.Auth(authType, service.Update),
+		},
+		pz.Route{
+			Method:  "DELETE",
+			Path:    "/api/posts/{post-id}/comments/{comment-id}",
+			Handler: a.Auth(authType, service.Delete),
+		},
+		pz.Route{
+			Method:  "GET",
+			Path:    "/api/moderation/queue",
+			Handler: a.Auth(authType, service.Queue),
+		},
+	))
+}
@@ -0,0 +1,88 @@
+package comments
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/url"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+const (
+	// csrfField is the name of the form field which holds the CSRF token.
+	csrfField = "csrf"
+
+	// sessionCookie is the cookie which identifies the user's session. The
+	// refresh token is used (rather than the access token) because it's
+	// stable for the lifetime of the session while the access token is
+	// periodically refreshed.
+	sessionCookie = "Refresh-Token"
+
+	// formSizeMax is the maximum number of bytes read from a form submission
+	// while checking its CSRF token.
+	formSizeMax = 1 << 16
+)
+
+var ErrInvalidCSRFToken = &pz.HTTPError{
+	Status:  403,
+	Message: "missing or invalid csrf token",
+}
+
+// CSRF issues and checks the tokens which protect forms against cross-site
+// request forgery. A token is an HMAC of the session cookie, so it's valid for
+// the lifetime of the session and useless to any other session.
+type CSRF struct {
+	Key []byte
+}
+
+// Token returns the CSRF token for the request's session. It returns an empty
+// string if the request has no session or if `c` is nil.
+func (c *CSRF) Token(r pz.Request) string {
+	if c == nil {
+		return ""
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil || cookie.Value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write([]byte(cookie.Value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Valid reports whether `token` is the CSRF token for the request's session.
+func (c *CSRF) Valid(r pz.Request, token string) bool {
+	wanted := c.Token(r)
+	return wanted != "" && hmac.Equal([]byte(wanted), []byte(token))
+}
+
+// Protect wraps a form handler such that submissions without a valid CSRF
+// token in their `csrf` field are rejected. The wrapped handler receives the
+// form submission unchanged.
+func (c *CSRF) Protect(h pz.Handler) pz.Handler {
+	return func(r pz.Request) pz.Response {
+		var data []byte
+		if r.Body != nil {
+			var err error
+			data, err = ioutil.ReadAll(io.LimitReader(r.Body, formSizeMax))
+			if err != nil {
+				return pz.HandleError("reading request body", err)
+			}
+		}
+
+		values, err := url.ParseQuery(string(data))
+		if err != nil || !c.Valid(r, values.Get(csrfField)) {
+			return pz.HandleError(
+				"validating csrf token",
+				ErrInvalidCSRFToken,
+			)
+		}
+
+		r.Body = bytes.NewReader(data)
+		return h(r)
+	}
+}
@@ -0,0 +1,167 @@
+package comments
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/weberc2/auth/pkg/client"
+	"github.com/weberc2/comments/pkg/comments/testsupport"
+	pz "github.com/weberc2/httpeasy"
+)
+
+func sessionRequest(session, body string) pz.Request {
+	headers := http.Header{}
+	if session != "" {
+		headers.Set("Cookie", sessionCookie+"="+session)
+	}
+	return pz.Request{Headers: headers, Body: strings.NewReader(body)}
+}
+
+func TestCSRF_Token(t *testing.T) {
+	csrf := CSRF{Key: []byte("key")}
+	token := csrf.Token(sessionRequest("session", ""))
+	if token == "" {
+		t.Fatal("wanted a token for a session; found none")
+	}
+	if found := csrf.Token(sessionRequest("session", "")); found != token {
+		t.Fatalf("wanted a stable token `%s`; found `%s`", token, found)
+	}
+	if csrf.Token(sessionRequest("other-session", "")) == token {
+		t.Fatal("wanted different sessions to have different tokens")
+	}
+	other := CSRF{Key: []byte("other-key")}
+	if other.Token(sessionRequest("session", "")) == token {
+		t.Fatal("wanted different keys to issue different tokens")
+	}
+	if found := csrf.Token(sessionRequest("", "")); found != "" {
+		t.Fatalf("wanted no token without a session; found `%s`", found)
+	}
+	if found := (*CSRF)(nil).Token(sessionRequest("session", "")); found != "" {
+		t.Fatalf("wanted no token from nil `CSRF`; found `%s`", found)
+	}
+}
+
+func TestCSRF_Protect(t *testing.T) {
+	csrf := CSRF{Key: []byte("key")}
+	token := csrf.Token(sessionRequest("session", ""))
+
+	for _, testCase := range []struct {
+		name         string
+		session      string
+		body         string
+		wantedStatus int
+	}{
+		{
+			name:         "valid token",
+			session:      "session",
+			body:         url.Values{"csrf": {token}, "body": {"hi"}}.Encode(),
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name:         "missing token",
+			session:      "session",
+			body:         url.Values{"body": {"hi"}}.Encode(),
+			wantedStatus: http.StatusForbidden,
+		},
+		{
+			name:         "invalid token",
+			session:      "session",
+			body:         url.Values{"csrf": {"garbage"}}.Encode(),
+			wantedStatus: http.StatusForbidden,
+		},
+		{
+			name:         "token from another session",
+			session:      "other-session",
+			body:         url.Values{"csrf": {token}}.Encode(),
+			wantedStatus: http.StatusForbidden,
+		},
+		{
+			name:         "no session",
+			body:         url.Values{"csrf": {""}}.Encode(),
+			wantedStatus: http.StatusForbidden,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			var body string
+			rsp := csrf.Protect(func(r pz.Request) pz.Response {
+				data, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatalf("reading body: %v", err)
+				}
+				body = string(data)
+				return pz.Ok(nil)
+			})(sessionRequest(testCase.session, testCase.body))
+
+			if rsp.Status != testCase.wantedStatus {
+				t.Fatalf(
+					"Response.Status: wanted `%d`; found `%d`",
+					testCase.wantedStatus,
+					rsp.Status,
+				)
+			}
+			if rsp.Status == http.StatusOK && body != testCase.body {
+				t.Fatalf(
+					"handler body: wanted `%s`; found `%s`",
+					testCase.body,
+					body,
+				)
+			}
+		})
+	}
+}
+
+func TestAuthWebServer_CSRF(t *testing.T) {
+	csrf := &CSRF{Key: []byte("key")}
+	aws := AuthWebServer{
+		WebServer: WebServer{
+			BaseURL: "https://comments.example.org",
+			Comments: CommentsModel{
+				CommentsStore: testsupport.CommentsStoreFake{},
+			},
+			CSRF: csrf,
+		},
+		AuthType: client.ConstantAuthType(client.ResultOK("OK", "adam")),
+	}
+
+	for _, route := range []func(*AuthWebServer) pz.Route{
+		(*AuthWebServer).DeleteRoute,
+		(*AuthWebServer).ReplyRoute,
+		(*AuthWebServer).VoteRoute,
+		(*AuthWebServer).EditRoute,
+	} {
+		r := route(&aws)
+		if r.Method != "POST" {
+			t.Fatalf("%s: wanted `POST`; found `%s`", r.Path, r.Method)
+		}
+		rsp := r.Handler(sessionRequest("session", "body=hi"))
+		if rsp.Status != http.StatusForbidden {
+			t.Fatalf(
+				"%s: wanted `403` without a csrf token; found `%d`",
+				r.Path,
+				rsp.Status,
+			)
+		}
+	}
+
+	// the forms embed the session's token
+	rsp := aws.ReplyFormRoute().Handler(pz.Request{
+		Vars: map[string]string{"post-id": "post", "comment-id": "toplevel"},
+		Headers: http.Header{
+			"Cookie": []string{sessionCookie + "=session"},
+		},
+	})
+	data, err := readAll(rsp.Data)
+	if err != nil {
+		t.Fatalf("Response.Data: %v", err)
+	}
+	token := csrf.Token(sessionRequest("session", ""))
+	if wanted := `value="` + token + `"`; !strings.Contains(
+		string(data),
+		wanted,
+	) {
+		t.Fatalf("Response.Data: missing `%s`:\n%s", wanted, data)
+	}
+}
@@ -0,0 +1,226 @@
+package comments
+
+import (
+	"fmt"
+	html "html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+// embedTemplate is the replies page's embed mode: the replies without the
+// page's `<html>` chrome, for rendering in an iframe created by the embed
+// loader (see `WebServer.EmbedLoader()`). Links and forms navigate the top
+// window since the service's cookies (and so logins) may not be available
+// to the iframe. If the parent's origin is allowed, the page reports its
+// height to the parent so that the iframe can be sized to fit.
+var embedTemplate = html.Must(html.Must(repliesTemplate.Clone()).Parse(`
+<base target="_top">
+<style>
+body {
+	margin: 0;
+	display: flow-root;
+}
+.comment {
+	border: 1px solid black;
+	margin: 1em 0em 1em 1em;
+	padding: 1em 0em 1em 1em;
+}
+.comment-children {
+	padding-left: 1em;
+}
+</style>
+<a href="{{.BaseURL}}/posts/{{.Post}}/comments/toplevel/reply">
+	Reply To Post
+</a>
+<div id=replies data-parent="{{.Parent}}" data-sort="{{.Sort}}" data-events="{{.EventsURL}}" data-embed-origin="{{.EmbedOrigin}}">
+{{if .User}}
+    {{.User}} - <a href="{{.LogoutURL}}">logout</a>
+{{else}}
+    <a href="{{.LoginURL}}">login</a>
+	<a href="{{.RegisterURL}}">register</a>
+{{end}}
+
+{{range .Replies}}
+	{{template "comment" .}}
+{{end}}
+{{if .Next}}
+<a class="load-more" href="{{.Next}}" target="_self">load more</a>
+{{end}}
+</div>
+{{template "live" .}}
+{{if .EmbedOrigin}}
+<script>
+(function () {
+	var origin = document.getElementById("replies").
+		getAttribute("data-embed-origin");
+	var height = 0;
+	function resize() {
+		if (document.body.offsetHeight === height) {
+			return;
+		}
+		height = document.body.offsetHeight;
+		window.parent.postMessage(
+			{type: "comments:resize", height: height},
+			origin
+		);
+	}
+	window.addEventListener("load", resize);
+	if (window.ResizeObserver) {
+		new ResizeObserver(resize).observe(document.body);
+	} else {
+		setInterval(resize, 500);
+	}
+	resize();
+})();
+</script>
+{{end}}`))
+
+// embedLoader is the embed loader (see `WebServer.EmbedLoader()`).
+var embedLoader = template.Must(template.New("").Parse(`// Embeds comments:
+//
+//     <script src="{{js .BaseURL}}/embed.js" data-post="POST-ID"></script>
+//
+// The replies are rendered in an iframe after the script element, and the
+// iframe is resized to fit them.
+(function () {
+	var base = "{{js .BaseURL}}";
+	var script = document.currentScript;
+	var post = script && script.getAttribute("data-post");
+	if (!post) {
+		return;
+	}
+
+	var iframe = document.createElement("iframe");
+	iframe.src = base + "/posts/" + encodeURIComponent(post) +
+		"/comments/toplevel/replies?embed=true&origin=" +
+		encodeURIComponent(window.location.origin);
+	iframe.title = "Comments";
+	iframe.setAttribute("scrolling", "no");
+	iframe.style.width = "100%";
+	iframe.style.border = "none";
+	script.parentNode.insertBefore(iframe, script.nextSibling);
+
+	var origin = new URL(base).origin;
+	window.addEventListener("message", function (event) {
+		if (event.origin !== origin || event.source !== iframe.contentWindow) {
+			return;
+		}
+		var data = event.data;
+		if (data && data.type === "comments:resize" &&
+			typeof data.height === "number") {
+			iframe.style.height = data.height + "px";
+		}
+	});
+})();
+`))
+
+// EmbedLoader serves the script which embeds a post's replies in another
+// site's pages. The post is identified by the script element's `data-post`
+// attribute. Any site can load the script, but browsers only render the
+// embed mode in sites whose origins are among the `WebServer`'s
+// `EmbedOrigins` (see `frameAncestors()`).
+func (ws *WebServer) EmbedLoader(r pz.Request) pz.Response {
+	var sb strings.Builder
+	if err := embedLoader.Execute(&sb, struct {
+		BaseURL string
+	}{strings.TrimRight(ws.BaseURL, "/")}); err != nil {
+		return pz.InternalServerError(&logging{Error: err.Error()})
+	}
+	return pz.Ok(pz.String(sb.String())).WithHeaders(http.Header{
+		"Content-Type":  []string{"text/javascript; charset=utf-8"},
+		"Cache-Control": []string{"public, max-age=3600"},
+	})
+}
+
+// embedding reports whether the request is for the replies page's embed
+// mode.
+func embedding(r pz.Request) bool {
+	return queryValues(r).Get("embed") == "true"
+}
+
+// embedOrigin returns the request's `origin` parameter (the embedding page's
+// origin) if it's one of the `EmbedOrigins`, or else an empty string.
+func (ws *WebServer) embedOrigin(r pz.Request) string {
+	origin := queryValues(r).Get("origin")
+	for _, allowed := range ws.EmbedOrigins {
+		if origin == allowed {
+			return origin
+		}
+	}
+	return ""
+}
+
+// frameAncestorsSelf is the `Content-Security-Policy` which only allows the
+// service itself to frame a page.
+const frameAncestorsSelf = "frame-ancestors 'self'"
+
+// frameAncestors returns the `Content-Security-Policy` which only allows the
+// `EmbedOrigins` (and the service itself) to frame the embed mode.
+func (ws *WebServer) frameAncestors() string {
+	return strings.Join(
+		append([]string{frameAncestorsSelf}, ws.EmbedOrigins...),
+		" ",
+	)
+}
+
+// FrameAncestors wraps a handler such that its responses may only be framed
+// by the service itself (so other sites can't, e.g., clickjack its forms),
+// unless they set their own `Content-Security-Policy` like the replies page's
+// embed mode does.
+func FrameAncestors(h pz.Handler) pz.Handler {
+	return func(r pz.Request) pz.Response {
+		rsp := h(r)
+		if rsp.Headers == nil {
+			rsp.Headers = http.Header{}
+		}
+		if rsp.Headers.Get("Content-Security-Policy") == "" {
+			rsp.Headers.Set("Content-Security-Policy", frameAncestorsSelf)
+		}
+		return rsp
+	}
+}
+
+// frameAncestorsRoutes wraps every route's handler with `FrameAncestors()`.
+func frameAncestorsRoutes(routes []pz.Route) []pz.Route {
+	for i := range routes {
+		routes[i].Handler = FrameAncestors(routes[i].Handler)
+	}
+	return routes
+}
+
+// ParseOrigins parses a comma-separated list of origins (e.g.,
+// `https://blog.example.org,https://example.org`), normalizing each to
+// `scheme://host[:port]`.
+func ParseOrigins(s string) ([]string, error) {
+	var origins []string
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		u, err := url.Parse(field)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			u.Hostname() == "" || u.User != nil || u.Opaque != "" ||
+			(u.Path != "" && u.Path != "/") || u.RawQuery != "" ||
+			u.Fragment != "" {
+			return nil, fmt.Errorf("invalid origin: %q", field)
+		}
+		origins = append(
+			origins,
+			u.Scheme+"://"+strings.ToLower(u.Host),
+		)
+	}
+	return origins, nil
+}
+
+func (ws *WebServer) EmbedLoaderRoute() pz.Route {
+	return pz.Route{
+		Method:  "GET",
+		Path:    "/embed.js",
+		Handler: ws.EmbedLoader,
+	}
+}
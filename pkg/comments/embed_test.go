@@ -0,0 +1,209 @@
+package comments
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+func TestWebServer_Replies_Embed(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		query     string
+		wantedCSP string
+		wanted    []string
+		unwanted  []string
+	}{
+		{
+			name:      "allowed origin",
+			query:     "embed=true&origin=https%3A%2F%2Fblog.example.org",
+			wantedCSP: "frame-ancestors 'self' https://blog.example.org",
+			wanted: []string{
+				`data-embed-origin="https://blog.example.org"`,
+				"comments:resize",
+				`id="approved"`,
+			},
+			unwanted: []string{"<html>", "<body>"},
+		},
+		{
+			name:      "other origin",
+			query:     "embed=true&origin=https%3A%2F%2Fevil.example.org",
+			wantedCSP: "frame-ancestors 'self' https://blog.example.org",
+			wanted:    []string{`id="approved"`},
+			unwanted:  []string{"<html>", "comments:resize"},
+		},
+		{
+			name:      "full page",
+			wantedCSP: "frame-ancestors 'self'",
+			wanted:    []string{"<html>", `id="approved"`},
+			unwanted:  []string{"comments:resize"},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			webServer := WebServer{
+				BaseURL: "https://comments.example.org",
+				Comments: CommentsModel{
+					CommentsStore: moderationState(),
+					TimeFunc:      func() time.Time { return now },
+				},
+				EmbedOrigins: []string{"https://blog.example.org"},
+			}
+			rsp := webServer.Replies(pz.Request{
+				Vars: map[string]string{
+					"post-id":    "post",
+					"comment-id": "toplevel",
+				},
+				URL:     &url.URL{RawQuery: testCase.query},
+				Headers: http.Header{},
+			})
+			if rsp.Status != http.StatusOK {
+				t.Fatalf(
+					"Response.Status: wanted `200`; found `%d`",
+					rsp.Status,
+				)
+			}
+			csp := rsp.Headers.Get("Content-Security-Policy")
+			if csp != testCase.wantedCSP {
+				t.Fatalf(
+					"Content-Security-Policy: wanted `%s`; found `%s`",
+					testCase.wantedCSP,
+					csp,
+				)
+			}
+
+			data, err := readAll(rsp.Data)
+			if err != nil {
+				t.Fatalf("Response.Data: %v", err)
+			}
+			for _, wanted := range testCase.wanted {
+				if !strings.Contains(string(data), wanted) {
+					t.Fatalf("Response.Data: missing `%s`:\n%s", wanted, data)
+				}
+			}
+			for _, unwanted := range testCase.unwanted {
+				if strings.Contains(string(data), unwanted) {
+					t.Fatalf(
+						"Response.Data: unexpected `%s`:\n%s",
+						unwanted,
+						data,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestFrameAncestors(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		handler   pz.Handler
+		wantedCSP string
+	}{
+		{
+			name: "no policy",
+			handler: func(pz.Request) pz.Response {
+				return pz.Ok(pz.String("hello"))
+			},
+			wantedCSP: "frame-ancestors 'self'",
+		},
+		{
+			name: "error",
+			handler: func(pz.Request) pz.Response {
+				return pz.NotFound(nil)
+			},
+			wantedCSP: "frame-ancestors 'self'",
+		},
+		{
+			name: "own policy",
+			handler: func(pz.Request) pz.Response {
+				return pz.Ok(pz.String("hello")).WithHeaders(http.Header{
+					"Content-Security-Policy": []string{
+						"frame-ancestors 'self' https://blog.example.org",
+					},
+				})
+			},
+			wantedCSP: "frame-ancestors 'self' https://blog.example.org",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			rsp := FrameAncestors(testCase.handler)(pz.Request{})
+			csp := rsp.Headers.Get("Content-Security-Policy")
+			if csp != testCase.wantedCSP {
+				t.Fatalf(
+					"Content-Security-Policy: wanted `%s`; found `%s`",
+					testCase.wantedCSP,
+					csp,
+				)
+			}
+		})
+	}
+}
+
+func TestWebServer_EmbedLoader(t *testing.T) {
+	webServer := WebServer{BaseURL: "https://comments.example.org/"}
+	rsp := webServer.EmbedLoader(pz.Request{})
+	if rsp.Status != http.StatusOK {
+		t.Fatalf("Response.Status: wanted `200`; found `%d`", rsp.Status)
+	}
+	if found := rsp.Headers.Get("Content-Type"); !strings.HasPrefix(
+		found,
+		"text/javascript",
+	) {
+		t.Fatalf("Content-Type: wanted `text/javascript`; found `%s`", found)
+	}
+	data, err := readAll(rsp.Data)
+	if err != nil {
+		t.Fatalf("Response.Data: %v", err)
+	}
+	for _, wanted := range []string{
+		`var base = "https://comments.example.org";`,
+		`getAttribute("data-post")`,
+	} {
+		if !strings.Contains(string(data), wanted) {
+			t.Fatalf("Response.Data: missing `%s`:\n%s", wanted, data)
+		}
+	}
+}
+
+func TestParseOrigins(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		input     string
+		wanted    []string
+		wantedErr bool
+	}{
+		{name: "empty"},
+		{
+			name:  "several",
+			input: "https://blog.example.org, HTTP://Example.org:8080/,",
+			wanted: []string{
+				"https://blog.example.org",
+				"http://example.org:8080",
+			},
+		},
+		{name: "path", input: "https://example.org/blog", wantedErr: true},
+		{name: "scheme", input: "ftp://example.org", wantedErr: true},
+		{name: "no host", input: "https://", wantedErr: true},
+		{
+			name:      "csp injection",
+			input:     "https://example.org; script-src *",
+			wantedErr: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			found, err := ParseOrigins(testCase.input)
+			if (err != nil) != testCase.wantedErr {
+				t.Fatalf(
+					"wanted error: `%t`; found `%v`",
+					testCase.wantedErr,
+					err,
+				)
+			}
+			checkStrings(t, "origins", testCase.wanted, found)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Package events provides in-process `types.EventSink` implementations.
+package events
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// Bus fans events out to in-process subscribers. Publishing never blocks: a
+// subscriber whose buffer is full is closed rather than allowed to hold up
+// every other subscriber (and the relay). The zero value is ready to use.
+type Bus struct {
+	lock          sync.Mutex
+	subscriptions map[*Subscription]struct{}
+}
+
+// Subscription receives the events published to a `Bus` after it was
+// created. `Events` is closed when the subscription is closed, either by
+// `Close()` or by the bus because the subscriber fell behind.
+type Subscription struct {
+	Events <-chan *types.Event
+
+	bus    *Bus
+	events chan *types.Event
+}
+
+// Subscribe creates a subscription which buffers up to `size` events.
+func (b *Bus) Subscribe(size int) *Subscription {
+	events := make(chan *types.Event, size)
+	s := Subscription{Events: events, bus: b, events: events}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.subscriptions == nil {
+		b.subscriptions = map[*Subscription]struct{}{}
+	}
+	b.subscriptions[&s] = struct{}{}
+	return &s
+}
+
+// Close closes the subscription. It's safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.lock.Lock()
+	defer s.bus.lock.Unlock()
+	s.bus.remove(s)
+}
+
+// remove must be called with the lock held.
+func (b *Bus) remove(s *Subscription) {
+	if _, ok := b.subscriptions[s]; ok {
+		delete(b.subscriptions, s)
+		close(s.events)
+	}
+}
+
+// Publish sends the event to every subscriber. It implements
+// `types.EventSink` and never fails.
+func (b *Bus) Publish(e *types.Event) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for s := range b.subscriptions {
+		select {
+		case s.events <- e:
+		default:
+			b.remove(s)
+		}
+	}
+	return nil
+}
+
+// Sinks publishes each event to every sink in order. If a sink fails, the
+// remaining sinks are skipped and the event is published to every sink again
+// on retry, so each sink must tolerate duplicates (as it must anyway; see
+// `types.EventSink`).
+type Sinks []types.EventSink
+
+// Publish implements `types.EventSink`.
+func (sinks Sinks) Publish(e *types.Event) error {
+	for i, sink := range sinks {
+		if err := sink.Publish(e); err != nil {
+			return fmt.Errorf("publishing to sink %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+var (
+	// fail compilation if `Bus` or `Sinks` don't implement the
+	// `types.EventSink` interface.
+	_ types.EventSink = &Bus{}
+	_ types.EventSink = Sinks{}
+)
@@ -0,0 +1,78 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestBus(t *testing.T) {
+	var bus Bus
+	fast := bus.Subscribe(2)
+	slow := bus.Subscribe(1)
+	closed := bus.Subscribe(1)
+	closed.Close()
+	closed.Close() // idempotent
+
+	for id := int64(1); id <= 2; id++ {
+		if err := bus.Publish(&types.Event{ID: id}); err != nil {
+			t.Fatalf("Publish(): unexpected error: %v", err)
+		}
+	}
+
+	for _, wanted := range []int64{1, 2} {
+		if e := <-fast.Events; e.ID != wanted {
+			t.Fatalf("fast: wanted event `%d`; found `%d`", wanted, e.ID)
+		}
+	}
+
+	// the slow subscriber got the first event and was then dropped
+	if e := <-slow.Events; e.ID != 1 {
+		t.Fatalf("slow: wanted event `1`; found `%d`", e.ID)
+	}
+	if _, ok := <-slow.Events; ok {
+		t.Fatal("slow: wanted closed subscription")
+	}
+	slow.Close() // safe after the bus closed it
+
+	if _, ok := <-closed.Events; ok {
+		t.Fatal("closed: wanted closed subscription")
+	}
+}
+
+func TestSinks(t *testing.T) {
+	var first, second recorder
+	failing := sinkFunc(func(*types.Event) error {
+		return errors.New("unavailable")
+	})
+
+	if err := (Sinks{&first, &second}).Publish(
+		&types.Event{ID: 1},
+	); err != nil {
+		t.Fatalf("Publish(): unexpected error: %v", err)
+	}
+	if err := (Sinks{&first, failing, &second}).Publish(
+		&types.Event{ID: 2},
+	); err == nil {
+		t.Fatal("Publish(): wanted error; found `nil`")
+	}
+
+	if len(first) != 2 {
+		t.Fatalf("first sink: wanted 2 events; found %d", len(first))
+	}
+	if len(second) != 1 {
+		t.Fatalf("second sink: wanted 1 event; found %d", len(second))
+	}
+}
+
+type recorder []*types.Event
+
+func (r *recorder) Publish(e *types.Event) error {
+	*r = append(*r, e)
+	return nil
+}
+
+type sinkFunc func(*types.Event) error
+
+func (f sinkFunc) Publish(e *types.Event) error { return f(e) }
@@ -0,0 +1,422 @@
+package comments
+
+import (
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+// feedLimit is the maximum number of comments in a feed.
+const feedLimit = 50
+
+// Feed is the newest comments in a feed, newest first.
+type Feed struct {
+	Comments []*types.Comment
+
+	// Modified is the newest `Modified` time among the comments which were
+	// considered for the feed, including those which were left out (e.g.,
+	// because they were deleted). It's zero if there were no comments.
+	Modified time.Time
+}
+
+// ETag returns a weak entity tag which changes whenever the feed does. Unlike
+// `Modified`, it changes when comments join or leave the feed without being
+// modified (e.g., when they're approved, hidden by reports or when their
+// author is shadow-banned), so it's the feed's validator.
+func (f *Feed) ETag() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n", f.Modified.UnixNano())
+	for _, c := range f.Comments {
+		fmt.Fprintf(h, "%s/%s@%d\n", c.Post, c.ID, c.Modified.UnixNano())
+	}
+	return fmt.Sprintf(`W/"%x"`, h.Sum(nil)[:16])
+}
+
+// PostFeed returns the newest comments on a post. Feed readers don't log in,
+// so the feed only has comments which are visible to anonymous readers, and
+// deleted comments are left out.
+func (cm *CommentsModel) PostFeed(post types.PostID) (*Feed, error) {
+	comments, err := cm.CommentsStore.CommentsByPost(post, feedLimit)
+	if err != nil {
+		return nil, fmt.Errorf("fetching post feed: %w", err)
+	}
+	feed, err := cm.feed(comments)
+	if err != nil {
+		return nil, fmt.Errorf("fetching post feed: %w", err)
+	}
+	return feed, nil
+}
+
+// UserFeed returns a user's newest comments on every post. Like `PostFeed()`,
+// it only has comments which are visible to anonymous readers.
+func (cm *CommentsModel) UserFeed(user types.UserID) (*Feed, error) {
+	comments, err := cm.CommentsStore.CommentsByAuthor(user, feedLimit)
+	if err != nil {
+		return nil, fmt.Errorf("fetching user feed: %w", err)
+	}
+	feed, err := cm.feed(comments)
+	if err != nil {
+		return nil, fmt.Errorf("fetching user feed: %w", err)
+	}
+	return feed, nil
+}
+
+// feed builds a feed from the newest comments, leaving out those which are
+// deleted or which (or one of whose ancestors) aren't visible to anonymous
+// readers. Every comment's ancestors are fetched in a single query.
+func (cm *CommentsModel) feed(comments []*types.Comment) (*Feed, error) {
+	visibility, err := cm.visibility("")
+	if err != nil {
+		return nil, err
+	}
+	ancestors, err := cm.CommentsStore.Ancestors(comments...)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		post types.PostID
+		id   types.CommentID
+	}
+	byKey := make(map[key]*types.Comment, len(ancestors))
+	for _, a := range ancestors {
+		byKey[key{a.Post, a.ID}] = a
+	}
+	memo := map[key]bool{}
+	var isVisible func(c *types.Comment) bool
+	isVisible = func(c *types.Comment) bool {
+		k := key{c.Post, c.ID}
+		if v, found := memo[k]; found {
+			return v
+		}
+		memo[k] = false // guards against cycles
+		v := visibility.Visible(c)
+		if v && c.Parent != "" {
+			parent, found := byKey[key{c.Post, c.Parent}]
+			v = found && isVisible(parent)
+		}
+		memo[k] = v
+		return v
+	}
+
+	feed := Feed{Modified: newestModified(comments)}
+	for _, c := range comments {
+		if !c.Deleted && isVisible(c) {
+			feed.Comments = append(feed.Comments, c)
+		}
+	}
+	hideSpamChecks(feed.Comments...)
+	render(feed.Comments...)
+	return &feed, nil
+}
+
+func newestModified(comments []*types.Comment) time.Time {
+	var newest time.Time
+	for _, c := range comments {
+		if c.Modified.After(newest) {
+			newest = c.Modified
+		}
+	}
+	return newest
+}
+
+// PostFeed serves the newest comments on a post as an Atom or RSS feed,
+// depending on the path's `format`.
+func (ws *WebServer) PostFeed(r pz.Request) pz.Response {
+	post := types.PostID(r.Vars["post-id"])
+	context := logging{Post: post}
+	feed, err := ws.Comments.PostFeed(post)
+	if err != nil {
+		context.Error = err.Error()
+		return pz.HandleError("fetching post feed", err, &context)
+	}
+	return ws.feed(r, feed, &feedInfo{
+		title: fmt.Sprintf("Comments on %s", post),
+		self: join(
+			ws.BaseURL,
+			fmt.Sprintf("/posts/%s/comments.%s", post, r.Vars["format"]),
+		),
+		alternate: join(
+			ws.BaseURL,
+			fmt.Sprintf("/posts/%s/comments/toplevel/replies", post),
+		),
+	}, &context)
+}
+
+// UserFeed serves a user's newest comments as an Atom or RSS feed, depending
+// on the path's `format`.
+func (ws *WebServer) UserFeed(r pz.Request) pz.Response {
+	user := types.UserID(r.Vars["user-id"])
+	context := logging{User: user}
+	feed, err := ws.Comments.UserFeed(user)
+	if err != nil {
+		context.Error = err.Error()
+		return pz.HandleError("fetching user feed", err, &context)
+	}
+	return ws.feed(r, feed, &feedInfo{
+		title: fmt.Sprintf("Comments by %s", user),
+		self: join(
+			ws.BaseURL,
+			fmt.Sprintf("/users/%s/comments.%s", user, r.Vars["format"]),
+		),
+	}, &context)
+}
+
+// feedInfo describes a feed.
+type feedInfo struct {
+	title string
+
+	// self is the feed's URL, which is also its Atom ID.
+	self string
+
+	// alternate is the URL of the feed's HTML page, if it has one.
+	alternate string
+}
+
+// feed serializes the feed in the path's `format`. If the request's
+// `If-None-Match` header matches the feed's entity tag (see `Feed.ETag()`),
+// the response is `304 Not Modified`. `If-Modified-Since` is ignored since
+// the feed can change without its `Modified` time changing.
+func (ws *WebServer) feed(
+	r pz.Request,
+	feed *Feed,
+	info *feedInfo,
+	context *logging,
+) pz.Response {
+	headers := http.Header{}
+	etag := feed.ETag()
+	headers.Set("ETag", etag)
+	if etagMatches(r.Headers.Get("If-None-Match"), etag) {
+		return pz.Response{
+			Status:  http.StatusNotModified,
+			Data:    pz.Bytes(nil),
+			Headers: headers,
+			Logging: []interface{}{context},
+		}
+	}
+	// HTTP times have a resolution of a second
+	modified := feed.Modified.UTC().Truncate(time.Second)
+
+	var (
+		v           interface{}
+		contentType string
+	)
+	switch r.Vars["format"] {
+	case "atom":
+		v = ws.atomFeed(feed, info, modified)
+		contentType = "application/atom+xml; charset=utf-8"
+	case "rss":
+		v = ws.rssFeed(feed, info, modified)
+		contentType = "application/rss+xml; charset=utf-8"
+	default:
+		context.Error = fmt.Sprintf("invalid feed format: %s", r.Vars["format"])
+		return pz.NotFound(nil, context)
+	}
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		context.Error = err.Error()
+		return pz.InternalServerError(context)
+	}
+	headers.Set("Content-Type", contentType)
+	return pz.Ok(
+		pz.Bytes(append([]byte(xml.Header), data...)),
+		context,
+	).WithHeaders(headers)
+}
+
+// etagMatches reports whether an `If-None-Match` header matches an entity
+// tag. Like all `If-None-Match` comparisons, it's weak: `W/` prefixes are
+// ignored.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" ||
+			strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// entryLink returns the URL of the comment's anchor on its permalink page.
+func (ws *WebServer) entryLink(c *types.Comment) string {
+	return join(
+		ws.BaseURL,
+		fmt.Sprintf("/posts/%s/comments/%s#%s", c.Post, c.ID, c.ID),
+	)
+}
+
+// entryID returns a tag URI (RFC 4151) which identifies the comment. Unlike
+// its URL, it doesn't change if the site's paths do.
+func (ws *WebServer) entryID(c *types.Comment) string {
+	host := "localhost"
+	if u, err := url.Parse(ws.BaseURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	return fmt.Sprintf(
+		"tag:%s,%s:posts/%s/comments/%s",
+		host,
+		c.Created.UTC().Format("2006-01-02"),
+		c.Post,
+		c.ID,
+	)
+}
+
+func entryTitle(c *types.Comment) string {
+	return fmt.Sprintf("Comment by %s on %s", c.Author, c.Post)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Author    atomPerson  `xml:"author"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Link      atomLink    `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func (ws *WebServer) atomFeed(
+	feed *Feed,
+	info *feedInfo,
+	modified time.Time,
+) *atomFeed {
+	out := atomFeed{
+		ID:    info.self,
+		Title: info.title,
+		// an empty feed has never been updated
+		Updated: modified.Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: info.self},
+		},
+	}
+	if info.alternate != "" {
+		out.Links = append(out.Links, atomLink{
+			Rel:  "alternate",
+			Type: "text/html",
+			Href: info.alternate,
+		})
+	}
+	for _, c := range feed.Comments {
+		out.Entries = append(out.Entries, atomEntry{
+			ID:        ws.entryID(c),
+			Title:     entryTitle(c),
+			Author:    atomPerson{Name: string(c.Author)},
+			Published: c.Created.UTC().Format(time.RFC3339),
+			Updated:   c.Modified.UTC().Format(time.RFC3339),
+			Link: atomLink{
+				Rel:  "alternate",
+				Type: "text/html",
+				Href: ws.entryLink(c),
+			},
+			Content: atomContent{Type: "html", Body: c.HTML},
+		})
+	}
+	return &out
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func (ws *WebServer) rssFeed(
+	feed *Feed,
+	info *feedInfo,
+	modified time.Time,
+) *rssFeed {
+	out := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       info.title,
+			Link:        info.alternate,
+			Description: info.title,
+		},
+	}
+	if out.Channel.Link == "" {
+		// RSS requires a link
+		out.Channel.Link = info.self
+	}
+	if !modified.IsZero() {
+		out.Channel.LastBuildDate = modified.Format(time.RFC1123Z)
+	}
+	for _, c := range feed.Comments {
+		out.Channel.Items = append(out.Channel.Items, rssItem{
+			Title:       entryTitle(c),
+			Link:        ws.entryLink(c),
+			GUID:        rssGUID{Value: ws.entryID(c)},
+			PubDate:     c.Created.UTC().Format(time.RFC1123Z),
+			Description: c.HTML,
+		})
+	}
+	return &out
+}
+
+func (ws *WebServer) PostFeedRoute() pz.Route {
+	return pz.Route{
+		Method:  "GET",
+		Path:    "/posts/{post-id}/comments.{format:atom|rss}",
+		Handler: ws.PostFeed,
+	}
+}
+
+func (ws *WebServer) UserFeedRoute() pz.Route {
+	return pz.Route{
+		Method:  "GET",
+		Path:    "/users/{user-id}/comments.{format:atom|rss}",
+		Handler: ws.UserFeed,
+	}
+}
@@ -0,0 +1,403 @@
+package comments
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/testsupport"
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+// feedState is `moderationState()` plus a visible reply, a deleted comment
+// (the most recently modified) and a comment by a shadow-banned user.
+func feedState() testsupport.CommentsStoreFake {
+	state := moderationState()
+	state["post"]["approved-child"] = &types.Comment{
+		ID:       "approved-child",
+		Post:     "post",
+		Parent:   "approved",
+		Author:   "other",
+		Created:  someTime.Add(4 * time.Hour),
+		Modified: someTime.Add(4 * time.Hour),
+		Body:     "body",
+		Status:   types.StatusApproved,
+	}
+	state["post"]["deleted"] = &types.Comment{
+		ID:       "deleted",
+		Post:     "post",
+		Author:   "other",
+		Created:  someTime.Add(5 * time.Hour),
+		Modified: someTime.Add(6 * time.Hour),
+		Deleted:  true,
+		Status:   types.StatusApproved,
+	}
+	state["post"]["shadow-banned"] = &types.Comment{
+		ID:       "shadow-banned",
+		Post:     "post",
+		Author:   "troll",
+		Created:  someTime.Add(5 * time.Hour),
+		Modified: someTime.Add(5 * time.Hour),
+		Body:     "body",
+		Status:   types.StatusApproved,
+	}
+	return state
+}
+
+func feedModel() CommentsModel {
+	return CommentsModel{
+		CommentsStore: feedState(),
+		SanctionsStore: testsupport.Sanctions(&types.Sanction{
+			User: "troll",
+			Kind: types.SanctionShadowBan,
+		}),
+		TimeFunc: func() time.Time { return now },
+	}
+}
+
+func TestCommentsModel_PostFeed(t *testing.T) {
+	model := feedModel()
+	feed, err := model.PostFeed("post")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wanted := []types.CommentID{"approved-child", "approved"}
+	checkCommentIDs(t, "PostFeed()", wanted, feed.Comments)
+	for i, id := range wanted {
+		if feed.Comments[i].ID != id {
+			t.Fatalf(
+				"Comments[%d]: wanted `%s`; found `%s`",
+				i,
+				id,
+				feed.Comments[i].ID,
+			)
+		}
+	}
+	if wanted := someTime.Add(6 * time.Hour); !feed.Modified.Equal(wanted) {
+		t.Fatalf("Modified: wanted `%s`; found `%s`", wanted, feed.Modified)
+	}
+}
+
+func TestCommentsModel_UserFeed(t *testing.T) {
+	for _, testCase := range []struct {
+		name   string
+		user   types.UserID
+		wanted []types.CommentID
+	}{
+		{
+			// `pending-child`'s parent is awaiting moderation
+			name:   "replies to hidden comments",
+			user:   "other",
+			wanted: []types.CommentID{"approved-child"},
+		},
+		{
+			name:   "held comments",
+			user:   "author",
+			wanted: []types.CommentID{"approved"},
+		},
+		{
+			name: "shadow-banned user",
+			user: "troll",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			model := feedModel()
+			feed, err := model.UserFeed(testCase.user)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkCommentIDs(t, "UserFeed()", testCase.wanted, feed.Comments)
+		})
+	}
+}
+
+func TestWebServer_PostFeed(t *testing.T) {
+	model := feedModel()
+	feed, err := model.PostFeed("post")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	etag := feed.ETag()
+	for _, testCase := range []struct {
+		name              string
+		format            string
+		ifNoneMatch       string
+		ifModifiedSince   string
+		wantedStatus      int
+		wantedContentType string
+	}{
+		{
+			name:              "atom",
+			format:            "atom",
+			wantedStatus:      http.StatusOK,
+			wantedContentType: "application/atom+xml; charset=utf-8",
+		},
+		{
+			name:              "rss",
+			format:            "rss",
+			wantedStatus:      http.StatusOK,
+			wantedContentType: "application/rss+xml; charset=utf-8",
+		},
+		{
+			name:         "not modified",
+			format:       "atom",
+			ifNoneMatch:  etag,
+			wantedStatus: http.StatusNotModified,
+		},
+		{
+			name:         "not modified among several tags",
+			format:       "rss",
+			ifNoneMatch:  `"other", ` + etag,
+			wantedStatus: http.StatusNotModified,
+		},
+		{
+			name:              "modified",
+			format:            "rss",
+			ifNoneMatch:       `W/"stale"`,
+			wantedStatus:      http.StatusOK,
+			wantedContentType: "application/rss+xml; charset=utf-8",
+		},
+		{
+			name:   "if-modified-since ignored",
+			format: "atom",
+			ifModifiedSince: someTime.Add(24 * time.Hour).
+				Format(http.TimeFormat),
+			wantedStatus:      http.StatusOK,
+			wantedContentType: "application/atom+xml; charset=utf-8",
+		},
+		{
+			name:         "invalid format",
+			format:       "json",
+			wantedStatus: http.StatusNotFound,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			webServer := WebServer{
+				BaseURL:  "https://comments.example.org",
+				Comments: feedModel(),
+			}
+			headers := http.Header{}
+			if testCase.ifNoneMatch != "" {
+				headers.Set("If-None-Match", testCase.ifNoneMatch)
+			}
+			if testCase.ifModifiedSince != "" {
+				headers.Set("If-Modified-Since", testCase.ifModifiedSince)
+			}
+			rsp := webServer.PostFeed(pz.Request{
+				Vars: map[string]string{
+					"post-id": "post",
+					"format":  testCase.format,
+				},
+				Headers: headers,
+			})
+			if rsp.Status != testCase.wantedStatus {
+				t.Fatalf(
+					"Response.Status: wanted `%d`; found `%d`",
+					testCase.wantedStatus,
+					rsp.Status,
+				)
+			}
+			if rsp.Status == http.StatusNotFound {
+				return
+			}
+			if found := rsp.Headers.Get("ETag"); found != etag {
+				t.Fatalf("ETag: wanted `%s`; found `%s`", etag, found)
+			}
+			if rsp.Status != http.StatusOK {
+				return
+			}
+			if found := rsp.Headers.Get(
+				"Content-Type",
+			); found != testCase.wantedContentType {
+				t.Fatalf(
+					"Content-Type: wanted `%s`; found `%s`",
+					testCase.wantedContentType,
+					found,
+				)
+			}
+
+			data, err := readAll(rsp.Data)
+			if err != nil {
+				t.Fatalf("Response.Data: %v", err)
+			}
+			var ids, links []string
+			if testCase.format == "atom" {
+				var feed atomFeed
+				if err := xml.Unmarshal(data, &feed); err != nil {
+					t.Fatalf("parsing feed: %v\n%s", err, data)
+				}
+				for _, e := range feed.Entries {
+					ids = append(ids, e.ID)
+					links = append(links, e.Link.Href)
+				}
+			} else {
+				var feed rssFeed
+				if err := xml.Unmarshal(data, &feed); err != nil {
+					t.Fatalf("parsing feed: %v\n%s", err, data)
+				}
+				for _, item := range feed.Channel.Items {
+					ids = append(ids, item.GUID.Value)
+					links = append(links, item.Link)
+				}
+			}
+			checkStrings(t, "entry IDs", []string{
+				"tag:comments.example.org,2022-01-01:" +
+					"posts/post/comments/approved-child",
+				"tag:comments.example.org,2022-01-01:" +
+					"posts/post/comments/approved",
+			}, ids)
+			checkStrings(t, "entry links", []string{
+				"https://comments.example.org/posts/post/comments/" +
+					"approved-child#approved-child",
+				"https://comments.example.org/posts/post/comments/" +
+					"approved#approved",
+			}, links)
+		})
+	}
+}
+
+// The feed's entity tag changes when comments join the feed without being
+// modified, so readers which revalidate don't miss them.
+func TestWebServer_PostFeed_Visibility(t *testing.T) {
+	for _, testCase := range []struct {
+		name   string
+		change func(model *CommentsModel) error
+	}{
+		{
+			name: "approved",
+			change: func(model *CommentsModel) error {
+				return model.CommentsStore.Update(
+					types.NewCommentPatch("pending", "post").
+						SetStatus(types.StatusApproved),
+				)
+			},
+		},
+		{
+			name: "unbanned",
+			change: func(model *CommentsModel) error {
+				return model.SanctionsStore.DeleteSanction(
+					"troll",
+					types.SanctionShadowBan,
+				)
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			model := feedModel()
+			webServer := WebServer{
+				BaseURL:  "https://comments.example.org",
+				Comments: model,
+			}
+			request := pz.Request{
+				Vars:    map[string]string{"post-id": "post", "format": "atom"},
+				Headers: http.Header{},
+			}
+			etag := webServer.PostFeed(request).Headers.Get("ETag")
+
+			if err := testCase.change(&model); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			request.Headers.Set("If-None-Match", etag)
+			rsp := webServer.PostFeed(request)
+			if rsp.Status != http.StatusOK {
+				t.Fatalf(
+					"Response.Status: wanted `%d`; found `%d`",
+					http.StatusOK,
+					rsp.Status,
+				)
+			}
+			if found := rsp.Headers.Get("ETag"); found == etag {
+				t.Fatalf("ETag: wanted a new tag; found `%s`", found)
+			}
+		})
+	}
+}
+
+func checkStrings(t *testing.T, context string, wanted, found []string) {
+	t.Helper()
+	if len(wanted) != len(found) {
+		t.Fatalf("%s: wanted `%v`; found `%v`", context, wanted, found)
+	}
+	for i := range wanted {
+		if wanted[i] != found[i] {
+			t.Fatalf("%s: wanted `%v`; found `%v`", context, wanted, found)
+		}
+	}
+}
+
+// lookupCounter counts the lookups which the feeds shouldn't need: the feeds
+// query a bounded number of comments and fetch their ancestors in bulk.
+type lookupCounter struct {
+	types.CommentsStore
+	lookups int
+}
+
+func (lc *lookupCounter) Comment(
+	post types.PostID,
+	comment types.CommentID,
+) (*types.Comment, error) {
+	lc.lookups++
+	return lc.CommentsStore.Comment(post, comment)
+}
+
+func (lc *lookupCounter) Replies(
+	post types.PostID,
+	parent types.CommentID,
+) ([]*types.Comment, error) {
+	lc.lookups++
+	return lc.CommentsStore.Replies(post, parent)
+}
+
+func TestCommentsModel_Feeds_Bounded(t *testing.T) {
+	state := feedState()
+	for i := 0; i < feedLimit; i++ {
+		id := types.CommentID(fmt.Sprintf("reply-%d", i))
+		state["post"][id] = &types.Comment{
+			ID:       id,
+			Post:     "post",
+			Parent:   "approved-child",
+			Author:   "other",
+			Created:  someTime.Add(time.Duration(7+i) * time.Hour),
+			Modified: someTime.Add(time.Duration(7+i) * time.Hour),
+			Body:     "body",
+			Status:   types.StatusApproved,
+		}
+	}
+	store := lookupCounter{CommentsStore: state}
+	model := feedModel()
+	model.CommentsStore = &store
+
+	feed, err := model.PostFeed("post")
+	if err != nil {
+		t.Fatalf("PostFeed(): unexpected error: %v", err)
+	}
+	if len(feed.Comments) != feedLimit ||
+		feed.Comments[0].ID != types.CommentID(
+			fmt.Sprintf("reply-%d", feedLimit-1),
+		) {
+		t.Fatalf(
+			"PostFeed(): wanted the newest %d comments; found %d",
+			feedLimit,
+			len(feed.Comments),
+		)
+	}
+
+	feed, err = model.UserFeed("other")
+	if err != nil {
+		t.Fatalf("UserFeed(): unexpected error: %v", err)
+	}
+	if len(feed.Comments) != feedLimit {
+		t.Fatalf(
+			"UserFeed(): wanted %d comments; found %d",
+			feedLimit,
+			len(feed.Comments),
+		)
+	}
+
+	if store.lookups != 0 {
+		t.Fatalf("wanted no per-comment lookups; found %d", store.lookups)
+	}
+}
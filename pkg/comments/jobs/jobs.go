@@ -0,0 +1,232 @@
+// Package jobs attempts queued jobs in the background, retrying failed
+// attempts with exponential backoff. Jobs are kept in a `types.JobsStore`, so
+// jobs in a durable store outlive the process which queued them.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+const (
+	// QueueSizeDefault is the default number of jobs which can be waiting in
+	// a queue's default `MemoryStore`.
+	QueueSizeDefault = 256
+
+	// MaxAttemptsDefault is the default number of attempts at a job before
+	// it's abandoned.
+	MaxAttemptsDefault = 5
+
+	// BackoffDefault is the default delay before the first retry.
+	BackoffDefault = time.Minute
+
+	// IntervalDefault is the default delay between checks for due jobs.
+	IntervalDefault = time.Second
+
+	// LeaseDefault is the default time for which a claimed job is hidden
+	// from other workers.
+	LeaseDefault = 5 * time.Minute
+
+	// BatchSizeDefault is the number of jobs which are claimed at a time.
+	BatchSizeDefault = 100
+)
+
+var ErrQueueFull = errors.New("job queue is full")
+
+// Handler attempts a queue's jobs.
+type Handler interface {
+	// Attempt makes a single attempt at a job. If it fails, the job is
+	// retried later.
+	Attempt(ctx context.Context, j *types.Job) error
+
+	// Abandon is called with the last attempt's error when a job has run out
+	// of attempts. The job is removed from the queue afterwards.
+	Abandon(j *types.Job, err error)
+}
+
+// Queue attempts the jobs of one kind. Failed attempts are retried with
+// exponential backoff and jobs which fail every attempt are abandoned. Jobs
+// are only attempted while `Run()` is running; several queues (e.g., in
+// different replicas) can share a store.
+type Queue struct {
+	// Kind identifies the queue's jobs in the store.
+	Kind string
+
+	// Store holds the queued jobs. It defaults to a `MemoryStore` which holds
+	// up to `QueueSizeDefault` jobs.
+	Store types.JobsStore
+
+	Handler Handler
+
+	// MaxAttempts is the number of attempts at a job before it's abandoned.
+	// It defaults to `MaxAttemptsDefault`.
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry. The delay doubles with
+	// each subsequent retry. It defaults to `BackoffDefault`.
+	Backoff time.Duration
+
+	// Interval is the delay between checks for due jobs. Jobs which are
+	// queued via `Enqueue()` are attempted without waiting for the next
+	// check. It defaults to `IntervalDefault`.
+	Interval time.Duration
+
+	// Lease is how long a claimed job is hidden from other workers. It must
+	// be longer than an attempt can take. If the process dies mid-attempt,
+	// the job is attempted again once its lease expires. It defaults to
+	// `LeaseDefault`.
+	Lease time.Duration
+
+	// TimeFunc defaults to `time.Now()`.
+	TimeFunc func() time.Time
+
+	once sync.Once
+	wake chan struct{}
+}
+
+func (q *Queue) init() {
+	q.once.Do(func() {
+		q.wake = make(chan struct{}, 1)
+		if q.Store == nil {
+			q.Store = &MemoryStore{Size: QueueSizeDefault}
+		}
+		if q.TimeFunc == nil {
+			q.TimeFunc = time.Now
+		}
+	})
+}
+
+// Enqueue queues a job which is due right away. If a job with the same ID is
+// already queued, nothing is changed (see `types.Job.ID`).
+func (q *Queue) Enqueue(id string, payload []byte) error {
+	q.init()
+	if err := q.Store.PutJob(&types.Job{
+		Kind:    q.Kind,
+		ID:      id,
+		Payload: payload,
+		Due:     q.TimeFunc(),
+	}); err != nil {
+		return fmt.Errorf("queueing %s job `%s`: %w", q.Kind, id, err)
+	}
+
+	// wake `Run()` without blocking; if it's already awake, it will see the
+	// job anyway
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run attempts due jobs until the context is canceled.
+func (q *Queue) Run(ctx context.Context) {
+	q.init()
+	interval := q.Interval
+	if interval <= 0 {
+		interval = IntervalDefault
+	}
+	for {
+		n, err := q.RunOnce(ctx)
+		if err != nil {
+			log.Printf("running %s jobs: %v", q.Kind, err)
+		}
+
+		// keep going while there's a backlog
+		if err == nil && n >= BatchSizeDefault {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(interval):
+		}
+	}
+}
+
+// RunOnce claims a batch of due jobs and attempts each of them once,
+// returning the number of jobs which were claimed.
+func (q *Queue) RunOnce(ctx context.Context) (int, error) {
+	q.init()
+	lease := q.Lease
+	if lease <= 0 {
+		lease = LeaseDefault
+	}
+	now := q.TimeFunc()
+	jobs, err := q.Store.ClaimJobs(
+		q.Kind,
+		now,
+		now.Add(lease),
+		BatchSizeDefault,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("claiming %s jobs: %w", q.Kind, err)
+	}
+	for _, j := range jobs {
+		if err := q.attempt(ctx, j); err != nil {
+			return 0, err
+		}
+	}
+	return len(jobs), nil
+}
+
+// attempt makes an attempt at a job and then removes it from the queue if it
+// succeeded or ran out of attempts; otherwise it schedules a retry.
+func (q *Queue) attempt(ctx context.Context, j *types.Job) error {
+	err := q.Handler.Attempt(ctx, j)
+	if err != nil && ctx.Err() != nil {
+		// the attempt was interrupted, so it doesn't count; the job is
+		// attempted again once its lease expires
+		return ctx.Err()
+	}
+	if err == nil {
+		return q.delete(j)
+	}
+	j.Attempts++
+
+	maxAttempts := q.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = MaxAttemptsDefault
+	}
+	if j.Attempts >= maxAttempts {
+		q.Handler.Abandon(j, err)
+		return q.delete(j)
+	}
+
+	delay := q.Backoff
+	if delay <= 0 {
+		delay = BackoffDefault
+	}
+	delay <<= j.Attempts - 1
+	log.Printf(
+		"%s job `%s` (attempt %d): %v; retrying in %s",
+		q.Kind,
+		j.ID,
+		j.Attempts,
+		err,
+		delay,
+	)
+	j.Due = q.TimeFunc().Add(delay)
+	if err := q.Store.UpdateJob(j); err != nil {
+		return fmt.Errorf(
+			"scheduling retry of %s job `%s`: %w",
+			q.Kind,
+			j.ID,
+			err,
+		)
+	}
+	return nil
+}
+
+func (q *Queue) delete(j *types.Job) error {
+	if err := q.Store.DeleteJob(j.Kind, j.ID); err != nil {
+		return fmt.Errorf("removing %s job `%s`: %w", q.Kind, j.ID, err)
+	}
+	return nil
+}
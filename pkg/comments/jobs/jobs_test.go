@@ -0,0 +1,216 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+var someTime = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// flakyHandler fails the first `failures` attempts at each job.
+type flakyHandler struct {
+	failures  int
+	attempts  map[string]int
+	abandoned []string
+}
+
+func (h *flakyHandler) Attempt(ctx context.Context, j *types.Job) error {
+	h.attempts[j.ID]++
+	if h.attempts[j.ID] <= h.failures {
+		return errors.New("service unavailable")
+	}
+	return nil
+}
+
+func (h *flakyHandler) Abandon(j *types.Job, err error) {
+	h.abandoned = append(h.abandoned, j.ID)
+}
+
+func TestQueue(t *testing.T) {
+	for _, testCase := range []struct {
+		name            string
+		failures        int
+		wantedAttempts  int
+		wantedAbandoned bool
+	}{
+		{
+			name:           "succeeds first time",
+			wantedAttempts: 1,
+		},
+		{
+			name:           "succeeds after retries",
+			failures:       2,
+			wantedAttempts: 3,
+		},
+		{
+			name:            "abandoned after max attempts",
+			failures:        3,
+			wantedAttempts:  3,
+			wantedAbandoned: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			now := someTime
+			store := MemoryStore{}
+			handler := flakyHandler{
+				failures: testCase.failures,
+				attempts: map[string]int{},
+			}
+			queue := Queue{
+				Kind:        "kind",
+				Store:       &store,
+				Handler:     &handler,
+				MaxAttempts: 3,
+				Backoff:     time.Minute,
+				TimeFunc:    func() time.Time { return now },
+			}
+			if err := queue.Enqueue("job", nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// the retries are due after 1 minute and then 2 more minutes
+			for _, wait := range []time.Duration{
+				0,
+				time.Minute,
+				time.Minute,
+				time.Minute,
+				5 * time.Minute,
+			} {
+				now = now.Add(wait)
+				if _, err := queue.RunOnce(context.Background()); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			if handler.attempts["job"] != testCase.wantedAttempts {
+				t.Fatalf(
+					"attempts: wanted `%d`; found `%d`",
+					testCase.wantedAttempts,
+					handler.attempts["job"],
+				)
+			}
+			abandoned := len(handler.abandoned) > 0
+			if abandoned != testCase.wantedAbandoned {
+				t.Fatalf(
+					"abandoned: wanted `%t`; found `%t`",
+					testCase.wantedAbandoned,
+					abandoned,
+				)
+			}
+			if len(store.jobs) != 0 {
+				t.Fatalf(
+					"wanted empty store; found `%d` jobs",
+					len(store.jobs),
+				)
+			}
+		})
+	}
+}
+
+func TestQueue_Backoff(t *testing.T) {
+	now := someTime
+	store := MemoryStore{}
+	handler := flakyHandler{failures: 1, attempts: map[string]int{}}
+	queue := Queue{
+		Kind:     "kind",
+		Store:    &store,
+		Handler:  &handler,
+		Backoff:  time.Minute,
+		TimeFunc: func() time.Time { return now },
+	}
+	if err := queue.Enqueue("job", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, wait := range []time.Duration{0, 59 * time.Second} {
+		now = now.Add(wait)
+		if _, err := queue.RunOnce(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if handler.attempts["job"] != 1 {
+		t.Fatalf(
+			"wanted no retry before backoff; found `%d` attempts",
+			handler.attempts["job"],
+		)
+	}
+}
+
+func TestQueue_Interrupted(t *testing.T) {
+	now := someTime
+	store := MemoryStore{}
+	queue := Queue{
+		Kind:     "kind",
+		Store:    &store,
+		Handler:  &flakyHandler{failures: 1, attempts: map[string]int{}},
+		Lease:    time.Minute,
+		TimeFunc: func() time.Time { return now },
+	}
+	if err := queue.Enqueue("job", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := queue.RunOnce(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wanted `context.Canceled`; found `%v`", err)
+	}
+
+	// the interrupted attempt doesn't count and the job is due again once
+	// its lease expires
+	j := store.jobs[memoryKey{"kind", "job"}]
+	if j.Attempts != 0 {
+		t.Fatalf("Job.Attempts: wanted `0`; found `%d`", j.Attempts)
+	}
+	if wanted := now.Add(time.Minute); !j.Due.Equal(wanted) {
+		t.Fatalf("Job.Due: wanted `%s`; found `%s`", wanted, j.Due)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := MemoryStore{Size: 2}
+	for _, j := range []*types.Job{
+		{Kind: "kind", ID: "later", Due: someTime.Add(time.Minute)},
+		{Kind: "kind", ID: "sooner", Due: someTime, Payload: []byte("a")},
+	} {
+		if err := store.PutJob(j); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// queueing a job which is already queued changes nothing
+	if err := store.PutJob(&types.Job{
+		Kind:    "kind",
+		ID:      "sooner",
+		Payload: []byte("b"),
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.PutJob(&types.Job{
+		Kind: "other",
+		ID:   "sooner",
+	}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("wanted `ErrQueueFull`; found `%v`", err)
+	}
+
+	until := someTime.Add(time.Hour)
+	claimed, err := store.ClaimJobs("kind", someTime.Add(time.Minute), until, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != "sooner" ||
+		string(claimed[0].Payload) != "a" {
+		t.Fatalf("wanted job `sooner` with payload `a`; found `%+v`", claimed)
+	}
+
+	// claimed jobs aren't claimed again until `until`
+	claimed, err = store.ClaimJobs("kind", someTime.Add(time.Minute), until, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != "later" {
+		t.Fatalf("wanted job `later`; found `%+v`", claimed)
+	}
+}
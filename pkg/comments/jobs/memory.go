@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// MemoryStore is an in-memory `types.JobsStore`. Its jobs are lost if the
+// process exits and it can't be shared between replicas, so deployments which
+// need jobs to survive restarts should use a durable store instead. The zero
+// value is ready to use.
+type MemoryStore struct {
+	// Size is the maximum number of queued jobs. When the store is full,
+	// `PutJob()` returns `ErrQueueFull`. Zero means there's no limit.
+	Size int
+
+	lock sync.Mutex
+	jobs map[memoryKey]types.Job
+}
+
+type memoryKey struct{ kind, id string }
+
+func (s *MemoryStore) PutJob(j *types.Job) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.jobs == nil {
+		s.jobs = map[memoryKey]types.Job{}
+	}
+	key := memoryKey{j.Kind, j.ID}
+	if _, found := s.jobs[key]; found {
+		return nil
+	}
+	if s.Size > 0 && len(s.jobs) >= s.Size {
+		return ErrQueueFull
+	}
+	s.jobs[key] = *j
+	return nil
+}
+
+func (s *MemoryStore) ClaimJobs(
+	kind string,
+	now time.Time,
+	until time.Time,
+	limit int,
+) ([]*types.Job, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var due []*types.Job
+	for key, j := range s.jobs {
+		if key.kind == kind && !j.Due.After(now) {
+			cp := j
+			due = append(due, &cp)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].Due.Before(due[j].Due)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, j := range due {
+		j.Due = until
+		s.jobs[memoryKey{j.Kind, j.ID}] = *j
+	}
+	return due, nil
+}
+
+func (s *MemoryStore) UpdateJob(j *types.Job) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := memoryKey{j.Kind, j.ID}
+	if _, found := s.jobs[key]; found {
+		s.jobs[key] = *j
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteJob(kind, id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.jobs, memoryKey{kind, id})
+	return nil
+}
+
+// fail compilation if `MemoryStore` doesn't implement `types.JobsStore`.
+var _ types.JobsStore = &MemoryStore{}
@@ -0,0 +1,233 @@
+package comments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/events"
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+const (
+	// liveBufferSize is the number of events which are buffered for each
+	// live stream. Streams which fall further behind are closed (see
+	// `events.Bus`) and their clients reconnect.
+	liveBufferSize = 64
+
+	// liveHeartbeat is how often live streams are sent a comment, which keeps
+	// proxies from timing out idle streams. It's also how a client which went
+	// away is noticed if the response writer can't tell (see `WriteTo()`).
+	liveHeartbeat = 30 * time.Second
+)
+
+// LiveComment fetches the comment which an event is about as the viewer
+// should see it. It returns `types.ErrCommentNotFound` if the comment no
+// longer exists or if it (or one of its ancestors) isn't visible to the
+// viewer.
+func (cm *CommentsModel) LiveComment(
+	viewer types.UserID,
+	e *types.Event,
+) (*types.Comment, error) {
+	visibility, err := cm.visibility(viewer)
+	if err != nil {
+		return nil, err
+	}
+	c, err := cm.CommentsStore.Comment(e.Comment.Post, e.Comment.ID)
+	if err != nil {
+		return nil, err
+	}
+	visible, err := cm.threadVisible(visibility, c)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, types.ErrCommentNotFound
+	}
+	redact([]*types.Comment{c})
+	if !visibility.AllStatuses {
+		hideSpamChecks(c)
+	}
+	render(c)
+	return c, nil
+}
+
+// Events streams a post's comment events to the replies page as server-sent
+// events. Each event's data is the comment rendered as it is on the replies
+// page, or no HTML if the viewer can no longer see the comment. Events about
+// comments which the viewer can't see are skipped.
+func (ws *WebServer) Events(r pz.Request) pz.Response {
+	post := types.PostID(r.Vars["post-id"])
+	user := types.UserID(r.Headers.Get("User"))
+	if ws.Bus == nil {
+		return pz.NotFound(nil, &logging{
+			Post:  post,
+			User:  user,
+			Error: "live updates are disabled",
+		})
+	}
+
+	moderator, err := ws.Comments.IsModerator(user)
+	if err != nil {
+		return pz.InternalServerError(&logging{
+			Post:  post,
+			User:  user,
+			Error: err.Error(),
+		})
+	}
+
+	stream := liveStream{
+		comments: &ws.Comments,
+		post:     post,
+		globals: &globals{
+			BaseURL:   ws.BaseURL,
+			User:      user,
+			Moderator: moderator,
+			CSRFToken: ws.CSRF.Token(r),
+		},
+		subscription: ws.Bus.Subscribe(liveBufferSize),
+		heartbeat:    liveHeartbeat,
+	}
+	return pz.Response{
+		Status:  http.StatusOK,
+		Data:    func() (io.WriterTo, error) { return &stream, nil },
+		Logging: []interface{}{&logging{Post: post, User: user}},
+		Headers: http.Header{
+			"Content-Type":  []string{"text/event-stream"},
+			"Cache-Control": []string{"no-cache"},
+
+			// ask proxies (e.g., nginx) not to buffer the stream
+			"X-Accel-Buffering": []string{"no"},
+		},
+	}
+}
+
+// liveStream writes a post's events to a client until the subscription is
+// closed, the client goes away or a write fails.
+type liveStream struct {
+	comments     *CommentsModel
+	post         types.PostID
+	globals      *globals
+	subscription *events.Subscription
+	heartbeat    time.Duration
+}
+
+// liveData is the data of a server-sent event. `HTML` is empty if the viewer
+// can't see the comment.
+type liveData struct {
+	ID     types.CommentID `json:"id"`
+	Parent types.CommentID `json:"parent"`
+	HTML   string          `json:"html"`
+}
+
+// WriteTo implements `io.WriterTo`. Handlers don't see their request's
+// context, so the stream learns that the client went away from the response
+// writer, if it's an `http.CloseNotifier` (as `net/http`'s are), and stops
+// right away rather than at the next heartbeat.
+func (ls *liveStream) WriteTo(w io.Writer) (int64, error) {
+	defer ls.subscription.Close()
+	flusher, _ := w.(http.Flusher)
+
+	// a nil channel never fires, so writers which can't tell fall back to
+	// failed heartbeats
+	var gone <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		gone = notifier.CloseNotify()
+	}
+	var written int64
+	write := func(s string) error {
+		n, err := io.WriteString(w, s)
+		written += int64(n)
+		if err == nil && flusher != nil {
+			flusher.Flush()
+		}
+		return err
+	}
+
+	// flush the headers so that the client knows that it's connected
+	if err := write(": connected\n\n"); err != nil {
+		return written, err
+	}
+	ticker := time.NewTicker(ls.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-gone:
+			return written, nil
+		case e, ok := <-ls.subscription.Events:
+			if !ok {
+				// the stream fell behind; the client will reconnect
+				return written, nil
+			}
+			if e.Comment.Post != ls.post {
+				continue
+			}
+			message, err := ls.message(e)
+			if err != nil {
+				log.Printf("streaming event `%d`: %v", e.ID, err)
+				continue
+			}
+			if message == "" {
+				continue
+			}
+			if err := write(message); err != nil {
+				return written, err
+			}
+		case <-ticker.C:
+			if err := write(": heartbeat\n\n"); err != nil {
+				return written, err
+			}
+		}
+	}
+}
+
+// message formats the event for the viewer. It returns an empty string if
+// the event should be skipped.
+func (ls *liveStream) message(e *types.Event) (string, error) {
+	data := liveData{ID: e.Comment.ID, Parent: e.Comment.Parent}
+	c, err := ls.comments.LiveComment(ls.globals.User, e)
+	if err != nil {
+		if !errors.Is(err, types.ErrCommentNotFound) {
+			return "", err
+		}
+		if e.Type == types.EventCommentCreated {
+			return "", nil
+		}
+	} else {
+		var sb strings.Builder
+		if err := repliesTemplate.ExecuteTemplate(
+			&sb,
+			"comment",
+			&reply{globals: ls.globals, Comment: c},
+		); err != nil {
+			return "", fmt.Errorf("rendering comment: %w", err)
+		}
+		data.HTML = sb.String()
+	}
+
+	// JSON doesn't contain newlines, so it fits in a single `data` field
+	payload, err := json.Marshal(&data)
+	if err != nil {
+		return "", fmt.Errorf("marshaling event data: %w", err)
+	}
+	return fmt.Sprintf(
+		"id: %d\nevent: %s\ndata: %s\n\n",
+		e.ID,
+		e.Type,
+		payload,
+	), nil
+}
+
+func (ws *WebServer) EventsRoute() pz.Route {
+	return pz.Route{
+		Method:  "GET",
+		Path:    "/posts/{post-id}/events",
+		Handler: ws.Events,
+	}
+}
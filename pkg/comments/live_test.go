@@ -0,0 +1,237 @@
+package comments
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/events"
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+func TestWebServer_Events(t *testing.T) {
+	event := func(
+		id int64,
+		t types.EventType,
+		post types.PostID,
+		comment types.CommentID,
+	) *types.Event {
+		return &types.Event{
+			ID:      id,
+			Type:    t,
+			Comment: &types.EventComment{Post: post, ID: comment},
+		}
+	}
+	published := []*types.Event{
+		event(1, types.EventCommentCreated, "post", "approved"),
+		event(2, types.EventCommentCreated, "other-post", "approved"),
+		event(3, types.EventCommentCreated, "post", "pending"),
+		event(4, types.EventCommentUpdated, "post", "pending-child"),
+		event(5, types.EventCommentDeleted, "post", "missing"),
+	}
+
+	for _, testCase := range []struct {
+		name   string
+		viewer string
+		wanted []int64 // IDs of the events which are streamed
+		hidden []int64 // IDs of the events which are streamed without HTML
+	}{
+		{
+			name:   "anonymous",
+			wanted: []int64{1, 4, 5},
+			hidden: []int64{4, 5},
+		},
+		{
+			name:   "author",
+			viewer: "author",
+			wanted: []int64{1, 3, 4, 5},
+			hidden: []int64{5},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			var bus events.Bus
+			webServer := WebServer{
+				BaseURL:  "https://comments.example.org",
+				Comments: CommentsModel{CommentsStore: moderationState()},
+				Bus:      &bus,
+			}
+			rsp := webServer.Events(pz.Request{
+				Vars:    map[string]string{"post-id": "post"},
+				Headers: http.Header{"User": []string{testCase.viewer}},
+			})
+			if rsp.Status != http.StatusOK {
+				t.Fatalf(
+					"Response.Status: wanted `200`; found `%d`",
+					rsp.Status,
+				)
+			}
+			writerTo, err := rsp.Data()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// the subscription exists before the stream is written, so
+			// these are buffered
+			for _, e := range published {
+				if err := bus.Publish(e); err != nil {
+					t.Fatalf("Publish(): unexpected error: %v", err)
+				}
+			}
+			writerTo.(*liveStream).subscription.Close()
+
+			r, w := io.Pipe()
+			go func() {
+				_, err := writerTo.WriteTo(w)
+				w.CloseWithError(err)
+			}()
+			found := readEvents(t, r)
+
+			if len(found) != len(testCase.wanted) {
+				t.Fatalf(
+					"events: wanted `%v`; found `%d` events",
+					testCase.wanted,
+					len(found),
+				)
+			}
+			hidden := map[int64]bool{}
+			for _, id := range testCase.hidden {
+				hidden[id] = true
+			}
+			for i, id := range testCase.wanted {
+				e := found[i]
+				if e.id != id {
+					t.Fatalf("events[%d]: wanted `%d`; found `%d`", i, id, e.id)
+				}
+				if hidden[id] != (e.data.HTML == "") {
+					t.Fatalf(
+						"events[%d].html: wanted hidden=%t; found `%s`",
+						i,
+						hidden[id],
+						e.data.HTML,
+					)
+				}
+				if !hidden[id] && !strings.Contains(
+					e.data.HTML,
+					`id="`+string(e.data.ID)+`"`,
+				) {
+					t.Fatalf(
+						"events[%d].html: wanted comment `%s`; found `%s`",
+						i,
+						e.data.ID,
+						e.data.HTML,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestWebServer_Events_Disabled(t *testing.T) {
+	webServer := WebServer{Comments: CommentsModel{
+		CommentsStore: moderationState(),
+	}}
+	rsp := webServer.Events(pz.Request{
+		Vars:    map[string]string{"post-id": "post"},
+		Headers: http.Header{},
+	})
+	if rsp.Status != http.StatusNotFound {
+		t.Fatalf("Response.Status: wanted `404`; found `%d`", rsp.Status)
+	}
+}
+
+// closeNotifyWriter is a response writer whose client goes away when `gone`
+// is closed.
+type closeNotifyWriter struct {
+	io.Writer
+	gone chan bool
+}
+
+func (cnw *closeNotifyWriter) CloseNotify() <-chan bool { return cnw.gone }
+
+func TestWebServer_Events_Disconnect(t *testing.T) {
+	var bus events.Bus
+	webServer := WebServer{
+		Comments: CommentsModel{CommentsStore: moderationState()},
+		Bus:      &bus,
+	}
+	rsp := webServer.Events(pz.Request{
+		Vars:    map[string]string{"post-id": "post"},
+		Headers: http.Header{},
+	})
+	writerTo, err := rsp.Data()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stream := writerTo.(*liveStream)
+
+	w := closeNotifyWriter{Writer: ioutil.Discard, gone: make(chan bool)}
+	done := make(chan error)
+	go func() {
+		_, err := stream.WriteTo(&w)
+		done <- err
+	}()
+	close(w.gone)
+
+	// the stream stops well before the next heartbeat
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("wanted the stream to stop; timed out")
+	}
+	if _, ok := <-stream.subscription.Events; ok {
+		t.Fatal("wanted the subscription to be closed")
+	}
+}
+
+type streamedEvent struct {
+	id   int64
+	data liveData
+}
+
+// readEvents parses server-sent events until the stream ends.
+func readEvents(t *testing.T, r io.Reader) []streamedEvent {
+	t.Helper()
+	var (
+		out     []streamedEvent
+		current streamedEvent
+		scanner = bufio.NewScanner(r)
+	)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if current.data.ID != "" {
+				out = append(out, current)
+			}
+			current = streamedEvent{}
+		case strings.HasPrefix(line, "id: "):
+			if err := json.Unmarshal(
+				[]byte(line[len("id: "):]),
+				&current.id,
+			); err != nil {
+				t.Fatalf("parsing event ID: %v", err)
+			}
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal(
+				[]byte(line[len("data: "):]),
+				&current.data,
+			); err != nil {
+				t.Fatalf("parsing event data: %v", err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("reading events: %v", err)
+	}
+	return out
+}
@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// LogSender writes emails to a writer (e.g., a file or stderr) instead of
+// sending them, for local testing.
+type LogSender struct {
+	Writer io.Writer
+
+	// From is the sender's address.
+	From string
+
+	lock sync.Mutex
+}
+
+func (ls *LogSender) SendEmail(e *types.Email) error {
+	msg, err := message(ls.From, e, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	if _, err := fmt.Fprintf(ls.Writer, "%s\r\n.\r\n", msg); err != nil {
+		return fmt.Errorf("logging email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Package mail sends the emails which notify users of activity on their
+// comments.
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// message formats an email as an RFC 5322 message with a quoted-printable,
+// UTF-8 plain text body. Newlines are stripped from header values so that
+// user-controlled values (e.g., post IDs in subjects) can't inject headers.
+func message(from string, e *types.Email, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	oneLine := strings.NewReplacer("\r", "", "\n", "")
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, oneLine.Replace(value))
+	}
+	header("From", from)
+	header("To", e.To)
+	header("Subject", mime.QEncoding.Encode(
+		"utf-8",
+		oneLine.Replace(e.Subject),
+	))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+
+	names := make([]string, 0, len(e.Headers))
+	for name := range e.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header(name, e.Headers[name])
+	}
+	buf.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	body := strings.ReplaceAll(e.Body, "\r\n", "\n")
+	if _, err := w.Write(
+		[]byte(strings.ReplaceAll(body, "\n", "\r\n")),
+	); err != nil {
+		return nil, fmt.Errorf("encoding email body: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("encoding email body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
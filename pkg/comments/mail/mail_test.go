@@ -0,0 +1,173 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/jobs"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestMessage(t *testing.T) {
+	msg, err := message(
+		"comments@example.org",
+		&types.Email{
+			To:      "adam@example.org",
+			Subject: "New reply on post\r\nBcc: eve@example.org",
+			Body:    "café\nhttps://example.org/unsubscribe?token=abc",
+			Headers: map[string]string{
+				"List-Unsubscribe": "<https://example.org/unsubscribe>",
+			},
+		},
+		time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wanted := "From: comments@example.org\r\n" +
+		"To: adam@example.org\r\n" +
+		"Subject: New reply on postBcc: eve@example.org\r\n" +
+		"Date: Sat, 01 Jan 2022 00:00:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"List-Unsubscribe: <https://example.org/unsubscribe>\r\n" +
+		"\r\n" +
+		"caf=C3=A9\r\n" +
+		"https://example.org/unsubscribe?token=3Dabc"
+	if string(msg) != wanted {
+		t.Fatalf("wanted:\n%q\nfound:\n%q", wanted, msg)
+	}
+}
+
+func TestLogSender(t *testing.T) {
+	var buf bytes.Buffer
+	sender := LogSender{Writer: &buf, From: "comments@example.org"}
+	if err := sender.SendEmail(&types.Email{
+		To:      "adam@example.org",
+		Subject: "hello",
+		Body:    "body",
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "To: adam@example.org\r\n") ||
+		!strings.HasSuffix(buf.String(), "\r\n\r\nbody\r\n.\r\n") {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
+
+// flakySender fails the first `failures` sends of each email.
+type flakySender struct {
+	failures int
+
+	lock     sync.Mutex
+	attempts map[string]int
+	sent     chan *types.Email
+}
+
+func (fs *flakySender) SendEmail(e *types.Email) error {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	fs.attempts[e.To]++
+	if fs.attempts[e.To] <= fs.failures {
+		return errors.New("service unavailable")
+	}
+	fs.sent <- e
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	for _, testCase := range []struct {
+		name           string
+		failures       int
+		maxAttempts    int
+		wantedSent     bool
+		wantedAttempts int
+	}{
+		{
+			name:           "sent first time",
+			maxAttempts:    3,
+			wantedSent:     true,
+			wantedAttempts: 1,
+		},
+		{
+			name:           "sent after retries",
+			failures:       2,
+			maxAttempts:    3,
+			wantedSent:     true,
+			wantedAttempts: 3,
+		},
+		{
+			name:           "dropped after max attempts",
+			failures:       3,
+			maxAttempts:    3,
+			wantedAttempts: 3,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			sender := flakySender{
+				failures: testCase.failures,
+				attempts: map[string]int{},
+				sent:     make(chan *types.Email, 1),
+			}
+			queue := Queue{
+				Sender: &sender,
+				Jobs: jobs.Queue{
+					MaxAttempts: testCase.maxAttempts,
+					Backoff:     time.Millisecond,
+					Interval:    time.Millisecond,
+				},
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go queue.Run(ctx)
+
+			if err := queue.SendEmail(&types.Email{
+				To: "adam@example.org",
+			}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			select {
+			case <-sender.sent:
+				if !testCase.wantedSent {
+					t.Fatal("wanted email to be dropped; found sent")
+				}
+			case <-time.After(100 * time.Millisecond):
+				if testCase.wantedSent {
+					t.Fatal("wanted email to be sent; timed out")
+				}
+			}
+
+			sender.lock.Lock()
+			defer sender.lock.Unlock()
+			found := sender.attempts["adam@example.org"]
+			if found != testCase.wantedAttempts {
+				t.Fatalf(
+					"attempts: wanted `%d`; found `%d`",
+					testCase.wantedAttempts,
+					found,
+				)
+			}
+		})
+	}
+}
+
+func TestQueue_Full(t *testing.T) {
+	queue := Queue{
+		Sender: &LogSender{},
+		Jobs:   jobs.Queue{Store: &jobs.MemoryStore{Size: 1}},
+	}
+	if err := queue.SendEmail(&types.Email{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := queue.SendEmail(&types.Email{}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("wanted `ErrQueueFull`; found `%v`", err)
+	}
+}
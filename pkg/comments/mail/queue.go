@@ -0,0 +1,82 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/weberc2/comments/pkg/comments/jobs"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// JobKind identifies queued emails in a `types.JobsStore`.
+const JobKind = "email"
+
+// ErrQueueFull is returned when an email doesn't fit in the queue's store.
+var ErrQueueFull = jobs.ErrQueueFull
+
+// Queue sends emails asynchronously via another sender so that callers aren't
+// blocked by slow mail servers. Emails are queued as jobs (see `jobs.Queue`),
+// so failed sends are retried with exponential backoff and emails which fail
+// every attempt are dropped. Emails are only sent while `Run()` is running.
+type Queue struct {
+	Sender types.EmailSender
+
+	// Jobs configures the queue's store, attempts and backoff. Its `Kind` and
+	// `Handler` are set by the `Queue`.
+	Jobs jobs.Queue
+
+	once sync.Once
+}
+
+func (q *Queue) init() {
+	q.once.Do(func() {
+		q.Jobs.Kind = JobKind
+		q.Jobs.Handler = (*emailHandler)(q)
+	})
+}
+
+// SendEmail queues an email without blocking. If the queue is full,
+// `ErrQueueFull` is returned.
+func (q *Queue) SendEmail(e *types.Email) error {
+	q.init()
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshaling email: %w", err)
+	}
+	return q.Jobs.Enqueue(uuid.NewString(), payload)
+}
+
+// Run sends queued emails until the context is canceled.
+func (q *Queue) Run(ctx context.Context) {
+	q.init()
+	q.Jobs.Run(ctx)
+}
+
+// emailHandler implements `jobs.Handler` for a `Queue`.
+type emailHandler Queue
+
+func (h *emailHandler) Attempt(ctx context.Context, j *types.Job) error {
+	var e types.Email
+	if err := json.Unmarshal(j.Payload, &e); err != nil {
+		return fmt.Errorf("unmarshaling email: %w", err)
+	}
+	return h.Sender.SendEmail(&e)
+}
+
+func (h *emailHandler) Abandon(j *types.Job, err error) {
+	var e types.Email
+	if err := json.Unmarshal(j.Payload, &e); err != nil {
+		log.Printf("giving up on email `%s`: %v", j.ID, err)
+		return
+	}
+	log.Printf(
+		"giving up on email to `%s` after %d attempts: %v",
+		e.To,
+		j.Attempts,
+		err,
+	)
+}
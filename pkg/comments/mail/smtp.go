@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"fmt"
+	"net/smtp"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// SMTPSender sends emails via an SMTP server.
+type SMTPSender struct {
+	// Addr is the server's `host:port`.
+	Addr string
+
+	// From is the sender's address.
+	From string
+
+	// Auth authenticates with the server. If it's nil, no authentication is
+	// attempted.
+	Auth smtp.Auth
+}
+
+func (ss *SMTPSender) SendEmail(e *types.Email) error {
+	msg, err := message(ss.From, e, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(
+		ss.Addr,
+		ss.Auth,
+		ss.From,
+		[]string{e.To},
+		msg,
+	); err != nil {
+		return fmt.Errorf("sending email via smtp: %w", err)
+	}
+	return nil
+}
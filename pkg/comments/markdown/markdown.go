@@ -0,0 +1,144 @@
+// Package markdown renders comment bodies from a restricted subset of
+// Markdown to HTML.
+//
+// Rather than rendering arbitrary Markdown and sanitizing the result, the
+// renderer only ever emits the elements in its allowlist: paragraphs, line
+// breaks, links, emphasis, inline code, code blocks, block quotes, and lists.
+// All text is escaped, raw HTML in the source is rendered as text, and
+// anything else (headings, images, rules, etc) is reduced to its text
+// content. Links are only rendered for `http`, `https`, and `mailto` URLs and
+// they're marked `rel="nofollow ugc"`.
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"net/url"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+const extensions = blackfriday.NoIntraEmphasis |
+	blackfriday.FencedCode |
+	blackfriday.Autolink |
+	blackfriday.BackslashLineBreak
+
+// allowedSchemes are the URL schemes which may be linked to.
+var allowedSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// Render renders Markdown source to sanitized HTML.
+func Render(source string) string {
+	return string(bytes.TrimSpace(blackfriday.Run(
+		[]byte(source),
+		blackfriday.WithExtensions(extensions),
+		blackfriday.WithRenderer(renderer{}),
+	)))
+}
+
+// renderer implements `blackfriday.Renderer`.
+type renderer struct{}
+
+func (renderer) RenderHeader(io.Writer, *blackfriday.Node) {}
+
+func (renderer) RenderFooter(io.Writer, *blackfriday.Node) {}
+
+func (renderer) RenderNode(
+	w io.Writer,
+	node *blackfriday.Node,
+	entering bool,
+) blackfriday.WalkStatus {
+	switch node.Type {
+	case blackfriday.Text, blackfriday.HTMLSpan:
+		writeEscaped(w, node.Literal)
+	case blackfriday.HTMLBlock:
+		tag(w, "p", entering)
+		writeEscaped(w, node.Literal)
+		tag(w, "p", false)
+	case blackfriday.Paragraph:
+		// like blackfriday's HTML renderer, tight lists' items aren't
+		// wrapped in paragraphs.
+		if !inTightList(node) {
+			tag(w, "p", entering)
+		}
+	case blackfriday.Heading:
+		tag(w, "p", entering)
+	case blackfriday.Softbreak:
+		io.WriteString(w, "\n")
+	case blackfriday.Hardbreak:
+		io.WriteString(w, "<br>\n")
+	case blackfriday.Emph:
+		tag(w, "em", entering)
+	case blackfriday.Strong:
+		tag(w, "strong", entering)
+	case blackfriday.Code:
+		tag(w, "code", true)
+		writeEscaped(w, node.Literal)
+		tag(w, "code", false)
+	case blackfriday.CodeBlock:
+		io.WriteString(w, "<pre><code>")
+		writeEscaped(w, node.Literal)
+		io.WriteString(w, "</code></pre>\n")
+	case blackfriday.BlockQuote:
+		tag(w, "blockquote", entering)
+	case blackfriday.List:
+		if node.ListFlags&blackfriday.ListTypeOrdered != 0 {
+			tag(w, "ol", entering)
+		} else {
+			tag(w, "ul", entering)
+		}
+	case blackfriday.Item:
+		tag(w, "li", entering)
+	case blackfriday.Link:
+		// links to disallowed URLs are reduced to their text
+		if !allowedURL(node.LinkData.Destination) {
+			break
+		}
+		if entering {
+			io.WriteString(w, `<a href="`)
+			writeEscaped(w, node.LinkData.Destination)
+			io.WriteString(w, `" rel="nofollow ugc">`)
+		} else {
+			io.WriteString(w, "</a>")
+		}
+	}
+
+	// Every other node type (the document itself, images, rules, etc) is
+	// reduced to its children's text.
+	return blackfriday.GoToNext
+}
+
+func tag(w io.Writer, name string, entering bool) {
+	if entering {
+		io.WriteString(w, "<"+name+">")
+		switch name {
+		case "blockquote", "ol", "ul":
+			io.WriteString(w, "\n")
+		}
+		return
+	}
+	io.WriteString(w, "</"+name+">")
+	switch name {
+	case "p", "blockquote", "ol", "ul", "li":
+		io.WriteString(w, "\n")
+	}
+}
+
+func writeEscaped(w io.Writer, text []byte) {
+	io.WriteString(w, html.EscapeString(string(text)))
+}
+
+func inTightList(paragraph *blackfriday.Node) bool {
+	item := paragraph.Parent
+	return item != nil && item.Type == blackfriday.Item &&
+		item.Parent != nil && item.Parent.Tight
+}
+
+func allowedURL(destination []byte) bool {
+	u, err := url.Parse(string(destination))
+	return err == nil && allowedSchemes[u.Scheme]
+}
@@ -0,0 +1,95 @@
+package markdown
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	for _, testCase := range []struct {
+		name   string
+		source string
+		wanted string
+	}{
+		{
+			name:   "paragraph",
+			source: "hello, world",
+			wanted: "<p>hello, world</p>",
+		},
+		{
+			name:   "emphasis",
+			source: "*hello* __world__",
+			wanted: "<p><em>hello</em> <strong>world</strong></p>",
+		},
+		{
+			name:   "inline code",
+			source: "call `f(<x>)`",
+			wanted: "<p>call <code>f(&lt;x&gt;)</code></p>",
+		},
+		{
+			name:   "code block",
+			source: "```go\nif a < b {}\n```",
+			wanted: "<pre><code>if a &lt; b {}\n</code></pre>",
+		},
+		{
+			name:   "block quote",
+			source: "> quoted",
+			wanted: "<blockquote>\n<p>quoted</p>\n</blockquote>",
+		},
+		{
+			name:   "unordered list",
+			source: "- one\n- two",
+			wanted: "<ul>\n<li>one</li>\n<li>two</li>\n</ul>",
+		},
+		{
+			name:   "ordered list",
+			source: "1. one\n2. two",
+			wanted: "<ol>\n<li>one</li>\n<li>two</li>\n</ol>",
+		},
+		{
+			name:   "link",
+			source: "[example](https://example.org/?a=1&b=2)",
+			wanted: `<p><a href="https://example.org/?a=1&amp;b=2" ` +
+				`rel="nofollow ugc">example</a></p>`,
+		},
+		{
+			name:   "autolink",
+			source: "see https://example.org",
+			wanted: `<p>see <a href="https://example.org" ` +
+				`rel="nofollow ugc">https://example.org</a></p>`,
+		},
+		{
+			name:   "javascript link",
+			source: "[click](javascript:alert)",
+			wanted: "<p>click</p>",
+		},
+		{
+			name:   "relative link",
+			source: "[click](/admin)",
+			wanted: "<p>click</p>",
+		},
+		{
+			name:   "inline html is escaped",
+			source: `hi <script>alert("x")</script>`,
+			wanted: "<p>hi &lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</p>",
+		},
+		{
+			name:   "html block is escaped",
+			source: "<div onclick=\"x()\">hi</div>",
+			wanted: "<p>&lt;div onclick=&#34;x()&#34;&gt;hi&lt;/div&gt;</p>",
+		},
+		{
+			name:   "image is reduced to alt text",
+			source: "![alt text](https://example.org/x.png)",
+			wanted: "<p>alt text</p>",
+		},
+		{
+			name:   "heading is reduced to paragraph",
+			source: "# title",
+			wanted: "<p>title</p>",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if found := Render(testCase.source); found != testCase.wanted {
+				t.Fatalf("wanted:\n%s\n\nfound:\n%s", testCase.wanted, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package comments
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// premoderate reports whether new comments on the post go into the moderation
+// queue.
+func (cm *CommentsModel) premoderate(post types.PostID) (bool, error) {
+	if cm.Premoderate {
+		return true, nil
+	}
+	settings, err := cm.PostSettings(post)
+	if err != nil {
+		return false, err
+	}
+	return settings.Premoderate, nil
+}
+
+// PostSettings returns the post's settings. Posts without settings get the
+// default settings.
+func (cm *CommentsModel) PostSettings(
+	post types.PostID,
+) (*types.PostSettings, error) {
+	if cm.PostSettingsStore == nil {
+		return &types.PostSettings{Post: post}, nil
+	}
+	settings, err := cm.PostSettingsStore.PostSettings(post)
+	if err != nil {
+		if errors.Is(err, types.ErrPostSettingsNotFound) {
+			return &types.PostSettings{Post: post}, nil
+		}
+		return nil, fmt.Errorf("fetching post settings: %w", err)
+	}
+	return settings, nil
+}
+
+// PutPostSettings replaces the post's settings. Only moderators can change
+// post settings.
+func (cm *CommentsModel) PutPostSettings(
+	moderator types.UserID,
+	settings *types.PostSettings,
+) error {
+	if err := cm.requireModerator(moderator); err != nil {
+		return err
+	}
+	if settings.Post == "" {
+		return ErrInvalidPost
+	}
+	if cm.PostSettingsStore == nil {
+		return fmt.Errorf("putting post settings: no post settings store")
+	}
+	if err := cm.PostSettingsStore.PutPostSettings(settings); err != nil {
+		return fmt.Errorf("putting post settings: %w", err)
+	}
+	return nil
+}
+
+// Queue returns the comments which are pending moderation, oldest first.
+// Only moderators can view the queue.
+func (cm *CommentsModel) Queue(
+	moderator types.UserID,
+) ([]*types.Comment, error) {
+	if err := cm.requireModerator(moderator); err != nil {
+		return nil, err
+	}
+	comments, err := cm.CommentsStore.CommentsByStatus(types.StatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("fetching moderation queue: %w", err)
+	}
+	redact(comments)
+	render(comments...)
+	return comments, nil
+}
+
+// Moderate sets a comment's moderation status to `types.StatusApproved` or
+// `types.StatusRejected`, resolving the comment's open reports. Only
+// moderators can moderate comments.
+func (cm *CommentsModel) Moderate(
+	moderator types.UserID,
+	post types.PostID,
+	comment types.CommentID,
+	status types.Status,
+) error {
+	if err := cm.requireModerator(moderator); err != nil {
+		return err
+	}
+	if status != types.StatusApproved && status != types.StatusRejected {
+		return types.ErrInvalidStatus
+	}
+	c, err := cm.CommentsStore.Comment(post, comment)
+	if err != nil {
+		return fmt.Errorf("moderating comment: %w", err)
+	}
+	publish, err := cm.publishedByApproval(c, status)
+	if err != nil {
+		return fmt.Errorf("moderating comment: %w", err)
+	}
+	if err := cm.CommentsStore.Update(
+		types.NewCommentPatch(comment, post).SetStatus(status),
+	); err != nil {
+		return fmt.Errorf("moderating comment: %w", err)
+	}
+	if publish {
+		c.Status = status
+		cm.published(c)
+	}
+	return cm.resolveReports(post, comment)
+}
+
+// publishedByApproval reports whether moderating the comment with the status
+// approves a held comment which no one has been told about yet. Comments
+// which are pending because they were reported had already been approved
+// (and told about) before.
+func (cm *CommentsModel) publishedByApproval(
+	c *types.Comment,
+	status types.Status,
+) (bool, error) {
+	if status != types.StatusApproved ||
+		c.Status != types.StatusPending ||
+		c.Deleted {
+		return false, nil
+	}
+	if cm.ReportsStore == nil {
+		return true, nil
+	}
+	reports, err := cm.ReportsStore.CountOpenReports(c.Post, c.ID)
+	if err != nil {
+		return false, fmt.Errorf("counting open reports: %w", err)
+	}
+	return reports < 1, nil
+}
@@ -0,0 +1,326 @@
+package comments
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/testsupport"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func moderationState() testsupport.CommentsStoreFake {
+	return testsupport.CommentsStoreFake{
+		"post": {
+			"approved": {
+				ID:       "approved",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime,
+				Modified: someTime,
+				Body:     "body",
+				Status:   types.StatusApproved,
+			},
+			"pending": {
+				ID:       "pending",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime.Add(time.Hour),
+				Modified: someTime.Add(time.Hour),
+				Body:     "body",
+				Status:   types.StatusPending,
+			},
+			"pending-child": {
+				ID:       "pending-child",
+				Post:     "post",
+				Parent:   "pending",
+				Author:   "other",
+				Created:  someTime.Add(2 * time.Hour),
+				Modified: someTime.Add(2 * time.Hour),
+				Body:     "body",
+				Status:   types.StatusApproved,
+			},
+			"rejected": {
+				ID:       "rejected",
+				Post:     "post",
+				Author:   "author",
+				Created:  someTime.Add(3 * time.Hour),
+				Modified: someTime.Add(3 * time.Hour),
+				Body:     "body",
+				Status:   types.StatusRejected,
+			},
+		},
+	}
+}
+
+func TestCommentsModel_Moderate(t *testing.T) {
+	for _, testCase := range []struct {
+		name         string
+		moderator    types.UserID
+		comment      types.CommentID
+		status       types.Status
+		wantedStatus types.Status
+		wantedErr    types.WantedError
+	}{
+		{
+			name:         "approve",
+			moderator:    "moderator",
+			comment:      "pending",
+			status:       types.StatusApproved,
+			wantedStatus: types.StatusApproved,
+		},
+		{
+			name:         "reject",
+			moderator:    "moderator",
+			comment:      "pending",
+			status:       types.StatusRejected,
+			wantedStatus: types.StatusRejected,
+		},
+		{
+			name:         "not a moderator",
+			moderator:    "author",
+			comment:      "pending",
+			status:       types.StatusApproved,
+			wantedStatus: types.StatusPending,
+			wantedErr:    ErrForbidden,
+		},
+		{
+			name:         "invalid status",
+			moderator:    "moderator",
+			comment:      "pending",
+			status:       types.StatusPending,
+			wantedStatus: types.StatusPending,
+			wantedErr:    types.ErrInvalidStatus,
+		},
+		{
+			name:      "missing comment",
+			moderator: "moderator",
+			comment:   "missing",
+			status:    types.StatusApproved,
+			wantedErr: types.ErrCommentNotFound,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.wantedErr == nil {
+				testCase.wantedErr = types.NilError{}
+			}
+
+			state := moderationState()
+			model := CommentsModel{
+				CommentsStore: state,
+				Roles:         testsupport.RolesStoreFake{"moderator": types.RoleModerator},
+				TimeFunc:      func() time.Time { return now },
+			}
+
+			if err := testCase.wantedErr.CompareErr(model.Moderate(
+				testCase.moderator,
+				"post",
+				testCase.comment,
+				testCase.status,
+			)); err != nil {
+				t.Fatal(err)
+			}
+
+			if testCase.wantedStatus == "" {
+				return
+			}
+			found := state["post"][testCase.comment].Status
+			if found != testCase.wantedStatus {
+				t.Fatalf(
+					"Comment.Status: wanted `%s`; found `%s`",
+					testCase.wantedStatus,
+					found,
+				)
+			}
+		})
+	}
+}
+
+func TestCommentsModel_Queue(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		moderator types.UserID
+		wanted    []types.CommentID
+		wantedErr types.WantedError
+	}{
+		{
+			name:      "lists pending comments",
+			moderator: "moderator",
+			wanted:    []types.CommentID{"pending"},
+		},
+		{
+			name:      "not a moderator",
+			moderator: "author",
+			wantedErr: ErrForbidden,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.wantedErr == nil {
+				testCase.wantedErr = types.NilError{}
+			}
+
+			model := CommentsModel{
+				CommentsStore: moderationState(),
+				Roles:         testsupport.RolesStoreFake{"moderator": types.RoleModerator},
+			}
+
+			comments, err := model.Queue(testCase.moderator)
+			if err := testCase.wantedErr.CompareErr(err); err != nil {
+				t.Fatal(err)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(comments) != len(testCase.wanted) {
+				t.Fatalf(
+					"len(comments): wanted `%d`; found `%d`",
+					len(testCase.wanted),
+					len(comments),
+				)
+			}
+			for i, c := range comments {
+				if c.ID != testCase.wanted[i] {
+					t.Fatalf(
+						"comments[%d].ID: wanted `%s`; found `%s`",
+						i,
+						testCase.wanted[i],
+						c.ID,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestCommentsModel_PutPostSettings(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		moderator types.UserID
+		settings  types.PostSettings
+		wanted    types.PostSettings
+		wantedErr types.WantedError
+	}{
+		{
+			name:      "premoderate",
+			moderator: "moderator",
+			settings:  types.PostSettings{Post: "post", Premoderate: true},
+			wanted:    types.PostSettings{Post: "post", Premoderate: true},
+		},
+		{
+			name:      "not a moderator",
+			moderator: "author",
+			settings:  types.PostSettings{Post: "post", Premoderate: true},
+			wanted:    types.PostSettings{Post: "post"},
+			wantedErr: ErrForbidden,
+		},
+		{
+			name:      "missing post",
+			moderator: "moderator",
+			settings:  types.PostSettings{Premoderate: true},
+			wanted:    types.PostSettings{Post: "post"},
+			wantedErr: ErrInvalidPost,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.wantedErr == nil {
+				testCase.wantedErr = types.NilError{}
+			}
+
+			model := CommentsModel{
+				CommentsStore:     testsupport.CommentsStoreFake{},
+				PostSettingsStore: testsupport.PostSettingsStoreFake{},
+				Roles:             testsupport.RolesStoreFake{"moderator": types.RoleModerator},
+			}
+
+			if err := testCase.wantedErr.CompareErr(model.PutPostSettings(
+				testCase.moderator,
+				&testCase.settings,
+			)); err != nil {
+				t.Fatal(err)
+			}
+
+			settings, err := model.PostSettings("post")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *settings != testCase.wanted {
+				t.Fatalf(
+					"PostSettings: wanted `%+v`; found `%+v`",
+					testCase.wanted,
+					*settings,
+				)
+			}
+		})
+	}
+}
+
+func TestCommentsModel_RepliesPage_Moderation(t *testing.T) {
+	for _, testCase := range []struct {
+		name   string
+		viewer types.UserID
+		wanted []types.CommentID
+	}{
+		{
+			name:   "anonymous",
+			wanted: []types.CommentID{"approved"},
+		},
+		{
+			name:   "other user",
+			viewer: "other",
+			wanted: []types.CommentID{"approved"},
+		},
+		{
+			name:   "author",
+			viewer: "author",
+			wanted: []types.CommentID{
+				"approved",
+				"pending",
+				"rejected",
+				"pending-child",
+			},
+		},
+		{
+			name:   "moderator",
+			viewer: "moderator",
+			wanted: []types.CommentID{
+				"approved",
+				"pending",
+				"rejected",
+				"pending-child",
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			model := CommentsModel{
+				CommentsStore: moderationState(),
+				Roles:         testsupport.RolesStoreFake{"moderator": types.RoleModerator},
+			}
+
+			page, err := model.RepliesPage(&types.RepliesQuery{
+				Post:   "post",
+				Viewer: testCase.viewer,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(page.Comments) != len(testCase.wanted) {
+				t.Fatalf(
+					"len(page.Comments): wanted `%d`; found `%d`",
+					len(testCase.wanted),
+					len(page.Comments),
+				)
+			}
+			for i, c := range page.Comments {
+				if c.ID != testCase.wanted[i] {
+					t.Fatalf(
+						"page.Comments[%d].ID: wanted `%s`; found `%s`",
+						i,
+						testCase.wanted[i],
+						c.ID,
+					)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package comments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/weberc2/comments/pkg/comments/jobs"
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+var ErrInvalidUnsubscribeToken = &pz.HTTPError{
+	Status:  http.StatusForbidden,
+	Message: "invalid unsubscribe link",
+}
+
+// ReplyNotifier tells users about replies to their comments.
+type ReplyNotifier interface {
+	NotifyReply(parent, reply *types.Comment) error
+}
+
+// published tells the users who follow the comment's thread about a comment
+// which has just become visible to everyone, i.e., it was created approved or
+// a moderator approved it. No one is told about shadow-banned users'
+// comments, since they're only visible to their authors. The parent's author
+// is told about replies asynchronously (see `ReplyNotifications`).
+func (cm *CommentsModel) published(c *types.Comment) {
+	active, err := cm.activeSanctions(c.Author)
+	if err != nil {
+		log.Printf("notifying about comment `%s`: %v", c.ID, err)
+		return
+	}
+	if active[types.SanctionShadowBan] != nil {
+		return
+	}
+	cm.deliver(c)
+}
+
+// NotifyReply tells the parent's author about a reply which has become
+// visible to everyone (unless they wrote it, or the reply's author is
+// shadow-banned). The reply is looked up when the notification is sent, so a
+// reply which has been deleted or hidden (e.g., by reports) since it became
+// visible is skipped; if a moderator approves it later, it becomes visible
+// again, and its notification is queued again (see `ReplyNotifications`).
+func (cm *CommentsModel) NotifyReply(
+	post types.PostID,
+	comment types.CommentID,
+) error {
+	if cm.ReplyNotifier == nil {
+		return nil
+	}
+	reply, err := cm.CommentsStore.Comment(post, comment)
+	if err != nil {
+		if errors.Is(err, types.ErrCommentNotFound) {
+			return nil
+		}
+		return fmt.Errorf("fetching reply: %w", err)
+	}
+	if reply.Parent == "" || reply.Deleted || !reply.Status.Visible() {
+		return nil
+	}
+	active, err := cm.activeSanctions(reply.Author)
+	if err != nil {
+		return err
+	}
+	if active[types.SanctionShadowBan] != nil {
+		return nil
+	}
+
+	parent, err := cm.CommentsStore.Comment(post, reply.Parent)
+	if err != nil {
+		if errors.Is(err, types.ErrCommentNotFound) {
+			return nil
+		}
+		return fmt.Errorf("fetching parent: %w", err)
+	}
+	if parent.Deleted ||
+		parent.Author == "" ||
+		parent.Author == reply.Author {
+		return nil
+	}
+	return cm.ReplyNotifier.NotifyReply(parent, reply)
+}
+
+// ReplyNotificationsJobKind identifies queued reply notifications in a
+// `types.JobsStore`.
+const ReplyNotificationsJobKind = "reply-notification"
+
+// ReplyNotifications tells the parents' authors about replies once they're
+// visible to everyone (see `CommentsModel.NotifyReply()`). It's a
+// `types.EventSink` for the events which are relayed from the store's outbox,
+// so a reply's notification is recorded if and only if the reply is. Each
+// `types.EventCommentCreated` event only queues a job (see `jobs.Queue`), so
+// neither comment submissions nor the relay wait for the notifier's lookups
+// or for mail servers, and failed notifications are retried. Notifications
+// are only sent while `Run()` is running, and they're only kept across
+// restarts if `Jobs.Store` is durable.
+type ReplyNotifications struct {
+	Comments *CommentsModel
+
+	// Jobs configures the notifications' store, attempts and backoff. Its
+	// `Kind` and `Handler` are set by `ReplyNotifications`.
+	Jobs jobs.Queue
+
+	once sync.Once
+}
+
+// replyNotification is the payload of a reply notification's job.
+type replyNotification struct {
+	Post    types.PostID    `json:"post"`
+	Comment types.CommentID `json:"comment"`
+}
+
+func (rn *ReplyNotifications) init() {
+	rn.once.Do(func() {
+		rn.Jobs.Kind = ReplyNotificationsJobKind
+		rn.Jobs.Handler = (*replyNotificationsHandler)(rn)
+	})
+}
+
+// Publish implements `types.EventSink`. Each job's ID is derived from the
+// event's ID so that an event which is published again while its
+// notification is queued isn't queued twice. Notifications are at-least-once,
+// though: jobs are removed once they're done, so an event which is published
+// again afterwards is notified again. If the queue is full, the notification
+// is dropped; if queueing fails for any other reason, an error is returned so
+// that the event is published again.
+func (rn *ReplyNotifications) Publish(e *types.Event) error {
+	rn.init()
+	if e.Type != types.EventCommentCreated || e.Comment.Parent == "" {
+		return nil
+	}
+	payload, err := json.Marshal(&replyNotification{
+		Post:    e.Comment.Post,
+		Comment: e.Comment.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling reply notification: %w", err)
+	}
+	if err := rn.Jobs.Enqueue(
+		strconv.FormatInt(e.ID, 10),
+		payload,
+	); err != nil {
+		if !errors.Is(err, jobs.ErrQueueFull) {
+			return fmt.Errorf("queueing reply notification: %w", err)
+		}
+		log.Printf(
+			"dropping notification of reply `%s` on post `%s`: %v",
+			e.Comment.ID,
+			e.Comment.Post,
+			err,
+		)
+	}
+	return nil
+}
+
+// Run sends queued notifications until the context is canceled.
+func (rn *ReplyNotifications) Run(ctx context.Context) {
+	rn.init()
+	rn.Jobs.Run(ctx)
+}
+
+// replyNotificationsHandler implements `jobs.Handler` for
+// `ReplyNotifications`.
+type replyNotificationsHandler ReplyNotifications
+
+func (h *replyNotificationsHandler) Attempt(
+	ctx context.Context,
+	j *types.Job,
+) error {
+	var n replyNotification
+	if err := json.Unmarshal(j.Payload, &n); err != nil {
+		return fmt.Errorf("unmarshaling reply notification: %w", err)
+	}
+	return h.Comments.NotifyReply(n.Post, n.Comment)
+}
+
+func (h *replyNotificationsHandler) Abandon(j *types.Job, err error) {
+	log.Printf(
+		"giving up on reply notification `%s` after %d attempts: %v",
+		j.ID,
+		j.Attempts,
+		err,
+	)
+}
+
+var (
+	// fail compilation if `ReplyNotifications` doesn't implement the
+	// `types.EventSink` interface.
+	_ types.EventSink = &ReplyNotifications{}
+)
+
+// NotificationSettings returns the user's notification settings. Users
+// without settings get the default settings.
+func (cm *CommentsModel) NotificationSettings(
+	user types.UserID,
+) (*types.NotificationSettings, error) {
+	return notificationSettings(cm.NotificationSettingsStore, user)
+}
+
+func notificationSettings(
+	store types.NotificationSettingsStore,
+	user types.UserID,
+) (*types.NotificationSettings, error) {
+	if store == nil {
+		return types.DefaultNotificationSettings(user), nil
+	}
+	settings, err := store.NotificationSettings(user)
+	if err != nil {
+		if errors.Is(err, types.ErrNotificationSettingsNotFound) {
+			return types.DefaultNotificationSettings(user), nil
+		}
+		return nil, fmt.Errorf("fetching notification settings: %w", err)
+	}
+	return settings, nil
+}
+
+// PutNotificationSettings replaces the settings' user's notification
+// settings.
+func (cm *CommentsModel) PutNotificationSettings(
+	settings *types.NotificationSettings,
+) error {
+	if settings.User == "" {
+		return fmt.Errorf("putting notification settings: %w", ErrInvalidUser)
+	}
+	if cm.NotificationSettingsStore == nil {
+		return fmt.Errorf(
+			"putting notification settings: no notification settings store",
+		)
+	}
+	if err := cm.NotificationSettingsStore.PutNotificationSettings(
+		settings,
+	); err != nil {
+		return fmt.Errorf("putting notification settings: %w", err)
+	}
+	return nil
+}
+
+// UnsubscribeTokens issues and checks the tokens in the unsubscribe links of
+// notification emails. A token is an HMAC of the user ID, so the links work
+// without logging in and can't be forged for other users.
+type UnsubscribeTokens struct {
+	Key []byte
+}
+
+// Token returns the user's unsubscribe token.
+func (ut *UnsubscribeTokens) Token(user types.UserID) string {
+	mac := hmac.New(sha256.New, ut.Key)
+	mac.Write([]byte("unsubscribe:"))
+	mac.Write([]byte(user))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Valid reports whether `token` is the user's unsubscribe token.
+func (ut *UnsubscribeTokens) Valid(user types.UserID, token string) bool {
+	return user != "" && hmac.Equal([]byte(ut.Token(user)), []byte(token))
+}
+
+// unsubscribePath is the path of the page which turns off reply emails.
+const unsubscribePath = "/notifications/unsubscribe"
+
+// EmailNotifier emails users about replies to their comments, unless they've
+// opted out. Each email has a signed one-click unsubscribe link. The sender
+// should be asynchronous (e.g., a `mail.Queue`) so that slow mail servers
+// don't hold up other notifications.
+type EmailNotifier struct {
+	Settings          types.NotificationSettingsStore
+	Addresses         types.AddressBook
+	Sender            types.EmailSender
+	UnsubscribeTokens *UnsubscribeTokens
+
+	// BaseURL is the service's URL, which links in emails are relative to.
+	BaseURL string
+}
+
+var replyEmailTemplate = template.Must(template.New("").Parse(
+	`{{.Reply.Author}} replied to your comment on {{.Reply.Post}}:
+
+{{.Reply.Body}}
+
+View the reply: {{.ReplyURL}}
+
+--
+To stop getting emails about replies to your comments, visit:
+{{.UnsubscribeURL}}
+`))
+
+func (en *EmailNotifier) NotifyReply(parent, reply *types.Comment) error {
+	settings, err := notificationSettings(en.Settings, parent.Author)
+	if err != nil {
+		return err
+	}
+	if !settings.EmailReplies {
+		return nil
+	}
+	address, err := en.Addresses.EmailAddress(parent.Author)
+	if err != nil {
+		if errors.Is(err, types.ErrAddressNotFound) {
+			return nil
+		}
+		return fmt.Errorf("looking up email address: %w", err)
+	}
+
+	unsubscribeURL := en.UnsubscribeURL(parent.Author)
+	var body strings.Builder
+	if err := replyEmailTemplate.Execute(&body, struct {
+		Reply          *types.Comment
+		ReplyURL       string
+		UnsubscribeURL string
+	}{
+		Reply: reply,
+		ReplyURL: fmt.Sprintf(
+			"%s/posts/%s/comments/%s",
+			strings.TrimSuffix(en.BaseURL, "/"),
+			reply.Post,
+			reply.ID,
+		),
+		UnsubscribeURL: unsubscribeURL,
+	}); err != nil {
+		return fmt.Errorf("rendering reply email: %w", err)
+	}
+
+	if err := en.Sender.SendEmail(&types.Email{
+		To: address,
+		Subject: fmt.Sprintf(
+			"%s replied to your comment on %s",
+			reply.Author,
+			reply.Post,
+		),
+		Body: body.String(),
+		Headers: map[string]string{
+			// RFC 8058 one-click unsubscribe
+			"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}); err != nil {
+		return fmt.Errorf("sending reply email: %w", err)
+	}
+	return nil
+}
+
+// UnsubscribeURL returns the URL which turns off the user's reply emails.
+func (en *EmailNotifier) UnsubscribeURL(user types.UserID) string {
+	return strings.TrimSuffix(en.BaseURL, "/") + unsubscribePath + "?" +
+		url.Values{
+			"user":  {string(user)},
+			"token": {en.UnsubscribeTokens.Token(user)},
+		}.Encode()
+}
@@ -0,0 +1,531 @@
+package comments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/jobs"
+	"github.com/weberc2/comments/pkg/comments/testsupport"
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+type notification struct {
+	parent types.CommentID
+	reply  types.CommentID
+}
+
+type replyNotifierFake struct {
+	notifications []notification
+}
+
+func (rnf *replyNotifierFake) NotifyReply(parent, reply *types.Comment) error {
+	rnf.notifications = append(
+		rnf.notifications,
+		notification{parent.ID, reply.ID},
+	)
+	return nil
+}
+
+func TestCommentsModel_Put_NotifyReply(t *testing.T) {
+	for _, testCase := range []struct {
+		name        string
+		comment     types.Comment
+		premoderate bool
+		shadowBan   bool
+		wanted      []notification
+	}{
+		{
+			name: "reply",
+			comment: types.Comment{
+				Post:   "post",
+				Parent: "approved",
+				Author: "replier",
+				Body:   "a short reply",
+			},
+			wanted: []notification{{"approved", "new"}},
+		},
+		{
+			name: "toplevel",
+			comment: types.Comment{
+				Post:   "post",
+				Author: "replier",
+				Body:   "a toplevel comment",
+			},
+		},
+		{
+			name: "reply to self",
+			comment: types.Comment{
+				Post:   "post",
+				Parent: "approved",
+				Author: "author",
+				Body:   "a short reply",
+			},
+		},
+		{
+			name: "held reply",
+			comment: types.Comment{
+				Post:   "post",
+				Parent: "approved",
+				Author: "replier",
+				Body:   "a short reply",
+			},
+			premoderate: true,
+		},
+		{
+			name: "shadow-banned replier",
+			comment: types.Comment{
+				Post:   "post",
+				Parent: "approved",
+				Author: "replier",
+				Body:   "a short reply",
+			},
+			shadowBan: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			sanctions := testsupport.Sanctions()
+			if testCase.shadowBan {
+				sanctions = testsupport.Sanctions(&types.Sanction{
+					User:    "replier",
+					Kind:    types.SanctionShadowBan,
+					Created: now,
+				})
+			}
+			store := testsupport.EventsStoreFake{
+				CommentsStore: moderationState(),
+			}
+			var notifier replyNotifierFake
+			model := CommentsModel{
+				CommentsStore:  &store,
+				Premoderate:    testCase.premoderate,
+				SanctionsStore: sanctions,
+				ReplyNotifier:  &notifier,
+				IDFunc:         func() types.CommentID { return "new" },
+				TimeFunc:       func() time.Time { return now },
+			}
+			if _, err := model.Put(&testCase.comment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// the notification isn't sent until the comment's event has
+			// been relayed
+			if len(notifier.notifications) != 0 {
+				t.Fatalf(
+					"wanted no notifications before relaying; found `%v`",
+					notifier.notifications,
+				)
+			}
+			relayReplies(t, &model, store.Events)
+			checkNotifications(t, testCase.wanted, notifier.notifications)
+		})
+	}
+}
+
+func TestCommentsModel_Moderate_NotifyReply(t *testing.T) {
+	for _, testCase := range []struct {
+		name    string
+		status  types.Status
+		reports []*types.Report
+		wanted  []notification
+	}{
+		{
+			name:   "approved",
+			status: types.StatusApproved,
+			wanted: []notification{{"pending", "pending-reply"}},
+		},
+		{
+			name:   "rejected",
+			status: types.StatusRejected,
+		},
+		{
+			// reports which a moderator dismisses don't suppress the
+			// notification
+			name:   "approved after reports",
+			status: types.StatusApproved,
+			reports: []*types.Report{{
+				Post:     "post",
+				Comment:  "pending-reply",
+				Reporter: "reporter",
+			}},
+			wanted: []notification{{"pending", "pending-reply"}},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			state := moderationState()
+			state["post"]["pending-reply"] = &types.Comment{
+				ID:     "pending-reply",
+				Post:   "post",
+				Parent: "pending",
+				Author: "replier",
+				Body:   "body",
+				Status: types.StatusPending,
+			}
+			store := testsupport.EventsStoreFake{CommentsStore: state}
+			var notifier replyNotifierFake
+			model := CommentsModel{
+				CommentsStore: &store,
+				ReportsStore: &testsupport.ReportsStoreFake{
+					Reports: testCase.reports,
+				},
+				Roles: testsupport.RolesStoreFake{
+					"moderator": types.RoleModerator,
+				},
+				ReplyNotifier: &notifier,
+			}
+			if err := model.Moderate(
+				"moderator",
+				"post",
+				"pending-reply",
+				testCase.status,
+			); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			relayReplies(t, &model, store.Events)
+			checkNotifications(t, testCase.wanted, notifier.notifications)
+		})
+	}
+}
+
+// flakyReplyNotifier fails the first `failures` notifications.
+type flakyReplyNotifier struct {
+	replyNotifierFake
+	failures int
+}
+
+func (frn *flakyReplyNotifier) NotifyReply(
+	parent *types.Comment,
+	reply *types.Comment,
+) error {
+	if frn.failures > 0 {
+		frn.failures--
+		return errors.New("mail server unavailable")
+	}
+	return frn.replyNotifierFake.NotifyReply(parent, reply)
+}
+
+func TestReplyNotifications(t *testing.T) {
+	store := testsupport.EventsStoreFake{CommentsStore: moderationState()}
+	notifier := flakyReplyNotifier{failures: 1}
+	model := CommentsModel{
+		CommentsStore: &store,
+		ReplyNotifier: &notifier,
+		IDFunc:        func() types.CommentID { return "new" },
+		TimeFunc:      func() time.Time { return now },
+	}
+	if _, err := model.Put(&types.Comment{
+		Post:   "post",
+		Parent: "approved",
+		Author: "replier",
+		Body:   "a short reply",
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clock := now
+	notifications := ReplyNotifications{
+		Comments: &model,
+		Jobs: jobs.Queue{
+			Backoff:  time.Minute,
+			TimeFunc: func() time.Time { return clock },
+		},
+	}
+
+	// the relay publishes the event twice (e.g., because it died before
+	// acknowledging it)
+	for i := 0; i < 2; i++ {
+		if err := notifications.Publish(store.Events[0]); err != nil {
+			t.Fatalf("Publish(): unexpected error: %v", err)
+		}
+	}
+
+	// the first attempt fails, so the notification is retried
+	for _, wait := range []time.Duration{0, time.Minute, time.Minute} {
+		clock = clock.Add(wait)
+		if _, err := notifications.Jobs.RunOnce(
+			context.Background(),
+		); err != nil {
+			t.Fatalf("RunOnce(): unexpected error: %v", err)
+		}
+	}
+	checkNotifications(
+		t,
+		[]notification{{"approved", "new"}},
+		notifier.notifications,
+	)
+}
+
+// A reply which is hidden before its notification is sent isn't notified
+// until a moderator approves it.
+func TestReplyNotifications_HiddenReply(t *testing.T) {
+	store := testsupport.EventsStoreFake{CommentsStore: moderationState()}
+	var notifier replyNotifierFake
+	model := CommentsModel{
+		CommentsStore:   &store,
+		ReportsStore:    &testsupport.ReportsStoreFake{},
+		ReportThreshold: 1,
+		Roles: testsupport.RolesStoreFake{
+			"moderator": types.RoleModerator,
+		},
+		ReplyNotifier: &notifier,
+		IDFunc:        func() types.CommentID { return "new" },
+		TimeFunc:      func() time.Time { return now },
+	}
+	if _, err := model.Put(&types.Comment{
+		Post:   "post",
+		Parent: "approved",
+		Author: "replier",
+		Body:   "a short reply",
+	}); err != nil {
+		t.Fatalf("Put(): unexpected error: %v", err)
+	}
+	if _, err := model.Report(&types.Report{
+		Post:     "post",
+		Comment:  "new",
+		Reporter: "reporter",
+		Reason:   "rude",
+	}); err != nil {
+		t.Fatalf("Report(): unexpected error: %v", err)
+	}
+	relayReplies(t, &model, store.Events)
+	checkNotifications(t, nil, notifier.notifications)
+
+	relayed := len(store.Events)
+	if err := model.Moderate(
+		"moderator",
+		"post",
+		"new",
+		types.StatusApproved,
+	); err != nil {
+		t.Fatalf("Moderate(): unexpected error: %v", err)
+	}
+	relayReplies(t, &model, store.Events[relayed:])
+	checkNotifications(
+		t,
+		[]notification{{"approved", "new"}},
+		notifier.notifications,
+	)
+}
+
+// relayReplies publishes the events to `ReplyNotifications` like the outbox's
+// relay does, and then sends the queued notifications.
+func relayReplies(t *testing.T, model *CommentsModel, events []*types.Event) {
+	t.Helper()
+	notifications := ReplyNotifications{Comments: model}
+	for _, e := range events {
+		if err := notifications.Publish(e); err != nil {
+			t.Fatalf("Publish(): unexpected error: %v", err)
+		}
+	}
+	if _, err := notifications.Jobs.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce(): unexpected error: %v", err)
+	}
+}
+
+func checkNotifications(t *testing.T, wanted, found []notification) {
+	t.Helper()
+	if len(found) != len(wanted) {
+		t.Fatalf("notifications: wanted `%v`; found `%v`", wanted, found)
+	}
+	for i := range wanted {
+		if found[i] != wanted[i] {
+			t.Fatalf("notifications: wanted `%v`; found `%v`", wanted, found)
+		}
+	}
+}
+
+type emailSenderFake struct {
+	emails []*types.Email
+}
+
+func (esf *emailSenderFake) SendEmail(e *types.Email) error {
+	esf.emails = append(esf.emails, e)
+	return nil
+}
+
+type addressBookFake map[types.UserID]string
+
+func (abf addressBookFake) EmailAddress(user types.UserID) (string, error) {
+	address, found := abf[user]
+	if !found {
+		return "", types.ErrAddressNotFound
+	}
+	return address, nil
+}
+
+func TestEmailNotifier_NotifyReply(t *testing.T) {
+	parent := types.Comment{ID: "parent", Post: "post", Author: "adam"}
+	reply := types.Comment{
+		ID:     "reply",
+		Post:   "post",
+		Parent: "parent",
+		Author: "eve",
+		Body:   "I disagree",
+	}
+	tokens := UnsubscribeTokens{Key: []byte("key")}
+
+	for _, testCase := range []struct {
+		name     string
+		settings testsupport.NotificationSettingsStoreFake
+		wanted   bool
+	}{
+		{
+			name:     "default settings",
+			settings: testsupport.NotificationSettingsStoreFake{},
+			wanted:   true,
+		},
+		{
+			name: "opted out",
+			settings: testsupport.NotificationSettingsStoreFake{
+				"adam": {User: "adam", EmailReplies: false},
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			var sender emailSenderFake
+			notifier := EmailNotifier{
+				Settings:          testCase.settings,
+				Addresses:         addressBookFake{"adam": "adam@example.org"},
+				Sender:            &sender,
+				UnsubscribeTokens: &tokens,
+				BaseURL:           "https://comments.example.org/",
+			}
+			if err := notifier.NotifyReply(&parent, &reply); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !testCase.wanted {
+				if len(sender.emails) != 0 {
+					t.Fatalf("wanted no emails; found `%d`", len(sender.emails))
+				}
+				return
+			}
+			if len(sender.emails) != 1 {
+				t.Fatalf("wanted 1 email; found `%d`", len(sender.emails))
+			}
+
+			email := sender.emails[0]
+			if email.To != "adam@example.org" {
+				t.Fatalf(
+					"Email.To: wanted `adam@example.org`; found `%s`",
+					email.To,
+				)
+			}
+			unsubscribeURL := "https://comments.example.org" +
+				"/notifications/unsubscribe?token=" + tokens.Token("adam") +
+				"&user=adam"
+			for _, wanted := range []string{
+				"I disagree",
+				"https://comments.example.org/posts/post/comments/reply",
+				unsubscribeURL,
+			} {
+				if !strings.Contains(email.Body, wanted) {
+					t.Fatalf(
+						"Email.Body: missing `%s`:\n%s",
+						wanted,
+						email.Body,
+					)
+				}
+			}
+			found := email.Headers["List-Unsubscribe"]
+			if found != "<"+unsubscribeURL+">" {
+				t.Fatalf(
+					"List-Unsubscribe: wanted `<%s>`; found `%s`",
+					unsubscribeURL,
+					found,
+				)
+			}
+		})
+	}
+
+	// users without an address are skipped
+	var sender emailSenderFake
+	notifier := EmailNotifier{
+		Addresses:         addressBookFake{},
+		Sender:            &sender,
+		UnsubscribeTokens: &tokens,
+	}
+	if err := notifier.NotifyReply(&parent, &reply); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sender.emails) != 0 {
+		t.Fatalf("wanted no emails; found `%d`", len(sender.emails))
+	}
+}
+
+func TestWebServer_Unsubscribe(t *testing.T) {
+	tokens := UnsubscribeTokens{Key: []byte("key")}
+	settings := testsupport.NotificationSettingsStoreFake{}
+	webServer := WebServer{
+		Comments: CommentsModel{
+			NotificationSettingsStore: settings,
+		},
+		BaseURL:           "https://comments.example.org",
+		UnsubscribeTokens: &tokens,
+	}
+	request := func(user, token string) pz.Request {
+		return pz.Request{URL: &url.URL{
+			Path: unsubscribePath,
+			RawQuery: url.Values{
+				"user":  {user},
+				"token": {token},
+			}.Encode(),
+		}}
+	}
+
+	for _, testCase := range []struct {
+		name         string
+		handler      pz.Handler
+		request      pz.Request
+		wantedStatus int
+		wantedEmail  bool
+	}{
+		{
+			name:         "invalid token",
+			handler:      webServer.Unsubscribe,
+			request:      request("adam", tokens.Token("eve")),
+			wantedStatus: http.StatusForbidden,
+			wantedEmail:  true,
+		},
+		{
+			name:         "confirmation form",
+			handler:      webServer.UnsubscribeForm,
+			request:      request("adam", tokens.Token("adam")),
+			wantedStatus: http.StatusOK,
+			wantedEmail:  true,
+		},
+		{
+			name:         "unsubscribe",
+			handler:      webServer.Unsubscribe,
+			request:      request("adam", tokens.Token("adam")),
+			wantedStatus: http.StatusOK,
+			wantedEmail:  false,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			rsp := testCase.handler(testCase.request)
+			if rsp.Status != testCase.wantedStatus {
+				t.Fatalf(
+					"Response.Status: wanted `%d`; found `%d`",
+					testCase.wantedStatus,
+					rsp.Status,
+				)
+			}
+			found, err := webServer.Comments.NotificationSettings("adam")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if found.EmailReplies != testCase.wantedEmail {
+				t.Fatalf(
+					"EmailReplies: wanted `%t`; found `%t`",
+					testCase.wantedEmail,
+					found.EmailReplies,
+				)
+			}
+		})
+	}
+}
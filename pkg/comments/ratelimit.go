@@ -0,0 +1,263 @@
+package comments
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+var ErrRateLimited = &pz.HTTPError{
+	Status:  http.StatusTooManyRequests,
+	Message: "too many requests",
+}
+
+// ClientIPHeader is the request header which `ClientIP()` sets to the
+// client's IP address. `pz.Request` doesn't carry the remote address, so this
+// header is how handlers learn it.
+const ClientIPHeader = "X-Comments-Client-Ip"
+
+// DefaultRateLimits are the limits used for actions which aren't configured
+// otherwise.
+var DefaultRateLimits = map[types.Action]types.Limit{
+	types.ActionCreate: {Count: 5, Per: time.Minute},
+	types.ActionEdit:   {Count: 10, Per: time.Minute},
+	types.ActionDelete: {Count: 10, Per: time.Minute},
+}
+
+// RateLimiter limits how often each user and each client IP may perform each
+// action. Every request takes a token from both the user's and the IP's
+// bucket for the action, and a request which is refused by either bucket
+// takes no tokens from the other.
+type RateLimiter struct {
+	Store types.RateLimitStore
+
+	// Limits are the per-action limits. Actions without a limit aren't
+	// limited.
+	Limits   map[types.Action]types.Limit
+	TimeFunc func() time.Time
+}
+
+// Allow takes a token for the action from the request's user's and client
+// IP's buckets. If either bucket is empty, no tokens are taken and it returns
+// how long the client should wait before retrying; otherwise it returns zero.
+// A nil `RateLimiter` allows every request.
+func (rl *RateLimiter) Allow(
+	action types.Action,
+	r pz.Request,
+) (time.Duration, error) {
+	if rl == nil {
+		return 0, nil
+	}
+	limit := rl.Limits[action]
+	if limit.Unlimited() {
+		return 0, nil
+	}
+
+	var keys []string
+	for _, key := range []struct{ kind, value string }{
+		{"user", r.Headers.Get("User")},
+		{"ip", r.Headers.Get(ClientIPHeader)},
+	} {
+		if key.value != "" {
+			keys = append(
+				keys,
+				fmt.Sprintf("%s:%s:%s", action, key.kind, key.value),
+			)
+		}
+	}
+	if len(keys) < 1 {
+		return 0, nil
+	}
+	retryAfter, err := rl.Store.Take(keys, limit, rl.TimeFunc())
+	if err != nil {
+		return 0, fmt.Errorf("taking %s rate limit token: %w", action, err)
+	}
+	return retryAfter, nil
+}
+
+// Limit wraps an API handler such that requests beyond the action's limit
+// get a `429 Too Many Requests` response with a `Retry-After` header.
+func (rl *RateLimiter) Limit(action types.Action, h pz.Handler) pz.Handler {
+	return func(r pz.Request) pz.Response {
+		retryAfter, err := rl.Allow(action, r)
+		if err != nil {
+			return pz.HandleError("checking rate limit", err)
+		}
+		if retryAfter > 0 {
+			return pz.HandleError("checking rate limit", ErrRateLimited).
+				WithHeaders(retryAfterHeader(retryAfter))
+		}
+		return h(r)
+	}
+}
+
+// retryAfterSeconds rounds a wait up to whole seconds.
+func retryAfterSeconds(retryAfter time.Duration) int {
+	return int(math.Ceil(retryAfter.Seconds()))
+}
+
+func retryAfterHeader(retryAfter time.Duration) http.Header {
+	return http.Header{
+		"Retry-After": []string{strconv.Itoa(retryAfterSeconds(retryAfter))},
+	}
+}
+
+// rateLimitNotice is the message shown to web users who hit a rate limit.
+func rateLimitNotice(retryAfter time.Duration) string {
+	seconds := retryAfterSeconds(retryAfter)
+	unit := "seconds"
+	if seconds == 1 {
+		unit = "second"
+	}
+	return fmt.Sprintf(
+		"You're doing that too often. Please try again in %d %s.",
+		seconds,
+		unit,
+	)
+}
+
+// memoryBucketsSweepMin is the number of buckets a `MemoryRateLimitStore`
+// holds before it starts sweeping out full buckets.
+const memoryBucketsSweepMin = 1024
+
+// MemoryRateLimitStore is an in-memory `types.RateLimitStore`. Its limits
+// only hold within a single process, so deployments with several replicas
+// should use a shared store instead. The zero value is ready to use.
+type MemoryRateLimitStore struct {
+	lock    sync.Mutex
+	buckets map[string]memoryBucket
+	sweepAt int
+}
+
+type memoryBucket struct {
+	types.Bucket
+	limit types.Limit
+}
+
+func (s *MemoryRateLimitStore) Take(
+	keys []string,
+	limit types.Limit,
+	now time.Time,
+) (time.Duration, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.buckets == nil {
+		s.buckets = map[string]memoryBucket{}
+	}
+	if len(s.buckets) >= s.sweepAt {
+		s.sweep(now)
+	}
+
+	buckets := make([]types.Bucket, len(keys))
+	for i, key := range keys {
+		b, found := s.buckets[key]
+		if !found {
+			b.Bucket = limit.Full(now)
+		}
+		buckets[i] = b.Bucket
+	}
+	buckets, retryAfter := limit.TakeAll(buckets, now)
+	for i, key := range keys {
+		s.buckets[key] = memoryBucket{Bucket: buckets[i], limit: limit}
+	}
+	return retryAfter, nil
+}
+
+// sweep deletes the buckets which have had time to refill completely since
+// they're indistinguishable from missing buckets.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.Updated) >= b.limit.Per {
+			delete(s.buckets, key)
+		}
+	}
+	s.sweepAt = 2 * len(s.buckets)
+	if s.sweepAt < memoryBucketsSweepMin {
+		s.sweepAt = memoryBucketsSweepMin
+	}
+}
+
+// ClientIP wraps an HTTP handler, setting each request's `ClientIPHeader` to
+// the client's IP address and overwriting any value sent by the client. The
+// address is the connection's unless it comes from one of the trusted
+// proxies (see `ParseTrustedProxies()`), in which case `X-Forwarded-For` is
+// walked from its last entry (the one appended by the proxy in front of the
+// server) back to the first address which isn't a trusted proxy. Entries
+// before that are ignored since clients can send whatever they like.
+func ClientIP(h http.Handler, trustedProxies []*net.IPNet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		var forwarded []string
+		for _, value := range r.Header.Values("X-Forwarded-For") {
+			for _, entry := range strings.Split(value, ",") {
+				forwarded = append(forwarded, strings.TrimSpace(entry))
+			}
+		}
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			if !trusted(trustedProxies, ip) ||
+				net.ParseIP(forwarded[i]) == nil {
+				break
+			}
+			ip = forwarded[i]
+		}
+		r.Header.Set(ClientIPHeader, ip)
+		h.ServeHTTP(w, r)
+	})
+}
+
+func trusted(proxies []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range proxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustedProxiesNone is the `ParseTrustedProxies()` setting for servers which
+// clients connect to directly.
+const TrustedProxiesNone = "none"
+
+// ParseTrustedProxies parses a comma-separated list of the networks of the
+// reverse proxies in front of the server in CIDR notation (e.g.,
+// `10.0.0.0/8,192.0.2.1/32`), or `TrustedProxiesNone` if there aren't any.
+// There's deliberately no default: behind a proxy, every request would come
+// from the proxy's address, so clients would share a single rate limit, and
+// directly exposed servers mustn't trust `X-Forwarded-For` at all.
+func ParseTrustedProxies(s string) ([]*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if s == TrustedProxiesNone {
+		return nil, nil
+	}
+	if s == "" {
+		return nil, fmt.Errorf(
+			"missing trusted proxies (use `%s` if there aren't any)",
+			TrustedProxiesNone,
+		)
+	}
+	var proxies []*net.IPNet
+	for _, field := range strings.Split(s, ",") {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %w", err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
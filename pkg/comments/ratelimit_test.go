@@ -0,0 +1,398 @@
+package comments
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/testsupport"
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+func TestRateLimiter_Limit(t *testing.T) {
+	request := func(user, ip string) pz.Request {
+		headers := http.Header{}
+		if user != "" {
+			headers.Set("User", user)
+		}
+		if ip != "" {
+			headers.Set(ClientIPHeader, ip)
+		}
+		return pz.Request{Headers: headers}
+	}
+
+	for _, testCase := range []struct {
+		name             string
+		requests         []pz.Request
+		wantedStatus     int
+		wantedRetryAfter string
+	}{
+		{
+			name:         "within limit",
+			requests:     []pz.Request{request("adam", "10.0.0.1")},
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name: "user over limit",
+			requests: []pz.Request{
+				request("adam", "10.0.0.1"),
+				request("adam", "10.0.0.2"),
+				request("adam", "10.0.0.3"),
+			},
+			wantedStatus:     http.StatusTooManyRequests,
+			wantedRetryAfter: "30",
+		},
+		{
+			name: "ip over limit",
+			requests: []pz.Request{
+				request("adam", "10.0.0.1"),
+				request("eve", "10.0.0.1"),
+				request("steve", "10.0.0.1"),
+			},
+			wantedStatus:     http.StatusTooManyRequests,
+			wantedRetryAfter: "30",
+		},
+		{
+			name: "refused requests don't take tokens",
+			requests: []pz.Request{
+				request("adam", "10.0.0.1"),
+				request("adam", "10.0.0.2"),
+				request("adam", "10.0.0.3"), // refused by adam's bucket
+				request("eve", "10.0.0.3"),
+				request("eve", "10.0.0.3"),
+			},
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name: "distinct users and ips",
+			requests: []pz.Request{
+				request("adam", "10.0.0.1"),
+				request("eve", "10.0.0.2"),
+				request("steve", "10.0.0.3"),
+			},
+			wantedStatus: http.StatusOK,
+		},
+		{
+			name: "other actions have their own buckets",
+			requests: []pz.Request{
+				request("adam", "10.0.0.1"),
+				request("adam", "10.0.0.1"),
+			},
+			wantedStatus: http.StatusOK,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+			rl := RateLimiter{
+				Store: &MemoryRateLimitStore{},
+				Limits: map[types.Action]types.Limit{
+					types.ActionCreate: {Count: 2, Per: time.Minute},
+				},
+				TimeFunc: func() time.Time { return now },
+			}
+			// exhaust a different action's limit to make sure it doesn't
+			// affect `ActionCreate`
+			for i := 0; i < 3; i++ {
+				if _, err := rl.Allow(
+					types.ActionEdit,
+					request("adam", "10.0.0.1"),
+				); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			handler := rl.Limit(
+				types.ActionCreate,
+				func(pz.Request) pz.Response { return pz.Ok(nil) },
+			)
+			var rsp pz.Response
+			for _, r := range testCase.requests {
+				rsp = handler(r)
+			}
+
+			if rsp.Status != testCase.wantedStatus {
+				t.Fatalf(
+					"Response.Status: wanted `%d`; found `%d`",
+					testCase.wantedStatus,
+					rsp.Status,
+				)
+			}
+			if found := rsp.Headers.Get("Retry-After"); found !=
+				testCase.wantedRetryAfter {
+				t.Fatalf(
+					"Retry-After: wanted `%s`; found `%s`",
+					testCase.wantedRetryAfter,
+					found,
+				)
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStore_Sweep(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := types.Limit{Count: 1, Per: time.Minute}
+	var store MemoryRateLimitStore
+	for i := 0; i < memoryBucketsSweepMin; i++ {
+		if _, err := store.Take([]string{string(rune(i))}, limit, now); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// after the buckets have refilled, the next `Take()` sweeps them out
+	if _, err := store.Take(
+		[]string{"new"},
+		limit,
+		now.Add(time.Minute),
+	); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.buckets) != 1 {
+		t.Fatalf("wanted `1` bucket; found `%d`", len(store.buckets))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("192.0.2.0/24,10.0.0.2/32")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, testCase := range []struct {
+		name      string
+		proxies   []*net.IPNet
+		remote    string
+		forwarded []string
+		spoofed   string
+		wanted    string
+	}{
+		{
+			name:   "remote address",
+			remote: "203.0.113.1:1234",
+			wanted: "203.0.113.1",
+		},
+		{
+			name:    "client-supplied header is overwritten",
+			remote:  "203.0.113.1:1234",
+			spoofed: "10.0.0.1",
+			wanted:  "203.0.113.1",
+		},
+		{
+			name:      "forwarded for is ignored without trusted proxies",
+			remote:    "192.0.2.1:1234",
+			forwarded: []string{"10.0.0.1"},
+			wanted:    "192.0.2.1",
+		},
+		{
+			name:      "forwarded for is ignored from untrusted addresses",
+			proxies:   proxies,
+			remote:    "203.0.113.1:1234",
+			forwarded: []string{"10.0.0.1"},
+			wanted:    "203.0.113.1",
+		},
+		{
+			name:      "last forwarded for entry",
+			proxies:   proxies,
+			remote:    "192.0.2.1:1234",
+			forwarded: []string{"10.0.0.1, 10.0.0.3", "10.0.0.4"},
+			wanted:    "10.0.0.4",
+		},
+		{
+			name:      "chained trusted proxies",
+			proxies:   proxies,
+			remote:    "192.0.2.1:1234",
+			forwarded: []string{"10.0.0.1, 10.0.0.3, 10.0.0.2"},
+			wanted:    "10.0.0.3",
+		},
+		{
+			name:      "only trusted proxies",
+			proxies:   proxies,
+			remote:    "192.0.2.1:1234",
+			forwarded: []string{"10.0.0.2"},
+			wanted:    "10.0.0.2",
+		},
+		{
+			name:      "invalid entries stop the walk",
+			proxies:   proxies,
+			remote:    "192.0.2.1:1234",
+			forwarded: []string{"10.0.0.1, bogus"},
+			wanted:    "192.0.2.1",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = testCase.remote
+			for _, f := range testCase.forwarded {
+				r.Header.Add("X-Forwarded-For", f)
+			}
+			if testCase.spoofed != "" {
+				r.Header.Set(ClientIPHeader, testCase.spoofed)
+			}
+
+			var found string
+			ClientIP(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					found = r.Header.Get(ClientIPHeader)
+				}),
+				testCase.proxies,
+			).ServeHTTP(httptest.NewRecorder(), r)
+
+			if found != testCase.wanted {
+				t.Fatalf("wanted `%s`; found `%s`", testCase.wanted, found)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		setting   string
+		wanted    []string
+		wantedErr bool
+	}{
+		{name: "none", setting: "none"},
+		{
+			name:    "networks",
+			setting: "10.0.0.0/8, 192.0.2.1/32,2001:db8::/32",
+			wanted:  []string{"10.0.0.0/8", "192.0.2.1/32", "2001:db8::/32"},
+		},
+		{name: "missing", setting: "", wantedErr: true},
+		{name: "bare address", setting: "10.0.0.1", wantedErr: true},
+		{name: "empty entry", setting: "10.0.0.0/8,", wantedErr: true},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			proxies, err := ParseTrustedProxies(testCase.setting)
+			if (err != nil) != testCase.wantedErr {
+				t.Fatalf(
+					"wanted error: %t; found `%v`",
+					testCase.wantedErr,
+					err,
+				)
+			}
+			if len(proxies) != len(testCase.wanted) {
+				t.Fatalf("wanted `%v`; found `%v`", testCase.wanted, proxies)
+			}
+			for i, proxy := range proxies {
+				if proxy.String() != testCase.wanted[i] {
+					t.Fatalf(
+						"proxies[%d]: wanted `%s`; found `%s`",
+						i,
+						testCase.wanted[i],
+						proxy,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestWebServer_RateLimited(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	webServer := WebServer{
+		Comments: CommentsModel{
+			CommentsStore: testsupport.CommentsStoreFake{"post": {
+				"comment": {
+					ID:     "comment",
+					Post:   "post",
+					Author: "adam",
+					Body:   "original body",
+				},
+			}},
+			IDFunc:   func() types.CommentID { return "reply" },
+			TimeFunc: func() time.Time { return now },
+		},
+		BaseURL: "https://comments.example.org",
+		RateLimiter: &RateLimiter{
+			Store: &MemoryRateLimitStore{},
+			Limits: map[types.Action]types.Limit{
+				types.ActionCreate: {Count: 1, Per: time.Minute},
+				types.ActionEdit:   {Count: 1, Per: time.Minute},
+				types.ActionDelete: {Count: 1, Per: time.Minute},
+			},
+			TimeFunc: func() time.Time { return now },
+		},
+	}
+
+	for _, testCase := range []struct {
+		name    string
+		handler func(pz.Request) pz.Response
+		values  url.Values
+		wanted  []string
+	}{
+		{
+			name:    "reply",
+			handler: webServer.Reply,
+			values:  url.Values{"body": {"my reply to you"}},
+			wanted: []string{
+				"my reply to you",
+				`action="https://comments.example.org/posts/post/comments/` +
+					`comment/reply"`,
+			},
+		},
+		{
+			name:    "edit",
+			handler: webServer.Edit,
+			values:  url.Values{"body": {"my edited body"}},
+			wanted: []string{
+				"my edited body",
+				`action="https://comments.example.org/posts/post/comments/` +
+					`comment/edit"`,
+			},
+		},
+		{
+			name:    "delete",
+			handler: webServer.Delete,
+			values:  url.Values{},
+			wanted:  []string{"Confirm Comment Deletion"},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := func() pz.Request {
+				return pz.Request{
+					Vars: map[string]string{
+						"post-id":    "post",
+						"comment-id": "comment",
+					},
+					Headers: http.Header{"User": []string{"adam"}},
+					Body:    strings.NewReader(testCase.values.Encode()),
+				}
+			}
+			if rsp := testCase.handler(request()); rsp.Status !=
+				http.StatusSeeOther {
+				t.Fatalf(
+					"first request: wanted `303`; found `%d`",
+					rsp.Status,
+				)
+			}
+
+			rsp := testCase.handler(request())
+			if rsp.Status != http.StatusTooManyRequests {
+				t.Fatalf(
+					"Response.Status: wanted `429`; found `%d`",
+					rsp.Status,
+				)
+			}
+			if found := rsp.Headers.Get("Retry-After"); found != "60" {
+				t.Fatalf("Retry-After: wanted `60`; found `%s`", found)
+			}
+			data, err := readAll(rsp.Data)
+			if err != nil {
+				t.Fatalf("Response.Data: %v", err)
+			}
+			for _, wanted := range append(
+				testCase.wanted,
+				"You&#39;re doing that too often. Please try again in 60 "+
+					"seconds.",
+			) {
+				if !strings.Contains(string(data), wanted) {
+					t.Fatalf("Response.Data: missing `%s`:\n%s", wanted, data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package comments
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// redirectPath validates a redirect target, which is a path relative to
+// `BaseURL` (optionally with a query string and fragment). It returns the
+// target (without a leading slash) if it's a same-origin path which stays
+// under `BaseURL`; otherwise it returns the path of the post's replies page.
+func redirectPath(target string, post types.PostID) string {
+	if path, ok := validRedirect(target); ok {
+		return path
+	}
+	return fmt.Sprintf("posts/%s/comments/toplevel/replies", post)
+}
+
+// redirectURL is like `redirectPath`, but it returns an absolute URL.
+func (ws *WebServer) redirectURL(target string, post types.PostID) string {
+	return join(ws.BaseURL, redirectPath(target, post))
+}
+
+// validRedirect reports whether the target is a relative path which stays
+// under `BaseURL`, returning its normalized form if so. Targets with schemes,
+// hosts (including `//host` forms), backslashes, control characters, or `..`
+// segments are rejected, as are targets which only have these properties
+// after percent-decoding.
+func validRedirect(target string) (string, bool) {
+	if target == "" || !safeChars(target) {
+		return "", false
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil ||
+		u.Opaque != "" {
+		return "", false
+	}
+
+	// `url.Parse()` decodes the path once; keep decoding to catch payloads
+	// which were encoded several times over.
+	decoded := u.Path
+	for {
+		if !safeChars(decoded) || strings.HasPrefix(decoded, "//") {
+			return "", false
+		}
+		for _, segment := range strings.Split(decoded, "/") {
+			if segment == ".." {
+				return "", false
+			}
+		}
+		next, err := url.PathUnescape(decoded)
+		if err != nil || next == decoded {
+			break
+		}
+		decoded = next
+	}
+
+	u.Path = strings.TrimLeft(u.Path, "/")
+	u.RawPath = ""
+	return u.String(), true
+}
+
+// safeChars reports whether s is free of backslashes (which browsers treat as
+// slashes) and control characters.
+func safeChars(s string) bool {
+	return !strings.ContainsRune(s, '\\') &&
+		strings.IndexFunc(s, unicode.IsControl) < 0
+}
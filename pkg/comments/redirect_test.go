@@ -0,0 +1,110 @@
+package comments
+
+import "testing"
+
+func TestRedirectPath(t *testing.T) {
+	const fallback = "posts/post/comments/toplevel/replies"
+	for _, testCase := range []struct {
+		name   string
+		target string
+		wanted string
+	}{
+		{
+			name:   "relative path",
+			target: "posts/post/comments/toplevel/replies",
+			wanted: "posts/post/comments/toplevel/replies",
+		},
+		{
+			name:   "absolute path",
+			target: "/posts/post/comments/comment",
+			wanted: "posts/post/comments/comment",
+		},
+		{
+			name:   "query and fragment are preserved",
+			target: "posts/post/comments/toplevel/replies?sort=top#comment",
+			wanted: "posts/post/comments/toplevel/replies?sort=top#comment",
+		},
+		{
+			name:   "empty",
+			target: "",
+			wanted: fallback,
+		},
+		{
+			name:   "http scheme",
+			target: "http://evil.example.org/",
+			wanted: fallback,
+		},
+		{
+			name:   "javascript scheme",
+			target: "javascript:alert(1)",
+			wanted: fallback,
+		},
+		{
+			name:   "data scheme",
+			target: "data:text/html,<script>alert(1)</script>",
+			wanted: fallback,
+		},
+		{
+			name:   "protocol-relative",
+			target: "//evil.example.org/",
+			wanted: fallback,
+		},
+		{
+			name:   "protocol-relative with extra slashes",
+			target: "///evil.example.org/",
+			wanted: fallback,
+		},
+		{
+			name:   "backslashes",
+			target: `/\evil.example.org/`,
+			wanted: fallback,
+		},
+		{
+			name:   "encoded slashes",
+			target: "%2F%2Fevil.example.org/",
+			wanted: fallback,
+		},
+		{
+			name:   "encoded backslash",
+			target: "/%5Cevil.example.org/",
+			wanted: fallback,
+		},
+		{
+			name:   "userinfo",
+			target: "//user@evil.example.org/",
+			wanted: fallback,
+		},
+		{
+			name:   "traversal",
+			target: "posts/../../admin",
+			wanted: fallback,
+		},
+		{
+			name:   "encoded traversal",
+			target: "posts/%2e%2e/%2e%2e/admin",
+			wanted: fallback,
+		},
+		{
+			name:   "double-encoded traversal",
+			target: "posts/%252e%252e/%252e%252e/admin",
+			wanted: fallback,
+		},
+		{
+			name:   "encoded newline",
+			target: "posts/%0d%0aLocation:%20evil",
+			wanted: fallback,
+		},
+		{
+			name:   "tab",
+			target: "/\t/evil.example.org/",
+			wanted: fallback,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			found := redirectPath(testCase.target, "post")
+			if found != testCase.wanted {
+				t.Fatalf("wanted `%s`; found `%s`", testCase.wanted, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package comments
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+// parseRepliesQuery builds a `types.RepliesQuery` from the request's
+// `post-id` and `comment-id` path variables and its `limit`, `cursor`,
+// `depth`, and `sort` query string parameters. A `comment-id` of `toplevel`
+// selects the post's toplevel comments.
+func parseRepliesQuery(r pz.Request) (*types.RepliesQuery, error) {
+	q := types.RepliesQuery{Post: types.PostID(r.Vars["post-id"])}
+	if parent := r.Vars["comment-id"]; parent != "toplevel" {
+		q.Parent = types.CommentID(parent)
+	}
+
+	values := queryValues(r)
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return nil, ErrInvalidLimit
+		}
+		q.Limit = n
+	}
+
+	if depth := values.Get("depth"); depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil || n < 0 {
+			return nil, ErrInvalidDepth
+		}
+		q.MaxDepth = n
+	}
+
+	sort, err := types.ParseSort(values.Get("sort"))
+	if err != nil {
+		return nil, err
+	}
+	q.Sort = sort
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		c, err := types.ParseCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		q.Cursor = c
+	}
+
+	return &q, nil
+}
+
+// pageURL returns `path` with the request's query string, except that the
+// `cursor` parameter is replaced by the provided cursor.
+func pageURL(path string, r pz.Request, cursor *types.Cursor) string {
+	values := queryValues(r)
+	values.Set("cursor", cursor.String())
+	return path + "?" + values.Encode()
+}
+
+// queryValues returns the request's query string parameters. Unlike
+// `r.URL.Query()`, it tolerates requests without a URL.
+func queryValues(r pz.Request) url.Values {
+	if r.URL == nil {
+		return url.Values{}
+	}
+	return r.URL.Query()
+}
@@ -0,0 +1,130 @@
+package comments
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+const reportReasonMax = 512
+
+// ReportedComment is a comment along with its open reports.
+type ReportedComment struct {
+	Comment *types.Comment  `json:"comment"`
+	Reports []*types.Report `json:"reports"`
+}
+
+// Report records a user's report of a comment. Each user may only report a
+// comment once. If `ReportThreshold` is set and the comment has at least that
+// many open reports, the comment is hidden by putting it back into the
+// moderation queue.
+func (cm *CommentsModel) Report(r *types.Report) (*types.Report, error) {
+	if r.Reporter == "" {
+		return nil, ErrInvalidUser
+	}
+	reason := strings.TrimSpace(r.Reason)
+	if reason == "" || len(reason) > reportReasonMax {
+		return nil, types.ErrInvalidReport
+	}
+	if cm.ReportsStore == nil {
+		return nil, fmt.Errorf("reporting comment: no reports store")
+	}
+
+	c, _, err := cm.visibleComment(r.Reporter, r.Post, r.Comment)
+	if err != nil {
+		return nil, fmt.Errorf("reporting comment: %w", err)
+	}
+	if c.Deleted {
+		return nil, fmt.Errorf(
+			"reporting comment: %w",
+			types.ErrCommentNotFound,
+		)
+	}
+
+	report := types.Report{
+		Post:     r.Post,
+		Comment:  r.Comment,
+		Reporter: r.Reporter,
+		Reason:   reason,
+		Created:  cm.TimeFunc(),
+	}
+	if err := cm.ReportsStore.PutReport(&report); err != nil {
+		return nil, fmt.Errorf("reporting comment: %w", err)
+	}
+
+	if cm.ReportThreshold > 0 && c.Status.Visible() {
+		n, err := cm.ReportsStore.CountOpenReports(r.Post, r.Comment)
+		if err != nil {
+			return nil, fmt.Errorf("reporting comment: %w", err)
+		}
+		if n >= cm.ReportThreshold {
+			if err := cm.CommentsStore.Update(
+				types.NewCommentPatch(r.Comment, r.Post).
+					SetStatus(types.StatusPending),
+			); err != nil {
+				return nil, fmt.Errorf("hiding reported comment: %w", err)
+			}
+		}
+	}
+	return &report, nil
+}
+
+// Reports returns the open reports grouped by comment. The comments are
+// ordered by their oldest open report. Only moderators can view reports.
+func (cm *CommentsModel) Reports(
+	moderator types.UserID,
+) ([]*ReportedComment, error) {
+	if err := cm.requireModerator(moderator); err != nil {
+		return nil, err
+	}
+	if cm.ReportsStore == nil {
+		return []*ReportedComment{}, nil
+	}
+	reports, err := cm.ReportsStore.OpenReports()
+	if err != nil {
+		return nil, fmt.Errorf("fetching reports: %w", err)
+	}
+
+	type key struct {
+		post    types.PostID
+		comment types.CommentID
+	}
+	out := []*ReportedComment{}
+	byComment := map[key]*ReportedComment{}
+	for _, r := range reports {
+		k := key{r.Post, r.Comment}
+		if rc, found := byComment[k]; found {
+			rc.Reports = append(rc.Reports, r)
+			continue
+		}
+		c, err := cm.CommentsStore.Comment(r.Post, r.Comment)
+		if err != nil {
+			return nil, fmt.Errorf("fetching reported comment: %w", err)
+		}
+		rc := &ReportedComment{Comment: c, Reports: []*types.Report{r}}
+		byComment[k] = rc
+		out = append(out, rc)
+	}
+
+	for _, rc := range out {
+		redact([]*types.Comment{rc.Comment})
+		render(rc.Comment)
+	}
+	return out, nil
+}
+
+// resolveReports resolves a comment's open reports once a moderator has
+// acted on the comment (or it's been deleted).
+func (cm *CommentsModel) resolveReports(
+	post types.PostID,
+	comment types.CommentID,
+) error {
+	if cm.ReportsStore == nil {
+		return nil
+	}
+	if err := cm.ReportsStore.ResolveReports(post, comment); err != nil {
+		return fmt.Errorf("resolving reports: %w", err)
+	}
+	return nil
+}
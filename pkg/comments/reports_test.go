@@ -0,0 +1,376 @@
+package comments
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/testsupport"
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+func TestCommentsModel_Report(t *testing.T) {
+	for _, testCase := range []struct {
+		name         string
+		existing     []*types.Report
+		threshold    int
+		report       types.Report
+		wantedStatus types.Status
+		wantedErr    types.WantedError
+	}{
+		{
+			name: "base case",
+			report: types.Report{
+				Comment:  "approved",
+				Reporter: "reporter",
+				Reason:   "abusive",
+			},
+			wantedStatus: types.StatusApproved,
+		},
+		{
+			name:      "below threshold",
+			threshold: 3,
+			existing: []*types.Report{
+				{Post: "post", Comment: "approved", Reporter: "other"},
+			},
+			report: types.Report{
+				Comment:  "approved",
+				Reporter: "reporter",
+				Reason:   "abusive",
+			},
+			wantedStatus: types.StatusApproved,
+		},
+		{
+			name:      "threshold reached",
+			threshold: 2,
+			existing: []*types.Report{
+				{Post: "post", Comment: "approved", Reporter: "other"},
+			},
+			report: types.Report{
+				Comment:  "approved",
+				Reporter: "reporter",
+				Reason:   "abusive",
+			},
+			wantedStatus: types.StatusPending,
+		},
+		{
+			name:      "resolved reports don't count",
+			threshold: 2,
+			existing: []*types.Report{{
+				Post:     "post",
+				Comment:  "approved",
+				Reporter: "other",
+				Resolved: true,
+			}},
+			report: types.Report{
+				Comment:  "approved",
+				Reporter: "reporter",
+				Reason:   "abusive",
+			},
+			wantedStatus: types.StatusApproved,
+		},
+		{
+			name:      "rejected comments stay rejected",
+			threshold: 1,
+			report: types.Report{
+				Comment:  "rejected",
+				Reporter: "author",
+				Reason:   "abusive",
+			},
+			wantedStatus: types.StatusRejected,
+		},
+		{
+			name: "duplicate report",
+			existing: []*types.Report{
+				{Post: "post", Comment: "approved", Reporter: "reporter"},
+			},
+			report: types.Report{
+				Comment:  "approved",
+				Reporter: "reporter",
+				Reason:   "abusive",
+			},
+			wantedErr: types.ErrReportExists,
+		},
+		{
+			name: "missing reason",
+			report: types.Report{
+				Comment:  "approved",
+				Reporter: "reporter",
+				Reason:   " \n",
+			},
+			wantedErr: types.ErrInvalidReport,
+		},
+		{
+			name: "reason too long",
+			report: types.Report{
+				Comment:  "approved",
+				Reporter: "reporter",
+				Reason:   strings.Repeat("x", reportReasonMax+1),
+			},
+			wantedErr: types.ErrInvalidReport,
+		},
+		{
+			name: "missing reporter",
+			report: types.Report{
+				Comment: "approved",
+				Reason:  "abusive",
+			},
+			wantedErr: ErrInvalidUser,
+		},
+		{
+			name: "comment not visible to reporter",
+			report: types.Report{
+				Comment:  "pending",
+				Reporter: "reporter",
+				Reason:   "abusive",
+			},
+			wantedErr: types.ErrCommentNotFound,
+		},
+		{
+			name: "reply to a comment not visible to reporter",
+			report: types.Report{
+				Comment:  "pending-child",
+				Reporter: "reporter",
+				Reason:   "abusive",
+			},
+			wantedErr: types.ErrCommentNotFound,
+		},
+		{
+			name: "comment not found",
+			report: types.Report{
+				Comment:  "missing",
+				Reporter: "reporter",
+				Reason:   "abusive",
+			},
+			wantedErr: types.ErrCommentNotFound,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			state := moderationState()
+			reports := testsupport.ReportsStoreFake{Reports: testCase.existing}
+			model := CommentsModel{
+				CommentsStore:   state,
+				ReportsStore:    &reports,
+				ReportThreshold: testCase.threshold,
+				TimeFunc:        func() time.Time { return now },
+			}
+
+			testCase.report.Post = "post"
+			report, err := model.Report(&testCase.report)
+			if testCase.wantedErr == nil {
+				testCase.wantedErr = types.NilError{}
+			}
+			if err := testCase.wantedErr.CompareErr(err); err != nil {
+				t.Fatal(err)
+			}
+			if err != nil {
+				if len(reports.Reports) != len(testCase.existing) {
+					t.Fatalf(
+						"len(reports): wanted `%d`; found `%d`",
+						len(testCase.existing),
+						len(reports.Reports),
+					)
+				}
+				return
+			}
+
+			if !report.Created.Equal(now) {
+				t.Fatalf(
+					"Report.Created: wanted `%s`; found `%s`",
+					now,
+					report.Created,
+				)
+			}
+			found := state["post"][testCase.report.Comment].Status
+			if found != testCase.wantedStatus {
+				t.Fatalf(
+					"Comment.Status: wanted `%s`; found `%s`",
+					testCase.wantedStatus,
+					found,
+				)
+			}
+		})
+	}
+}
+
+func TestCommentsModel_Reports(t *testing.T) {
+	reports := testsupport.ReportsStoreFake{Reports: []*types.Report{{
+		Post:     "post",
+		Comment:  "pending",
+		Reporter: "adam",
+		Reason:   "spam",
+		Created:  someTime.Add(time.Hour),
+	}, {
+		Post:     "post",
+		Comment:  "approved",
+		Reporter: "adam",
+		Reason:   "abusive",
+		Created:  someTime.Add(2 * time.Hour),
+	}, {
+		Post:     "post",
+		Comment:  "pending",
+		Reporter: "eve",
+		Reason:   "also spam",
+		Created:  someTime.Add(3 * time.Hour),
+	}, {
+		Post:     "post",
+		Comment:  "rejected",
+		Reporter: "eve",
+		Reason:   "resolved",
+		Created:  someTime,
+		Resolved: true,
+	}}}
+	model := CommentsModel{
+		CommentsStore: moderationState(),
+		ReportsStore:  &reports,
+		Roles:         testsupport.RolesStoreFake{"moderator": types.RoleModerator},
+	}
+
+	if _, err := model.Reports("author"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("wanted `ErrForbidden`; found `%v`", err)
+	}
+
+	found, err := model.Reports("moderator")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wanted := []struct {
+		comment types.CommentID
+		reasons []string
+	}{
+		{"pending", []string{"spam", "also spam"}},
+		{"approved", []string{"abusive"}},
+	}
+	if len(found) != len(wanted) {
+		t.Fatalf(
+			"len(reports): wanted `%d`; found `%d`",
+			len(wanted),
+			len(found),
+		)
+	}
+	for i, w := range wanted {
+		if found[i].Comment.ID != w.comment {
+			t.Fatalf(
+				"reports[%d].Comment.ID: wanted `%s`; found `%s`",
+				i,
+				w.comment,
+				found[i].Comment.ID,
+			)
+		}
+		var reasons []string
+		for _, r := range found[i].Reports {
+			reasons = append(reasons, r.Reason)
+		}
+		if strings.Join(reasons, ",") != strings.Join(w.reasons, ",") {
+			t.Fatalf(
+				"reports[%d].Reports: wanted reasons `%v`; found `%v`",
+				i,
+				w.reasons,
+				reasons,
+			)
+		}
+	}
+
+	// moderating a comment resolves its reports
+	if err := model.Moderate(
+		"moderator",
+		"post",
+		"pending",
+		types.StatusRejected,
+	); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found, err = model.Reports("moderator"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != 1 || found[0].Comment.ID != "approved" {
+		t.Fatalf("wanted only `approved` to be reported; found `%v`", found)
+	}
+}
+
+func TestWebServer_Report(t *testing.T) {
+	reports := testsupport.ReportsStoreFake{}
+	webServer := WebServer{
+		Comments: CommentsModel{
+			CommentsStore: moderationState(),
+			ReportsStore:  &reports,
+			TimeFunc:      func() time.Time { return now },
+		},
+		BaseURL: "https://comments.example.org",
+	}
+	request := func(reason string) pz.Request {
+		return pz.Request{
+			Vars: map[string]string{
+				"post-id":    "post",
+				"comment-id": "approved",
+			},
+			Headers: http.Header{"User": []string{"reporter"}},
+			Body: strings.NewReader(
+				url.Values{"reason": {reason}}.Encode(),
+			),
+		}
+	}
+
+	rsp := webServer.Report(request("abusive"))
+	if rsp.Status != http.StatusSeeOther {
+		t.Fatalf("Response.Status: wanted `303`; found `%d`", rsp.Status)
+	}
+	wantedLocation := "https://comments.example.org/posts/post/comments/" +
+		"toplevel/replies#approved"
+	if found := rsp.Headers.Get("Location"); found != wantedLocation {
+		t.Fatalf(
+			"Location: wanted `%s`; found `%s`",
+			wantedLocation,
+			found,
+		)
+	}
+	if len(reports.Reports) != 1 || reports.Reports[0].Reason != "abusive" {
+		t.Fatalf("unexpected reports: %v", reports.Reports)
+	}
+
+	for _, testCase := range []struct {
+		name         string
+		reason       string
+		wantedStatus int
+		wantedNotice string
+	}{
+		{
+			name:         "duplicate",
+			reason:       "still abusive",
+			wantedStatus: http.StatusConflict,
+			wantedNotice: "You&#39;ve already reported this comment.",
+		},
+		{
+			name:         "missing reason",
+			reason:       "",
+			wantedStatus: http.StatusBadRequest,
+			wantedNotice: "Please give a reason of at most 512 characters.",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			rsp := webServer.Report(request(testCase.reason))
+			if rsp.Status != testCase.wantedStatus {
+				t.Fatalf(
+					"Response.Status: wanted `%d`; found `%d`",
+					testCase.wantedStatus,
+					rsp.Status,
+				)
+			}
+			data, err := readAll(rsp.Data)
+			if err != nil {
+				t.Fatalf("Response.Data: %v", err)
+			}
+			if !strings.Contains(string(data), testCase.wantedNotice) {
+				t.Fatalf(
+					"Response.Data: missing `%s`:\n%s",
+					testCase.wantedNotice,
+					data,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package comments
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+var (
+	ErrUnauthorized = &pz.HTTPError{Status: 401, Message: "unauthorized"}
+	ErrForbidden    = &pz.HTTPError{Status: 403, Message: "forbidden"}
+
+	// ErrNotAuthor is returned when a user who isn't a moderator tries to
+	// modify someone else's comment.
+	ErrNotAuthor = &pz.HTTPError{
+		Status:  403,
+		Message: "only the comment's author or a moderator may modify it",
+	}
+)
+
+// StripUserHeader wraps an HTTP handler, deleting any `User` header sent by
+// the client. Authenticators add the authenticated user to the `User` header
+// rather than replacing it, and handlers read the header's first value, so
+// without this a client could act as any user by sending the header itself.
+// It must wrap every route.
+func StripUserHeader(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("User")
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Role returns the user's role. Anonymous users and users who haven't been
+// assigned a role are `types.RoleUser`s.
+func (cm *CommentsModel) Role(user types.UserID) (types.Role, error) {
+	if user == "" || cm.Roles == nil {
+		return types.RoleUser, nil
+	}
+	userRole, err := cm.Roles.UserRole(user)
+	if err != nil {
+		if errors.Is(err, types.ErrUserRoleNotFound) {
+			return types.RoleUser, nil
+		}
+		return "", fmt.Errorf("fetching user role: %w", err)
+	}
+	return userRole.Role, nil
+}
+
+// IsModerator reports whether the user may moderate comments.
+func (cm *CommentsModel) IsModerator(user types.UserID) (bool, error) {
+	role, err := cm.Role(user)
+	if err != nil {
+		return false, err
+	}
+	return role.CanModerate(), nil
+}
+
+func (cm *CommentsModel) requireModerator(user types.UserID) error {
+	if user == "" {
+		return ErrUnauthorized
+	}
+	moderator, err := cm.IsModerator(user)
+	if err != nil {
+		return err
+	}
+	if !moderator {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (cm *CommentsModel) requireAdmin(user types.UserID) error {
+	if user == "" {
+		return ErrUnauthorized
+	}
+	role, err := cm.Role(user)
+	if err != nil {
+		return err
+	}
+	if !role.CanAssignRoles() {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// PutUserRole assigns a role to a user. Only admins can assign roles.
+func (cm *CommentsModel) PutUserRole(
+	admin types.UserID,
+	userRole *types.UserRole,
+) error {
+	if err := cm.requireAdmin(admin); err != nil {
+		return err
+	}
+	if userRole.User == "" {
+		return fmt.Errorf("assigning role: %w", ErrInvalidUser)
+	}
+	if _, err := types.ParseRole(string(userRole.Role)); err != nil {
+		return fmt.Errorf("assigning role: %w", err)
+	}
+	if cm.Roles == nil {
+		return fmt.Errorf("assigning role: no roles store")
+	}
+	if err := cm.Roles.PutUserRole(userRole); err != nil {
+		return fmt.Errorf("assigning role: %w", err)
+	}
+	return nil
+}
+
+// authorize checks whether the user may modify (i.e., edit or delete) the
+// comment. Authors may modify their own comments and moderators may modify
+// anyone's. It reports whether the user is acting as a moderator, i.e.,
+// modifying someone else's comment.
+func (cm *CommentsModel) authorize(
+	user types.UserID,
+	c *types.Comment,
+) (bool, error) {
+	if user == "" {
+		return false, ErrUnauthorized
+	}
+	if c.Author == user {
+		return false, nil
+	}
+	moderator, err := cm.IsModerator(user)
+	if err != nil {
+		return false, err
+	}
+	if !moderator {
+		return false, ErrNotAuthor
+	}
+	return true, nil
+}
@@ -0,0 +1,114 @@
+package comments
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weberc2/comments/pkg/comments/testsupport"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestCommentsModel_PutUserRole(t *testing.T) {
+	for _, testCase := range []struct {
+		name       string
+		admin      types.UserID
+		userRole   types.UserRole
+		wantedRole types.Role
+		wantedErr  types.WantedError
+	}{
+		{
+			name:       "admin assigns role",
+			admin:      "admin",
+			userRole:   types.UserRole{User: "user", Role: types.RoleModerator},
+			wantedRole: types.RoleModerator,
+		},
+		{
+			name:       "moderators can't assign roles",
+			admin:      "moderator",
+			userRole:   types.UserRole{User: "user", Role: types.RoleModerator},
+			wantedRole: types.RoleUser,
+			wantedErr:  ErrForbidden,
+		},
+		{
+			name:       "anonymous users can't assign roles",
+			userRole:   types.UserRole{User: "user", Role: types.RoleModerator},
+			wantedRole: types.RoleUser,
+			wantedErr:  ErrUnauthorized,
+		},
+		{
+			name:       "invalid role",
+			admin:      "admin",
+			userRole:   types.UserRole{User: "user", Role: "overlord"},
+			wantedRole: types.RoleUser,
+			wantedErr:  types.ErrInvalidRole,
+		},
+		{
+			name:       "missing user",
+			admin:      "admin",
+			userRole:   types.UserRole{Role: types.RoleModerator},
+			wantedRole: types.RoleUser,
+			wantedErr:  ErrInvalidUser,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.wantedErr == nil {
+				testCase.wantedErr = types.NilError{}
+			}
+
+			model := CommentsModel{
+				CommentsStore: testsupport.CommentsStoreFake{},
+				Roles: testsupport.RolesStoreFake{
+					"admin":     types.RoleAdmin,
+					"moderator": types.RoleModerator,
+				},
+			}
+
+			if err := testCase.wantedErr.CompareErr(model.PutUserRole(
+				testCase.admin,
+				&testCase.userRole,
+			)); err != nil {
+				t.Fatal(err)
+			}
+
+			role, err := model.Role("user")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if role != testCase.wantedRole {
+				t.Fatalf(
+					"Role: wanted `%s`; found `%s`",
+					testCase.wantedRole,
+					role,
+				)
+			}
+		})
+	}
+}
+
+func TestStripUserHeader(t *testing.T) {
+	for _, testCase := range []struct {
+		name   string
+		forged []string
+	}{
+		{name: "no header"},
+		{name: "forged", forged: []string{"admin"}},
+		{name: "forged twice", forged: []string{"admin", "moderator"}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			for _, user := range testCase.forged {
+				r.Header.Add("User", user)
+			}
+			var found []string
+			StripUserHeader(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					found = r.Header.Values("User")
+				},
+			)).ServeHTTP(httptest.NewRecorder(), r)
+			if len(found) > 0 {
+				t.Fatalf("User: wanted no values; found `%v`", found)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package comments
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+const sanctionReasonMax = 512
+
+// activeSanctions returns the user's sanctions which are in effect, by kind.
+func (cm *CommentsModel) activeSanctions(
+	user types.UserID,
+) (map[types.SanctionKind]*types.Sanction, error) {
+	if cm.SanctionsStore == nil || user == "" {
+		return nil, nil
+	}
+	sanctions, err := cm.SanctionsStore.UserSanctions(user)
+	if err != nil {
+		return nil, fmt.Errorf("fetching user sanctions: %w", err)
+	}
+	now := cm.TimeFunc()
+	active := map[types.SanctionKind]*types.Sanction{}
+	for _, s := range sanctions {
+		if s.Active(now) {
+			active[s.Kind] = s
+		}
+	}
+	return active, nil
+}
+
+// checkCanPost returns `types.ErrBanned` or `types.ErrMuted` if the user may
+// not post or edit comments.
+func (cm *CommentsModel) checkCanPost(user types.UserID) error {
+	active, err := cm.activeSanctions(user)
+	if err != nil {
+		return err
+	}
+	if active[types.SanctionBan] != nil {
+		return types.ErrBanned
+	}
+	if mute := active[types.SanctionMute]; mute != nil {
+		return fmt.Errorf(
+			"%w until %s",
+			types.ErrMuted,
+			mute.Expires.Format(time.RFC3339),
+		)
+	}
+	return nil
+}
+
+// shadowBanned returns the users whose shadow bans are in effect.
+func (cm *CommentsModel) shadowBanned() ([]types.UserID, error) {
+	if cm.SanctionsStore == nil {
+		return nil, nil
+	}
+	users, err := cm.SanctionsStore.ShadowBanned(cm.TimeFunc())
+	if err != nil {
+		return nil, fmt.Errorf("fetching shadow-banned users: %w", err)
+	}
+	return users, nil
+}
+
+// PutSanction imposes a sanction on a user, replacing the user's sanction of
+// the same kind (if any). Mutes must expire and expiry times must be in the
+// future. Only admins can impose sanctions.
+func (cm *CommentsModel) PutSanction(
+	admin types.UserID,
+	s *types.Sanction,
+) (*types.Sanction, error) {
+	if err := cm.requireAdmin(admin); err != nil {
+		return nil, err
+	}
+	if s.User == "" {
+		return nil, fmt.Errorf("imposing sanction: %w", ErrInvalidUser)
+	}
+	if _, err := types.ParseSanctionKind(string(s.Kind)); err != nil {
+		return nil, fmt.Errorf("imposing sanction: %w", err)
+	}
+	reason := strings.TrimSpace(s.Reason)
+	if len(reason) > sanctionReasonMax {
+		return nil, fmt.Errorf(
+			"imposing sanction: %w: reason too long",
+			types.ErrInvalidSanction,
+		)
+	}
+	now := cm.TimeFunc()
+	if s.Kind == types.SanctionMute && s.Expires == nil {
+		return nil, fmt.Errorf(
+			"imposing sanction: %w: mutes must expire",
+			types.ErrInvalidSanction,
+		)
+	}
+	if s.Expires != nil && !s.Expires.After(now) {
+		return nil, fmt.Errorf(
+			"imposing sanction: %w: already expired",
+			types.ErrInvalidSanction,
+		)
+	}
+	if cm.SanctionsStore == nil {
+		return nil, fmt.Errorf("imposing sanction: no sanctions store")
+	}
+
+	sanction := types.Sanction{
+		User:    s.User,
+		Kind:    s.Kind,
+		Reason:  reason,
+		Issuer:  admin,
+		Created: now,
+		Expires: s.Expires,
+	}
+	if err := cm.SanctionsStore.PutSanction(&sanction); err != nil {
+		return nil, fmt.Errorf("imposing sanction: %w", err)
+	}
+	return &sanction, nil
+}
+
+// DeleteSanction lifts a user's sanction. Only admins can lift sanctions.
+func (cm *CommentsModel) DeleteSanction(
+	admin types.UserID,
+	user types.UserID,
+	kind types.SanctionKind,
+) error {
+	if err := cm.requireAdmin(admin); err != nil {
+		return err
+	}
+	if cm.SanctionsStore == nil {
+		return fmt.Errorf("lifting sanction: %w", types.ErrSanctionNotFound)
+	}
+	if err := cm.SanctionsStore.DeleteSanction(user, kind); err != nil {
+		return fmt.Errorf("lifting sanction: %w", err)
+	}
+	return nil
+}
+
+// UserSanctions returns a user's sanctions, including expired ones. Only
+// admins can view sanctions.
+func (cm *CommentsModel) UserSanctions(
+	admin types.UserID,
+	user types.UserID,
+) ([]*types.Sanction, error) {
+	if err := cm.requireAdmin(admin); err != nil {
+		return nil, err
+	}
+	if cm.SanctionsStore == nil {
+		return []*types.Sanction{}, nil
+	}
+	sanctions, err := cm.SanctionsStore.UserSanctions(user)
+	if err != nil {
+		return nil, fmt.Errorf("fetching user sanctions: %w", err)
+	}
+	return sanctions, nil
+}
+
+// Sanctions returns every user's sanctions, including expired ones. Only
+// admins can view sanctions.
+func (cm *CommentsModel) Sanctions(
+	admin types.UserID,
+) ([]*types.Sanction, error) {
+	if err := cm.requireAdmin(admin); err != nil {
+		return nil, err
+	}
+	if cm.SanctionsStore == nil {
+		return []*types.Sanction{}, nil
+	}
+	sanctions, err := cm.SanctionsStore.Sanctions()
+	if err != nil {
+		return nil, fmt.Errorf("fetching sanctions: %w", err)
+	}
+	return sanctions, nil
+}
@@ -0,0 +1,361 @@
+package comments
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/testsupport"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestCommentsModel_Sanctions_PutAndUpdate(t *testing.T) {
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	for _, testCase := range []struct {
+		name      string
+		sanctions []*types.Sanction
+		wantedErr error
+	}{
+		{
+			name: "no sanctions",
+		},
+		{
+			name: "banned",
+			sanctions: []*types.Sanction{
+				{User: "author", Kind: types.SanctionBan},
+			},
+			wantedErr: types.ErrBanned,
+		},
+		{
+			name: "muted",
+			sanctions: []*types.Sanction{
+				{User: "author", Kind: types.SanctionMute, Expires: &future},
+			},
+			wantedErr: types.ErrMuted,
+		},
+		{
+			name: "mute expired",
+			sanctions: []*types.Sanction{
+				{User: "author", Kind: types.SanctionMute, Expires: &past},
+			},
+		},
+		{
+			name: "ban expired",
+			sanctions: []*types.Sanction{
+				{User: "author", Kind: types.SanctionBan, Expires: &past},
+			},
+		},
+		{
+			name: "shadow-banned users can still post",
+			sanctions: []*types.Sanction{
+				{User: "author", Kind: types.SanctionShadowBan},
+			},
+		},
+		{
+			name: "other user banned",
+			sanctions: []*types.Sanction{
+				{User: "other", Kind: types.SanctionBan},
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			model := CommentsModel{
+				CommentsStore:  moderationState(),
+				SanctionsStore: testsupport.Sanctions(testCase.sanctions...),
+				IDFunc:         func() types.CommentID { return "new" },
+				TimeFunc:       func() time.Time { return now },
+			}
+
+			_, err := model.Put(&types.Comment{
+				Post:   "post",
+				Author: "author",
+				Body:   "new body",
+			})
+			if !errors.Is(err, testCase.wantedErr) {
+				t.Fatalf(
+					"Put(): wanted `%v`; found `%v`",
+					testCase.wantedErr,
+					err,
+				)
+			}
+
+			err = model.Update("author", &CommentUpdate{
+				Post: "post",
+				ID:   "approved",
+				Body: "updated body",
+			})
+			if !errors.Is(err, testCase.wantedErr) {
+				t.Fatalf(
+					"Update(): wanted `%v`; found `%v`",
+					testCase.wantedErr,
+					err,
+				)
+			}
+		})
+	}
+}
+
+func TestCommentsModel_Sanctions_ShadowBan(t *testing.T) {
+	past := now.Add(-time.Hour)
+	for _, testCase := range []struct {
+		name      string
+		viewer    types.UserID
+		sanctions []*types.Sanction
+		wanted    []types.CommentID
+	}{
+		{
+			name:   "no sanctions",
+			viewer: "viewer",
+			wanted: []types.CommentID{"approved", "other"},
+		},
+		{
+			name:   "hidden from others",
+			viewer: "viewer",
+			sanctions: []*types.Sanction{
+				{User: "author", Kind: types.SanctionShadowBan},
+			},
+			wanted: []types.CommentID{"other"},
+		},
+		{
+			name:   "hidden from anonymous viewers",
+			viewer: "",
+			sanctions: []*types.Sanction{
+				{User: "author", Kind: types.SanctionShadowBan},
+			},
+			wanted: []types.CommentID{"other"},
+		},
+		{
+			name:   "visible to the author",
+			viewer: "author",
+			sanctions: []*types.Sanction{
+				{User: "author", Kind: types.SanctionShadowBan},
+			},
+			wanted: []types.CommentID{
+				"approved",
+				"pending",
+				"pending-child",
+				"rejected",
+				"other",
+			},
+		},
+		{
+			name:   "visible to moderators",
+			viewer: "moderator",
+			sanctions: []*types.Sanction{
+				{User: "author", Kind: types.SanctionShadowBan},
+			},
+			wanted: []types.CommentID{
+				"approved",
+				"pending",
+				"pending-child",
+				"rejected",
+				"other",
+			},
+		},
+		{
+			name:   "expired",
+			viewer: "viewer",
+			sanctions: []*types.Sanction{{
+				User:    "author",
+				Kind:    types.SanctionShadowBan,
+				Expires: &past,
+			}},
+			wanted: []types.CommentID{"approved", "other"},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			state := moderationState()
+			state["post"]["other"] = &types.Comment{
+				ID:       "other",
+				Post:     "post",
+				Author:   "other",
+				Created:  someTime.Add(4 * time.Hour),
+				Modified: someTime.Add(4 * time.Hour),
+				Body:     "body",
+				Status:   types.StatusApproved,
+			}
+			model := CommentsModel{
+				CommentsStore:  state,
+				SanctionsStore: testsupport.Sanctions(testCase.sanctions...),
+				Roles: testsupport.RolesStoreFake{
+					"moderator": types.RoleModerator,
+				},
+				TimeFunc: func() time.Time { return now },
+			}
+
+			page, err := model.RepliesPage(&types.RepliesQuery{
+				Post:   "post",
+				Viewer: testCase.viewer,
+			})
+			if err != nil {
+				t.Fatalf("RepliesPage(): unexpected error: %v", err)
+			}
+			checkCommentIDs(
+				t,
+				"RepliesPage()",
+				testCase.wanted,
+				page.Comments,
+			)
+
+			replies, err := model.Replies("post", "", testCase.viewer)
+			if err != nil {
+				t.Fatalf("Replies(): unexpected error: %v", err)
+			}
+			checkCommentIDs(t, "Replies()", testCase.wanted, replies)
+		})
+	}
+}
+
+// checkCommentIDs checks the IDs of the found comments, ignoring their order.
+func checkCommentIDs(
+	t *testing.T,
+	context string,
+	wanted []types.CommentID,
+	found []*types.Comment,
+) {
+	t.Helper()
+	ids := map[types.CommentID]bool{}
+	for _, c := range found {
+		ids[c.ID] = true
+	}
+	if len(ids) != len(wanted) || len(found) != len(wanted) {
+		t.Fatalf("%s: wanted `%v`; found `%v`", context, wanted, ids)
+	}
+	for _, id := range wanted {
+		if !ids[id] {
+			t.Fatalf("%s: wanted `%v`; found `%v`", context, wanted, ids)
+		}
+	}
+}
+
+func TestCommentsModel_PutSanction(t *testing.T) {
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	for _, testCase := range []struct {
+		name      string
+		admin     types.UserID
+		sanction  types.Sanction
+		wantedErr error
+	}{
+		{
+			name:     "ban",
+			admin:    "admin",
+			sanction: types.Sanction{User: "user", Kind: types.SanctionBan},
+		},
+		{
+			name:  "mute",
+			admin: "admin",
+			sanction: types.Sanction{
+				User:    "user",
+				Kind:    types.SanctionMute,
+				Expires: &future,
+			},
+		},
+		{
+			name:      "mute without expiry",
+			admin:     "admin",
+			sanction:  types.Sanction{User: "user", Kind: types.SanctionMute},
+			wantedErr: types.ErrInvalidSanction,
+		},
+		{
+			name:  "already expired",
+			admin: "admin",
+			sanction: types.Sanction{
+				User:    "user",
+				Kind:    types.SanctionShadowBan,
+				Expires: &past,
+			},
+			wantedErr: types.ErrInvalidSanction,
+		},
+		{
+			name:      "unknown kind",
+			admin:     "admin",
+			sanction:  types.Sanction{User: "user", Kind: "exile"},
+			wantedErr: types.ErrInvalidSanction,
+		},
+		{
+			name:      "missing user",
+			admin:     "admin",
+			sanction:  types.Sanction{Kind: types.SanctionBan},
+			wantedErr: ErrInvalidUser,
+		},
+		{
+			name:      "moderators can't sanction",
+			admin:     "moderator",
+			sanction:  types.Sanction{User: "user", Kind: types.SanctionBan},
+			wantedErr: ErrForbidden,
+		},
+		{
+			name:      "anonymous",
+			sanction:  types.Sanction{User: "user", Kind: types.SanctionBan},
+			wantedErr: ErrUnauthorized,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			sanctions := testsupport.Sanctions()
+			model := CommentsModel{
+				SanctionsStore: sanctions,
+				Roles: testsupport.RolesStoreFake{
+					"admin":     types.RoleAdmin,
+					"moderator": types.RoleModerator,
+				},
+				TimeFunc: func() time.Time { return now },
+			}
+
+			_, err := model.PutSanction(testCase.admin, &testCase.sanction)
+			if !errors.Is(err, testCase.wantedErr) {
+				t.Fatalf(
+					"wanted `%v`; found `%v`",
+					testCase.wantedErr,
+					err,
+				)
+			}
+			if err != nil {
+				if len(sanctions) != 0 {
+					t.Fatalf("wanted no sanctions; found `%d`", len(sanctions))
+				}
+				return
+			}
+
+			found, err := model.UserSanctions(testCase.admin, "user")
+			if err != nil {
+				t.Fatalf("UserSanctions(): unexpected error: %v", err)
+			}
+			if len(found) != 1 {
+				t.Fatalf("len(sanctions): wanted `1`; found `%d`", len(found))
+			}
+			if found[0].Issuer != testCase.admin {
+				t.Fatalf(
+					"Sanction.Issuer: wanted `%s`; found `%s`",
+					testCase.admin,
+					found[0].Issuer,
+				)
+			}
+			if !found[0].Created.Equal(now) {
+				t.Fatalf(
+					"Sanction.Created: wanted `%s`; found `%s`",
+					now,
+					found[0].Created,
+				)
+			}
+
+			if err := model.DeleteSanction(
+				testCase.admin,
+				"user",
+				testCase.sanction.Kind,
+			); err != nil {
+				t.Fatalf("DeleteSanction(): unexpected error: %v", err)
+			}
+			if err := model.DeleteSanction(
+				testCase.admin,
+				"user",
+				testCase.sanction.Kind,
+			); !errors.Is(err, types.ErrSanctionNotFound) {
+				t.Fatalf(
+					"DeleteSanction(): wanted `ErrSanctionNotFound`; "+
+						"found `%v`",
+					err,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package comments
+
+import (
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+// spamNotice is the message shown to web users whose comments are rejected as
+// spam. It deliberately doesn't say which check rejected the comment.
+const spamNotice = "Your comment looks like spam, so it wasn't posted."
+
+// editSpamNotice is like `spamNotice` but for edits.
+const editSpamNotice = "Your edit looks like spam, so it wasn't saved."
+
+// submission collects what the spam checkers need to know about the request
+// which submitted a comment (or an edit, in which case `c` is `nil`).
+func submission(r pz.Request, c *types.Comment) *types.Submission {
+	return &types.Submission{
+		Comment:   c,
+		IP:        r.Headers.Get(ClientIPHeader),
+		UserAgent: r.Headers.Get("User-Agent"),
+		Referrer:  r.Headers.Get("Referer"),
+	}
+}
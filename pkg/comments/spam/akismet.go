@@ -0,0 +1,107 @@
+package spam
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// AkismetURLDefault is Akismet's API URL.
+const AkismetURLDefault = "https://rest.akismet.com"
+
+// akismetResponseMax is the most of an Akismet response body that's read.
+const akismetResponseMax = 1 << 10
+
+// Akismet checks comments against an Akismet-compatible spam checking
+// service. Comments which the service considers spam are held, except for
+// "blatant" spam (which the service marks with an `X-akismet-pro-tip:
+// discard` header), which is rejected.
+type Akismet struct {
+	// URL is the service's base URL. It defaults to `AkismetURLDefault`.
+	URL string
+	Key string
+
+	// Blog is the URL of the site which the comments are posted on.
+	Blog string
+
+	// Client is the HTTP client for requests to the service. It defaults to
+	// `http.DefaultClient`.
+	Client *http.Client
+}
+
+func (a *Akismet) CheckSpam(s *types.Submission) (types.SpamCheck, error) {
+	base := a.URL
+	if base == "" {
+		base = AkismetURLDefault
+	}
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	commentType := "comment"
+	if s.Comment.Parent != "" {
+		commentType = "reply"
+	}
+	rsp, err := client.PostForm(
+		strings.TrimSuffix(base, "/")+"/1.1/comment-check",
+		url.Values{
+			"api_key":         {a.Key},
+			"blog":            {a.Blog},
+			"user_ip":         {s.IP},
+			"user_agent":      {s.UserAgent},
+			"referrer":        {s.Referrer},
+			"comment_type":    {commentType},
+			"comment_author":  {string(s.Comment.Author)},
+			"comment_content": {s.Comment.Body},
+		},
+	)
+	if err != nil {
+		return types.SpamCheck{}, fmt.Errorf("checking with Akismet: %w", err)
+	}
+	defer func() {
+		if err := rsp.Body.Close(); err != nil {
+			log.Printf("Akismet.CheckSpam(): closing response body: %v", err)
+		}
+	}()
+
+	data, err := ioutil.ReadAll(io.LimitReader(rsp.Body, akismetResponseMax))
+	if err != nil {
+		return types.SpamCheck{}, fmt.Errorf("checking with Akismet: %w", err)
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return types.SpamCheck{}, fmt.Errorf(
+			"checking with Akismet: unexpected status `%d`",
+			rsp.StatusCode,
+		)
+	}
+
+	switch strings.TrimSpace(string(data)) {
+	case "false":
+		return types.Allow, nil
+	case "true":
+		if rsp.Header.Get("X-akismet-pro-tip") == "discard" {
+			return types.SpamCheck{
+				Verdict: types.VerdictReject,
+				Reason:  "Akismet: blatant spam",
+			}, nil
+		}
+		return types.SpamCheck{
+			Verdict: types.VerdictHold,
+			Reason:  "Akismet: spam",
+		}, nil
+	default:
+		// e.g., `invalid` for a bad key, with an explanation in a header
+		return types.SpamCheck{}, fmt.Errorf(
+			"checking with Akismet: unexpected response `%s`: %s",
+			data,
+			rsp.Header.Get("X-akismet-debug-help"),
+		)
+	}
+}
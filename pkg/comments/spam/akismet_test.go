@@ -0,0 +1,128 @@
+package spam
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestAkismet(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		status    int
+		body      string
+		headers   map[string]string
+		wanted    types.SpamCheck
+		wantedErr bool
+	}{
+		{
+			name:   "ham",
+			status: http.StatusOK,
+			body:   "false",
+			wanted: types.Allow,
+		},
+		{
+			name:   "spam",
+			status: http.StatusOK,
+			body:   "true",
+			wanted: types.SpamCheck{
+				Verdict: types.VerdictHold,
+				Reason:  "Akismet: spam",
+			},
+		},
+		{
+			name:    "blatant spam",
+			status:  http.StatusOK,
+			body:    "true",
+			headers: map[string]string{"X-akismet-pro-tip": "discard"},
+			wanted: types.SpamCheck{
+				Verdict: types.VerdictReject,
+				Reason:  "Akismet: blatant spam",
+			},
+		},
+		{
+			name:      "invalid key",
+			status:    http.StatusOK,
+			body:      "invalid",
+			headers:   map[string]string{"X-akismet-debug-help": "bad key"},
+			wantedErr: true,
+		},
+		{
+			name:      "server error",
+			status:    http.StatusInternalServerError,
+			body:      "false",
+			wantedErr: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			var form url.Values
+			var path string
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					path = r.URL.Path
+					if err := r.ParseForm(); err != nil {
+						t.Errorf("parsing form: %v", err)
+					}
+					form = r.PostForm
+					for k, v := range testCase.headers {
+						w.Header().Set(k, v)
+					}
+					w.WriteHeader(testCase.status)
+					w.Write([]byte(testCase.body))
+				},
+			))
+			defer server.Close()
+
+			checker := Akismet{
+				URL:    server.URL,
+				Key:    "key",
+				Blog:   "https://blog.example.org",
+				Client: server.Client(),
+			}
+			found, err := checker.CheckSpam(&types.Submission{
+				Comment: &types.Comment{
+					Post:   "post",
+					Parent: "parent",
+					Author: "user",
+					Body:   "comment body",
+				},
+				IP:        "10.0.0.1",
+				UserAgent: "agent",
+				Referrer:  "https://blog.example.org/post",
+			})
+			if testCase.wantedErr {
+				if err == nil {
+					t.Fatal("wanted error; found `nil`")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := compareCheck(testCase.wanted, found); err != nil {
+				t.Fatal(err)
+			}
+
+			if path != "/1.1/comment-check" {
+				t.Fatalf("path: wanted `/1.1/comment-check`; found `%s`", path)
+			}
+			for key, wanted := range map[string]string{
+				"api_key":         "key",
+				"blog":            "https://blog.example.org",
+				"user_ip":         "10.0.0.1",
+				"user_agent":      "agent",
+				"referrer":        "https://blog.example.org/post",
+				"comment_type":    "reply",
+				"comment_author":  "user",
+				"comment_content": "comment body",
+			} {
+				if found := form.Get(key); found != wanted {
+					t.Fatalf("%s: wanted `%s`; found `%s`", key, wanted, found)
+				}
+			}
+		})
+	}
+}
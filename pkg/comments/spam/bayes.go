@@ -0,0 +1,160 @@
+package spam
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// Class is a class of comments for the naive Bayes classifier.
+type Class int
+
+const (
+	Ham Class = iota
+	Spam
+)
+
+// ParseClass parses `ham` or `spam`.
+func ParseClass(s string) (Class, error) {
+	switch s {
+	case "ham":
+		return Ham, nil
+	case "spam":
+		return Spam, nil
+	default:
+		return 0, fmt.Errorf("invalid class `%s`: wanted `ham` or `spam`", s)
+	}
+}
+
+func (c Class) String() string {
+	if c == Spam {
+		return "spam"
+	}
+	return "ham"
+}
+
+// Default thresholds for `Bayes`.
+const (
+	BayesHoldDefault   = 0.9
+	BayesRejectDefault = 0.99
+)
+
+// Bayes is a multinomial naive Bayes classifier which is trained on local
+// examples of spam and ham (i.e., legitimate comments). Comments whose spam
+// probability is at least `Reject` are rejected and those whose probability
+// is at least `Hold` are held. Until it has been trained on at least one
+// example of each class, every comment is allowed. The zero value is ready to
+// train.
+type Bayes struct {
+	// Hold and Reject are the spam probability thresholds. They default to
+	// `BayesHoldDefault` and `BayesRejectDefault` respectively.
+	Hold   float64
+	Reject float64
+
+	lock   sync.RWMutex
+	counts [2]map[string]int // each class's word counts
+	words  [2]int            // each class's total word count
+	docs   [2]int            // each class's example count
+}
+
+// Train adds an example of the class to the classifier.
+func (b *Bayes) Train(class Class, text string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.counts[class] == nil {
+		b.counts[class] = map[string]int{}
+	}
+	for _, word := range words(text) {
+		b.counts[class][word]++
+		b.words[class]++
+	}
+	b.docs[class]++
+}
+
+// TrainCorpus trains the classifier on a corpus with one example per line.
+// Each line is a class (`spam` or `ham`), a tab, and the example text. Blank
+// lines and lines starting with `#` are ignored.
+func (b *Bayes) TrainCorpus(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		i := strings.IndexByte(text, '\t')
+		if i < 0 {
+			return fmt.Errorf(
+				"training on corpus: line %d: missing tab after class",
+				line,
+			)
+		}
+		class, err := ParseClass(text[:i])
+		if err != nil {
+			return fmt.Errorf("training on corpus: line %d: %w", line, err)
+		}
+		b.Train(class, text[i+1:])
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("training on corpus: %w", err)
+	}
+	return nil
+}
+
+// SpamProbability returns the probability that the text is spam, or -1 if
+// the classifier hasn't been trained on both classes yet.
+func (b *Bayes) SpamProbability(text string) float64 {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	if b.docs[Ham] < 1 || b.docs[Spam] < 1 {
+		return -1
+	}
+
+	// the vocabulary size for Laplace smoothing
+	vocabulary := len(b.counts[Ham])
+	for word := range b.counts[Spam] {
+		if _, found := b.counts[Ham][word]; !found {
+			vocabulary++
+		}
+	}
+
+	var logLikelihoods [2]float64
+	for _, class := range []Class{Ham, Spam} {
+		ll := math.Log(
+			float64(b.docs[class]) / float64(b.docs[Ham]+b.docs[Spam]),
+		)
+		for _, word := range words(text) {
+			ll += math.Log(
+				float64(b.counts[class][word]+1) /
+					float64(b.words[class]+vocabulary),
+			)
+		}
+		logLikelihoods[class] = ll
+	}
+	return 1 / (1 + math.Exp(logLikelihoods[Ham]-logLikelihoods[Spam]))
+}
+
+func (b *Bayes) CheckSpam(s *types.Submission) (types.SpamCheck, error) {
+	hold, reject := b.Hold, b.Reject
+	if hold == 0 {
+		hold = BayesHoldDefault
+	}
+	if reject == 0 {
+		reject = BayesRejectDefault
+	}
+
+	p := b.SpamProbability(s.Comment.Body)
+	reason := fmt.Sprintf("naive Bayes spam probability %.3f", p)
+	switch {
+	case p >= reject:
+		return types.SpamCheck{Verdict: types.VerdictReject, Reason: reason}, nil
+	case p >= hold:
+		return types.SpamCheck{Verdict: types.VerdictHold, Reason: reason}, nil
+	default:
+		return types.Allow, nil
+	}
+}
@@ -0,0 +1,105 @@
+package spam
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+const corpus = `# a tiny corpus
+spam	buy cheap pills now limited offer
+spam	cheap pills online no prescription buy now
+spam	limited offer click here to buy cheap watches
+ham	great post, thanks for writing this up
+
+ham	I disagree with the second point about closures
+ham	thanks, this helped me understand goroutines
+`
+
+func TestBayes(t *testing.T) {
+	var b Bayes
+	if err := b.TrainCorpus(strings.NewReader(corpus)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, testCase := range []struct {
+		name          string
+		body          string
+		wantedVerdict types.Verdict
+	}{
+		{
+			name:          "ham",
+			body:          "thanks for the post about goroutines",
+			wantedVerdict: types.VerdictAllow,
+		},
+		{
+			name:          "spam",
+			body:          "buy cheap pills now, limited offer, click here",
+			wantedVerdict: types.VerdictReject,
+		},
+		{
+			name:          "unknown words",
+			body:          "zyx wvu tsr",
+			wantedVerdict: types.VerdictAllow,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			found, err := b.CheckSpam(submission(testCase.body))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if found.Verdict != testCase.wantedVerdict {
+				t.Fatalf(
+					"SpamCheck.Verdict: wanted `%s`; found `%s` (%s)",
+					testCase.wantedVerdict,
+					found.Verdict,
+					found.Reason,
+				)
+			}
+		})
+	}
+}
+
+func TestBayes_Untrained(t *testing.T) {
+	var b Bayes
+	b.Train(Spam, "buy cheap pills")
+	if p := b.SpamProbability("buy cheap pills"); p != -1 {
+		t.Fatalf("wanted `-1`; found `%f`", p)
+	}
+	found, err := b.CheckSpam(submission("buy cheap pills"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := compareCheck(types.Allow, found); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBayes_TrainCorpus_Invalid(t *testing.T) {
+	for _, testCase := range []struct {
+		name   string
+		corpus string
+		wanted string
+	}{
+		{
+			name:   "missing tab",
+			corpus: "ham\tfine\nspam no tab",
+			wanted: "training on corpus: line 2: missing tab after class",
+		},
+		{
+			name:   "invalid class",
+			corpus: "eggs\tgreen",
+			wanted: "training on corpus: line 1: invalid class `eggs`: " +
+				"wanted `ham` or `spam`",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			var b Bayes
+			err := b.TrainCorpus(strings.NewReader(testCase.corpus))
+			if err == nil || err.Error() != testCase.wanted {
+				t.Fatalf("wanted error `%s`; found `%v`", testCase.wanted, err)
+			}
+		})
+	}
+}
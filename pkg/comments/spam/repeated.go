@@ -0,0 +1,108 @@
+package spam
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// repeatedSweepMin is the number of keys a `RepeatedPosts` holds before it
+// starts sweeping out expired posts.
+const repeatedSweepMin = 1024
+
+// RepeatedPosts flags comments whose bodies were already posted at least
+// `Max` times within `Window` by the same author or from the same IP address.
+// Bodies are compared ignoring case, punctuation, and whitespace. It only
+// remembers the posts it has checked, and only within a single process.
+type RepeatedPosts struct {
+	Window time.Duration
+
+	// Max is the number of times a body may be posted within the window
+	// before further posts are flagged. It defaults to one, so any repeat is
+	// flagged.
+	Max int
+
+	// Verdict is the verdict for repeated posts. It defaults to
+	// `types.VerdictHold`.
+	Verdict  types.Verdict
+	TimeFunc func() time.Time
+
+	lock    sync.Mutex
+	posts   map[string][]time.Time
+	sweepAt int
+}
+
+func (rp *RepeatedPosts) CheckSpam(
+	s *types.Submission,
+) (types.SpamCheck, error) {
+	max := rp.Max
+	if max < 1 {
+		max = 1
+	}
+	now := rp.TimeFunc()
+	sum := sha256.Sum256([]byte(strings.Join(words(s.Comment.Body), " ")))
+
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+	if rp.posts == nil {
+		rp.posts = map[string][]time.Time{}
+	}
+	if len(rp.posts) >= rp.sweepAt {
+		rp.sweep(now)
+	}
+
+	repeats := 0
+	for _, key := range []struct{ kind, value string }{
+		{"author", string(s.Comment.Author)},
+		{"ip", s.IP},
+	} {
+		if key.value == "" {
+			continue
+		}
+		k := fmt.Sprintf("%s:%s:%x", key.kind, key.value, sum)
+		times := rp.recent(k, now)
+		if len(times) > repeats {
+			repeats = len(times)
+		}
+		rp.posts[k] = append(times, now)
+	}
+
+	if repeats >= max {
+		return types.SpamCheck{
+			Verdict: verdictOrHold(rp.Verdict),
+			Reason: fmt.Sprintf(
+				"repeated post (%d times in %s)",
+				repeats+1,
+				rp.Window,
+			),
+		}, nil
+	}
+	return types.Allow, nil
+}
+
+// recent returns the times within the window at which the key was posted.
+func (rp *RepeatedPosts) recent(key string, now time.Time) []time.Time {
+	times := rp.posts[key]
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= rp.Window {
+		i++
+	}
+	return times[i:]
+}
+
+// sweep deletes the keys which haven't been posted within the window.
+func (rp *RepeatedPosts) sweep(now time.Time) {
+	for key := range rp.posts {
+		if len(rp.recent(key, now)) < 1 {
+			delete(rp.posts, key)
+		}
+	}
+	rp.sweepAt = 2 * len(rp.posts)
+	if rp.sweepAt < repeatedSweepMin {
+		rp.sweepAt = repeatedSweepMin
+	}
+}
@@ -0,0 +1,114 @@
+package spam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestRepeatedPosts(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	type post struct {
+		author types.UserID
+		ip     string
+		body   string
+		at     time.Duration // since `start`
+	}
+	for _, testCase := range []struct {
+		name   string
+		max    int
+		posts  []post
+		wanted types.SpamCheck
+	}{
+		{
+			name:   "first post",
+			posts:  []post{{"adam", "10.0.0.1", "hello there", 0}},
+			wanted: types.Allow,
+		},
+		{
+			name: "repeated by author",
+			posts: []post{
+				{"adam", "10.0.0.1", "hello there", 0},
+				{"adam", "10.0.0.2", "Hello, there!", time.Minute},
+			},
+			wanted: types.SpamCheck{
+				Verdict: types.VerdictHold,
+				Reason:  "repeated post (2 times in 1h0m0s)",
+			},
+		},
+		{
+			name: "repeated from ip",
+			posts: []post{
+				{"adam", "10.0.0.1", "hello there", 0},
+				{"eve", "10.0.0.1", "hello there", time.Minute},
+			},
+			wanted: types.SpamCheck{
+				Verdict: types.VerdictHold,
+				Reason:  "repeated post (2 times in 1h0m0s)",
+			},
+		},
+		{
+			name: "different bodies",
+			posts: []post{
+				{"adam", "10.0.0.1", "hello there", 0},
+				{"adam", "10.0.0.1", "hello again", time.Minute},
+			},
+			wanted: types.Allow,
+		},
+		{
+			name: "outside the window",
+			posts: []post{
+				{"adam", "10.0.0.1", "hello there", 0},
+				{"adam", "10.0.0.1", "hello there", time.Hour},
+			},
+			wanted: types.Allow,
+		},
+		{
+			name: "within max",
+			max:  2,
+			posts: []post{
+				{"adam", "10.0.0.1", "hello there", 0},
+				{"adam", "10.0.0.1", "hello there", time.Minute},
+			},
+			wanted: types.Allow,
+		},
+		{
+			name: "over max",
+			max:  2,
+			posts: []post{
+				{"adam", "10.0.0.1", "hello there", 0},
+				{"adam", "10.0.0.1", "hello there", time.Minute},
+				{"adam", "10.0.0.1", "hello there", 2 * time.Minute},
+			},
+			wanted: types.SpamCheck{
+				Verdict: types.VerdictHold,
+				Reason:  "repeated post (3 times in 1h0m0s)",
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			var now time.Time
+			checker := RepeatedPosts{
+				Window:   time.Hour,
+				Max:      testCase.max,
+				TimeFunc: func() time.Time { return now },
+			}
+			var found types.SpamCheck
+			for _, p := range testCase.posts {
+				now = start.Add(p.at)
+				var err error
+				found, err = checker.CheckSpam(&types.Submission{
+					Comment: &types.Comment{Author: p.author, Body: p.body},
+					IP:      p.ip,
+				})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if err := compareCheck(testCase.wanted, found); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
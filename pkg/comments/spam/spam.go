@@ -0,0 +1,88 @@
+// Package spam provides `types.SpamChecker` implementations: simple
+// heuristics (link counts, banned words, and repeated posts), a naive Bayes
+// classifier which is trained locally, and a client for Akismet-compatible
+// spam checking services.
+package spam
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// verdictOrHold returns the verdict, defaulting to `types.VerdictHold`. The
+// heuristic checkers hold comments by default since they're prone to false
+// positives.
+func verdictOrHold(v types.Verdict) types.Verdict {
+	if v == "" {
+		return types.VerdictHold
+	}
+	return v
+}
+
+// words splits text into lower-cased words, dropping punctuation.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// links matches the starts of URLs, whether or not they're Markdown links.
+var links = regexp.MustCompile(`(?i)\b(?:https?://|www\.)`)
+
+// LinkCount flags comments which contain more than `Max` links.
+type LinkCount struct {
+	Max int
+
+	// Verdict is the verdict for comments with too many links. It defaults to
+	// `types.VerdictHold`.
+	Verdict types.Verdict
+}
+
+func (lc *LinkCount) CheckSpam(s *types.Submission) (types.SpamCheck, error) {
+	if n := len(links.FindAllStringIndex(s.Comment.Body, -1)); n > lc.Max {
+		return types.SpamCheck{
+			Verdict: verdictOrHold(lc.Verdict),
+			Reason:  fmt.Sprintf("too many links (%d > %d)", n, lc.Max),
+		}, nil
+	}
+	return types.Allow, nil
+}
+
+// BannedWords flags comments which contain any of the banned words or
+// phrases. Matching is case-insensitive, ignores punctuation, and only matches
+// whole words (e.g., `cialis` doesn't match `specialist`).
+type BannedWords struct {
+	Words []string
+
+	// Verdict is the verdict for comments with banned words. It defaults to
+	// `types.VerdictHold`.
+	Verdict types.Verdict
+}
+
+func (bw *BannedWords) CheckSpam(s *types.Submission) (types.SpamCheck, error) {
+	body := " " + strings.Join(words(s.Comment.Body), " ") + " "
+	for _, word := range bw.Words {
+		phrase := strings.Join(words(word), " ")
+		if phrase != "" && strings.Contains(body, " "+phrase+" ") {
+			return types.SpamCheck{
+				Verdict: verdictOrHold(bw.Verdict),
+				Reason:  fmt.Sprintf("banned word `%s`", word),
+			}, nil
+		}
+	}
+	return types.Allow, nil
+}
+
+var (
+	// fail compilation if the checkers don't implement the
+	// `types.SpamChecker` interface.
+	_ types.SpamChecker = &LinkCount{}
+	_ types.SpamChecker = &BannedWords{}
+	_ types.SpamChecker = &RepeatedPosts{}
+	_ types.SpamChecker = &Bayes{}
+	_ types.SpamChecker = &Akismet{}
+)
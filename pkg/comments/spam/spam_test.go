@@ -0,0 +1,127 @@
+package spam
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func submission(body string) *types.Submission {
+	return &types.Submission{
+		Comment: &types.Comment{Post: "post", Author: "user", Body: body},
+		IP:      "10.0.0.1",
+	}
+}
+
+func compareCheck(wanted, found types.SpamCheck) error {
+	if wanted != found {
+		return fmt.Errorf(
+			"SpamCheck: wanted `%+v`; found `%+v`",
+			wanted,
+			found,
+		)
+	}
+	return nil
+}
+
+func TestLinkCount(t *testing.T) {
+	for _, testCase := range []struct {
+		name    string
+		checker LinkCount
+		body    string
+		wanted  types.SpamCheck
+	}{
+		{
+			name:    "no links",
+			checker: LinkCount{Max: 1},
+			body:    "no links here",
+			wanted:  types.Allow,
+		},
+		{
+			name:    "at the limit",
+			checker: LinkCount{Max: 2},
+			body:    "see [this](https://example.org) and www.example.com",
+			wanted:  types.Allow,
+		},
+		{
+			name:    "over the limit",
+			checker: LinkCount{Max: 2},
+			body:    "http://a.example https://b.example HTTP://c.example",
+			wanted: types.SpamCheck{
+				Verdict: types.VerdictHold,
+				Reason:  "too many links (3 > 2)",
+			},
+		},
+		{
+			name:    "custom verdict",
+			checker: LinkCount{Verdict: types.VerdictReject},
+			body:    "https://example.org",
+			wanted: types.SpamCheck{
+				Verdict: types.VerdictReject,
+				Reason:  "too many links (1 > 0)",
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			found, err := testCase.checker.CheckSpam(submission(testCase.body))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := compareCheck(testCase.wanted, found); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestBannedWords(t *testing.T) {
+	checker := BannedWords{Words: []string{"cialis", "Cheap Watches"}}
+	for _, testCase := range []struct {
+		name   string
+		body   string
+		wanted types.SpamCheck
+	}{
+		{
+			name:   "no banned words",
+			body:   "a perfectly normal comment",
+			wanted: types.Allow,
+		},
+		{
+			name: "banned word",
+			body: "buy CIALIS now!",
+			wanted: types.SpamCheck{
+				Verdict: types.VerdictHold,
+				Reason:  "banned word `cialis`",
+			},
+		},
+		{
+			name:   "substring of a word",
+			body:   "ask a specialist",
+			wanted: types.Allow,
+		},
+		{
+			name: "phrase across punctuation and whitespace",
+			body: "get your cheap,\n  watches here",
+			wanted: types.SpamCheck{
+				Verdict: types.VerdictHold,
+				Reason:  "banned word `Cheap Watches`",
+			},
+		},
+		{
+			name:   "partial phrase",
+			body:   "cheap shots",
+			wanted: types.Allow,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			found, err := checker.CheckSpam(submission(testCase.body))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := compareCheck(testCase.wanted, found); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package comments
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/testsupport"
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+func TestWebServer_Reply_Spam(t *testing.T) {
+	var submitted *types.Submission
+	store := testsupport.CommentsStoreFake{}
+	webServer := WebServer{
+		Comments: CommentsModel{
+			CommentsStore: store,
+			SpamCheckers: []types.SpamChecker{spamCheckerFunc(
+				func(s *types.Submission) (types.SpamCheck, error) {
+					submitted = s
+					return types.SpamCheck{
+						Verdict: types.VerdictReject,
+						Reason:  "blatant",
+					}, nil
+				},
+			)},
+			IDFunc:   func() types.CommentID { return "reply" },
+			TimeFunc: func() time.Time { return now },
+		},
+		BaseURL: "https://comments.example.org",
+	}
+
+	rsp := webServer.Reply(pz.Request{
+		Vars: map[string]string{
+			"post-id":    "post",
+			"comment-id": "toplevel",
+		},
+		Headers: http.Header{
+			"User":         []string{"adam"},
+			ClientIPHeader: []string{"10.0.0.1"},
+			"User-Agent":   []string{"agent"},
+			"Referer":      []string{"https://blog.example.org/post"},
+		},
+		Body: strings.NewReader(url.Values{"body": {goodBody}}.Encode()),
+	})
+
+	if rsp.Status != http.StatusBadRequest {
+		t.Fatalf("Response.Status: wanted `400`; found `%d`", rsp.Status)
+	}
+	data, err := readAll(rsp.Data)
+	if err != nil {
+		t.Fatalf("Response.Data: %v", err)
+	}
+	for _, wanted := range []string{
+		goodBody,
+		"Your comment looks like spam, so it wasn&#39;t posted.",
+	} {
+		if !strings.Contains(string(data), wanted) {
+			t.Fatalf("Response.Data: missing `%s`:\n%s", wanted, data)
+		}
+	}
+	if strings.Contains(string(data), "blatant") {
+		t.Fatalf("Response.Data: leaked spam check reason:\n%s", data)
+	}
+	if len(store["post"]) > 0 {
+		t.Fatalf("wanted no stored comments; found `%d`", len(store["post"]))
+	}
+
+	if submitted.IP != "10.0.0.1" ||
+		submitted.UserAgent != "agent" ||
+		submitted.Referrer != "https://blog.example.org/post" {
+		t.Fatalf("unexpected submission: %+v", submitted)
+	}
+}
+
+func TestWebServer_Edit_Spam(t *testing.T) {
+	var submitted *types.Submission
+	store := testsupport.CommentsStoreFake{"post": {"id": &types.Comment{
+		ID:       "id",
+		Post:     "post",
+		Author:   "adam",
+		Created:  someTime,
+		Modified: someTime,
+		Body:     "hello, world",
+		Status:   types.StatusApproved,
+	}}}
+	webServer := WebServer{
+		Comments: CommentsModel{
+			CommentsStore: store,
+			SpamCheckers: []types.SpamChecker{spamCheckerFunc(
+				func(s *types.Submission) (types.SpamCheck, error) {
+					submitted = s
+					return types.SpamCheck{
+						Verdict: types.VerdictReject,
+						Reason:  "blatant",
+					}, nil
+				},
+			)},
+			TimeFunc: func() time.Time { return now },
+		},
+		BaseURL: "https://comments.example.org",
+	}
+
+	rsp := webServer.Edit(pz.Request{
+		Vars: map[string]string{"post-id": "post", "comment-id": "id"},
+		Headers: http.Header{
+			"User":         []string{"adam"},
+			ClientIPHeader: []string{"10.0.0.1"},
+		},
+		Body: strings.NewReader(url.Values{"body": {goodBody}}.Encode()),
+	})
+
+	if rsp.Status != http.StatusBadRequest {
+		t.Fatalf("Response.Status: wanted `400`; found `%d`", rsp.Status)
+	}
+	data, err := readAll(rsp.Data)
+	if err != nil {
+		t.Fatalf("Response.Data: %v", err)
+	}
+	wanted := "Your edit looks like spam, so it wasn&#39;t saved."
+	if !strings.Contains(string(data), wanted) {
+		t.Fatalf("Response.Data: missing `%s`:\n%s", wanted, data)
+	}
+	if body := store["post"]["id"].Body; body != "hello, world" {
+		t.Fatalf("Body: wanted `hello, world`; found `%s`", body)
+	}
+	if submitted.IP != "10.0.0.1" || submitted.Comment.Body != goodBody {
+		t.Fatalf("unexpected submission: %+v", submitted)
+	}
+}
+
+type spamCheckerFunc func(*types.Submission) (types.SpamCheck, error)
+
+func (f spamCheckerFunc) CheckSpam(
+	s *types.Submission,
+) (types.SpamCheck, error) {
+	return f(s)
+}
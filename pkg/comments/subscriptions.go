@@ -0,0 +1,289 @@
+package comments
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// inboxLimit is the maximum number of notifications listed in an inbox.
+const inboxLimit = 100
+
+// inboxPath is the path of the notifications inbox page.
+const inboxPath = "/notifications"
+
+// Subscribe follows a post (if `comment` is empty) or one of its subthreads
+// on behalf of the user. New comments in the thread are delivered to the
+// user's inbox.
+func (cm *CommentsModel) Subscribe(
+	user types.UserID,
+	post types.PostID,
+	comment types.CommentID,
+) (*types.Subscription, error) {
+	if user == "" {
+		return nil, ErrUnauthorized
+	}
+	if post == "" {
+		return nil, ErrInvalidPost
+	}
+	if comment != "" {
+		c, err := cm.CommentsStore.Comment(post, comment)
+		if err != nil {
+			return nil, fmt.Errorf("subscribing to thread: %w", err)
+		}
+		q, err := cm.visibility(user)
+		if err != nil {
+			return nil, err
+		}
+		visible, err := cm.threadVisible(q, c)
+		if err != nil {
+			return nil, fmt.Errorf("subscribing to thread: %w", err)
+		}
+		if c.Deleted || !visible {
+			return nil, fmt.Errorf(
+				"subscribing to thread: %w",
+				types.ErrCommentNotFound,
+			)
+		}
+	}
+	if cm.SubscriptionsStore == nil {
+		return nil, fmt.Errorf(
+			"subscribing to thread: no subscriptions store",
+		)
+	}
+
+	subscription := types.Subscription{
+		User:    user,
+		Post:    post,
+		Comment: comment,
+		Created: cm.TimeFunc(),
+	}
+	if err := cm.SubscriptionsStore.PutSubscription(
+		&subscription,
+	); err != nil {
+		return nil, fmt.Errorf("subscribing to thread: %w", err)
+	}
+	return &subscription, nil
+}
+
+// Unsubscribe stops following a post or subthread on behalf of the user.
+func (cm *CommentsModel) Unsubscribe(
+	user types.UserID,
+	post types.PostID,
+	comment types.CommentID,
+) error {
+	if user == "" {
+		return ErrUnauthorized
+	}
+	if cm.SubscriptionsStore == nil {
+		return fmt.Errorf(
+			"unsubscribing from thread: %w",
+			types.ErrSubscriptionNotFound,
+		)
+	}
+	if err := cm.SubscriptionsStore.DeleteSubscription(
+		user,
+		post,
+		comment,
+	); err != nil {
+		return fmt.Errorf("unsubscribing from thread: %w", err)
+	}
+	return nil
+}
+
+// Subscribed reports whether the user follows the post (if `comment` is
+// empty) or subthread.
+func (cm *CommentsModel) Subscribed(
+	user types.UserID,
+	post types.PostID,
+	comment types.CommentID,
+) (bool, error) {
+	if user == "" || cm.SubscriptionsStore == nil {
+		return false, nil
+	}
+	_, err := cm.SubscriptionsStore.Subscription(user, post, comment)
+	if err != nil {
+		if errors.Is(err, types.ErrSubscriptionNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("fetching subscription: %w", err)
+	}
+	return true, nil
+}
+
+// Subscriptions returns the posts and subthreads which the user follows,
+// newest first.
+func (cm *CommentsModel) Subscriptions(
+	user types.UserID,
+) ([]*types.Subscription, error) {
+	if user == "" {
+		return nil, ErrUnauthorized
+	}
+	if cm.SubscriptionsStore == nil {
+		return []*types.Subscription{}, nil
+	}
+	subscriptions, err := cm.SubscriptionsStore.UserSubscriptions(user)
+	if err != nil {
+		return nil, fmt.Errorf("fetching subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// deliver adds a notification about a new comment to the inbox of every user
+// who follows the post or one of the comment's ancestors (except for the
+// comment's author). Failures are logged rather than returned since the
+// comment has already been stored.
+func (cm *CommentsModel) deliver(c *types.Comment) {
+	if cm.SubscriptionsStore == nil || cm.InboxStore == nil {
+		return
+	}
+	subscriptions, err := cm.SubscriptionsStore.PostSubscriptions(c.Post)
+	if err != nil {
+		log.Printf("delivering comment `%s`: %v", c.ID, err)
+		return
+	}
+	if len(subscriptions) < 1 {
+		return
+	}
+	ancestors, err := cm.ancestorIDs(c)
+	if err != nil {
+		log.Printf("delivering comment `%s`: %v", c.ID, err)
+		return
+	}
+
+	now := cm.TimeFunc()
+	notified := map[types.UserID]bool{c.Author: true}
+	for _, s := range subscriptions {
+		if notified[s.User] || (s.Comment != "" && !ancestors[s.Comment]) {
+			continue
+		}
+		notified[s.User] = true
+		if err := cm.InboxStore.PutNotification(&types.Notification{
+			User:    s.User,
+			Post:    c.Post,
+			Comment: c.ID,
+			Thread:  s.Comment,
+			Created: now,
+		}); err != nil && !errors.Is(err, types.ErrNotificationExists) {
+			log.Printf(
+				"delivering comment `%s` to `%s`: %v",
+				c.ID,
+				s.User,
+				err,
+			)
+		}
+	}
+}
+
+// ancestorIDs returns the IDs of the comment's ancestors.
+func (cm *CommentsModel) ancestorIDs(
+	c *types.Comment,
+) (map[types.CommentID]bool, error) {
+	ancestors := map[types.CommentID]bool{}
+	for parent := c.Parent; parent != "" && !ancestors[parent]; {
+		ancestors[parent] = true
+		a, err := cm.CommentsStore.Comment(c.Post, parent)
+		if err != nil {
+			return nil, fmt.Errorf("fetching ancestor `%s`: %w", parent, err)
+		}
+		parent = a.Parent
+	}
+	return ancestors, nil
+}
+
+// InboxEntry is a notification along with the comment it's about.
+type InboxEntry struct {
+	Notification *types.Notification `json:"notification"`
+	Comment      *types.Comment      `json:"comment"`
+}
+
+// Inbox returns the user's newest notifications. Read notifications are only
+// included if `read` is true. Notifications about comments which are no
+// longer visible to the user are left out.
+func (cm *CommentsModel) Inbox(
+	user types.UserID,
+	read bool,
+) ([]*InboxEntry, error) {
+	if user == "" {
+		return nil, ErrUnauthorized
+	}
+	if cm.InboxStore == nil {
+		return []*InboxEntry{}, nil
+	}
+	notifications, err := cm.InboxStore.Notifications(user, read, inboxLimit)
+	if err != nil {
+		return nil, fmt.Errorf("fetching notifications: %w", err)
+	}
+	q, err := cm.visibility(user)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*InboxEntry{}
+	var comments []*types.Comment
+	for _, n := range notifications {
+		c, err := cm.CommentsStore.Comment(n.Post, n.Comment)
+		if err != nil {
+			if errors.Is(err, types.ErrCommentNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("fetching notification comment: %w", err)
+		}
+		visible, err := cm.threadVisible(q, c)
+		if err != nil {
+			return nil, fmt.Errorf("fetching notification comment: %w", err)
+		}
+		if !visible {
+			continue
+		}
+		entries = append(entries, &InboxEntry{Notification: n, Comment: c})
+		comments = append(comments, c)
+	}
+	redact(comments)
+	if !q.AllStatuses {
+		hideSpamChecks(comments...)
+	}
+	render(comments...)
+	return entries, nil
+}
+
+// MarkRead marks the user's notification about a comment read.
+func (cm *CommentsModel) MarkRead(
+	user types.UserID,
+	post types.PostID,
+	comment types.CommentID,
+) error {
+	if user == "" {
+		return ErrUnauthorized
+	}
+	if cm.InboxStore == nil {
+		return fmt.Errorf(
+			"marking notification read: %w",
+			types.ErrNotificationNotFound,
+		)
+	}
+	if err := cm.InboxStore.MarkNotificationRead(
+		user,
+		post,
+		comment,
+	); err != nil {
+		return fmt.Errorf("marking notification read: %w", err)
+	}
+	return nil
+}
+
+// MarkAllRead marks all of the user's notifications read.
+func (cm *CommentsModel) MarkAllRead(user types.UserID) error {
+	if user == "" {
+		return ErrUnauthorized
+	}
+	if cm.InboxStore == nil {
+		return nil
+	}
+	if err := cm.InboxStore.MarkNotificationsRead(user); err != nil {
+		return fmt.Errorf("marking notifications read: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,448 @@
+package comments
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/testsupport"
+	"github.com/weberc2/comments/pkg/comments/types"
+	pz "github.com/weberc2/httpeasy"
+)
+
+func TestCommentsModel_Put_Deliver(t *testing.T) {
+	for _, testCase := range []struct {
+		name          string
+		comment       types.Comment
+		subscriptions []*types.Subscription
+		sanctions     []*types.Sanction
+		premoderate   bool
+		wanted        []types.UserID
+	}{
+		{
+			name: "post",
+			comment: types.Comment{
+				Post:   "post",
+				Author: "replier",
+				Body:   "a toplevel comment",
+			},
+			subscriptions: []*types.Subscription{
+				{User: "follower", Post: "post"},
+				{User: "stranger", Post: "other-post"},
+			},
+			wanted: []types.UserID{"follower"},
+		},
+		{
+			name: "subthread",
+			comment: types.Comment{
+				Post:   "post",
+				Parent: "approved-child",
+				Author: "replier",
+				Body:   "a short reply",
+			},
+			subscriptions: []*types.Subscription{
+				{User: "follower", Post: "post", Comment: "approved"},
+			},
+			wanted: []types.UserID{"follower"},
+		},
+		{
+			name: "other subthread",
+			comment: types.Comment{
+				Post:   "post",
+				Author: "replier",
+				Body:   "a toplevel comment",
+			},
+			subscriptions: []*types.Subscription{
+				{User: "follower", Post: "post", Comment: "approved"},
+			},
+		},
+		{
+			name: "post and subthread",
+			comment: types.Comment{
+				Post:   "post",
+				Parent: "approved",
+				Author: "replier",
+				Body:   "a short reply",
+			},
+			subscriptions: []*types.Subscription{
+				{User: "follower", Post: "post"},
+				{User: "follower", Post: "post", Comment: "approved"},
+			},
+			wanted: []types.UserID{"follower"},
+		},
+		{
+			name: "own comment",
+			comment: types.Comment{
+				Post:   "post",
+				Author: "replier",
+				Body:   "a toplevel comment",
+			},
+			subscriptions: []*types.Subscription{
+				{User: "replier", Post: "post"},
+			},
+		},
+		{
+			name: "held comment",
+			comment: types.Comment{
+				Post:   "post",
+				Author: "replier",
+				Body:   "a toplevel comment",
+			},
+			subscriptions: []*types.Subscription{
+				{User: "follower", Post: "post"},
+			},
+			premoderate: true,
+		},
+		{
+			name: "shadow-banned author",
+			comment: types.Comment{
+				Post:   "post",
+				Author: "replier",
+				Body:   "a toplevel comment",
+			},
+			subscriptions: []*types.Subscription{
+				{User: "follower", Post: "post"},
+			},
+			sanctions: []*types.Sanction{
+				{User: "replier", Kind: types.SanctionShadowBan},
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			state := moderationState()
+			state["post"]["approved-child"] = &types.Comment{
+				ID:     "approved-child",
+				Post:   "post",
+				Parent: "approved",
+				Author: "other",
+				Body:   "body",
+				Status: types.StatusApproved,
+			}
+			var inbox testsupport.InboxStoreFake
+			model := CommentsModel{
+				CommentsStore:  state,
+				Premoderate:    testCase.premoderate,
+				SanctionsStore: testsupport.Sanctions(testCase.sanctions...),
+				SubscriptionsStore: testsupport.Subscriptions(
+					testCase.subscriptions...,
+				),
+				InboxStore: &inbox,
+				IDFunc:     func() types.CommentID { return "new" },
+				TimeFunc:   func() time.Time { return now },
+			}
+			if _, err := model.Put(&testCase.comment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkInbox(t, "new", testCase.wanted, inbox.Items)
+		})
+	}
+}
+
+func TestCommentsModel_Moderate_Deliver(t *testing.T) {
+	var inbox testsupport.InboxStoreFake
+	model := CommentsModel{
+		CommentsStore: moderationState(),
+		Roles: testsupport.RolesStoreFake{
+			"moderator": types.RoleModerator,
+		},
+		SubscriptionsStore: testsupport.Subscriptions(
+			&types.Subscription{User: "follower", Post: "post"},
+		),
+		InboxStore: &inbox,
+		TimeFunc:   func() time.Time { return now },
+	}
+	if err := model.Moderate(
+		"moderator",
+		"post",
+		"pending",
+		types.StatusApproved,
+	); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkInbox(t, "pending", []types.UserID{"follower"}, inbox.Items)
+}
+
+// checkInbox checks that exactly the wanted users were notified about the
+// comment.
+func checkInbox(
+	t *testing.T,
+	comment types.CommentID,
+	wanted []types.UserID,
+	found []*types.Notification,
+) {
+	t.Helper()
+	users := map[types.UserID]bool{}
+	for _, n := range found {
+		if n.Comment != comment {
+			t.Fatalf(
+				"Notification.Comment: wanted `%s`; found `%s`",
+				comment,
+				n.Comment,
+			)
+		}
+		users[n.User] = true
+	}
+	if len(users) != len(wanted) || len(found) != len(wanted) {
+		t.Fatalf("notified users: wanted `%v`; found `%v`", wanted, users)
+	}
+	for _, user := range wanted {
+		if !users[user] {
+			t.Fatalf("notified users: wanted `%v`; found `%v`", wanted, users)
+		}
+	}
+}
+
+func TestCommentsModel_Inbox(t *testing.T) {
+	inbox := testsupport.InboxStoreFake{Items: []*types.Notification{
+		{
+			User:    "follower",
+			Post:    "post",
+			Comment: "approved",
+			Created: someTime,
+		},
+		{
+			User:    "follower",
+			Post:    "post",
+			Comment: "approved-child",
+			Created: someTime.Add(time.Hour),
+		},
+		{
+			// its parent is awaiting moderation
+			User:    "follower",
+			Post:    "post",
+			Comment: "pending-child",
+			Created: someTime.Add(time.Hour),
+		},
+		{
+			// rejected after the notification was delivered
+			User:    "follower",
+			Post:    "post",
+			Comment: "rejected",
+			Created: someTime.Add(2 * time.Hour),
+		},
+		{
+			User:    "other",
+			Post:    "post",
+			Comment: "approved",
+			Created: someTime,
+		},
+	}}
+	state := moderationState()
+	state["post"]["approved-child"] = &types.Comment{
+		ID:     "approved-child",
+		Post:   "post",
+		Parent: "approved",
+		Author: "other",
+		Body:   "body",
+		Status: types.StatusApproved,
+	}
+	model := CommentsModel{
+		CommentsStore: state,
+		InboxStore:    &inbox,
+		TimeFunc:      func() time.Time { return now },
+	}
+	check := func(read bool, wanted ...types.CommentID) {
+		t.Helper()
+		entries, err := model.Inbox("follower", read)
+		if err != nil {
+			t.Fatalf("Inbox(): unexpected error: %v", err)
+		}
+		comments := make([]*types.Comment, len(entries))
+		for i, e := range entries {
+			comments[i] = e.Comment
+		}
+		checkCommentIDs(t, "Inbox()", wanted, comments)
+	}
+
+	check(false, "approved", "approved-child")
+
+	if err := model.MarkRead("follower", "post", "approved"); err != nil {
+		t.Fatalf("MarkRead(): unexpected error: %v", err)
+	}
+	check(false, "approved-child")
+	check(true, "approved", "approved-child")
+
+	if err := model.MarkRead(
+		"follower",
+		"post",
+		"missing",
+	); !errors.Is(err, types.ErrNotificationNotFound) {
+		t.Fatalf(
+			"MarkRead(): wanted `ErrNotificationNotFound`; found `%v`",
+			err,
+		)
+	}
+
+	if err := model.MarkAllRead("follower"); err != nil {
+		t.Fatalf("MarkAllRead(): unexpected error: %v", err)
+	}
+	check(false)
+	if inbox.Items[4].Read {
+		t.Fatal("MarkAllRead(): marked another user's notification read")
+	}
+
+	if _, err := model.Inbox("", false); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Inbox(): wanted `ErrUnauthorized`; found `%v`", err)
+	}
+}
+
+func TestCommentsModel_Subscribe(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		user      types.UserID
+		comment   types.CommentID
+		wantedErr error
+	}{
+		{name: "post", user: "follower"},
+		{name: "subthread", user: "follower", comment: "approved"},
+		{
+			name:      "missing comment",
+			user:      "follower",
+			comment:   "missing",
+			wantedErr: types.ErrCommentNotFound,
+		},
+		{
+			name:      "hidden comment",
+			user:      "follower",
+			comment:   "rejected",
+			wantedErr: types.ErrCommentNotFound,
+		},
+		{
+			name:      "anonymous",
+			wantedErr: ErrUnauthorized,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			subscriptions := testsupport.Subscriptions()
+			model := CommentsModel{
+				CommentsStore:      moderationState(),
+				SubscriptionsStore: subscriptions,
+				TimeFunc:           func() time.Time { return now },
+			}
+			_, err := model.Subscribe(
+				testCase.user,
+				"post",
+				testCase.comment,
+			)
+			if !errors.Is(err, testCase.wantedErr) {
+				t.Fatalf(
+					"Subscribe(): wanted `%v`; found `%v`",
+					testCase.wantedErr,
+					err,
+				)
+			}
+			subscribed, err := model.Subscribed(
+				testCase.user,
+				"post",
+				testCase.comment,
+			)
+			if err != nil {
+				t.Fatalf("Subscribed(): unexpected error: %v", err)
+			}
+			if subscribed != (testCase.wantedErr == nil) {
+				t.Fatalf(
+					"Subscribed(): wanted `%t`; found `%t`",
+					testCase.wantedErr == nil,
+					subscribed,
+				)
+			}
+		})
+	}
+}
+
+func TestWebServer_ThreadSubscription(t *testing.T) {
+	subscriptions := testsupport.Subscriptions()
+	webServer := WebServer{
+		BaseURL: "https://comments.example.org",
+		Comments: CommentsModel{
+			CommentsStore:      moderationState(),
+			SubscriptionsStore: subscriptions,
+			TimeFunc:           func() time.Time { return now },
+		},
+	}
+	request := func(comment, action string) pz.Request {
+		return pz.Request{
+			Vars: map[string]string{
+				"post-id":    "post",
+				"comment-id": comment,
+			},
+			Headers: http.Header{"User": []string{"follower"}},
+			Body:    strings.NewReader("action=" + action),
+		}
+	}
+
+	for _, testCase := range []struct {
+		name         string
+		comment      string
+		action       string
+		wantedStatus int
+		wanted       []types.CommentID
+	}{
+		{
+			name:         "subscribe to post",
+			comment:      "toplevel",
+			action:       "subscribe",
+			wantedStatus: http.StatusSeeOther,
+			wanted:       []types.CommentID{""},
+		},
+		{
+			name:         "subscribe to subthread",
+			comment:      "approved",
+			action:       "subscribe",
+			wantedStatus: http.StatusSeeOther,
+			wanted:       []types.CommentID{"", "approved"},
+		},
+		{
+			name:         "unsubscribe from post",
+			comment:      "toplevel",
+			action:       "unsubscribe",
+			wantedStatus: http.StatusSeeOther,
+			wanted:       []types.CommentID{"approved"},
+		},
+		{
+			name:         "unsubscribe again",
+			comment:      "toplevel",
+			action:       "unsubscribe",
+			wantedStatus: http.StatusSeeOther,
+			wanted:       []types.CommentID{"approved"},
+		},
+		{
+			name:         "invalid action",
+			comment:      "toplevel",
+			action:       "follow",
+			wantedStatus: http.StatusBadRequest,
+			wanted:       []types.CommentID{"approved"},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			rsp := webServer.ThreadSubscription(
+				request(testCase.comment, testCase.action),
+			)
+			if rsp.Status != testCase.wantedStatus {
+				t.Fatalf(
+					"Response.Status: wanted `%d`; found `%d`",
+					testCase.wantedStatus,
+					rsp.Status,
+				)
+			}
+			if len(subscriptions) != len(testCase.wanted) {
+				t.Fatalf(
+					"subscriptions: wanted `%v`; found `%d`",
+					testCase.wanted,
+					len(subscriptions),
+				)
+			}
+			for _, comment := range testCase.wanted {
+				if _, err := subscriptions.Subscription(
+					"follower",
+					"post",
+					comment,
+				); err != nil {
+					t.Fatalf("subscription `%s`: %v", comment, err)
+				}
+			}
+		})
+	}
+}
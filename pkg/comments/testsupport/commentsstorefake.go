@@ -2,6 +2,7 @@ package testsupport
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/weberc2/comments/pkg/comments/types"
 )
@@ -43,15 +44,185 @@ func (csf CommentsStoreFake) Replies(
 	if !found {
 		return nil, types.ErrCommentNotFound
 	}
+	// like `PGCommentsStore.Replies()`, return every descendant, not just
+	// the direct children.
 	var replies []*types.Comment
 	for _, c := range postComments {
 		if c.Parent == comment {
 			replies = append(replies, c)
+			descendants, err := csf.Replies(post, c.ID)
+			if err != nil {
+				return nil, err
+			}
+			replies = append(replies, descendants...)
 		}
 	}
 	return replies, nil
 }
 
+func (csf CommentsStoreFake) RepliesPage(
+	q *types.RepliesQuery,
+) (*types.RepliesPage, error) {
+	// collect the page's threads (the direct children of `q.Parent`) in
+	// `q.Sort` order, skipping any which precede the cursor.
+	var threads []*types.Comment
+	for _, c := range csf.children(q, q.Parent) {
+		if q.Cursor == nil || q.Cursor.Before(c) {
+			threads = append(threads, c)
+		}
+	}
+
+	page := types.RepliesPage{Comments: []*types.Comment{}}
+	if len(threads) > q.Limit {
+		threads = threads[:q.Limit]
+		page.Next = types.CursorFor(q.Sort, threads[len(threads)-1])
+	}
+
+	// copy each thread and its descendants (down to `q.MaxDepth`) into the
+	// page so callers can't modify the fake's state through the returned
+	// pointers.
+	type entry struct {
+		comment *types.Comment
+		depth   int
+	}
+	queue := make([]entry, len(threads))
+	for i := range threads {
+		queue[i] = entry{threads[i], 1}
+	}
+	for len(queue) > 0 {
+		e := queue[0]
+		queue = queue[1:]
+		c := *e.comment
+		page.Comments = append(page.Comments, &c)
+		if q.MaxDepth > 0 && e.depth >= q.MaxDepth {
+			c.HiddenReplies = csf.countDescendants(q, c.ID)
+			continue
+		}
+		for _, child := range csf.children(q, c.ID) {
+			queue = append(queue, entry{child, e.depth + 1})
+		}
+	}
+	return &page, nil
+}
+
+// children returns copies of the direct children of `parent` which are
+// visible to the query with their `ReplyCount` fields populated, ordered by
+// `q.Sort`.
+func (csf CommentsStoreFake) children(
+	q *types.RepliesQuery,
+	parent types.CommentID,
+) []*types.Comment {
+	var children []*types.Comment
+	for _, c := range csf[q.Post] {
+		if c.Parent == parent && q.Visible(c) {
+			child := *c
+			for _, grandchild := range csf[q.Post] {
+				if grandchild.Parent == c.ID && q.Visible(grandchild) {
+					child.ReplyCount++
+				}
+			}
+			children = append(children, &child)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return q.Sort.Less(children[i], children[j])
+	})
+	return children
+}
+
+func (csf CommentsStoreFake) countDescendants(
+	q *types.RepliesQuery,
+	comment types.CommentID,
+) int {
+	count := 0
+	for _, c := range csf[q.Post] {
+		if c.Parent == comment && q.Visible(c) {
+			count += 1 + csf.countDescendants(q, c.ID)
+		}
+	}
+	return count
+}
+
+// CommentsByStatus returns every comment with the provided status, oldest
+// first.
+func (csf CommentsStoreFake) CommentsByStatus(
+	status types.Status,
+) ([]*types.Comment, error) {
+	out := []*types.Comment{}
+	for _, comments := range csf {
+		for _, c := range comments {
+			if c.Status == status {
+				out = append(out, c)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return types.SortOldest.Less(out[i], out[j])
+	})
+	return out, nil
+}
+
+// CommentsByAuthor returns up to `limit` of the author's comments, newest
+// first.
+func (csf CommentsStoreFake) CommentsByAuthor(
+	author types.UserID,
+	limit int,
+) ([]*types.Comment, error) {
+	out := []*types.Comment{}
+	for _, comments := range csf {
+		for _, c := range comments {
+			if c.Author == author {
+				out = append(out, c)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return types.SortNewest.Less(out[i], out[j])
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// CommentsByPost returns up to `limit` of the post's comments, newest first.
+func (csf CommentsStoreFake) CommentsByPost(
+	post types.PostID,
+	limit int,
+) ([]*types.Comment, error) {
+	out := []*types.Comment{}
+	for _, c := range csf[post] {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return types.SortNewest.Less(out[i], out[j])
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// Ancestors returns the comments' ancestors, in no particular order.
+func (csf CommentsStoreFake) Ancestors(
+	comments ...*types.Comment,
+) ([]*types.Comment, error) {
+	out := []*types.Comment{}
+	seen := map[*types.Comment]bool{}
+	for _, c := range comments {
+		for parent := c.Parent; parent != ""; {
+			a, found := csf[c.Post][parent]
+			if !found || seen[a] {
+				break
+			}
+			seen[a] = true
+			out = append(out, a)
+			parent = a.Parent
+		}
+	}
+	return out, nil
+}
+
 func (csf CommentsStoreFake) Delete(
 	post types.PostID,
 	comment types.CommentID,
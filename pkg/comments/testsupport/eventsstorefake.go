@@ -0,0 +1,97 @@
+package testsupport
+
+import (
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// EventsStoreFake wraps a `types.CommentsStore` and records the events which
+// a store with an outbox (e.g., `pgcommentsstore.PGCommentsStore`) records
+// for each successful change (see `types.NewEvent()`). Like the outbox's, the
+// events' IDs increase from 1.
+type EventsStoreFake struct {
+	types.CommentsStore
+	Events []*types.Event
+
+	// Sanctions, if set, is consulted for shadow-bans like the outbox
+	// consults the `sanctions` table.
+	Sanctions types.SanctionsStore
+}
+
+func (esf *EventsStoreFake) Put(c *types.Comment) error {
+	if err := esf.CommentsStore.Put(c); err != nil {
+		return err
+	}
+	return esf.record(nil, c)
+}
+
+func (esf *EventsStoreFake) Update(patch *types.CommentPatch) error {
+	before, err := esf.snapshot(patch.Post(), patch.ID())
+	if err != nil {
+		return err
+	}
+	if err := esf.CommentsStore.Update(patch); err != nil {
+		return err
+	}
+	after, err := esf.snapshot(patch.Post(), patch.ID())
+	if err != nil {
+		return err
+	}
+	return esf.record(before, after)
+}
+
+func (esf *EventsStoreFake) Delete(
+	post types.PostID,
+	comment types.CommentID,
+) error {
+	before, err := esf.snapshot(post, comment)
+	if err != nil {
+		return err
+	}
+	if err := esf.CommentsStore.Delete(post, comment); err != nil {
+		return err
+	}
+	return esf.record(before, nil)
+}
+
+// snapshot copies the comment since some stores (e.g., `CommentsStoreFake`)
+// update comments in place.
+func (esf *EventsStoreFake) snapshot(
+	post types.PostID,
+	comment types.CommentID,
+) (*types.Comment, error) {
+	c, err := esf.CommentsStore.Comment(post, comment)
+	if err != nil {
+		return nil, err
+	}
+	cp := *c
+	return &cp, nil
+}
+
+func (esf *EventsStoreFake) record(before, after *types.Comment) error {
+	c := after
+	if c == nil {
+		c = before
+	}
+	shadowBanned := false
+	if esf.Sanctions != nil {
+		users, err := esf.Sanctions.ShadowBanned(time.Now())
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			if user == c.Author {
+				shadowBanned = true
+				break
+			}
+		}
+	}
+	e, ok := types.NewEvent(before, after, shadowBanned)
+	if !ok {
+		return nil
+	}
+	e.ID = int64(len(esf.Events) + 1)
+	esf.Events = append(esf.Events, e)
+	return nil
+}
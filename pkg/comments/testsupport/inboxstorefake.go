@@ -0,0 +1,77 @@
+package testsupport
+
+import (
+	"sort"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// InboxStoreFake stores notifications in the order they're put.
+type InboxStoreFake struct {
+	Items []*types.Notification
+}
+
+func (isf *InboxStoreFake) PutNotification(n *types.Notification) error {
+	if isf.find(n.User, n.Post, n.Comment) != nil {
+		return types.ErrNotificationExists
+	}
+	cp := *n
+	isf.Items = append(isf.Items, &cp)
+	return nil
+}
+
+func (isf *InboxStoreFake) Notifications(
+	u types.UserID,
+	read bool,
+	limit int,
+) ([]*types.Notification, error) {
+	notifications := []*types.Notification{}
+	for _, n := range isf.Items {
+		if n.User == u && (read || !n.Read) {
+			cp := *n
+			notifications = append(notifications, &cp)
+		}
+	}
+	sort.SliceStable(notifications, func(i, j int) bool {
+		return notifications[i].Created.After(notifications[j].Created)
+	})
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+	}
+	return notifications, nil
+}
+
+func (isf *InboxStoreFake) MarkNotificationRead(
+	u types.UserID,
+	p types.PostID,
+	c types.CommentID,
+) error {
+	n := isf.find(u, p, c)
+	if n == nil {
+		return types.ErrNotificationNotFound
+	}
+	n.Read = true
+	return nil
+}
+
+func (isf *InboxStoreFake) MarkNotificationsRead(u types.UserID) error {
+	for _, n := range isf.Items {
+		if n.User == u {
+			n.Read = true
+		}
+	}
+	return nil
+}
+
+func (isf *InboxStoreFake) find(
+	u types.UserID,
+	p types.PostID,
+	c types.CommentID,
+) *types.Notification {
+	for _, n := range isf.Items {
+		if n.User == u && n.Post == p && n.Comment == c {
+			return n
+		}
+	}
+	return nil
+}
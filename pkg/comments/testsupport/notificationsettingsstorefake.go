@@ -0,0 +1,22 @@
+package testsupport
+
+import "github.com/weberc2/comments/pkg/comments/types"
+
+type NotificationSettingsStoreFake map[types.UserID]*types.NotificationSettings
+
+func (nssf NotificationSettingsStoreFake) NotificationSettings(
+	user types.UserID,
+) (*types.NotificationSettings, error) {
+	settings, found := nssf[user]
+	if !found {
+		return nil, types.ErrNotificationSettingsNotFound
+	}
+	return settings, nil
+}
+
+func (nssf NotificationSettingsStoreFake) PutNotificationSettings(
+	settings *types.NotificationSettings,
+) error {
+	nssf[settings.User] = settings
+	return nil
+}
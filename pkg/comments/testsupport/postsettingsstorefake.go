@@ -0,0 +1,24 @@
+package testsupport
+
+import (
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+type PostSettingsStoreFake map[types.PostID]*types.PostSettings
+
+func (pssf PostSettingsStoreFake) PostSettings(
+	post types.PostID,
+) (*types.PostSettings, error) {
+	settings, found := pssf[post]
+	if !found {
+		return nil, types.ErrPostSettingsNotFound
+	}
+	return settings, nil
+}
+
+func (pssf PostSettingsStoreFake) PutPostSettings(
+	settings *types.PostSettings,
+) error {
+	pssf[settings.Post] = settings
+	return nil
+}
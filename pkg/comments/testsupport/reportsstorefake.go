@@ -0,0 +1,64 @@
+package testsupport
+
+import (
+	"sort"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// ReportsStoreFake stores reports in the order they're put.
+type ReportsStoreFake struct {
+	Reports []*types.Report
+}
+
+func (rsf *ReportsStoreFake) PutReport(r *types.Report) error {
+	for _, report := range rsf.Reports {
+		if report.Post == r.Post &&
+			report.Comment == r.Comment &&
+			report.Reporter == r.Reporter {
+			return types.ErrReportExists
+		}
+	}
+	cp := *r
+	rsf.Reports = append(rsf.Reports, &cp)
+	return nil
+}
+
+func (rsf *ReportsStoreFake) OpenReports() ([]*types.Report, error) {
+	reports := []*types.Report{}
+	for _, r := range rsf.Reports {
+		if !r.Resolved {
+			cp := *r
+			reports = append(reports, &cp)
+		}
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Created.Before(reports[j].Created)
+	})
+	return reports, nil
+}
+
+func (rsf *ReportsStoreFake) CountOpenReports(
+	post types.PostID,
+	comment types.CommentID,
+) (int, error) {
+	n := 0
+	for _, r := range rsf.Reports {
+		if r.Post == post && r.Comment == comment && !r.Resolved {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (rsf *ReportsStoreFake) ResolveReports(
+	post types.PostID,
+	comment types.CommentID,
+) error {
+	for _, r := range rsf.Reports {
+		if r.Post == post && r.Comment == comment {
+			r.Resolved = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package testsupport
+
+import (
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+type revisionsKey struct {
+	post    types.PostID
+	comment types.CommentID
+}
+
+// RevisionsStoreFake stores revisions in the order they're put.
+type RevisionsStoreFake map[revisionsKey][]*types.Revision
+
+func (rsf RevisionsStoreFake) PutRevision(r *types.Revision) error {
+	key := revisionsKey{r.Post, r.Comment}
+	rsf[key] = append(rsf[key], r)
+	return nil
+}
+
+func (rsf RevisionsStoreFake) Revisions(
+	post types.PostID,
+	comment types.CommentID,
+) ([]*types.Revision, error) {
+	revisions := []*types.Revision{}
+	return append(revisions, rsf[revisionsKey{post, comment}]...), nil
+}
@@ -0,0 +1,22 @@
+package testsupport
+
+import (
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+type RolesStoreFake map[types.UserID]types.Role
+
+func (rsf RolesStoreFake) UserRole(
+	user types.UserID,
+) (*types.UserRole, error) {
+	role, found := rsf[user]
+	if !found {
+		return nil, types.ErrUserRoleNotFound
+	}
+	return &types.UserRole{User: user, Role: role}, nil
+}
+
+func (rsf RolesStoreFake) PutUserRole(userRole *types.UserRole) error {
+	rsf[userRole.User] = userRole.Role
+	return nil
+}
@@ -0,0 +1,90 @@
+package testsupport
+
+import (
+	"sort"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+type sanctionsKey struct {
+	user types.UserID
+	kind types.SanctionKind
+}
+
+// SanctionsStoreFake stores sanctions in memory.
+type SanctionsStoreFake map[sanctionsKey]*types.Sanction
+
+// Sanctions creates a `SanctionsStoreFake` holding the provided sanctions.
+func Sanctions(sanctions ...*types.Sanction) SanctionsStoreFake {
+	ssf := SanctionsStoreFake{}
+	for _, s := range sanctions {
+		ssf[sanctionsKey{s.User, s.Kind}] = s
+	}
+	return ssf
+}
+
+func (ssf SanctionsStoreFake) PutSanction(s *types.Sanction) error {
+	cp := *s
+	ssf[sanctionsKey{s.User, s.Kind}] = &cp
+	return nil
+}
+
+func (ssf SanctionsStoreFake) DeleteSanction(
+	user types.UserID,
+	kind types.SanctionKind,
+) error {
+	key := sanctionsKey{user, kind}
+	if _, found := ssf[key]; !found {
+		return types.ErrSanctionNotFound
+	}
+	delete(ssf, key)
+	return nil
+}
+
+func (ssf SanctionsStoreFake) UserSanctions(
+	user types.UserID,
+) ([]*types.Sanction, error) {
+	sanctions, err := ssf.Sanctions()
+	if err != nil {
+		return nil, err
+	}
+	out := []*types.Sanction{}
+	for _, s := range sanctions {
+		if s.User == user {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (ssf SanctionsStoreFake) Sanctions() ([]*types.Sanction, error) {
+	sanctions := make([]*types.Sanction, 0, len(ssf))
+	for _, s := range ssf {
+		cp := *s
+		sanctions = append(sanctions, &cp)
+	}
+	sort.Slice(sanctions, func(i, j int) bool {
+		if sanctions[i].User != sanctions[j].User {
+			return sanctions[i].User < sanctions[j].User
+		}
+		return sanctions[i].Kind < sanctions[j].Kind
+	})
+	return sanctions, nil
+}
+
+func (ssf SanctionsStoreFake) ShadowBanned(
+	now time.Time,
+) ([]types.UserID, error) {
+	sanctions, err := ssf.Sanctions()
+	if err != nil {
+		return nil, err
+	}
+	var users []types.UserID
+	for _, s := range sanctions {
+		if s.Kind == types.SanctionShadowBan && s.Active(now) {
+			users = append(users, s.User)
+		}
+	}
+	return users, nil
+}
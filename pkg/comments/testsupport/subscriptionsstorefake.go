@@ -0,0 +1,102 @@
+package testsupport
+
+import (
+	"sort"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+type subscriptionKey struct {
+	user    types.UserID
+	post    types.PostID
+	comment types.CommentID
+}
+
+// SubscriptionsStoreFake stores subscriptions in memory.
+type SubscriptionsStoreFake map[subscriptionKey]*types.Subscription
+
+// Subscriptions creates a `SubscriptionsStoreFake` holding the provided
+// subscriptions.
+func Subscriptions(
+	subscriptions ...*types.Subscription,
+) SubscriptionsStoreFake {
+	ssf := SubscriptionsStoreFake{}
+	for _, s := range subscriptions {
+		ssf[subscriptionKey{s.User, s.Post, s.Comment}] = s
+	}
+	return ssf
+}
+
+func (ssf SubscriptionsStoreFake) PutSubscription(
+	s *types.Subscription,
+) error {
+	cp := *s
+	ssf[subscriptionKey{s.User, s.Post, s.Comment}] = &cp
+	return nil
+}
+
+func (ssf SubscriptionsStoreFake) DeleteSubscription(
+	u types.UserID,
+	p types.PostID,
+	c types.CommentID,
+) error {
+	key := subscriptionKey{u, p, c}
+	if _, found := ssf[key]; !found {
+		return types.ErrSubscriptionNotFound
+	}
+	delete(ssf, key)
+	return nil
+}
+
+func (ssf SubscriptionsStoreFake) Subscription(
+	u types.UserID,
+	p types.PostID,
+	c types.CommentID,
+) (*types.Subscription, error) {
+	s, found := ssf[subscriptionKey{u, p, c}]
+	if !found {
+		return nil, types.ErrSubscriptionNotFound
+	}
+	cp := *s
+	return &cp, nil
+}
+
+func (ssf SubscriptionsStoreFake) UserSubscriptions(
+	u types.UserID,
+) ([]*types.Subscription, error) {
+	subscriptions := ssf.filter(func(s *types.Subscription) bool {
+		return s.User == u
+	})
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].Created.After(subscriptions[j].Created)
+	})
+	return subscriptions, nil
+}
+
+func (ssf SubscriptionsStoreFake) PostSubscriptions(
+	p types.PostID,
+) ([]*types.Subscription, error) {
+	subscriptions := ssf.filter(func(s *types.Subscription) bool {
+		return s.Post == p
+	})
+	sort.Slice(subscriptions, func(i, j int) bool {
+		if subscriptions[i].User != subscriptions[j].User {
+			return subscriptions[i].User < subscriptions[j].User
+		}
+		return subscriptions[i].Comment < subscriptions[j].Comment
+	})
+	return subscriptions, nil
+}
+
+func (ssf SubscriptionsStoreFake) filter(
+	keep func(*types.Subscription) bool,
+) []*types.Subscription {
+	subscriptions := []*types.Subscription{}
+	for _, s := range ssf {
+		if keep(s) {
+			cp := *s
+			subscriptions = append(subscriptions, &cp)
+		}
+	}
+	return subscriptions
+}
@@ -0,0 +1,58 @@
+package testsupport
+
+import (
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// VotesStoreFake stores votes in memory. Since `CommentsStoreFake` has nowhere
+// to aggregate votes, `VotesStoreFake` keeps the score fields of the comments
+// in `Comments` up to date itself.
+type VotesStoreFake struct {
+	Comments CommentsStoreFake
+	Votes    map[types.PostID]map[types.CommentID]map[types.UserID]int
+}
+
+func (vsf *VotesStoreFake) PutVote(v *types.Vote) error {
+	if vsf.Votes == nil {
+		vsf.Votes = map[types.PostID]map[types.CommentID]map[types.UserID]int{}
+	}
+	if vsf.Votes[v.Post] == nil {
+		vsf.Votes[v.Post] = map[types.CommentID]map[types.UserID]int{}
+	}
+	if vsf.Votes[v.Post][v.Comment] == nil {
+		vsf.Votes[v.Post][v.Comment] = map[types.UserID]int{}
+	}
+	vsf.Votes[v.Post][v.Comment][v.User] = v.Value
+	vsf.score(v.Post, v.Comment)
+	return nil
+}
+
+func (vsf *VotesStoreFake) DeleteVote(
+	post types.PostID,
+	comment types.CommentID,
+	user types.UserID,
+) error {
+	votes := vsf.Votes[post][comment]
+	if _, found := votes[user]; !found {
+		return types.ErrVoteNotFound
+	}
+	delete(votes, user)
+	vsf.score(post, comment)
+	return nil
+}
+
+func (vsf *VotesStoreFake) score(post types.PostID, comment types.CommentID) {
+	c, found := vsf.Comments[post][comment]
+	if !found {
+		return
+	}
+	c.Score, c.Upvotes, c.Downvotes = 0, 0, 0
+	for _, value := range vsf.Votes[post][comment] {
+		c.Score += value
+		if value > 0 {
+			c.Upvotes++
+		} else if value < 0 {
+			c.Downvotes++
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package testsupport
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// WebhooksStoreFake stores webhooks and failed deliveries in memory. It's
+// safe for concurrent use since deliveries fail in the background.
+type WebhooksStoreFake struct {
+	lock     sync.Mutex
+	webhooks map[string]*types.Webhook
+	failed   map[string]*types.WebhookDelivery
+}
+
+// Webhooks creates a `WebhooksStoreFake` holding the provided webhooks.
+func Webhooks(webhooks ...*types.Webhook) *WebhooksStoreFake {
+	wsf := WebhooksStoreFake{
+		webhooks: map[string]*types.Webhook{},
+		failed:   map[string]*types.WebhookDelivery{},
+	}
+	for _, w := range webhooks {
+		wsf.webhooks[w.ID] = w
+	}
+	return &wsf
+}
+
+func (wsf *WebhooksStoreFake) PutWebhook(w *types.Webhook) error {
+	wsf.lock.Lock()
+	defer wsf.lock.Unlock()
+	cp := *w
+	wsf.webhooks[w.ID] = &cp
+	return nil
+}
+
+func (wsf *WebhooksStoreFake) DeleteWebhook(id string) error {
+	wsf.lock.Lock()
+	defer wsf.lock.Unlock()
+	if _, found := wsf.webhooks[id]; !found {
+		return types.ErrWebhookNotFound
+	}
+	delete(wsf.webhooks, id)
+	return nil
+}
+
+func (wsf *WebhooksStoreFake) Webhook(id string) (*types.Webhook, error) {
+	wsf.lock.Lock()
+	defer wsf.lock.Unlock()
+	w, found := wsf.webhooks[id]
+	if !found {
+		return nil, types.ErrWebhookNotFound
+	}
+	cp := *w
+	return &cp, nil
+}
+
+func (wsf *WebhooksStoreFake) Webhooks() ([]*types.Webhook, error) {
+	wsf.lock.Lock()
+	defer wsf.lock.Unlock()
+	webhooks := make([]*types.Webhook, 0, len(wsf.webhooks))
+	for _, w := range wsf.webhooks {
+		cp := *w
+		webhooks = append(webhooks, &cp)
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if webhooks[i].Created.Equal(webhooks[j].Created) {
+			return webhooks[i].ID < webhooks[j].ID
+		}
+		return webhooks[i].Created.Before(webhooks[j].Created)
+	})
+	return webhooks, nil
+}
+
+func (wsf *WebhooksStoreFake) PutFailedDelivery(
+	d *types.WebhookDelivery,
+) error {
+	wsf.lock.Lock()
+	defer wsf.lock.Unlock()
+	cp := *d
+	wsf.failed[d.ID] = &cp
+	return nil
+}
+
+func (wsf *WebhooksStoreFake) DeleteFailedDelivery(id string) error {
+	wsf.lock.Lock()
+	defer wsf.lock.Unlock()
+	if _, found := wsf.failed[id]; !found {
+		return types.ErrDeliveryNotFound
+	}
+	delete(wsf.failed, id)
+	return nil
+}
+
+func (wsf *WebhooksStoreFake) FailedDelivery(
+	id string,
+) (*types.WebhookDelivery, error) {
+	wsf.lock.Lock()
+	defer wsf.lock.Unlock()
+	d, found := wsf.failed[id]
+	if !found {
+		return nil, types.ErrDeliveryNotFound
+	}
+	cp := *d
+	return &cp, nil
+}
+
+func (wsf *WebhooksStoreFake) FailedDeliveries() (
+	[]*types.WebhookDelivery,
+	error,
+) {
+	wsf.lock.Lock()
+	defer wsf.lock.Unlock()
+	deliveries := make([]*types.WebhookDelivery, 0, len(wsf.failed))
+	for _, d := range wsf.failed {
+		cp := *d
+		deliveries = append(deliveries, &cp)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].Failed.Equal(deliveries[j].Failed) {
+			return deliveries[i].ID < deliveries[j].ID
+		}
+		return deliveries[i].Failed.After(deliveries[j].Failed)
+	})
+	return deliveries, nil
+}
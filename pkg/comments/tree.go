@@ -0,0 +1,46 @@
+package comments
+
+import "github.com/weberc2/comments/pkg/comments/types"
+
+// Node is a comment along with its replies. Each comment's `ReplyCount` and
+// `HiddenReplies` fields report its number of direct replies and the number of
+// descendants which were cut off by a depth-limited query.
+type Node struct {
+	*types.Comment
+	Children []*Node `json:"children"`
+}
+
+// Tree arranges comments into trees: it returns the comments whose `Parent`
+// is `root` (`""` for toplevel comments), each with its replies nested under
+// it. The order of the comments is preserved among siblings. Comments whose
+// parents are neither `root` nor among the comments are left out.
+func Tree(comments []*types.Comment, root types.CommentID) []*Node {
+	// values is just a buffer so we don't have to allocate O(n) nodes. The
+	// first value is a "root" node (whose comment is nil) for the comments
+	// whose `Parent` field is `root`.
+	values := make([]Node, len(comments)+1)
+	nodesByID := make(map[types.CommentID]*Node, len(comments)+1)
+	nodesByID[root] = &values[0]
+
+	// insert a node into `nodesByID` for each input comment
+	for i, c := range comments {
+		n := &values[i+1]
+		n.Comment = c
+		n.Children = []*Node{}
+		nodesByID[c.ID] = n
+	}
+
+	// now that there is a node for each comment in `nodesByID`, append each
+	// comment's node to its parent's node's children.
+	for _, c := range comments {
+		if parent, found := nodesByID[c.Parent]; found {
+			parent.Children = append(parent.Children, nodesByID[c.ID])
+		}
+	}
+
+	// return the root node's children
+	if values[0].Children == nil {
+		return []*Node{}
+	}
+	return values[0].Children
+}
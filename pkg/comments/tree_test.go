@@ -0,0 +1,116 @@
+package comments
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestTree(t *testing.T) {
+	comment := func(id, parent types.CommentID) *types.Comment {
+		return &types.Comment{ID: id, Post: "post", Parent: parent}
+	}
+
+	for _, testCase := range []struct {
+		name     string
+		comments []*types.Comment
+		root     types.CommentID
+		wanted   string
+	}{
+		{
+			name:   "empty",
+			wanted: "[]",
+		},
+		{
+			name: "nested",
+			comments: []*types.Comment{
+				comment("a", ""),
+				comment("a1", "a"),
+				comment("a1a", "a1"),
+				comment("b", ""),
+			},
+			wanted: "[a[a1[a1a[]]] b[]]",
+		},
+		{
+			name: "sibling order is preserved",
+			comments: []*types.Comment{
+				comment("b", ""),
+				comment("a", ""),
+				comment("b2", "b"),
+				comment("b1", "b"),
+			},
+			wanted: "[b[b2[] b1[]] a[]]",
+		},
+		{
+			name: "subtree",
+			comments: []*types.Comment{
+				comment("a1", "a"),
+				comment("a1a", "a1"),
+				comment("a2", "a"),
+			},
+			root:   "a",
+			wanted: "[a1[a1a[]] a2[]]",
+		},
+		{
+			name: "orphans are omitted",
+			comments: []*types.Comment{
+				comment("a", ""),
+				comment("x1", "x"),
+				comment("x1a", "x1"),
+			},
+			wanted: "[a[]]",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			found := formatNodes(Tree(testCase.comments, testCase.root))
+			if found != testCase.wanted {
+				t.Fatalf("wanted `%s`; found `%s`", testCase.wanted, found)
+			}
+		})
+	}
+}
+
+func TestTree_JSON(t *testing.T) {
+	data, err := json.Marshal(Tree(
+		[]*types.Comment{
+			{ID: "a", Post: "post", ReplyCount: 1},
+			{ID: "a1", Post: "post", Parent: "a"},
+		},
+		"",
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var found []struct {
+		ID         types.CommentID `json:"id"`
+		ReplyCount int             `json:"replyCount"`
+		Children   []struct {
+			ID       types.CommentID   `json:"id"`
+			Children []json.RawMessage `json:"children"`
+		} `json:"children"`
+	}
+	if err := json.Unmarshal(data, &found); err != nil {
+		t.Fatalf("unmarshaling `%s`: %v", data, err)
+	}
+	if len(found) != 1 || found[0].ID != "a" || found[0].ReplyCount != 1 ||
+		len(found[0].Children) != 1 || found[0].Children[0].ID != "a1" ||
+		found[0].Children[0].Children == nil {
+		t.Fatalf("unexpected JSON: %s", data)
+	}
+}
+
+// formatNodes renders nodes as `[id[children...] ...]` for easy comparison.
+func formatNodes(nodes []*Node) string {
+	if nodes == nil {
+		return "nil"
+	}
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = fmt.Sprintf("%s%s", n.ID, formatNodes(n.Children))
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
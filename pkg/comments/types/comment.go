@@ -21,6 +21,35 @@ type Comment struct {
 	Modified time.Time `json:"modified"`
 	Deleted  bool      `json:"deleted"`
 	Body     string    `json:"body"`
+	Status   Status    `json:"status"`
+
+	// Removed is set when a comment was deleted by a moderator rather than by
+	// its author.
+	Removed bool `json:"removed"`
+
+	// SpamVerdict and SpamReason record the outcome of the spam checks which
+	// ran when the comment was created. They're only shown to moderators.
+	SpamVerdict Verdict `json:"spamVerdict,omitempty"`
+	SpamReason  string  `json:"spamReason,omitempty"`
+
+	// The following fields aren't stored; they're computed by queries (e.g.,
+	// `CommentsStore.RepliesPage()`) and they're ignored by `Compare()`.
+
+	// HTML is `Body` rendered from Markdown to sanitized HTML. It's empty
+	// for deleted comments.
+	HTML string `json:"html"`
+
+	// HiddenReplies is the number of descendants which were cut off by a
+	// depth-limited query.
+	HiddenReplies int `json:"hiddenReplies,omitempty"`
+
+	// ReplyCount is the number of direct replies to the comment.
+	ReplyCount int `json:"replyCount"`
+
+	// Score is the sum of the comment's votes, i.e., `Upvotes - Downvotes`.
+	Score     int `json:"score"`
+	Upvotes   int `json:"upvotes"`
+	Downvotes int `json:"downvotes"`
 }
 
 type Error string
@@ -198,9 +227,47 @@ func (wanted *Comment) Compare(found *Comment) error {
 		}
 	}
 
+	if wanted.Status != found.Status {
+		return &FieldMismatchErr{
+			Field:  FieldStatus,
+			Wanted: wanted.Status,
+			Found:  found.Status,
+		}
+	}
+
+	if wanted.Removed != found.Removed {
+		return &FieldMismatchErr{
+			Field:  FieldRemoved,
+			Wanted: wanted.Removed,
+			Found:  found.Removed,
+		}
+	}
+
+	if wanted.SpamVerdict != found.SpamVerdict {
+		return &FieldMismatchErr{
+			Field:  FieldSpamVerdict,
+			Wanted: wanted.SpamVerdict,
+			Found:  found.SpamVerdict,
+		}
+	}
+
+	if wanted.SpamReason != found.SpamReason {
+		return &FieldMismatchErr{
+			Field:  FieldSpamReason,
+			Wanted: wanted.SpamReason,
+			Found:  found.SpamReason,
+		}
+	}
+
 	return nil
 }
 
+// Edited reports whether the comment's body has been edited since the comment
+// was created.
+func (c *Comment) Edited() bool {
+	return !c.Deleted && c.Modified.After(c.Created)
+}
+
 func (wanted *Comment) CompareData(data []byte) error {
 	var other Comment
 	if err := json.Unmarshal(data, &other); err != nil {
@@ -18,6 +18,10 @@ const (
 	FieldModified
 	FieldDeleted
 	FieldBody
+	FieldStatus
+	FieldRemoved
+	FieldSpamVerdict
+	FieldSpamReason
 )
 
 var Fields = []Field{
@@ -29,6 +33,10 @@ var Fields = []Field{
 	FieldModified,
 	FieldDeleted,
 	FieldBody,
+	FieldStatus,
+	FieldRemoved,
+	FieldSpamVerdict,
+	FieldSpamReason,
 }
 
 type FieldMask int
@@ -59,6 +67,14 @@ func FieldFromName(name string) (Field, bool) {
 		return FieldDeleted, true
 	case "body":
 		return FieldBody, true
+	case "status":
+		return FieldStatus, true
+	case "removed":
+		return FieldRemoved, true
+	case "spamVerdict":
+		return FieldSpamVerdict, true
+	case "spamReason":
+		return FieldSpamReason, true
 	default:
 		return 0, false
 	}
@@ -82,6 +98,14 @@ func (field Field) String() string {
 		return "deleted"
 	case FieldBody:
 		return "body"
+	case FieldStatus:
+		return "status"
+	case FieldRemoved:
+		return "removed"
+	case FieldSpamVerdict:
+		return "spamVerdict"
+	case FieldSpamReason:
+		return "spamReason"
 	default:
 		panic(fmt.Sprintf("invalid field: %d", field))
 	}
@@ -105,6 +129,14 @@ func (field Field) GoString() string {
 		return "Deleted"
 	case FieldBody:
 		return "Body"
+	case FieldStatus:
+		return "Status"
+	case FieldRemoved:
+		return "Removed"
+	case FieldSpamVerdict:
+		return "SpamVerdict"
+	case FieldSpamReason:
+		return "SpamReason"
 	default:
 		panic(fmt.Sprintf("invalid field: %d", field))
 	}
@@ -119,14 +151,18 @@ func NewCommentPatch(id CommentID, post PostID) *CommentPatch {
 	return (&CommentPatch{}).SetID(id).SetPost(post)
 }
 
-func (cp *CommentPatch) ID() CommentID       { return cp.comment.ID }
-func (cp *CommentPatch) Post() PostID        { return cp.comment.Post }
-func (cp *CommentPatch) Parent() CommentID   { return cp.comment.Parent }
-func (cp *CommentPatch) Author() UserID      { return cp.comment.Author }
-func (cp *CommentPatch) Created() time.Time  { return cp.comment.Created }
-func (cp *CommentPatch) Modified() time.Time { return cp.comment.Modified }
-func (cp *CommentPatch) Deleted() bool       { return cp.comment.Deleted }
-func (cp *CommentPatch) Body() string        { return cp.comment.Body }
+func (cp *CommentPatch) ID() CommentID        { return cp.comment.ID }
+func (cp *CommentPatch) Post() PostID         { return cp.comment.Post }
+func (cp *CommentPatch) Parent() CommentID    { return cp.comment.Parent }
+func (cp *CommentPatch) Author() UserID       { return cp.comment.Author }
+func (cp *CommentPatch) Created() time.Time   { return cp.comment.Created }
+func (cp *CommentPatch) Modified() time.Time  { return cp.comment.Modified }
+func (cp *CommentPatch) Deleted() bool        { return cp.comment.Deleted }
+func (cp *CommentPatch) Body() string         { return cp.comment.Body }
+func (cp *CommentPatch) Status() Status       { return cp.comment.Status }
+func (cp *CommentPatch) Removed() bool        { return cp.comment.Removed }
+func (cp *CommentPatch) SpamVerdict() Verdict { return cp.comment.SpamVerdict }
+func (cp *CommentPatch) SpamReason() string   { return cp.comment.SpamReason }
 
 func (cp *CommentPatch) SetID(id CommentID) *CommentPatch {
 	cp.comment.ID = id
@@ -176,6 +212,30 @@ func (cp *CommentPatch) SetBody(body string) *CommentPatch {
 	return cp
 }
 
+func (cp *CommentPatch) SetStatus(status Status) *CommentPatch {
+	cp.comment.Status = status
+	cp.fields.Push(FieldStatus)
+	return cp
+}
+
+func (cp *CommentPatch) SetRemoved(removed bool) *CommentPatch {
+	cp.comment.Removed = removed
+	cp.fields.Push(FieldRemoved)
+	return cp
+}
+
+func (cp *CommentPatch) SetSpamVerdict(verdict Verdict) *CommentPatch {
+	cp.comment.SpamVerdict = verdict
+	cp.fields.Push(FieldSpamVerdict)
+	return cp
+}
+
+func (cp *CommentPatch) SetSpamReason(reason string) *CommentPatch {
+	cp.comment.SpamReason = reason
+	cp.fields.Push(FieldSpamReason)
+	return cp
+}
+
 func (cp *CommentPatch) IsSet(field Field) bool {
 	return cp.fields.Contains(field)
 }
@@ -219,6 +279,14 @@ func (c *Comment) marshalField(field Field) ([]byte, error) {
 		return json.Marshal(&c.Deleted)
 	case FieldBody:
 		return json.Marshal(&c.Body)
+	case FieldStatus:
+		return json.Marshal(&c.Status)
+	case FieldRemoved:
+		return json.Marshal(&c.Removed)
+	case FieldSpamVerdict:
+		return json.Marshal(&c.SpamVerdict)
+	case FieldSpamReason:
+		return json.Marshal(&c.SpamReason)
 	default:
 		panic(fmt.Sprintf("invalid field: %d", field))
 	}
@@ -249,6 +317,14 @@ func (c *Comment) unmarshalField(field Field, data []byte) error {
 		return json.Unmarshal(data, &c.Modified)
 	case FieldDeleted:
 		return json.Unmarshal(data, &c.Deleted)
+	case FieldStatus:
+		return json.Unmarshal(data, &c.Status)
+	case FieldRemoved:
+		return json.Unmarshal(data, &c.Removed)
+	case FieldSpamVerdict:
+		return json.Unmarshal(data, &c.SpamVerdict)
+	case FieldSpamReason:
+		return json.Unmarshal(data, &c.SpamReason)
 	default:
 		panic(fmt.Sprintf("invalid field: %d", field))
 	}
@@ -310,4 +386,16 @@ func (cp *CommentPatch) Apply(c *Comment) {
 	if cp.IsSet(FieldBody) {
 		c.Body = cp.Body()
 	}
+	if cp.IsSet(FieldStatus) {
+		c.Status = cp.Status()
+	}
+	if cp.IsSet(FieldRemoved) {
+		c.Removed = cp.Removed()
+	}
+	if cp.IsSet(FieldSpamVerdict) {
+		c.SpamVerdict = cp.SpamVerdict()
+	}
+	if cp.IsSet(FieldSpamReason) {
+		c.SpamReason = cp.SpamReason()
+	}
 }
@@ -21,6 +21,21 @@ type CommentsStore interface {
 	Put(*Comment) error
 	Comment(PostID, CommentID) (*Comment, error)
 	Replies(PostID, CommentID) ([]*Comment, error)
+	RepliesPage(*RepliesQuery) (*RepliesPage, error)
+	CommentsByStatus(Status) ([]*Comment, error)
+
+	// CommentsByAuthor returns up to `limit` of the author's comments on
+	// every post, newest first.
+	CommentsByAuthor(author UserID, limit int) ([]*Comment, error)
+
+	// CommentsByPost returns up to `limit` of the post's comments, newest
+	// first.
+	CommentsByPost(post PostID, limit int) ([]*Comment, error)
+
+	// Ancestors returns the ancestors of the comments (which may be on
+	// different posts), in no particular order. Ancestors which don't exist
+	// are left out.
+	Ancestors(comments ...*Comment) ([]*Comment, error)
 	Delete(PostID, CommentID) error
 	Update(*CommentPatch) error
 }
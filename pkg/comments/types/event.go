@@ -0,0 +1,127 @@
+package types
+
+import "time"
+
+// EventType identifies what happened to a comment.
+type EventType string
+
+const (
+	EventCommentCreated EventType = "comment.created"
+	EventCommentUpdated EventType = "comment.updated"
+	EventCommentDeleted EventType = "comment.deleted"
+)
+
+// EventTypes are all of the event types.
+var EventTypes = []EventType{
+	EventCommentCreated,
+	EventCommentUpdated,
+	EventCommentDeleted,
+}
+
+// Valid reports whether the event type is one of `EventTypes`.
+func (et EventType) Valid() bool {
+	for _, valid := range EventTypes {
+		if et == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Event describes a change to a comment. `Comment` is the comment as of
+// just after the change (deleted comments are redacted).
+type Event struct {
+	// ID identifies the event. IDs increase in the order in which the
+	// changes were committed (for a single relay; see `EventSink`).
+	ID      int64         `json:"id"`
+	Type    EventType     `json:"type"`
+	Time    time.Time     `json:"time"`
+	Comment *EventComment `json:"comment"`
+}
+
+// EventComment is the part of a comment which events carry. Events describe
+// comments as everyone sees them, so moderator-only data (e.g., the comment's
+// status and spam verdict) is left out.
+type EventComment struct {
+	ID       CommentID `json:"id"`
+	Post     PostID    `json:"post"`
+	Parent   CommentID `json:"parent"`
+	Author   UserID    `json:"author"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+	Deleted  bool      `json:"deleted"`
+	Body     string    `json:"body"`
+}
+
+// NewEvent returns the event which describes a change to a comment (see
+// `TransitionEvent()`), or `false` if the change isn't an event. Deleted
+// comments are redacted. The event's `ID` and `Time` are left to the caller.
+func NewEvent(before, after *Comment, shadowBanned bool) (*Event, bool) {
+	t, ok := TransitionEvent(before, after, shadowBanned)
+	if !ok {
+		return nil, false
+	}
+	c := after
+	if c == nil {
+		c = before
+	}
+	e := Event{Type: t, Comment: &EventComment{
+		ID:       c.ID,
+		Post:     c.Post,
+		Parent:   c.Parent,
+		Author:   c.Author,
+		Created:  c.Created,
+		Modified: c.Modified,
+		Deleted:  c.Deleted || after == nil,
+		Body:     c.Body,
+	}}
+	if t == EventCommentDeleted {
+		// redact comments which are no longer visible like `CommentsModel`
+		// redacts deleted comments
+		e.Comment.Author = ""
+		e.Comment.Body = ""
+	}
+	return &e, true
+}
+
+// TransitionEvent returns the type of the event which describes a change to a
+// comment from `before` to `after` (either of which is `nil` if the comment
+// doesn't exist), or `false` if the change isn't an event. Events describe
+// comments as everyone sees them: a comment which becomes visible (e.g., it's
+// created approved or it's approved by a moderator) is created, a comment
+// which stops being visible (e.g., it's deleted, rejected or hidden by
+// reports) is deleted, and changes to comments which are visible neither
+// before nor after (e.g., pending comments) aren't events. Comments whose
+// author is shadow-banned are only visible to the author, so changes to them
+// aren't events either (shadow-banning or unbanning an author doesn't produce
+// events for their existing comments).
+func TransitionEvent(
+	before *Comment,
+	after *Comment,
+	shadowBanned bool,
+) (EventType, bool) {
+	visibleBefore := before != nil && before.Status.Visible() &&
+		!shadowBanned
+	visibleAfter := after != nil && after.Status.Visible() && !shadowBanned
+	switch {
+	case !visibleBefore && !visibleAfter:
+		return "", false
+	case !visibleBefore:
+		return EventCommentCreated, true
+	case !visibleAfter || after.Deleted && !before.Deleted:
+		return EventCommentDeleted, true
+	default:
+		return EventCommentUpdated, true
+	}
+}
+
+// EventSink is where events end up once they've been recorded along with the
+// changes they describe. Delivery is at-least-once: an event may be published
+// more than once (e.g., if the process dies after publishing it but before
+// recording that it was published), so sinks should tolerate duplicates,
+// e.g., by dropping events whose IDs they've already seen.
+type EventSink interface {
+	// Publish publishes an event. If it returns an error, the event is
+	// published again later.
+	Publish(*Event) error
+}
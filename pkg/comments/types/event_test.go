@@ -0,0 +1,189 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransitionEvent(t *testing.T) {
+	approved := &Comment{Status: StatusApproved}
+	legacy := &Comment{}
+	pending := &Comment{Status: StatusPending}
+	rejected := &Comment{Status: StatusRejected}
+	deleted := &Comment{Status: StatusApproved, Deleted: true}
+	for _, testCase := range []struct {
+		name         string
+		before       *Comment
+		after        *Comment
+		shadowBanned bool
+		wantedType   EventType
+		wantedEvent  bool
+	}{
+		{
+			name:        "created approved",
+			after:       approved,
+			wantedType:  EventCommentCreated,
+			wantedEvent: true,
+		},
+		{
+			name:        "created without status",
+			after:       legacy,
+			wantedType:  EventCommentCreated,
+			wantedEvent: true,
+		},
+		{
+			name:  "created pending",
+			after: pending,
+		},
+		{
+			name:        "approved",
+			before:      pending,
+			after:       approved,
+			wantedType:  EventCommentCreated,
+			wantedEvent: true,
+		},
+		{
+			name:   "rejected while pending",
+			before: pending,
+			after:  rejected,
+		},
+		{
+			name:        "hidden",
+			before:      approved,
+			after:       pending,
+			wantedType:  EventCommentDeleted,
+			wantedEvent: true,
+		},
+		{
+			name:        "rejected",
+			before:      approved,
+			after:       rejected,
+			wantedType:  EventCommentDeleted,
+			wantedEvent: true,
+		},
+		{
+			name:        "edited",
+			before:      approved,
+			after:       approved,
+			wantedType:  EventCommentUpdated,
+			wantedEvent: true,
+		},
+		{
+			name:        "marked deleted",
+			before:      approved,
+			after:       deleted,
+			wantedType:  EventCommentDeleted,
+			wantedEvent: true,
+		},
+		{
+			name:        "removed",
+			before:      approved,
+			wantedType:  EventCommentDeleted,
+			wantedEvent: true,
+		},
+		{
+			name:   "removed while pending",
+			before: pending,
+		},
+		{
+			name:         "created by shadow-banned author",
+			after:        approved,
+			shadowBanned: true,
+		},
+		{
+			name:         "edited by shadow-banned author",
+			before:       approved,
+			after:        approved,
+			shadowBanned: true,
+		},
+		{
+			name:         "removed by shadow-banned author",
+			before:       approved,
+			shadowBanned: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			found, event := TransitionEvent(
+				testCase.before,
+				testCase.after,
+				testCase.shadowBanned,
+			)
+			if found != testCase.wantedType || event != testCase.wantedEvent {
+				t.Fatalf(
+					"wanted `%s` (%t); found `%s` (%t)",
+					testCase.wantedType,
+					testCase.wantedEvent,
+					found,
+					event,
+				)
+			}
+		})
+	}
+}
+
+func TestNewEvent(t *testing.T) {
+	c := Comment{
+		ID:          "id",
+		Post:        "post",
+		Parent:      "parent",
+		Author:      "author",
+		Body:        "body",
+		Status:      StatusApproved,
+		SpamVerdict: VerdictHold,
+		SpamReason:  "reason",
+	}
+	rejected := c
+	rejected.Status = StatusRejected
+	for _, testCase := range []struct {
+		name   string
+		before *Comment
+		after  *Comment
+		wanted *EventComment
+	}{
+		{
+			name:  "created",
+			after: &c,
+			wanted: &EventComment{
+				ID:     "id",
+				Post:   "post",
+				Parent: "parent",
+				Author: "author",
+				Body:   "body",
+			},
+		},
+		{
+			name:   "rejected",
+			before: &c,
+			after:  &rejected,
+			wanted: &EventComment{
+				ID:     "id",
+				Post:   "post",
+				Parent: "parent",
+			},
+		},
+		{
+			name:   "removed",
+			before: &c,
+			wanted: &EventComment{
+				ID:      "id",
+				Post:    "post",
+				Parent:  "parent",
+				Deleted: true,
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			e, ok := NewEvent(testCase.before, testCase.after, false)
+			if !ok {
+				t.Fatal("wanted event; found none")
+			}
+			if !reflect.DeepEqual(e.Comment, testCase.wanted) {
+				t.Fatalf(
+					"wanted `%+v`; found `%+v`",
+					testCase.wanted,
+					e.Comment,
+				)
+			}
+		})
+	}
+}
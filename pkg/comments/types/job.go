@@ -0,0 +1,43 @@
+package types
+
+import "time"
+
+// Job is a unit of work which is attempted in the background until it
+// succeeds or runs out of attempts (see `jobs.Queue`).
+type Job struct {
+	// Kind identifies the queue which attempts the job.
+	Kind string
+
+	// ID identifies the job among its kind. Queueing a job with the ID of a
+	// job which is already queued does nothing, so IDs which are derived from
+	// the work make queueing idempotent.
+	ID string
+
+	// Payload is the job's data. Its format is up to the job's queue.
+	Payload []byte
+
+	// Attempts is the number of failed attempts at the job so far.
+	Attempts int
+
+	// Due is when the job should next be attempted.
+	Due time.Time
+}
+
+// JobsStore stores queued jobs.
+type JobsStore interface {
+	// PutJob queues a job. If a job of the same kind with the same ID is
+	// already queued, nothing is changed.
+	PutJob(*Job) error
+
+	// ClaimJobs returns up to `limit` of the kind's jobs which are due at
+	// `now`, soonest due first, and postpones them until `until` so that
+	// other workers don't claim them while they're being attempted.
+	ClaimJobs(kind string, now, until time.Time, limit int) ([]*Job, error)
+
+	// UpdateJob updates a queued job's `Attempts` and `Due` fields.
+	UpdateJob(*Job) error
+
+	// DeleteJob removes a job from the queue. It's not an error if the job
+	// isn't queued.
+	DeleteJob(kind, id string) error
+}
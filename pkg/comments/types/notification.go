@@ -0,0 +1,65 @@
+package types
+
+import (
+	"net/http"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+var (
+	ErrNotificationSettingsNotFound = &pz.HTTPError{
+		Status:  http.StatusNotFound,
+		Message: "notification settings not found",
+	}
+	ErrAddressNotFound = &pz.HTTPError{
+		Status:  http.StatusNotFound,
+		Message: "email address not found",
+	}
+)
+
+// NotificationSettings are a user's notification preferences.
+type NotificationSettings struct {
+	User UserID `json:"user"`
+
+	// EmailReplies emails the user when someone replies to one of their
+	// comments.
+	EmailReplies bool `json:"emailReplies"`
+}
+
+// DefaultNotificationSettings returns the settings of users who haven't
+// changed them.
+func DefaultNotificationSettings(user UserID) *NotificationSettings {
+	return &NotificationSettings{User: user, EmailReplies: true}
+}
+
+// NotificationSettingsStore stores per-user notification settings.
+type NotificationSettingsStore interface {
+	// NotificationSettings returns the user's settings. If the user has no
+	// settings, `ErrNotificationSettingsNotFound` is returned.
+	NotificationSettings(UserID) (*NotificationSettings, error)
+
+	// PutNotificationSettings creates or replaces the user's settings.
+	PutNotificationSettings(*NotificationSettings) error
+}
+
+// Email is a plain text email message.
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+
+	// Headers are extra message headers, e.g., `List-Unsubscribe`.
+	Headers map[string]string
+}
+
+// EmailSender sends emails.
+type EmailSender interface {
+	SendEmail(*Email) error
+}
+
+// AddressBook looks up users' email addresses.
+type AddressBook interface {
+	// EmailAddress returns the user's email address. If the user has no
+	// address, `ErrAddressNotFound` is returned.
+	EmailAddress(UserID) (string, error)
+}
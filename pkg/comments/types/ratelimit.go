@@ -0,0 +1,128 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+var ErrInvalidRateLimit = &pz.HTTPError{
+	Status:  http.StatusBadRequest,
+	Message: "invalid rate limit",
+}
+
+// Action identifies a kind of rate-limited request.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionEdit   Action = "edit"
+	ActionDelete Action = "delete"
+)
+
+// Limit permits `Count` requests per `Per`. It's enforced by a token bucket
+// which holds up to `Count` tokens and refills at a rate of `Count` tokens
+// per `Per`, so bursts of up to `Count` requests are allowed. A zero `Count`
+// means there's no limit.
+type Limit struct {
+	Count int
+	Per   time.Duration
+}
+
+// ParseLimit parses a limit in the form `<count>/<duration>` (e.g., `5/1m`).
+func ParseLimit(s string) (Limit, error) {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return Limit{}, fmt.Errorf("%w: `%s`", ErrInvalidRateLimit, s)
+	}
+	count, err := strconv.Atoi(s[:i])
+	if err != nil || count < 0 {
+		return Limit{}, fmt.Errorf("%w: `%s`", ErrInvalidRateLimit, s)
+	}
+	per, err := time.ParseDuration(s[i+1:])
+	if err != nil || per <= 0 {
+		return Limit{}, fmt.Errorf("%w: `%s`", ErrInvalidRateLimit, s)
+	}
+	return Limit{Count: count, Per: per}, nil
+}
+
+func (l Limit) String() string { return fmt.Sprintf("%d/%s", l.Count, l.Per) }
+
+// Unlimited reports whether the limit permits every request.
+func (l Limit) Unlimited() bool { return l.Count == 0 }
+
+// Bucket is the state of a token bucket as of `Updated`.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Full returns a full bucket for the limit.
+func (l Limit) Full(now time.Time) Bucket {
+	return Bucket{Tokens: float64(l.Count), Updated: now}
+}
+
+// Take refills the bucket for the time elapsed since it was last updated and
+// takes a token from it. If the bucket holds less than one token, no token is
+// taken and `Take` also returns how long until a token will be available.
+func (l Limit) Take(b Bucket, now time.Time) (Bucket, time.Duration) {
+	buckets, retryAfter := l.TakeAll([]Bucket{b}, now)
+	return buckets[0], retryAfter
+}
+
+// TakeAll refills the buckets like `Take()` and takes a token from each of
+// them, but only if every bucket holds at least one token. Otherwise no token
+// is taken from any bucket and `TakeAll` also returns how long until every
+// bucket will hold a token.
+func (l Limit) TakeAll(
+	buckets []Bucket,
+	now time.Time,
+) ([]Bucket, time.Duration) {
+	out := make([]Bucket, len(buckets))
+	if l.Unlimited() {
+		for i := range out {
+			out[i] = l.Full(now)
+		}
+		return out, 0
+	}
+	var retryAfter time.Duration
+	for i, b := range buckets {
+		// the bucket's time never moves backwards in case the clocks of the
+		// processes sharing the bucket disagree.
+		if elapsed := now.Sub(b.Updated); elapsed > 0 {
+			b.Tokens += elapsed.Seconds() * float64(l.Count) / l.Per.Seconds()
+			b.Updated = now
+		}
+		b.Tokens = math.Min(b.Tokens, float64(l.Count))
+		if b.Tokens < 1 {
+			wait := time.Duration(
+				math.Ceil((1 - b.Tokens) * float64(l.Per) / float64(l.Count)),
+			)
+			if wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+		out[i] = b
+	}
+	if retryAfter == 0 {
+		for i := range out {
+			out[i].Tokens--
+		}
+	}
+	return out, retryAfter
+}
+
+// RateLimitStore stores token buckets.
+type RateLimitStore interface {
+	// Take atomically takes a token from each of the buckets identified by
+	// `keys`, creating full buckets for the keys which don't have one. If any
+	// of the buckets is empty, no tokens are taken and `Take` returns how
+	// long until every bucket will hold a token (see `Limit.TakeAll()`);
+	// otherwise it returns zero.
+	Take(keys []string, limit Limit, now time.Time) (time.Duration, error)
+}
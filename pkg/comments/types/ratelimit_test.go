@@ -0,0 +1,179 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	for _, testCase := range []struct {
+		input       string
+		wanted      Limit
+		wantedError bool
+	}{
+		{input: "5/1m", wanted: Limit{Count: 5, Per: time.Minute}},
+		{input: "0/1s", wanted: Limit{Count: 0, Per: time.Second}},
+		{input: "5", wantedError: true},
+		{input: "five/1m", wantedError: true},
+		{input: "-1/1m", wantedError: true},
+		{input: "5/minute", wantedError: true},
+		{input: "5/0s", wantedError: true},
+	} {
+		t.Run(testCase.input, func(t *testing.T) {
+			found, err := ParseLimit(testCase.input)
+			if testCase.wantedError {
+				if err == nil {
+					t.Fatalf("wanted error; found `%v`", found)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if found != testCase.wanted {
+				t.Fatalf("wanted `%v`; found `%v`", testCase.wanted, found)
+			}
+		})
+	}
+}
+
+func TestLimit_Take(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := Limit{Count: 3, Per: time.Minute}
+	for _, testCase := range []struct {
+		name             string
+		limit            Limit
+		bucket           Bucket
+		now              time.Time
+		wantedTokens     float64
+		wantedRetryAfter time.Duration
+	}{
+		{
+			name:         "full bucket",
+			limit:        limit,
+			bucket:       limit.Full(now),
+			now:          now,
+			wantedTokens: 2,
+		},
+		{
+			name:             "empty bucket",
+			limit:            limit,
+			bucket:           Bucket{Tokens: 0, Updated: now},
+			now:              now,
+			wantedRetryAfter: 20 * time.Second,
+		},
+		{
+			name:             "partially refilled bucket",
+			limit:            limit,
+			bucket:           Bucket{Tokens: 0, Updated: now},
+			now:              now.Add(15 * time.Second),
+			wantedTokens:     0.75,
+			wantedRetryAfter: 5 * time.Second,
+		},
+		{
+			name:         "refilled bucket",
+			limit:        limit,
+			bucket:       Bucket{Tokens: 0, Updated: now},
+			now:          now.Add(20 * time.Second),
+			wantedTokens: 0,
+		},
+		{
+			name:         "refills are capped",
+			limit:        limit,
+			bucket:       Bucket{Tokens: 0, Updated: now},
+			now:          now.Add(time.Hour),
+			wantedTokens: 2,
+		},
+		{
+			name:         "clock skew",
+			limit:        limit,
+			bucket:       Bucket{Tokens: 1, Updated: now},
+			now:          now.Add(-time.Minute),
+			wantedTokens: 0,
+		},
+		{
+			name:         "unlimited",
+			limit:        Limit{},
+			bucket:       Bucket{Tokens: 0, Updated: now},
+			now:          now,
+			wantedTokens: 0,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			bucket, retryAfter := testCase.limit.Take(
+				testCase.bucket,
+				testCase.now,
+			)
+			if bucket.Tokens != testCase.wantedTokens {
+				t.Fatalf(
+					"Bucket.Tokens: wanted `%f`; found `%f`",
+					testCase.wantedTokens,
+					bucket.Tokens,
+				)
+			}
+			if retryAfter != testCase.wantedRetryAfter {
+				t.Fatalf(
+					"retry after: wanted `%s`; found `%s`",
+					testCase.wantedRetryAfter,
+					retryAfter,
+				)
+			}
+		})
+	}
+}
+
+func TestLimit_TakeAll(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := Limit{Count: 3, Per: time.Minute}
+	for _, testCase := range []struct {
+		name             string
+		buckets          []Bucket
+		wantedTokens     []float64
+		wantedRetryAfter time.Duration
+	}{
+		{
+			name:         "full buckets",
+			buckets:      []Bucket{limit.Full(now), {Tokens: 1, Updated: now}},
+			wantedTokens: []float64{2, 0},
+		},
+		{
+			name: "one empty bucket",
+			buckets: []Bucket{
+				limit.Full(now),
+				{Tokens: 0.5, Updated: now},
+			},
+			wantedTokens:     []float64{3, 0.5},
+			wantedRetryAfter: 10 * time.Second,
+		},
+		{
+			name: "longest wait",
+			buckets: []Bucket{
+				{Tokens: 0.5, Updated: now},
+				{Tokens: 0, Updated: now},
+			},
+			wantedTokens:     []float64{0.5, 0},
+			wantedRetryAfter: 20 * time.Second,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			buckets, retryAfter := limit.TakeAll(testCase.buckets, now)
+			for i, b := range buckets {
+				if b.Tokens != testCase.wantedTokens[i] {
+					t.Fatalf(
+						"buckets[%d].Tokens: wanted `%f`; found `%f`",
+						i,
+						testCase.wantedTokens[i],
+						b.Tokens,
+					)
+				}
+			}
+			if retryAfter != testCase.wantedRetryAfter {
+				t.Fatalf(
+					"retry after: wanted `%s`; found `%s`",
+					testCase.wantedRetryAfter,
+					retryAfter,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package types
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+var ErrInvalidCursor = &pz.HTTPError{
+	Status:  http.StatusBadRequest,
+	Message: "invalid cursor",
+}
+
+// RepliesQuery describes a page of replies. Pages are made up of the direct
+// children of `Parent` (the page's "threads") along with their descendants.
+// Siblings at every level are ordered according to `Sort`.
+// `Limit` bounds the number of threads in the page and `Cursor` (if non-nil)
+// identifies the last thread of the previous page. If `MaxDepth` is non-zero,
+// only descendants up to that depth are returned (the threads themselves are
+// at depth 1) and the comments at `MaxDepth` report the number of descendants
+// that were left out via their `HiddenReplies` field. Comments which aren't
+// approved or whose authors are in `ShadowBanned` are left out (along with
+// their descendants) unless `Viewer` is their author or `AllStatuses` is set.
+type RepliesQuery struct {
+	Post         PostID
+	Parent       CommentID
+	Limit        int
+	Cursor       *Cursor
+	MaxDepth     int
+	Sort         Sort
+	Viewer       UserID
+	AllStatuses  bool
+	ShadowBanned []UserID
+}
+
+// Visible reports whether the query includes the comment `c` (irrespective of
+// its position in the tree).
+func (q *RepliesQuery) Visible(c *Comment) bool {
+	if q.AllStatuses || (q.Viewer != "" && c.Author == q.Viewer) {
+		return true
+	}
+	if !c.Status.Visible() {
+		return false
+	}
+	for _, user := range q.ShadowBanned {
+		if c.Author == user {
+			return false
+		}
+	}
+	return true
+}
+
+// RepliesPage is a page of replies. `Next` is nil if there are no more pages.
+type RepliesPage struct {
+	Comments []*Comment `json:"comments"`
+	Next     *Cursor    `json:"next,omitempty"`
+}
+
+// Cursor identifies a position in a list of sibling comments ordered by
+// `Sort`. It's opaque to clients: it marshals to and from a URL-safe string.
+type Cursor struct {
+	Sort    Sort
+	Key     int
+	Created time.Time
+	ID      CommentID
+}
+
+// cursorData is the serialized form of a `Cursor`. It's a distinct type so
+// that marshaling it doesn't recurse into `Cursor.MarshalText()`.
+type cursorData struct {
+	Sort    Sort      `json:"sort,omitempty"`
+	Key     int       `json:"key,omitempty"`
+	Created time.Time `json:"created"`
+	ID      CommentID `json:"id"`
+}
+
+// CursorFor returns a cursor which points at the provided comment in a list
+// ordered by `sort`.
+func CursorFor(sort Sort, c *Comment) *Cursor {
+	return &Cursor{Sort: sort, Key: sort.Key(c), Created: c.Created, ID: c.ID}
+}
+
+// Before reports whether the cursor position sorts strictly before the
+// comment `c`, i.e., whether `c` belongs on a page after the cursor.
+func (cursor *Cursor) Before(c *Comment) bool {
+	return cursor.Sort.less(
+		cursor.Key,
+		cursor.Created,
+		cursor.ID,
+		cursor.Sort.Key(c),
+		c.Created,
+		c.ID,
+	)
+}
+
+func (cursor *Cursor) String() string {
+	data, err := json.Marshal(cursorData(*cursor))
+	if err != nil {
+		// marshaling a time and a string can't fail
+		panic(fmt.Sprintf("marshaling cursor: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (cursor *Cursor) MarshalText() ([]byte, error) {
+	return []byte(cursor.String()), nil
+}
+
+func (cursor *Cursor) UnmarshalText(text []byte) error {
+	tmp, err := ParseCursor(string(text))
+	if err != nil {
+		return err
+	}
+	*cursor = *tmp
+	return nil
+}
+
+// ParseCursor parses a cursor from its string representation. If the input
+// can't be parsed, `ErrInvalidCursor` is returned.
+func ParseCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var tmp cursorData
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if tmp.Sort, err = ParseSort(string(tmp.Sort)); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return (*Cursor)(&tmp), nil
+}
@@ -0,0 +1,68 @@
+package types
+
+import "testing"
+
+func TestCursor_String(t *testing.T) {
+	wanted := &Cursor{
+		Sort:    SortReplies,
+		Key:     3,
+		Created: someTime,
+		ID:      "comment",
+	}
+	found, err := ParseCursor(wanted.String())
+	if err != nil {
+		t.Fatalf("unexpected error parsing cursor: %v", err)
+	}
+	if found.Sort != wanted.Sort ||
+		found.Key != wanted.Key ||
+		!found.Created.Equal(wanted.Created) ||
+		found.ID != wanted.ID {
+		t.Fatalf("wanted `%v`; found `%v`", wanted, found)
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	for _, input := range []string{"", "!!!", "bm90IGpzb24"} {
+		if err := ErrInvalidCursor.CompareErr(
+			func() error { _, err := ParseCursor(input); return err }(),
+		); err != nil {
+			t.Fatalf("input `%s`: %v", input, err)
+		}
+	}
+}
+
+func TestCursor_Before(t *testing.T) {
+	cursor := &Cursor{Sort: SortOldest, Created: someTime, ID: "b"}
+	for _, testCase := range []struct {
+		name    string
+		comment *Comment
+		wanted  bool
+	}{
+		{
+			name:    "later comment",
+			comment: &Comment{Created: someOtherTime, ID: "a"},
+			wanted:  true,
+		},
+		{
+			name:    "earlier comment",
+			comment: &Comment{Created: someTime.Add(-1), ID: "c"},
+			wanted:  false,
+		},
+		{
+			name:    "same time, greater id",
+			comment: &Comment{Created: someTime, ID: "c"},
+			wanted:  true,
+		},
+		{
+			name:    "same comment",
+			comment: &Comment{Created: someTime, ID: "b"},
+			wanted:  false,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if found := cursor.Before(testCase.comment); found != testCase.wanted {
+				t.Fatalf("wanted `%t`; found `%t`", testCase.wanted, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package types
+
+import (
+	"net/http"
+	"time"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+var (
+	ErrInvalidReport = &pz.HTTPError{
+		Status:  http.StatusBadRequest,
+		Message: "invalid report reason",
+	}
+	ErrReportExists = &pz.HTTPError{
+		Status:  http.StatusConflict,
+		Message: "comment already reported",
+	}
+)
+
+// Report is a user's report that a comment is abusive. Each user may report
+// each comment at most once.
+type Report struct {
+	Post     PostID    `json:"post"`
+	Comment  CommentID `json:"comment"`
+	Reporter UserID    `json:"reporter"`
+	Reason   string    `json:"reason"`
+	Created  time.Time `json:"created"`
+
+	// Resolved is set once a moderator has reviewed the reported comment.
+	Resolved bool `json:"resolved"`
+}
+
+// ReportsStore stores reports.
+type ReportsStore interface {
+	// PutReport records a report. If the reporter has already reported the
+	// comment, `ErrReportExists` is returned.
+	PutReport(*Report) error
+
+	// OpenReports returns the reports which haven't been resolved, oldest
+	// first.
+	OpenReports() ([]*Report, error)
+
+	// CountOpenReports returns the number of the comment's reports which
+	// haven't been resolved.
+	CountOpenReports(PostID, CommentID) (int, error)
+
+	// ResolveReports marks all of the comment's reports as resolved.
+	ResolveReports(PostID, CommentID) error
+}
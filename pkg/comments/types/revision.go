@@ -0,0 +1,32 @@
+package types
+
+import "time"
+
+// Revision is a prior version of a comment's body. A revision is recorded
+// each time a comment is edited.
+type Revision struct {
+	Post    PostID    `json:"post"`
+	Comment CommentID `json:"comment"`
+
+	// Body is the comment's body before the edit.
+	Body string `json:"body"`
+
+	// Created is when `Body` was written, i.e., the comment's `Modified` time
+	// before the edit.
+	Created time.Time `json:"created"`
+
+	// Edited is when `Body` was replaced and Editor is the user who replaced
+	// it (the comment's author or a moderator).
+	Edited time.Time `json:"edited"`
+	Editor UserID    `json:"editor"`
+}
+
+// RevisionsStore stores comments' revisions.
+type RevisionsStore interface {
+	// PutRevision records a revision.
+	PutRevision(*Revision) error
+
+	// Revisions returns a comment's revisions, oldest first. Comments which
+	// have never been edited have no revisions.
+	Revisions(PostID, CommentID) ([]*Revision, error)
+}
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"net/http"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+var (
+	ErrInvalidRole = &pz.HTTPError{
+		Status:  http.StatusBadRequest,
+		Message: "invalid role",
+	}
+	ErrUserRoleNotFound = &pz.HTTPError{
+		Status:  http.StatusNotFound,
+		Message: "user role not found",
+	}
+)
+
+// Role determines what a user may do beyond commenting. Users may always edit
+// and delete their own comments; moderators may also moderate, edit, and
+// remove anyone's comments; admins may do everything moderators may do and
+// they may also assign roles.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// ParseRole parses a role, returning `ErrInvalidRole` if the role is unknown.
+func ParseRole(s string) (Role, error) {
+	switch r := Role(s); r {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return r, nil
+	default:
+		return "", ErrInvalidRole
+	}
+}
+
+// CanModerate reports whether the role may moderate other users' comments.
+func (r Role) CanModerate() bool { return r == RoleModerator || r == RoleAdmin }
+
+// CanAssignRoles reports whether the role may change users' roles.
+func (r Role) CanAssignRoles() bool { return r == RoleAdmin }
+
+// UserRole assigns a role to a user.
+type UserRole struct {
+	User UserID `json:"user"`
+	Role Role   `json:"role"`
+}
+
+// RolesStore stores users' roles.
+type RolesStore interface {
+	// UserRole returns the user's role. If the user hasn't been assigned a
+	// role, `ErrUserRoleNotFound` is returned.
+	UserRole(UserID) (*UserRole, error)
+
+	// PutUserRole creates or replaces the user's role.
+	PutUserRole(*UserRole) error
+}
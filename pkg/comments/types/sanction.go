@@ -0,0 +1,96 @@
+package types
+
+import (
+	"net/http"
+	"time"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+var (
+	ErrInvalidSanction = &pz.HTTPError{
+		Status:  http.StatusBadRequest,
+		Message: "invalid sanction",
+	}
+	ErrSanctionNotFound = &pz.HTTPError{
+		Status:  http.StatusNotFound,
+		Message: "sanction not found",
+	}
+	ErrBanned = &pz.HTTPError{
+		Status:  http.StatusForbidden,
+		Message: "user is banned",
+	}
+	ErrMuted = &pz.HTTPError{
+		Status:  http.StatusForbidden,
+		Message: "user is muted",
+	}
+)
+
+// SanctionKind is a kind of restriction placed on an abusive user.
+type SanctionKind string
+
+const (
+	// SanctionBan stops the user from posting or editing comments.
+	SanctionBan SanctionKind = "ban"
+
+	// SanctionMute stops the user from posting or editing comments until the
+	// sanction expires. Mutes must expire.
+	SanctionMute SanctionKind = "mute"
+
+	// SanctionShadowBan hides the user's comments from everyone except the
+	// user and the moderators. The user isn't told.
+	SanctionShadowBan SanctionKind = "shadow-ban"
+)
+
+// ParseSanctionKind parses a sanction kind, returning `ErrInvalidSanction` if
+// the kind is unknown.
+func ParseSanctionKind(s string) (SanctionKind, error) {
+	switch k := SanctionKind(s); k {
+	case SanctionBan, SanctionMute, SanctionShadowBan:
+		return k, nil
+	default:
+		return "", ErrInvalidSanction
+	}
+}
+
+// Sanction restricts what a user may do. Each user has at most one sanction
+// of each kind.
+type Sanction struct {
+	User   UserID       `json:"user"`
+	Kind   SanctionKind `json:"kind"`
+	Reason string       `json:"reason"`
+
+	// Issuer is the admin who imposed the sanction.
+	Issuer  UserID    `json:"issuer"`
+	Created time.Time `json:"created"`
+
+	// Expires is when the sanction is lifted automatically. Sanctions which
+	// don't expire (i.e., `Expires` is nil) last until they're deleted.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Active reports whether the sanction is in effect at `now`.
+func (s *Sanction) Active(now time.Time) bool {
+	return s.Expires == nil || s.Expires.After(now)
+}
+
+// SanctionsStore stores users' sanctions.
+type SanctionsStore interface {
+	// PutSanction creates or replaces the user's sanction of the same kind.
+	PutSanction(*Sanction) error
+
+	// DeleteSanction lifts the user's sanction of the provided kind. If the
+	// user has no such sanction, `ErrSanctionNotFound` is returned.
+	DeleteSanction(UserID, SanctionKind) error
+
+	// UserSanctions returns the user's sanctions, including expired ones.
+	UserSanctions(UserID) ([]*Sanction, error)
+
+	// Sanctions returns every user's sanctions, including expired ones,
+	// ordered by user and kind.
+	Sanctions() ([]*Sanction, error)
+
+	// ShadowBanned returns the users whose shadow-bans are in effect at
+	// `now`, ordered by user.
+	ShadowBanned(now time.Time) ([]UserID, error)
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"net/http"
+	"time"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+var ErrInvalidSort = &pz.HTTPError{
+	Status:  http.StatusBadRequest,
+	Message: "invalid sort",
+}
+
+// Sort is the order in which sibling comments are returned.
+type Sort string
+
+const (
+	// SortOldest orders siblings by creation time, oldest first.
+	SortOldest Sort = "oldest"
+
+	// SortNewest orders siblings by creation time, newest first.
+	SortNewest Sort = "newest"
+
+	// SortReplies orders siblings by their number of direct replies, most
+	// first. Ties are broken by creation time, oldest first.
+	SortReplies Sort = "replies"
+
+	// SortTop orders siblings by score, highest first. Ties are broken by
+	// creation time, oldest first.
+	SortTop Sort = "top"
+)
+
+// ParseSort parses a sort order. The empty string parses to `SortOldest`. If
+// the input isn't a valid sort order, `ErrInvalidSort` is returned.
+func ParseSort(s string) (Sort, error) {
+	switch Sort(s) {
+	case "":
+		return SortOldest, nil
+	case SortOldest, SortNewest, SortReplies, SortTop:
+		return Sort(s), nil
+	default:
+		return "", ErrInvalidSort
+	}
+}
+
+// Less reports whether `a` sorts before `b`.
+func (s Sort) Less(a, b *Comment) bool {
+	return s.less(s.Key(a), a.Created, a.ID, s.Key(b), b.Created, b.ID)
+}
+
+// Key returns the comment's primary sort key. Keys sort in descending order
+// and they're followed by the comments' creation times and IDs. Sort orders
+// which are based only on creation time return `0` for every comment.
+func (s Sort) Key(c *Comment) int {
+	switch s {
+	case SortReplies:
+		return c.ReplyCount
+	case SortTop:
+		return c.Score
+	default:
+		return 0
+	}
+}
+
+func (s Sort) less(
+	lhsKey int,
+	lhsCreated time.Time,
+	lhsID CommentID,
+	rhsKey int,
+	rhsCreated time.Time,
+	rhsID CommentID,
+) bool {
+	if lhsKey != rhsKey {
+		return lhsKey > rhsKey
+	}
+	if s == SortNewest {
+		if !lhsCreated.Equal(rhsCreated) {
+			return lhsCreated.After(rhsCreated)
+		}
+		return lhsID > rhsID
+	}
+	if !lhsCreated.Equal(rhsCreated) {
+		return lhsCreated.Before(rhsCreated)
+	}
+	return lhsID < rhsID
+}
@@ -0,0 +1,106 @@
+package types
+
+import "testing"
+
+func TestParseSort(t *testing.T) {
+	for _, testCase := range []struct {
+		input     string
+		wanted    Sort
+		wantedErr WantedError
+	}{
+		{input: "", wanted: SortOldest, wantedErr: NilError{}},
+		{input: "oldest", wanted: SortOldest, wantedErr: NilError{}},
+		{input: "newest", wanted: SortNewest, wantedErr: NilError{}},
+		{input: "replies", wanted: SortReplies, wantedErr: NilError{}},
+		{input: "top", wanted: SortTop, wantedErr: NilError{}},
+		{input: "sideways", wantedErr: ErrInvalidSort},
+	} {
+		t.Run(testCase.input, func(t *testing.T) {
+			found, err := ParseSort(testCase.input)
+			if err := testCase.wantedErr.CompareErr(err); err != nil {
+				t.Fatal(err)
+			}
+			if found != testCase.wanted {
+				t.Fatalf("wanted `%s`; found `%s`", testCase.wanted, found)
+			}
+		})
+	}
+}
+
+func TestSort_Less(t *testing.T) {
+	older := &Comment{ID: "b", Created: someTime, ReplyCount: 1, Score: 3}
+	newer := &Comment{ID: "a", Created: someOtherTime, ReplyCount: 2, Score: -1}
+	tied := &Comment{ID: "c", Created: someTime, ReplyCount: 1, Score: 3}
+
+	for _, testCase := range []struct {
+		name   string
+		sort   Sort
+		lhs    *Comment
+		rhs    *Comment
+		wanted bool
+	}{
+		{
+			name:   "oldest",
+			sort:   SortOldest,
+			lhs:    older,
+			rhs:    newer,
+			wanted: true,
+		},
+		{
+			name:   "oldest tie broken by id",
+			sort:   SortOldest,
+			lhs:    older,
+			rhs:    tied,
+			wanted: true,
+		},
+		{
+			name:   "newest",
+			sort:   SortNewest,
+			lhs:    older,
+			rhs:    newer,
+			wanted: false,
+		},
+		{
+			name:   "newest tie broken by id",
+			sort:   SortNewest,
+			lhs:    tied,
+			rhs:    older,
+			wanted: true,
+		},
+		{
+			name:   "replies",
+			sort:   SortReplies,
+			lhs:    newer,
+			rhs:    older,
+			wanted: true,
+		},
+		{
+			name:   "replies tie broken by creation time",
+			sort:   SortReplies,
+			lhs:    older,
+			rhs:    tied,
+			wanted: true,
+		},
+		{
+			name:   "top",
+			sort:   SortTop,
+			lhs:    older,
+			rhs:    newer,
+			wanted: true,
+		},
+		{
+			name:   "top tie broken by id",
+			sort:   SortTop,
+			lhs:    tied,
+			rhs:    older,
+			wanted: false,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			found := testCase.sort.Less(testCase.lhs, testCase.rhs)
+			if found != testCase.wanted {
+				t.Fatalf("wanted `%t`; found `%t`", testCase.wanted, found)
+			}
+		})
+	}
+}
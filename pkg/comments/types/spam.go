@@ -0,0 +1,63 @@
+package types
+
+import (
+	"net/http"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+var ErrSpam = &pz.HTTPError{
+	Status:  http.StatusBadRequest,
+	Message: "comment rejected as spam",
+}
+
+// Verdict is the outcome of a spam check.
+type Verdict string
+
+const (
+	// VerdictAllow lets the comment through with its usual status.
+	VerdictAllow Verdict = "allow"
+
+	// VerdictHold puts the comment into the moderation queue.
+	VerdictHold Verdict = "hold"
+
+	// VerdictReject refuses to store the comment.
+	VerdictReject Verdict = "reject"
+)
+
+// Severity orders verdicts from least (allow) to most (reject) severe.
+// Unknown verdicts are treated like `VerdictAllow`.
+func (v Verdict) Severity() int {
+	switch v {
+	case VerdictHold:
+		return 1
+	case VerdictReject:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// SpamCheck is the result of a spam check. `Reason` explains a hold or a
+// rejection to moderators.
+type SpamCheck struct {
+	Verdict Verdict
+	Reason  string
+}
+
+// Allow is the result of a spam check which found nothing wrong.
+var Allow = SpamCheck{Verdict: VerdictAllow}
+
+// Submission is a new comment along with what's known about the request which
+// submitted it.
+type Submission struct {
+	Comment   *Comment
+	IP        string
+	UserAgent string
+	Referrer  string
+}
+
+// SpamChecker checks new comments for spam before they're stored.
+type SpamChecker interface {
+	CheckSpam(*Submission) (SpamCheck, error)
+}
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"net/http"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+var ErrInvalidStatus = &pz.HTTPError{
+	Status:  http.StatusBadRequest,
+	Message: "invalid status",
+}
+
+// Status is a comment's moderation status. Only approved comments are visible
+// to everyone; other comments are only visible to their authors and to
+// moderators.
+type Status string
+
+const (
+	StatusApproved Status = "approved"
+	StatusPending  Status = "pending"
+	StatusRejected Status = "rejected"
+)
+
+// Normalize returns `StatusApproved` for the empty status (comments without a
+// status predate moderation) and the status itself otherwise. Stores store
+// normalized statuses so that queries can filter on them directly.
+func (s Status) Normalize() Status {
+	if s == "" {
+		return StatusApproved
+	}
+	return s
+}
+
+// Visible reports whether a comment with the status is visible to everyone,
+// i.e., whether its normalized status is `StatusApproved`.
+func (s Status) Visible() bool { return s.Normalize() == StatusApproved }
+
+// PostSettings are a post's comment settings.
+type PostSettings struct {
+	Post PostID `json:"post"`
+
+	// Premoderate puts new comments on the post into the moderation queue
+	// (i.e., their status is `StatusPending` until a moderator approves
+	// them).
+	Premoderate bool `json:"premoderate"`
+}
+
+// PostSettingsStore stores per-post settings.
+type PostSettingsStore interface {
+	// PostSettings returns the post's settings. If the post has no settings,
+	// `ErrPostSettingsNotFound` is returned.
+	PostSettings(PostID) (*PostSettings, error)
+
+	// PutPostSettings creates or replaces the post's settings.
+	PutPostSettings(*PostSettings) error
+}
+
+var ErrPostSettingsNotFound = &pz.HTTPError{
+	Status:  http.StatusNotFound,
+	Message: "post settings not found",
+}
@@ -0,0 +1,38 @@
+package types
+
+import "testing"
+
+func TestStatus_Visible(t *testing.T) {
+	for _, testCase := range []struct {
+		status     Status
+		normalized Status
+		visible    bool
+	}{
+		{status: "", normalized: StatusApproved, visible: true},
+		{
+			status:     StatusApproved,
+			normalized: StatusApproved,
+			visible:    true,
+		},
+		{status: StatusPending, normalized: StatusPending},
+		{status: StatusRejected, normalized: StatusRejected},
+	} {
+		t.Run(string(testCase.status), func(t *testing.T) {
+			if found := testCase.status.Normalize(); found !=
+				testCase.normalized {
+				t.Fatalf(
+					"Normalize(): wanted `%s`; found `%s`",
+					testCase.normalized,
+					found,
+				)
+			}
+			if found := testCase.status.Visible(); found != testCase.visible {
+				t.Fatalf(
+					"Visible(): wanted `%t`; found `%t`",
+					testCase.visible,
+					found,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package types
+
+import (
+	"net/http"
+	"time"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+var (
+	ErrSubscriptionNotFound = &pz.HTTPError{
+		Status:  http.StatusNotFound,
+		Message: "subscription not found",
+	}
+	ErrNotificationExists = &pz.HTTPError{
+		Status:  http.StatusConflict,
+		Message: "notification exists",
+	}
+	ErrNotificationNotFound = &pz.HTTPError{
+		Status:  http.StatusNotFound,
+		Message: "notification not found",
+	}
+)
+
+// Subscription follows a post or a subthread of a post. New comments in the
+// followed thread are delivered to the user's notifications inbox.
+type Subscription struct {
+	User UserID `json:"user"`
+	Post PostID `json:"post"`
+
+	// Comment is the root of the followed subthread. If it's empty, the
+	// whole post is followed.
+	Comment CommentID `json:"comment,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// SubscriptionsStore stores users' subscriptions.
+type SubscriptionsStore interface {
+	// PutSubscription creates or replaces a subscription.
+	PutSubscription(*Subscription) error
+
+	// DeleteSubscription deletes a subscription. If there's no such
+	// subscription, `ErrSubscriptionNotFound` is returned.
+	DeleteSubscription(UserID, PostID, CommentID) error
+
+	// Subscription returns a subscription. If there's no such subscription,
+	// `ErrSubscriptionNotFound` is returned.
+	Subscription(UserID, PostID, CommentID) (*Subscription, error)
+
+	// UserSubscriptions returns the user's subscriptions, newest first.
+	UserSubscriptions(UserID) ([]*Subscription, error)
+
+	// PostSubscriptions returns every subscription to the post or to any of
+	// its subthreads.
+	PostSubscriptions(PostID) ([]*Subscription, error)
+}
+
+// Notification is an entry in a user's notifications inbox which tells them
+// about a new comment in a thread they follow.
+type Notification struct {
+	User    UserID    `json:"user"`
+	Post    PostID    `json:"post"`
+	Comment CommentID `json:"comment"`
+
+	// Thread is the root of the followed subthread which the comment was
+	// posted in (empty if the user follows the whole post).
+	Thread  CommentID `json:"thread,omitempty"`
+	Created time.Time `json:"created"`
+	Read    bool      `json:"read"`
+}
+
+// InboxStore stores users' notifications.
+type InboxStore interface {
+	// PutNotification adds a notification to a user's inbox. If the user has
+	// already been notified about the comment, `ErrNotificationExists` is
+	// returned.
+	PutNotification(*Notification) error
+
+	// Notifications returns up to `limit` of the user's newest
+	// notifications. Read notifications are only included if `read` is true.
+	Notifications(user UserID, read bool, limit int) ([]*Notification, error)
+
+	// MarkNotificationRead marks a notification read. If there's no such
+	// notification, `ErrNotificationNotFound` is returned.
+	MarkNotificationRead(UserID, PostID, CommentID) error
+
+	// MarkNotificationsRead marks all of the user's notifications read.
+	MarkNotificationsRead(UserID) error
+}
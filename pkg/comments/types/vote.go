@@ -0,0 +1,47 @@
+package types
+
+import (
+	"net/http"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+var (
+	ErrInvalidVote = &pz.HTTPError{
+		Status:  http.StatusBadRequest,
+		Message: "invalid vote",
+	}
+	ErrVoteNotFound = &pz.HTTPError{
+		Status:  http.StatusNotFound,
+		Message: "vote not found",
+	}
+)
+
+const (
+	// Upvote is the `Value` of a vote in favor of a comment.
+	Upvote = 1
+
+	// Downvote is the `Value` of a vote against a comment.
+	Downvote = -1
+)
+
+// Vote is a user's vote on a comment. Each user has at most one vote per
+// comment.
+type Vote struct {
+	Post    PostID    `json:"post"`
+	Comment CommentID `json:"comment"`
+	User    UserID    `json:"user"`
+	Value   int       `json:"value"`
+}
+
+// VotesStore stores votes. The scores aggregated from the votes are reported
+// by the `CommentsStore` on each comment it returns.
+type VotesStore interface {
+	// PutVote records a vote, replacing any existing vote by the same user
+	// on the same comment.
+	PutVote(*Vote) error
+
+	// DeleteVote removes the user's vote on the comment. If the user hasn't
+	// voted on the comment, `ErrVoteNotFound` is returned.
+	DeleteVote(PostID, CommentID, UserID) error
+}
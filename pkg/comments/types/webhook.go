@@ -0,0 +1,91 @@
+package types
+
+import (
+	"net/http"
+	"time"
+
+	pz "github.com/weberc2/httpeasy"
+)
+
+var (
+	ErrWebhookNotFound = &pz.HTTPError{
+		Status:  http.StatusNotFound,
+		Message: "webhook not found",
+	}
+	ErrDeliveryNotFound = &pz.HTTPError{
+		Status:  http.StatusNotFound,
+		Message: "webhook delivery not found",
+	}
+	ErrInvalidWebhook = &pz.HTTPError{
+		Status:  http.StatusBadRequest,
+		Message: "invalid webhook",
+	}
+)
+
+// Webhook is an HTTP endpoint which is sent events. Each delivery is signed
+// with the webhook's secret.
+type Webhook struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+
+	// Events are the types of events which are delivered to the webhook. If
+	// it's empty, every event is delivered.
+	Events  []EventType `json:"events,omitempty"`
+	Created time.Time   `json:"created"`
+}
+
+// Wants reports whether the webhook is sent events of the type.
+func (w *Webhook) Wants(et EventType) bool {
+	if len(w.Events) < 1 {
+		return true
+	}
+	for _, wanted := range w.Events {
+		if et == wanted {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is an event's delivery to a webhook which failed every
+// attempt. Failed deliveries are kept so they can be replayed.
+type WebhookDelivery struct {
+	ID       string    `json:"id"`
+	Webhook  string    `json:"webhook"`
+	Event    *Event    `json:"event"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Failed   time.Time `json:"failed"`
+}
+
+// WebhooksStore stores webhooks and their failed deliveries.
+type WebhooksStore interface {
+	// PutWebhook creates or replaces a webhook.
+	PutWebhook(*Webhook) error
+
+	// DeleteWebhook deletes a webhook. If there's no such webhook,
+	// `ErrWebhookNotFound` is returned.
+	DeleteWebhook(string) error
+
+	// Webhook returns a webhook. If there's no such webhook,
+	// `ErrWebhookNotFound` is returned.
+	Webhook(string) (*Webhook, error)
+
+	// Webhooks returns every webhook, oldest first.
+	Webhooks() ([]*Webhook, error)
+
+	// PutFailedDelivery creates or replaces a failed delivery.
+	PutFailedDelivery(*WebhookDelivery) error
+
+	// DeleteFailedDelivery deletes a failed delivery. If there's no such
+	// delivery, `ErrDeliveryNotFound` is returned.
+	DeleteFailedDelivery(string) error
+
+	// FailedDelivery returns a failed delivery. If there's no such delivery,
+	// `ErrDeliveryNotFound` is returned.
+	FailedDelivery(string) (*WebhookDelivery, error)
+
+	// FailedDeliveries returns every failed delivery, newest first.
+	FailedDeliveries() ([]*WebhookDelivery, error)
+}
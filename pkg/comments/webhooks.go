@@ -0,0 +1,137 @@
+package comments
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// WebhookReplayer redelivers failed webhook deliveries.
+type WebhookReplayer interface {
+	Replay(id string) error
+}
+
+// PutWebhook registers a webhook, generating its ID and (unless one is
+// provided) its secret. The webhook's URL must be an absolute `http` or
+// `https` URL. Only admins can register webhooks.
+func (cm *CommentsModel) PutWebhook(
+	admin types.UserID,
+	w *types.Webhook,
+) (*types.Webhook, error) {
+	if err := cm.requireAdmin(admin); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+		u.Host == "" {
+		return nil, fmt.Errorf(
+			"%w: url must be an absolute http(s) url",
+			types.ErrInvalidWebhook,
+		)
+	}
+	for _, t := range w.Events {
+		if !t.Valid() {
+			return nil, fmt.Errorf(
+				"%w: unknown event type `%s`",
+				types.ErrInvalidWebhook,
+				t,
+			)
+		}
+	}
+	if cm.WebhooksStore == nil {
+		return nil, fmt.Errorf("registering webhook: no webhooks store")
+	}
+
+	cp := *w
+	if cp.ID, err = randomToken(12); err != nil {
+		return nil, fmt.Errorf("registering webhook: %w", err)
+	}
+	if cp.Secret == "" {
+		if cp.Secret, err = randomToken(32); err != nil {
+			return nil, fmt.Errorf("registering webhook: %w", err)
+		}
+	}
+	cp.Created = cm.TimeFunc()
+	if err := cm.WebhooksStore.PutWebhook(&cp); err != nil {
+		return nil, fmt.Errorf("registering webhook: %w", err)
+	}
+	return &cp, nil
+}
+
+// randomToken returns `n` random bytes, base64-encoded.
+func randomToken(n int) (string, error) {
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("generating random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DeleteWebhook unregisters a webhook. Only admins can unregister webhooks.
+func (cm *CommentsModel) DeleteWebhook(admin types.UserID, id string) error {
+	if err := cm.requireAdmin(admin); err != nil {
+		return err
+	}
+	if cm.WebhooksStore == nil {
+		return types.ErrWebhookNotFound
+	}
+	if err := cm.WebhooksStore.DeleteWebhook(id); err != nil {
+		return fmt.Errorf("deleting webhook: %w", err)
+	}
+	return nil
+}
+
+// Webhooks lists the registered webhooks, oldest first. Their secrets are
+// left out; they're only returned when webhooks are registered. Only admins
+// can list webhooks.
+func (cm *CommentsModel) Webhooks(
+	admin types.UserID,
+) ([]*types.Webhook, error) {
+	if err := cm.requireAdmin(admin); err != nil {
+		return nil, err
+	}
+	if cm.WebhooksStore == nil {
+		return []*types.Webhook{}, nil
+	}
+	webhooks, err := cm.WebhooksStore.Webhooks()
+	if err != nil {
+		return nil, fmt.Errorf("fetching webhooks: %w", err)
+	}
+	for _, w := range webhooks {
+		w.Secret = ""
+	}
+	return webhooks, nil
+}
+
+// FailedDeliveries lists the webhook deliveries which failed every attempt,
+// newest first. Only admins can list failed deliveries.
+func (cm *CommentsModel) FailedDeliveries(
+	admin types.UserID,
+) ([]*types.WebhookDelivery, error) {
+	if err := cm.requireAdmin(admin); err != nil {
+		return nil, err
+	}
+	if cm.WebhooksStore == nil {
+		return []*types.WebhookDelivery{}, nil
+	}
+	deliveries, err := cm.WebhooksStore.FailedDeliveries()
+	if err != nil {
+		return nil, fmt.Errorf("fetching failed deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery redelivers a failed webhook delivery. Only admins can replay
+// deliveries.
+func (cm *CommentsModel) ReplayDelivery(admin types.UserID, id string) error {
+	if err := cm.requireAdmin(admin); err != nil {
+		return err
+	}
+	if cm.WebhookReplayer == nil {
+		return types.ErrDeliveryNotFound
+	}
+	return cm.WebhookReplayer.Replay(id)
+}
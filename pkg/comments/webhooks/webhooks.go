@@ -0,0 +1,308 @@
+// Package webhooks delivers comment events to the webhooks which are
+// registered in a `types.WebhooksStore`.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/jobs"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+const (
+	// JobKind identifies queued deliveries in a `types.JobsStore`.
+	JobKind = "webhook-delivery"
+
+	// TimeoutDefault is the default time limit for a single attempt.
+	TimeoutDefault = 10 * time.Second
+
+	// SignatureHeader holds the payload's signature (see `Sign()`).
+	SignatureHeader = "X-Comments-Signature"
+
+	// EventHeader holds the event's type.
+	EventHeader = "X-Comments-Event"
+
+	// DeliveryHeader holds the delivery's ID, which is the same for every
+	// attempt (including replays) so that receivers can drop duplicates.
+	DeliveryHeader = "X-Comments-Delivery"
+)
+
+// ErrQueueFull is returned when a delivery doesn't fit in the dispatcher's
+// store.
+var ErrQueueFull = jobs.ErrQueueFull
+
+// Sign returns the signature of a payload: `sha256=` followed by the
+// hex-encoded HMAC-SHA256 of the payload, keyed with the webhook's secret.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature is the payload's signature.
+func Verify(secret string, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, payload)), []byte(signature))
+}
+
+// Dispatcher POSTs events to webhooks asynchronously so that callers aren't
+// blocked by slow receivers. Deliveries are queued as jobs (see `jobs.Queue`),
+// so failed attempts are retried with exponential backoff; deliveries which
+// fail every attempt are recorded in the store's failed deliveries, from which
+// they can be replayed. Deliveries are only sent while `Run()` is running, and
+// they're only kept across restarts if `Jobs.Store` is durable.
+type Dispatcher struct {
+	Store types.WebhooksStore
+
+	// Client sends the deliveries. It defaults to a client with a
+	// `TimeoutDefault` timeout.
+	Client *http.Client
+
+	// Jobs configures the dispatcher's store, attempts and backoff. Its
+	// `Kind` and `Handler` are set by the `Dispatcher`.
+	Jobs jobs.Queue
+
+	// TimeFunc defaults to `time.Now()`.
+	TimeFunc func() time.Time
+
+	once sync.Once
+}
+
+// delivery is the payload of a delivery's job.
+type delivery struct {
+	Webhook string       `json:"webhook"`
+	Event   *types.Event `json:"event"`
+}
+
+func (d *Dispatcher) init() {
+	d.once.Do(func() {
+		d.Jobs.Kind = JobKind
+		d.Jobs.Handler = (*deliveryHandler)(d)
+		if d.Client == nil {
+			d.Client = &http.Client{Timeout: TimeoutDefault}
+		}
+		if d.TimeFunc == nil {
+			d.TimeFunc = time.Now
+		}
+	})
+}
+
+// Payload is the body of a delivery. It's built from the event rather than
+// being the event itself so that receivers get a stable format which only has
+// public data, e.g., not the comment's status or spam verdict.
+type Payload struct {
+	// ID is the event's ID.
+	ID      int64           `json:"id"`
+	Type    types.EventType `json:"type"`
+	Time    time.Time       `json:"time"`
+	Comment PayloadComment  `json:"comment"`
+}
+
+// PayloadComment is the comment in a `Payload`.
+type PayloadComment struct {
+	ID       types.CommentID `json:"id"`
+	Post     types.PostID    `json:"post"`
+	Parent   types.CommentID `json:"parent"`
+	Author   types.UserID    `json:"author"`
+	Created  time.Time       `json:"created"`
+	Modified time.Time       `json:"modified"`
+	Deleted  bool            `json:"deleted"`
+	Body     string          `json:"body"`
+}
+
+// NewPayload returns the payload of an event's deliveries.
+func NewPayload(e *types.Event) *Payload {
+	return &Payload{
+		ID:   e.ID,
+		Type: e.Type,
+		Time: e.Time,
+		Comment: PayloadComment{
+			ID:       e.Comment.ID,
+			Post:     e.Comment.Post,
+			Parent:   e.Comment.Parent,
+			Author:   e.Comment.Author,
+			Created:  e.Comment.Created,
+			Modified: e.Comment.Modified,
+			Deleted:  e.Comment.Deleted,
+			Body:     e.Comment.Body,
+		},
+	}
+}
+
+// DeliveryID returns the ID of an event's delivery to a webhook. It's derived
+// from the event's ID, so every delivery of an event which is published more
+// than once (see `types.EventSink`) has the same ID. Delivery is
+// at-least-once: an event which is published again while its delivery is
+// queued isn't queued twice, but deliveries are removed once they succeed, so
+// an event which is published again afterwards is delivered again. Receivers
+// should drop deliveries whose IDs (see `DeliveryHeader`) they've seen.
+func DeliveryID(e *types.Event, webhook string) string {
+	return fmt.Sprintf("%d-%s", e.ID, webhook)
+}
+
+// Publish queues a delivery of the event to every webhook which wants it,
+// without blocking. Publish only returns once the deliveries are in
+// `Jobs.Store`, so if it's durable (e.g., `pgcommentsstore`), the event won't
+// be lost after it's acknowledged. If the queue is full, the delivery is
+// recorded as failed so that it can be replayed later; if it fails for any
+// other reason, an error is returned so that the event is published again.
+func (d *Dispatcher) Publish(e *types.Event) error {
+	d.init()
+	webhooks, err := d.Store.Webhooks()
+	if err != nil {
+		return fmt.Errorf("publishing `%s` event: %w", e.Type, err)
+	}
+	for _, w := range webhooks {
+		if !w.Wants(e.Type) {
+			continue
+		}
+		id := DeliveryID(e, w.ID)
+		if err := d.enqueue(id, w.ID, e); err != nil {
+			if !errors.Is(err, ErrQueueFull) {
+				return fmt.Errorf("publishing `%s` event: %w", e.Type, err)
+			}
+			d.fail(&types.Job{ID: id}, &delivery{w.ID, e}, err)
+		}
+	}
+	return nil
+}
+
+// Replay queues a failed delivery again, removing it from the failed
+// deliveries. It's retried from scratch, i.e., it gets `Jobs.MaxAttempts`
+// more attempts.
+func (d *Dispatcher) Replay(id string) error {
+	d.init()
+	failed, err := d.Store.FailedDelivery(id)
+	if err != nil {
+		return fmt.Errorf("replaying delivery: %w", err)
+	}
+	if _, err := d.Store.Webhook(failed.Webhook); err != nil {
+		return fmt.Errorf("replaying delivery: %w", err)
+	}
+	if err := d.Store.DeleteFailedDelivery(id); err != nil {
+		return fmt.Errorf("replaying delivery: %w", err)
+	}
+	if err := d.enqueue(id, failed.Webhook, failed.Event); err != nil {
+		if err := d.Store.PutFailedDelivery(failed); err != nil {
+			log.Printf("restoring failed delivery `%s`: %v", id, err)
+		}
+		return fmt.Errorf("replaying delivery: %w", err)
+	}
+	return nil
+}
+
+func (d *Dispatcher) enqueue(id, webhook string, e *types.Event) error {
+	payload, err := json.Marshal(&delivery{Webhook: webhook, Event: e})
+	if err != nil {
+		return fmt.Errorf("marshaling delivery: %w", err)
+	}
+	return d.Jobs.Enqueue(id, payload)
+}
+
+// Run sends queued deliveries until the context is canceled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.init()
+	d.Jobs.Run(ctx)
+}
+
+// deliveryHandler implements `jobs.Handler` for a `Dispatcher`.
+type deliveryHandler Dispatcher
+
+func (h *deliveryHandler) Attempt(ctx context.Context, j *types.Job) error {
+	var delivery delivery
+	if err := json.Unmarshal(j.Payload, &delivery); err != nil {
+		return fmt.Errorf("unmarshaling delivery: %w", err)
+	}
+	w, err := h.Store.Webhook(delivery.Webhook)
+	if errors.Is(err, types.ErrWebhookNotFound) {
+		// the webhook was deleted after the delivery was queued, so there's
+		// nowhere to deliver it to
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return (*Dispatcher)(h).post(ctx, j.ID, w, delivery.Event)
+}
+
+func (h *deliveryHandler) Abandon(j *types.Job, err error) {
+	var delivery delivery
+	if err := json.Unmarshal(j.Payload, &delivery); err != nil {
+		log.Printf("giving up on delivery `%s`: %v", j.ID, err)
+		return
+	}
+	(*Dispatcher)(h).fail(j, &delivery, err)
+}
+
+// post makes a single attempt at a delivery. Any `2xx` response counts as
+// success.
+func (d *Dispatcher) post(
+	ctx context.Context,
+	id string,
+	w *types.Webhook,
+	e *types.Event,
+) error {
+	payload, err := json.Marshal(NewPayload(e))
+	if err != nil {
+		return fmt.Errorf("marshaling payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		w.URL,
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(e.Type))
+	req.Header.Set(DeliveryHeader, id)
+	req.Header.Set(SignatureHeader, Sign(w.Secret, payload))
+
+	rsp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	// drain (some of) the body so that the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(rsp.Body, 4096))
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", rsp.Status)
+	}
+	return nil
+}
+
+// fail records a delivery which won't be attempted again.
+func (d *Dispatcher) fail(j *types.Job, delivery *delivery, err error) {
+	log.Printf(
+		"giving up on delivering `%s` to webhook `%s` after %d attempts: %v",
+		j.ID,
+		delivery.Webhook,
+		j.Attempts,
+		err,
+	)
+	if err := d.Store.PutFailedDelivery(&types.WebhookDelivery{
+		ID:       j.ID,
+		Webhook:  delivery.Webhook,
+		Event:    delivery.Event,
+		Attempts: j.Attempts,
+		Error:    err.Error(),
+		Failed:   d.TimeFunc(),
+	}); err != nil {
+		log.Printf("recording failed delivery `%s`: %v", j.ID, err)
+	}
+}
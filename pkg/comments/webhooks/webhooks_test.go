@@ -0,0 +1,399 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/jobs"
+	"github.com/weberc2/comments/pkg/comments/testsupport"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// receiver is an HTTP endpoint which fails the first `failures` attempts at
+// each delivery and records the (validly signed) payloads it accepts.
+type receiver struct {
+	secret   string
+	failures int
+
+	lock     sync.Mutex
+	attempts map[string]int
+	received chan *Payload
+}
+
+func newReceiver(secret string, failures int) *receiver {
+	return &receiver{
+		secret:   secret,
+		failures: failures,
+		attempts: map[string]int{},
+		received: make(chan *Payload, 10),
+	}
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	payload, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !Verify(r.secret, payload, req.Header.Get(SignatureHeader)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.lock.Lock()
+	id := req.Header.Get(DeliveryHeader)
+	r.attempts[id]++
+	attempts := r.attempts[id]
+	r.lock.Unlock()
+	if attempts <= r.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var e Payload
+	if err := json.Unmarshal(payload, &e); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if string(e.Type) != req.Header.Get(EventHeader) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.received <- &e
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *receiver) Attempts(id string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.attempts[id]
+}
+
+func testEvent() *types.Event {
+	return &types.Event{
+		Type: types.EventCommentCreated,
+		Time: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Comment: &types.EventComment{
+			ID:     "comment",
+			Post:   "post",
+			Author: "adam",
+			Body:   "Hello, world!",
+		},
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	for _, testCase := range []struct {
+		name           string
+		failures       int
+		maxAttempts    int
+		secret         string
+		events         []types.EventType
+		wantedReceived bool
+		wantedFailed   bool
+	}{
+		{
+			name:           "delivered first time",
+			maxAttempts:    3,
+			secret:         "secret",
+			wantedReceived: true,
+		},
+		{
+			name:           "delivered after retries",
+			failures:       2,
+			maxAttempts:    3,
+			secret:         "secret",
+			wantedReceived: true,
+		},
+		{
+			name:         "failed after max attempts",
+			failures:     3,
+			maxAttempts:  3,
+			secret:       "secret",
+			wantedFailed: true,
+		},
+		{
+			name:         "wrong secret",
+			maxAttempts:  1,
+			secret:       "wrong",
+			wantedFailed: true,
+		},
+		{
+			name:        "unwanted event type",
+			maxAttempts: 1,
+			secret:      "secret",
+			events:      []types.EventType{types.EventCommentDeleted},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			receiver := newReceiver("secret", testCase.failures)
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			store := testsupport.Webhooks(&types.Webhook{
+				ID:     "webhook",
+				URL:    server.URL,
+				Secret: testCase.secret,
+				Events: testCase.events,
+			})
+			dispatcher := Dispatcher{
+				Store: store,
+				Jobs: jobs.Queue{
+					MaxAttempts: testCase.maxAttempts,
+					Backoff:     time.Millisecond,
+					Interval:    time.Millisecond,
+				},
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go dispatcher.Run(ctx)
+
+			if err := dispatcher.Publish(testEvent()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			select {
+			case e := <-receiver.received:
+				if !testCase.wantedReceived {
+					t.Fatal("wanted no delivery; found delivered")
+				}
+				if e.Comment.ID != "comment" {
+					t.Fatalf(
+						"Payload.Comment.ID: wanted `comment`; found `%s`",
+						e.Comment.ID,
+					)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if testCase.wantedReceived {
+					t.Fatal("wanted delivery; timed out")
+				}
+			}
+
+			failed, err := store.FailedDeliveries()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !testCase.wantedFailed {
+				if len(failed) != 0 {
+					t.Fatalf(
+						"wanted no failed deliveries; found `%d`",
+						len(failed),
+					)
+				}
+				return
+			}
+			if len(failed) != 1 {
+				t.Fatalf("wanted 1 failed delivery; found `%d`", len(failed))
+			}
+			if failed[0].Attempts != testCase.maxAttempts {
+				t.Fatalf(
+					"WebhookDelivery.Attempts: wanted `%d`; found `%d`",
+					testCase.maxAttempts,
+					failed[0].Attempts,
+				)
+			}
+		})
+	}
+}
+
+func TestDispatcher_Replay(t *testing.T) {
+	receiver := newReceiver("secret", 1)
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	store := testsupport.Webhooks(&types.Webhook{
+		ID:     "webhook",
+		URL:    server.URL,
+		Secret: "secret",
+	})
+	dispatcher := Dispatcher{
+		Store: store,
+		Jobs:  jobs.Queue{MaxAttempts: 1, Interval: time.Millisecond},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+
+	if err := dispatcher.Publish(testEvent()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	id := DeliveryID(testEvent(), "webhook")
+
+	// wait for the only attempt to fail
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := store.FailedDelivery(id); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("wanted a failed delivery; timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := dispatcher.Replay(id); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-receiver.received:
+	case <-time.After(time.Second):
+		t.Fatal("wanted replayed delivery; timed out")
+	}
+	if attempts := receiver.Attempts(id); attempts != 2 {
+		t.Fatalf("attempts: wanted `2`; found `%d`", attempts)
+	}
+	if _, err := store.FailedDelivery(
+		id,
+	); !errors.Is(err, types.ErrDeliveryNotFound) {
+		t.Fatalf("wanted `ErrDeliveryNotFound`; found `%v`", err)
+	}
+	if err := dispatcher.Replay(
+		id,
+	); !errors.Is(err, types.ErrDeliveryNotFound) {
+		t.Fatalf("wanted `ErrDeliveryNotFound`; found `%v`", err)
+	}
+}
+
+func TestDispatcher_QueueFull(t *testing.T) {
+	store := testsupport.Webhooks(&types.Webhook{
+		ID:  "webhook",
+		URL: "http://127.0.0.1:0",
+	})
+	dispatcher := Dispatcher{
+		Store: store,
+		Jobs:  jobs.Queue{Store: &jobs.MemoryStore{Size: 1}},
+	}
+
+	// nothing is running the dispatcher, so the second delivery doesn't fit
+	for _, id := range []int64{1, 2} {
+		e := testEvent()
+		e.ID = id
+		if err := dispatcher.Publish(e); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	failed, err := store.FailedDeliveries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(failed) != 1 || failed[0].ID != "2-webhook" {
+		t.Fatalf("wanted failed delivery `2-webhook`; found `%+v`", failed)
+	}
+}
+
+func TestDispatcher_Duplicates(t *testing.T) {
+	receiver := newReceiver("secret", 0)
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	store := testsupport.Webhooks(&types.Webhook{
+		ID:     "webhook",
+		URL:    server.URL,
+		Secret: "secret",
+	})
+	dispatcher := Dispatcher{
+		Store: store,
+		Jobs:  jobs.Queue{Interval: time.Millisecond},
+	}
+
+	// the event is published again (e.g., because the relay died before
+	// acknowledging it) before it's delivered
+	for i := 0; i < 2; i++ {
+		if err := dispatcher.Publish(testEvent()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+
+	select {
+	case <-receiver.received:
+	case <-time.After(time.Second):
+		t.Fatal("wanted delivery; timed out")
+	}
+	select {
+	case <-receiver.received:
+		t.Fatal("wanted a single delivery; found a duplicate")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// failingJobsStore fails to queue jobs.
+type failingJobsStore struct{ jobs.MemoryStore }
+
+func (*failingJobsStore) PutJob(*types.Job) error {
+	return errors.New("database unavailable")
+}
+
+func TestDispatcher_StoreFailure(t *testing.T) {
+	store := testsupport.Webhooks(&types.Webhook{
+		ID:  "webhook",
+		URL: "http://127.0.0.1:0",
+	})
+	dispatcher := Dispatcher{
+		Store: store,
+		Jobs:  jobs.Queue{Store: &failingJobsStore{}},
+	}
+
+	// the event isn't acknowledged, so it's published again later
+	if err := dispatcher.Publish(testEvent()); err == nil {
+		t.Fatal("wanted an error; found `nil`")
+	}
+	failed, err := store.FailedDeliveries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(failed) != 0 {
+		t.Fatalf("wanted no failed deliveries; found `%+v`", failed)
+	}
+}
+
+// Receivers only get the comment's public data.
+func TestNewPayload(t *testing.T) {
+	data, err := json.Marshal(NewPayload(testEvent()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var fields struct {
+		Comment map[string]interface{} `json:"comment"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fields.Comment["body"] != "Hello, world!" {
+		t.Fatalf("comment.body: wanted `Hello, world!`; found: %s", data)
+	}
+	for _, private := range []string{
+		"status",
+		"spamVerdict",
+		"spamReason",
+		"removed",
+	} {
+		if _, found := fields.Comment[private]; found {
+			t.Fatalf("comment.%s: wanted nothing; found: %s", private, data)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	payload := []byte(`{"type":"comment.created"}`)
+	signature := Sign("secret", payload)
+	if !Verify("secret", payload, signature) {
+		t.Fatal("wanted valid signature")
+	}
+	if Verify("other", payload, signature) {
+		t.Fatal("wanted invalid signature for another secret")
+	}
+	if Verify("secret", []byte(`{}`), signature) {
+		t.Fatal("wanted invalid signature for another payload")
+	}
+}
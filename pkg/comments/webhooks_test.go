@@ -0,0 +1,317 @@
+package comments
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/testsupport"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestCommentsModel_Events(t *testing.T) {
+	store := testsupport.EventsStoreFake{CommentsStore: moderationState()}
+	ids := []types.CommentID{"new", "premoderated"}
+	model := CommentsModel{
+		CommentsStore: &store,
+		PostSettingsStore: testsupport.PostSettingsStoreFake{
+			"premoderated": {Post: "premoderated", Premoderate: true},
+		},
+		ReportsStore:    &testsupport.ReportsStoreFake{},
+		ReportThreshold: 1,
+		Roles: testsupport.RolesStoreFake{
+			"moderator": types.RoleModerator,
+		},
+		IDFunc: func() types.CommentID {
+			id := ids[0]
+			ids = ids[1:]
+			return id
+		},
+		TimeFunc: func() time.Time { return now },
+	}
+
+	if _, err := model.Put(&types.Comment{
+		Post:   "post",
+		Author: "author",
+		Body:   "a toplevel comment",
+	}); err != nil {
+		t.Fatalf("Put(): unexpected error: %v", err)
+	}
+	if err := model.Update("author", &CommentUpdate{
+		Post: "post",
+		ID:   "new",
+		Body: "an edited comment",
+	}); err != nil {
+		t.Fatalf("Update(): unexpected error: %v", err)
+	}
+
+	// comments which aren't visible aren't created until they're approved
+	if _, err := model.Put(&types.Comment{
+		Post:   "premoderated",
+		Author: "author",
+		Body:   "a premoderated comment",
+	}); err != nil {
+		t.Fatalf("Put(): unexpected error: %v", err)
+	}
+	if err := model.Moderate(
+		"moderator",
+		"post",
+		"pending",
+		types.StatusApproved,
+	); err != nil {
+		t.Fatalf("Moderate(): unexpected error: %v", err)
+	}
+
+	// comments which are hidden by reports are deleted, and moderating them
+	// while they're hidden isn't an event
+	if _, err := model.Report(&types.Report{
+		Post:     "post",
+		Comment:  "new",
+		Reporter: "reporter",
+		Reason:   "abusive",
+	}); err != nil {
+		t.Fatalf("Report(): unexpected error: %v", err)
+	}
+	if err := model.Moderate(
+		"moderator",
+		"post",
+		"new",
+		types.StatusRejected,
+	); err != nil {
+		t.Fatalf("Moderate(): unexpected error: %v", err)
+	}
+	if err := model.Delete("moderator", "post", "approved"); err != nil {
+		t.Fatalf("Delete(): unexpected error: %v", err)
+	}
+
+	// failed changes aren't events
+	if _, err := model.Put(&types.Comment{Post: "post"}); err == nil {
+		t.Fatal("Put(): wanted an error; found `nil`")
+	}
+
+	wanted := []struct {
+		eventType types.EventType
+		comment   types.CommentID
+		body      string
+	}{
+		{types.EventCommentCreated, "new", "a toplevel comment"},
+		{types.EventCommentUpdated, "new", "an edited comment"},
+		{types.EventCommentCreated, "pending", "body"},
+		{types.EventCommentDeleted, "new", ""},
+		{types.EventCommentDeleted, "approved", ""},
+	}
+	if len(store.Events) != len(wanted) {
+		t.Fatalf(
+			"len(events): wanted `%d`; found `%d`",
+			len(wanted),
+			len(store.Events),
+		)
+	}
+	for i, w := range wanted {
+		e := store.Events[i]
+		if e.Type != w.eventType ||
+			e.Comment.ID != w.comment ||
+			e.Comment.Body != w.body {
+			t.Fatalf(
+				"events[%d]: wanted `%s` of `%s` with body `%s`; found `%s` "+
+					"with `%+v`",
+				i,
+				w.eventType,
+				w.comment,
+				w.body,
+				e.Type,
+				e.Comment,
+			)
+		}
+	}
+	if !store.Events[4].Comment.Deleted {
+		t.Fatal("events[4]: wanted deleted comment")
+	}
+}
+
+// Comments by shadow-banned authors are only visible to their authors, so
+// changes to them aren't events.
+func TestCommentsModel_Events_ShadowBan(t *testing.T) {
+	sanctions := testsupport.Sanctions(&types.Sanction{
+		User: "banned",
+		Kind: types.SanctionShadowBan,
+	})
+	store := testsupport.EventsStoreFake{
+		CommentsStore: &testsupport.CommentsStoreFake{},
+		Sanctions:     sanctions,
+	}
+	ids := []types.CommentID{"banned", "author"}
+	model := CommentsModel{
+		CommentsStore:  &store,
+		SanctionsStore: sanctions,
+		IDFunc: func() types.CommentID {
+			id := ids[0]
+			ids = ids[1:]
+			return id
+		},
+		TimeFunc: func() time.Time { return now },
+	}
+
+	for _, author := range []types.UserID{"banned", "author"} {
+		if _, err := model.Put(&types.Comment{
+			Post:   "post",
+			Author: author,
+			Body:   "a toplevel comment",
+		}); err != nil {
+			t.Fatalf("Put(): unexpected error: %v", err)
+		}
+		if err := model.Update(author, &CommentUpdate{
+			Post: "post",
+			ID:   types.CommentID(author),
+			Body: "an edited comment",
+		}); err != nil {
+			t.Fatalf("Update(): unexpected error: %v", err)
+		}
+	}
+
+	if len(store.Events) != 2 {
+		t.Fatalf("wanted `2` events; found `%d`", len(store.Events))
+	}
+	for i, e := range store.Events {
+		if e.Comment.Author != "author" {
+			t.Fatalf(
+				"events[%d].Comment.Author: wanted `author`; found `%s`",
+				i,
+				e.Comment.Author,
+			)
+		}
+	}
+}
+
+func TestCommentsModel_PutWebhook(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		admin     types.UserID
+		webhook   types.Webhook
+		wantedErr error
+	}{
+		{
+			name:    "every event",
+			admin:   "admin",
+			webhook: types.Webhook{URL: "https://example.org/hook"},
+		},
+		{
+			name:  "some events",
+			admin: "admin",
+			webhook: types.Webhook{
+				URL:    "http://localhost:8080/hook",
+				Events: []types.EventType{types.EventCommentDeleted},
+			},
+		},
+		{
+			name:      "relative url",
+			admin:     "admin",
+			webhook:   types.Webhook{URL: "/hook"},
+			wantedErr: types.ErrInvalidWebhook,
+		},
+		{
+			name:      "unsupported scheme",
+			admin:     "admin",
+			webhook:   types.Webhook{URL: "ftp://example.org/hook"},
+			wantedErr: types.ErrInvalidWebhook,
+		},
+		{
+			name:  "unknown event",
+			admin: "admin",
+			webhook: types.Webhook{
+				URL:    "https://example.org/hook",
+				Events: []types.EventType{"comment.liked"},
+			},
+			wantedErr: types.ErrInvalidWebhook,
+		},
+		{
+			name:      "moderator",
+			admin:     "moderator",
+			webhook:   types.Webhook{URL: "https://example.org/hook"},
+			wantedErr: ErrForbidden,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			model := CommentsModel{
+				Roles: testsupport.RolesStoreFake{
+					"admin":     types.RoleAdmin,
+					"moderator": types.RoleModerator,
+				},
+				WebhooksStore: testsupport.Webhooks(),
+				TimeFunc:      func() time.Time { return now },
+			}
+
+			created, err := model.PutWebhook(
+				testCase.admin,
+				&testCase.webhook,
+			)
+			if !errors.Is(err, testCase.wantedErr) {
+				t.Fatalf(
+					"PutWebhook(): wanted `%v`; found `%v`",
+					testCase.wantedErr,
+					err,
+				)
+			}
+			if err != nil {
+				return
+			}
+			if created.ID == "" || created.Secret == "" {
+				t.Fatalf("wanted generated ID and secret; found `%+v`", created)
+			}
+
+			webhooks, err := model.Webhooks(testCase.admin)
+			if err != nil {
+				t.Fatalf("Webhooks(): unexpected error: %v", err)
+			}
+			if len(webhooks) != 1 || webhooks[0].ID != created.ID {
+				t.Fatalf(
+					"Webhooks(): wanted `%s`; found `%+v`",
+					created.ID,
+					webhooks,
+				)
+			}
+			if webhooks[0].Secret != "" {
+				t.Fatal("Webhooks(): wanted secret to be left out")
+			}
+
+			if err := model.DeleteWebhook(
+				testCase.admin,
+				created.ID,
+			); err != nil {
+				t.Fatalf("DeleteWebhook(): unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+type webhookReplayerFake struct {
+	replayed []string
+}
+
+func (wrf *webhookReplayerFake) Replay(id string) error {
+	wrf.replayed = append(wrf.replayed, id)
+	return nil
+}
+
+func TestCommentsModel_ReplayDelivery(t *testing.T) {
+	var replayer webhookReplayerFake
+	model := CommentsModel{
+		Roles: testsupport.RolesStoreFake{
+			"admin":     types.RoleAdmin,
+			"moderator": types.RoleModerator,
+		},
+		WebhookReplayer: &replayer,
+	}
+	if err := model.ReplayDelivery(
+		"moderator",
+		"delivery",
+	); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("wanted `ErrForbidden`; found `%v`", err)
+	}
+	if err := model.ReplayDelivery("admin", "delivery"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(replayer.replayed) != 1 || replayer.replayed[0] != "delivery" {
+		t.Fatalf("wanted `[delivery]` replayed; found `%v`", replayer.replayed)
+	}
+}
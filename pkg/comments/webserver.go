@@ -9,7 +9,9 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/weberc2/comments/pkg/comments/events"
 	"github.com/weberc2/comments/pkg/comments/types"
 	pz "github.com/weberc2/httpeasy"
 )
@@ -21,6 +23,11 @@ type logging struct {
 	Error  string          `json:"error,omitempty"`
 }
 
+// threadDepthDefault is the number of levels of replies that the replies page
+// renders unless the request specifies a `depth`. Deeper replies are reachable
+// via "continue this thread" links.
+const threadDepthDefault = 6
+
 type WebServer struct {
 	LoginURL         string
 	RegisterURL      string
@@ -28,6 +35,27 @@ type WebServer struct {
 	BaseURL          string
 	Comments         CommentsModel
 	AuthCallbackPath string
+
+	// CSRF issues the CSRF tokens which are embedded in forms. If it's nil,
+	// forms are rendered without tokens.
+	CSRF *CSRF
+
+	// RateLimiter limits how often users may create, edit, and delete
+	// comments. If it's nil, there are no limits.
+	RateLimiter *RateLimiter
+
+	// UnsubscribeTokens checks the tokens in notification emails'
+	// unsubscribe links. If it's nil, every token is rejected.
+	UnsubscribeTokens *UnsubscribeTokens
+
+	// Bus carries comment events to the replies page's live updates. If it's
+	// nil, the replies page isn't updated live.
+	Bus *events.Bus
+
+	// EmbedOrigins are the origins (e.g., `https://blog.example.org`) which
+	// may embed the replies page (see `EmbedLoader()`). Other sites can't
+	// frame the embed mode.
+	EmbedOrigins []string
 }
 
 var repliesTemplate = html.Must(html.New("").Parse(`
@@ -41,7 +69,32 @@ var repliesTemplate = html.Must(html.New("").Parse(`
 			<span class="author">DELETED</span>
 			{{ end }}
 			<span class="date">{{.Created}}</p>
-			{{if eq .Author .User}}
+			<span class="score">{{.Score}} points</span>
+			<a class="permalink" href="{{.BaseURL}}/posts/{{.Post}}/comments/{{.ID}}">
+				permalink
+			</a>
+			{{if .Edited}}
+			<a class="edited" href="{{.BaseURL}}/posts/{{.Post}}/comments/{{.ID}}/revisions">
+				edited
+			</a>
+			{{end}}
+			{{if eq .Status "pending"}}
+			<span class="status">awaiting moderation</span>
+			{{else if eq .Status "rejected"}}
+			<span class="status">rejected</span>
+			{{end}}
+			{{if and .Moderator (eq .SpamVerdict "hold")}}
+			<span class="spam">held as possible spam: {{.SpamReason}}</span>
+			{{end}}
+			{{if and .User (not .Deleted)}}
+			<form class="vote" action="{{.BaseURL}}/posts/{{.Post}}/comments/{{.ID}}/vote" method="POST">
+				<input type="hidden" name="csrf" value="{{.CSRFToken}}">
+				<button name="vote" value="up">upvote</button>
+				<button name="vote" value="down">downvote</button>
+				<button name="vote" value="none">unvote</button>
+			</form>
+			{{end}}
+			{{if and .User (not .Deleted) (or (eq .Author .User) .Moderator)}}
 			<a href="{{.BaseURL}}/posts/{{.Post}}/comments/{{.ID}}/delete-confirm">
 				delete
 			</a>
@@ -49,6 +102,11 @@ var repliesTemplate = html.Must(html.New("").Parse(`
 				edit
 			</a>
 			{{end}}
+			{{if and .User (not .Deleted) (ne .Author .User)}}
+			<a href="{{.BaseURL}}/posts/{{.Post}}/comments/{{.ID}}/report">
+				report
+			</a>
+			{{end}}
 			{{/* if the user is logged in they can reply */}}
 			{{if and .User (not .Deleted) }}
 			<a href="{{.BaseURL}}/posts/{{.Post}}/comments/{{.ID}}/reply">
@@ -56,7 +114,9 @@ var repliesTemplate = html.Must(html.New("").Parse(`
 			</a>
 			{{end}}
 			{{ if not .Deleted }}
-			<p class="body">{{.Body}}</p>
+			<div class="body">{{.BodyHTML}}</div>
+			{{ else if .Removed }}
+			<p class="body">REMOVED BY A MODERATOR</p>
 			{{ else }}
 			<p class="body">DELETED</p>
 			{{end}}
@@ -64,13 +124,89 @@ var repliesTemplate = html.Must(html.New("").Parse(`
 			{{- range .Children}}
 				{{template "comment" .}}
 			{{- end}}
+			{{- if .HiddenReplies}}
+				<a class="continue-thread" href="{{.BaseURL}}/posts/{{.Post}}/comments/{{.ID}}/replies?sort={{.Sort}}">
+					continue this thread ({{.HiddenReplies}} more)
+				</a>
+			{{- end}}
 			</div>
 		</div>
 	</div>
 {{end}}
 
+{{- define "live"}}
+{{- if .EventsURL}}
+<script>
+// Live updates: new, edited and deleted comments are applied to the page as
+// they happen. Without JavaScript (or EventSource), the page still works; it
+// just needs to be reloaded.
+(function () {
+	var replies = document.getElementById("replies");
+	if (!window.EventSource || !replies) {
+		return;
+	}
+	var parent = replies.getAttribute("data-parent");
+	var sort = replies.getAttribute("data-sort");
+
+	function element(html) {
+		var container = document.createElement("div");
+		container.innerHTML = html;
+		return container.firstElementChild;
+	}
+
+	function insert(comment, parentID) {
+		if (parentID !== parent) {
+			var p = document.getElementById(parentID);
+			var children = p && p.querySelector(".comment-children");
+			if (children) {
+				children.appendChild(comment);
+			}
+			return;
+		}
+		if (sort === "newest") {
+			replies.insertBefore(comment, replies.querySelector(":scope > div"));
+		} else if (!replies.querySelector(".load-more")) {
+			// otherwise the comment belongs on a later page
+			replies.appendChild(comment);
+		}
+	}
+
+	function apply(event) {
+		var data = JSON.parse(event.data);
+		var old = document.getElementById(data.id);
+		if (!data.html) {
+			if (old) {
+				old.parentNode.removeChild(old);
+			}
+			return;
+		}
+		var comment = element(data.html);
+		if (!old) {
+			insert(comment, data.parent);
+			return;
+		}
+		// keep the replies which are already on the page
+		var children = comment.querySelector(".comment-children");
+		children.parentNode.replaceChild(
+			old.querySelector(".comment-children"),
+			children
+		);
+		old.parentNode.replaceChild(comment, old);
+	}
+
+	var source = new EventSource(replies.getAttribute("data-events"));
+	["comment.created", "comment.updated", "comment.deleted"].forEach(
+		function (type) { source.addEventListener(type, apply); }
+	);
+})();
+</script>
+{{- end}}
+{{- end}}
+
 <html>
 <head>
+<link rel="alternate" type="application/atom+xml" href="{{.BaseURL}}/posts/{{.Post}}/comments.atom">
+<link rel="alternate" type="application/rss+xml" href="{{.BaseURL}}/posts/{{.Post}}/comments.rss">
 <style>
 .comment {
 	border: 1px solid black;
@@ -87,9 +223,27 @@ var repliesTemplate = html.Must(html.New("").Parse(`
 	Reply To Post
 </a>
 <h1>Replies</h1>
-<div id=replies>
+<div class="sorts">
+{{- range .Sorts}}
+	{{- if .Current}}
+	<span class="sort">{{.Label}}</span>
+	{{- else}}
+	<a class="sort" href="{{.URL}}">{{.Label}}</a>
+	{{- end}}
+{{- end}}
+</div>
+<div id=replies data-parent="{{.Parent}}" data-sort="{{.Sort}}" data-events="{{.EventsURL}}">
 {{if .User}}
     {{.User}} - <a href="{{.LogoutURL}}">logout</a>
+	<a href="{{.BaseURL}}/notifications">notifications</a>
+	<form class="subscription" action="{{.BaseURL}}/posts/{{.Post}}/comments/{{.Thread}}/subscription" method="POST">
+		<input type="hidden" name="csrf" value="{{.CSRFToken}}">
+		{{if .Subscribed}}
+		<button name="action" value="unsubscribe">unfollow this thread</button>
+		{{else}}
+		<button name="action" value="subscribe">follow this thread</button>
+		{{end}}
+	</form>
 {{else}}
     <a href="{{.LoginURL}}">login</a>
 	<a href="{{.RegisterURL}}">register</a>
@@ -98,18 +252,34 @@ var repliesTemplate = html.Must(html.New("").Parse(`
 {{range .Replies}}
 	{{template "comment" .}}
 {{end}}
+{{if .Next}}
+<a class="load-more" href="{{.Next}}">load more</a>
+{{end}}
 </div>
+{{template "live" .}}
 </body>
 </html>`))
 
 func (ws *WebServer) Replies(r pz.Request) pz.Response {
 	post := types.PostID(r.Vars["post-id"])
-	parent := types.CommentID(r.Vars["parent-id"])
+	parent := types.CommentID(r.Vars["comment-id"])
 	user := types.UserID(r.Headers.Get("User"))
-	if parent == "toplevel" {
-		parent = "" // this tells the CommentStore to fetch toplevel replies.
+	query, err := parseRepliesQuery(r)
+	if err != nil {
+		return pz.BadRequest(nil, &logging{
+			Post:   post,
+			Parent: parent,
+			User:   user,
+			Error:  err.Error(),
+		})
 	}
-	comments, err := ws.Comments.Replies(post, parent)
+	parent = query.Parent // "toplevel" is translated to ""
+	query.Viewer = user
+	if queryValues(r).Get("depth") == "" {
+		query.MaxDepth = threadDepthDefault
+	}
+
+	page, err := ws.Comments.RepliesPage(query)
 	if err != nil {
 		if errors.Is(err, types.ErrCommentNotFound) {
 			return pz.NotFound(nil, &logging{
@@ -128,16 +298,83 @@ func (ws *WebServer) Replies(r pz.Request) pz.Response {
 		})
 	}
 
+	moderator, err := ws.Comments.IsModerator(user)
+	if err != nil {
+		return pz.InternalServerError(&logging{
+			Post:   post,
+			Parent: parent,
+			User:   user,
+			Error:  err.Error(),
+		})
+	}
+
+	subscribed, err := ws.Comments.Subscribed(user, post, parent)
+	if err != nil {
+		return pz.InternalServerError(&logging{
+			Post:   post,
+			Parent: parent,
+			User:   user,
+			Error:  err.Error(),
+		})
+	}
+
+	thread := parent
+	if thread == "" {
+		thread = "toplevel"
+	}
+	repliesPath := fmt.Sprintf("/posts/%s/comments/%s/replies", post, thread)
+
+	var next string
+	if page.Next != nil {
+		next = pageURL(join(ws.BaseURL, repliesPath), r, page.Next)
+	}
+
+	var eventsURL string
+	if ws.Bus != nil {
+		eventsURL = join(ws.BaseURL, fmt.Sprintf("/posts/%s/events", post))
+	}
+
+	sorts := make([]sortLink, len(sortLabels))
+	for i, l := range sortLabels {
+		sorts[i] = sortLink{
+			Label: l.label,
+			URL: fmt.Sprintf(
+				"%s?%s",
+				join(ws.BaseURL, repliesPath),
+				url.Values{"sort": []string{string(l.sort)}}.Encode(),
+			),
+			Current: l.sort == query.Sort,
+		}
+	}
+
+	// the embed mode may only be framed by the allowed origins, and the full
+	// page only by the service itself
+	template, csp, embedOrigin := repliesTemplate, frameAncestorsSelf, ""
+	if embedding(r) {
+		template = embedTemplate
+		csp = ws.frameAncestors()
+		embedOrigin = ws.embedOrigin(r)
+	}
+	headers := http.Header{"Content-Security-Policy": []string{csp}}
+
 	return pz.Ok(
-		pz.HTMLTemplate(repliesTemplate, struct {
+		pz.HTMLTemplate(template, struct {
 			LoginURL    string          `json:"loginURL"`
 			LogoutURL   string          `json:"logoutURL"`
 			RegisterURL string          `json:"registerURL"`
 			BaseURL     string          `json:"baseURL"`
 			Post        types.PostID    `json:"post"`
 			Parent      types.CommentID `json:"parent"`
+			Thread      types.CommentID `json:"thread"`
 			Replies     []*reply        `json:"replies"`
 			User        types.UserID    `json:"user"`
+			Subscribed  bool            `json:"subscribed"`
+			CSRFToken   string          `json:"-"`
+			Next        string          `json:"next,omitempty"`
+			Sorts       []sortLink      `json:"sorts"`
+			Sort        types.Sort      `json:"sort"`
+			EventsURL   string          `json:"eventsURL,omitempty"`
+			EmbedOrigin string          `json:"embedOrigin,omitempty"`
 		}{
 			LoginURL: fmt.Sprintf(
 				"%s?%s",
@@ -147,16 +384,9 @@ func (ws *WebServer) Replies(r pz.Request) pz.Response {
 						ws.BaseURL,
 						ws.AuthCallbackPath,
 					)},
-					"redirect": []string{fmt.Sprintf(
-						"/posts/%s/comments/%s/replies",
-						post,
-						func() types.CommentID {
-							if parent == "" {
-								return "toplevel"
-							}
-							return parent
-						}(),
-					)},
+					"redirect": []string{
+						"/" + redirectPath(repliesPath, post),
+					},
 				}.Encode(),
 			),
 			LogoutURL:   join(ws.BaseURL, ws.LogoutPath),
@@ -164,13 +394,175 @@ func (ws *WebServer) Replies(r pz.Request) pz.Response {
 			BaseURL:     ws.BaseURL,
 			Post:        post,
 			Parent:      parent,
+			Thread:      thread,
 			User:        user,
+			Subscribed:  subscribed,
+			CSRFToken:   ws.CSRF.Token(r),
 			Replies: replies(
-				comments,
-				&globals{BaseURL: ws.BaseURL, User: user},
+				Tree(page.Comments, parent),
+				&globals{
+					BaseURL:   ws.BaseURL,
+					User:      user,
+					Moderator: moderator,
+					Sort:      query.Sort,
+					CSRFToken: ws.CSRF.Token(r),
+				},
 			),
+			Next:        next,
+			Sorts:       sorts,
+			Sort:        query.Sort,
+			EventsURL:   eventsURL,
+			EmbedOrigin: embedOrigin,
 		}),
 		&logging{Post: post, Parent: parent, User: user},
+	).WithHeaders(headers)
+}
+
+// permalinkTemplate reuses the replies template's "comment" definition for
+// the comment, its ancestors, and its replies.
+var permalinkTemplate = html.Must(html.Must(repliesTemplate.Clone()).Parse(`
+<html>
+<head>
+<style>
+.comment {
+	border: 1px solid black;
+	margin: 1em 0em 1em 1em;
+	padding: 1em 0em 1em 1em;
+}
+.comment-children {
+	padding-left: 1em;
+}
+</style>
+</head>
+<body>
+<a href="{{.BaseURL}}/posts/{{.Post}}/comments/toplevel/replies">
+	View All Comments
+</a>
+<div id=replies>
+{{if .User}}
+    {{.User}} - <a href="{{.LogoutURL}}">logout</a>
+{{else}}
+    <a href="{{.LoginURL}}">login</a>
+	<a href="{{.RegisterURL}}">register</a>
+{{end}}
+
+{{if .Ancestors}}
+<div class="ancestors">
+{{range .Ancestors}}
+	{{template "comment" .}}
+{{end}}
+</div>
+{{end}}
+<div class="permalink-comment">
+	{{template "comment" .Comment}}
+</div>
+{{if .Next}}
+<a class="load-more" href="{{.Next}}">load more</a>
+{{end}}
+</div>
+</body>
+</html>`))
+
+// Permalink renders a single comment along with its ancestors (for context)
+// and its replies.
+func (ws *WebServer) Permalink(r pz.Request) pz.Response {
+	post := types.PostID(r.Vars["post-id"])
+	comment := types.CommentID(r.Vars["comment-id"])
+	user := types.UserID(r.Headers.Get("User"))
+	query, err := parseRepliesQuery(r)
+	if err != nil {
+		return pz.BadRequest(nil, &logging{
+			Post:   post,
+			Parent: comment,
+			User:   user,
+			Error:  err.Error(),
+		})
+	}
+	query.Viewer = user
+	if queryValues(r).Get("depth") == "" {
+		query.MaxDepth = threadDepthDefault
+	}
+
+	thread, err := ws.Comments.Thread(query)
+	if err != nil {
+		return pz.HandleError("fetching comment", err, &logging{
+			Post:   post,
+			Parent: comment,
+			User:   user,
+			Error:  err.Error(),
+		})
+	}
+
+	moderator, err := ws.Comments.IsModerator(user)
+	if err != nil {
+		return pz.InternalServerError(&logging{
+			Post:   post,
+			Parent: comment,
+			User:   user,
+			Error:  err.Error(),
+		})
+	}
+
+	permalinkPath := fmt.Sprintf("/posts/%s/comments/%s", post, comment)
+	var next string
+	if thread.Replies.Next != nil {
+		next = pageURL(join(ws.BaseURL, permalinkPath), r, thread.Replies.Next)
+	}
+
+	g := globals{
+		BaseURL:   ws.BaseURL,
+		User:      user,
+		Moderator: moderator,
+		Sort:      query.Sort,
+		CSRFToken: ws.CSRF.Token(r),
+	}
+	ancestors := make([]*reply, len(thread.Ancestors))
+	for i, a := range thread.Ancestors {
+		ancestors[i] = &reply{globals: &g, Comment: a}
+	}
+
+	return pz.Ok(
+		pz.HTMLTemplate(permalinkTemplate, struct {
+			LoginURL    string       `json:"loginURL"`
+			LogoutURL   string       `json:"logoutURL"`
+			RegisterURL string       `json:"registerURL"`
+			BaseURL     string       `json:"baseURL"`
+			Post        types.PostID `json:"post"`
+			Ancestors   []*reply     `json:"ancestors"`
+			Comment     *reply       `json:"comment"`
+			User        types.UserID `json:"user"`
+			Next        string       `json:"next,omitempty"`
+		}{
+			LoginURL: fmt.Sprintf(
+				"%s?%s",
+				ws.LoginURL,
+				url.Values{
+					"callback": []string{join(
+						ws.BaseURL,
+						ws.AuthCallbackPath,
+					)},
+					"redirect": []string{
+						"/" + redirectPath(permalinkPath, post),
+					},
+				}.Encode(),
+			),
+			LogoutURL:   join(ws.BaseURL, ws.LogoutPath),
+			RegisterURL: ws.RegisterURL,
+			BaseURL:     ws.BaseURL,
+			Post:        post,
+			Ancestors:   ancestors,
+			Comment: &reply{
+				globals: &g,
+				Comment: thread.Comment,
+				Children: replies(
+					Tree(thread.Replies.Comments, comment),
+					&g,
+				),
+			},
+			User: user,
+			Next: next,
+		}),
+		&logging{Post: post, Parent: comment, User: user},
 	)
 }
 
@@ -182,9 +574,41 @@ func join(lhs, rhs string) string {
 	)
 }
 
+// sortLabels lists the sort orders offered on the replies page, in the order
+// they're displayed.
+var sortLabels = []struct {
+	sort  types.Sort
+	label string
+}{
+	{types.SortOldest, "oldest"},
+	{types.SortNewest, "newest"},
+	{types.SortReplies, "most replies"},
+	{types.SortTop, "top"},
+}
+
+type sortLink struct {
+	Label   string `json:"label"`
+	URL     string `json:"url"`
+	Current bool   `json:"current"`
+}
+
+// rateLimited turns a re-rendered form into a `429 Too Many Requests`
+// response. Error responses (e.g., if the form couldn't be rendered) are
+// returned unchanged.
+func rateLimited(form pz.Response, retryAfter time.Duration) pz.Response {
+	if form.Status != http.StatusOK {
+		return form
+	}
+	form.Status = http.StatusTooManyRequests
+	return form.WithHeaders(retryAfterHeader(retryAfter))
+}
+
 type globals struct {
-	BaseURL string
-	User    types.UserID
+	BaseURL   string
+	User      types.UserID
+	Moderator bool
+	Sort      types.Sort
+	CSRFToken string
 }
 
 type reply struct {
@@ -193,41 +617,29 @@ type reply struct {
 	Children []*reply
 }
 
-func replies(comments []*types.Comment, globals *globals) []*reply {
-	// values is just a buffer so we don't have to allocate O(n) replies.
-	values := make([]reply, len(comments)+1)
-
-	// repliesByID allows us to look up a reply by the id of its comment. We'll
-	// put one "root" reply (whose comment is nil) in the map for toplevel
-	// comments (comments whose `Parent` field is `nil`).
-	repliesByID := map[types.CommentID]*reply{"": &values[0]}
-
-	// insert a reply into `repliesByID` for each input comment
-	for i, c := range comments {
-		r := &values[i+1]
-		r.Comment = c
-		r.globals = globals
-		repliesByID[c.ID] = r
-	}
+// BodyHTML marks the comment's rendered body as safe for the template. It's
+// safe because `markdown.Render()` only emits allowlisted elements and escapes
+// all text.
+func (r *reply) BodyHTML() html.HTML { return html.HTML(r.Comment.HTML) }
 
-	// now that there is a reply for each comment in `repliesByID`, loop over
-	// the comments again, fetch the reply corresponding to the current comment
-	// and the reply corresponding to the comment's parent and append the
-	// current comment's reply to the parent comment's reply's list of
-	// children.
-	for _, c := range comments {
-		p := repliesByID[c.Parent]
-		p.Children = append(p.Children, repliesByID[c.ID])
+// replies converts trees of comments into trees of replies for rendering.
+func replies(nodes []*Node, globals *globals) []*reply {
+	out := make([]*reply, len(nodes))
+	for i, n := range nodes {
+		out[i] = &reply{
+			globals:  globals,
+			Comment:  n.Comment,
+			Children: replies(n.Children, globals),
+		}
 	}
-
-	// return the root reply's children
-	return values[0].Children
+	return out
 }
 
 var deleteConfirmationTemplate = html.Must(html.New("").Parse(`<html>
 <head></head>
 <body>
 <h1>Confirm Comment Deletion</h1>
+{{if .Notice}}<p class="notice">{{.Notice}}</p>{{end}}
 <div id="comment">
     {{.Comment.Body}}
 </div>
@@ -240,29 +652,45 @@ var deleteConfirmationTemplate = html.Must(html.New("").Parse(`<html>
     <a href="{{.BaseURL}}/posts/{{.Post}}/comments/{{.Comment.ID}}">Cancel</a>
 </div>
 <div id="delete">
-    <a href="{{.BaseURL}}/posts/{{.Post}}/comments/{{.Comment.ID}}/delete?redirect=posts/{{.Post}}/comments/toplevel/replies">
-        Delete
-    </a>
+    <form action="{{.BaseURL}}/posts/{{.Post}}/comments/{{.Comment.ID}}/delete" method="POST">
+        <input type="hidden" name="csrf" value="{{.CSRFToken}}">
+        <input type="hidden" name="redirect" value="posts/{{.Post}}/comments/toplevel/replies">
+        <input type="submit" value="Delete">
+    </form>
 </div>
 </div>
 </body>
 </html>`))
 
 func (ws *WebServer) DeleteConfirm(r pz.Request) pz.Response {
+	return ws.deleteConfirm(r, "")
+}
+
+// deleteConfirm renders the delete confirmation form with an optional notice
+// for the user.
+func (ws *WebServer) deleteConfirm(r pz.Request, notice string) pz.Response {
 	context := struct {
-		BaseURL string         `json:"baseURL"`
-		User    types.UserID   `json:"user"`
-		Post    types.PostID   `json:"post"`
-		Comment *types.Comment `json:"comment"`
-		Error   string         `json:"error,omitempty"`
+		BaseURL   string         `json:"baseURL"`
+		User      types.UserID   `json:"user"`
+		Post      types.PostID   `json:"post"`
+		Comment   *types.Comment `json:"comment"`
+		Notice    string         `json:"notice,omitempty"`
+		CSRFToken string         `json:"-"`
+		Error     string         `json:"error,omitempty"`
 	}{
-		BaseURL: ws.BaseURL,
-		Post:    types.PostID(r.Vars["post-id"]),
-		Comment: &types.Comment{ID: types.CommentID(r.Vars["comment-id"])},
-		User:    types.UserID(r.Headers.Get("User")), // empty if unauthorized
+		BaseURL:   ws.BaseURL,
+		Post:      types.PostID(r.Vars["post-id"]),
+		Comment:   &types.Comment{ID: types.CommentID(r.Vars["comment-id"])},
+		User:      types.UserID(r.Headers.Get("User")), // empty if unauthorized
+		Notice:    notice,
+		CSRFToken: ws.CSRF.Token(r),
 	}
 
-	comment, err := ws.Comments.Comment(context.Post, context.Comment.ID)
+	comment, err := ws.Comments.Comment(
+		context.User,
+		context.Post,
+		context.Comment.ID,
+	)
 	if err != nil {
 		if errors.Is(err, types.ErrCommentNotFound) {
 			context.Error = err.Error()
@@ -284,35 +712,48 @@ func (ws *WebServer) Delete(r pz.Request) pz.Response {
 		Redirect string          `json:"redirect"`
 		Error    string          `json:"error,omitempty"`
 	}{
-		Post:     types.PostID(r.Vars["post-id"]),
-		Comment:  types.CommentID(r.Vars["comment-id"]),
-		User:     types.UserID(r.Headers.Get("User")),
-		Redirect: ws.BaseURL + "/" + r.URL.Query().Get("redirect"),
+		Post:    types.PostID(r.Vars["post-id"]),
+		Comment: types.CommentID(r.Vars["comment-id"]),
+		User:    types.UserID(r.Headers.Get("User")),
 	}
 
-	comment, err := ws.Comments.Comment(context.Post, context.Comment)
+	// limitreader = mitigate dos attack
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, 2056))
 	if err != nil {
-		return pz.HandleError("fetching comment", err, &context)
+		context.Message = "reading request body"
+		context.Error = err.Error()
+		return pz.InternalServerError(&context)
 	}
 
-	if comment.Author != context.User {
-		context.Message = "authorizing user"
-		context.Error = "user is not comment author"
-		return pz.Unauthorized(nil, &context)
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		context.Message = "parsing form values"
+		context.Error = err.Error()
+		return pz.BadRequest(nil, &context)
 	}
+	context.Redirect = ws.redirectURL(values.Get("redirect"), context.Post)
 
-	if err := ws.Comments.Delete(context.Post, context.Comment); err != nil {
-		return pz.HandleError("deleting comment", err, &context)
+	retryAfter, err := ws.RateLimiter.Allow(types.ActionDelete, r)
+	if err != nil {
+		return pz.HandleError("checking rate limit", err, &context)
+	}
+	if retryAfter > 0 {
+		return rateLimited(
+			ws.deleteConfirm(r, rateLimitNotice(retryAfter)),
+			retryAfter,
+		)
 	}
 
-	if _, err := url.Parse(context.Redirect); err != nil {
-		context.Message = "error parsing redirect; redirecting to `BaseURL`"
-		context.Error = err.Error()
-		return pz.TemporaryRedirect(context.Redirect, &context)
+	if err := ws.Comments.Delete(
+		context.User,
+		context.Post,
+		context.Comment,
+	); err != nil {
+		return pz.HandleError("deleting comment", err, &context)
 	}
 
 	context.Message = "successfully deleted comment"
-	return pz.TemporaryRedirect(context.Redirect, &context)
+	return pz.SeeOther(context.Redirect, &context)
 }
 
 var replyTemplate = html.Must(html.New("").Parse(`<html>
@@ -322,8 +763,10 @@ var replyTemplate = html.Must(html.New("").Parse(`<html>
 	{{if .Comment.Body}}{{.Comment.Body}}{{else}}&lt;toplevel&gt;{{end}}
 </div>
 <div id="form">
+{{if .Notice}}<p class="notice">{{.Notice}}</p>{{end}}
 <form action="{{.BaseURL}}/posts/{{.Comment.Post}}/comments/{{.Comment.ID}}/reply" method="POST">
-	<textarea name="body"></textarea>
+	<input type="hidden" name="csrf" value="{{.CSRFToken}}">
+	<textarea name="body">{{.Body}}</textarea>
 	<input type="submit" value="Submit">
 </form>
 </div>
@@ -331,21 +774,34 @@ var replyTemplate = html.Must(html.New("").Parse(`<html>
 </html>`))
 
 func (ws *WebServer) ReplyForm(r pz.Request) pz.Response {
+	return ws.replyForm(r, "", "")
+}
+
+// replyForm renders the reply form with an optional notice for the user and
+// the body of the reply so far.
+func (ws *WebServer) replyForm(r pz.Request, notice, body string) pz.Response {
 	context := struct {
-		Message string        `json:"message"`
-		BaseURL string        `json:"baseURL"`
-		Comment types.Comment `json:"comment"`
-		Error   string        `json:"error,omitempty"`
+		Message   string        `json:"message"`
+		BaseURL   string        `json:"baseURL"`
+		Comment   types.Comment `json:"comment"`
+		Notice    string        `json:"notice,omitempty"`
+		Body      string        `json:"-"`
+		CSRFToken string        `json:"-"`
+		Error     string        `json:"error,omitempty"`
 	}{
 		BaseURL: ws.BaseURL,
 		Comment: types.Comment{
 			Post: types.PostID(r.Vars["post-id"]),
 			ID:   types.CommentID(r.Vars["comment-id"]),
 		},
+		Notice:    notice,
+		Body:      body,
+		CSRFToken: ws.CSRF.Token(r),
 	}
 
 	if context.Comment.ID != "toplevel" {
 		comment, err := ws.Comments.Comment(
+			types.UserID(r.Headers.Get("User")),
 			context.Comment.Post,
 			context.Comment.ID,
 		)
@@ -393,52 +849,153 @@ func (ws *WebServer) Reply(r pz.Request) pz.Response {
 		return pz.BadRequest(nil, &context)
 	}
 
-	c, err := ws.Comments.Put(&types.Comment{
+	retryAfter, err := ws.RateLimiter.Allow(types.ActionCreate, r)
+	if err != nil {
+		return pz.HandleError("checking rate limit", err, &context)
+	}
+	if retryAfter > 0 {
+		return rateLimited(
+			ws.replyForm(r, rateLimitNotice(retryAfter), values.Get("body")),
+			retryAfter,
+		)
+	}
+
+	c, err := ws.Comments.PutSubmission(submission(r, &types.Comment{
 		Post:   context.Post,
 		Parent: context.Comment,
 		Author: context.Author,
 		Body:   values.Get("body"),
-	})
+	}))
+	if errors.Is(err, types.ErrSpam) {
+		form := ws.replyForm(r, spamNotice, values.Get("body"))
+		if form.Status == http.StatusOK {
+			form.Status = http.StatusBadRequest
+		}
+		return form
+	}
 	if err != nil {
 		return pz.HandleError("creating comment", err, &context)
 	}
 
-	context.Redirect = fmt.Sprintf(
-		"%s/posts/%s/comments/toplevel/replies#%s",
-		ws.BaseURL,
+	context.Redirect = ws.redirectURL(
+		fmt.Sprintf("posts/%s/comments/toplevel/replies#%s", context.Post, c.ID),
 		context.Post,
-		c.ID,
 	)
 	context.Message = "successfully created comment"
 	return pz.SeeOther(context.Redirect, &context)
 }
 
+// votes maps the values of the vote form's `vote` field to vote values. The
+// `none` value removes the user's vote.
+var votes = map[string]int{"up": types.Upvote, "down": types.Downvote}
+
+func (ws *WebServer) Vote(r pz.Request) pz.Response {
+	context := struct {
+		Message  string          `json:"message,omitempty"`
+		Post     types.PostID    `json:"post"`
+		Comment  types.CommentID `json:"comment"`
+		User     types.UserID    `json:"user"`
+		Vote     string          `json:"vote"`
+		Redirect string          `json:"redirect,omitempty"`
+		Error    string          `json:"error,omitempty"`
+	}{
+		Post:    types.PostID(r.Vars["post-id"]),
+		Comment: types.CommentID(r.Vars["comment-id"]),
+		User:    types.UserID(r.Headers.Get("User")),
+	}
+
+	// limitreader = mitigate dos attack
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, 2056))
+	if err != nil {
+		context.Message = "reading request body"
+		context.Error = err.Error()
+		return pz.InternalServerError(&context)
+	}
+
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		context.Message = "parsing form values"
+		context.Error = err.Error()
+		return pz.BadRequest(nil, &context)
+	}
+
+	context.Vote = values.Get("vote")
+	if context.Vote == "none" {
+		err = ws.Comments.Unvote(context.Post, context.Comment, context.User)
+		if errors.Is(err, types.ErrVoteNotFound) {
+			err = nil // there's nothing to remove
+		}
+	} else {
+		value, found := votes[context.Vote]
+		if !found {
+			context.Message = "parsing vote"
+			context.Error = types.ErrInvalidVote.Error()
+			return pz.BadRequest(nil, &context)
+		}
+		err = ws.Comments.Vote(&types.Vote{
+			Post:    context.Post,
+			Comment: context.Comment,
+			User:    context.User,
+			Value:   value,
+		})
+	}
+	if err != nil {
+		return pz.HandleError("voting on comment", err, &context)
+	}
+
+	context.Redirect = ws.redirectURL(
+		fmt.Sprintf(
+			"posts/%s/comments/toplevel/replies#%s",
+			context.Post,
+			context.Comment,
+		),
+		context.Post,
+	)
+	context.Message = "successfully voted on comment"
+	return pz.SeeOther(context.Redirect, &context)
+}
+
 var editTemplate = html.Must(html.New("").Parse(`<html>
 <head></head>
 <body>
 	<p>{{.Comment.Body}}</p>
+	{{if .Notice}}<p class="notice">{{.Notice}}</p>{{end}}
 	<form action="{{.BaseURL}}/posts/{{.Comment.Post}}/comments/{{.Comment.ID}}/edit" method="POST">
-		<textarea name="body">{{.Comment.Body}}</textarea>
+		<input type="hidden" name="csrf" value="{{.CSRFToken}}">
+		<textarea name="body">{{.Body}}</textarea>
 		<input type="submit" value="Submit">
 	</form>
 </body>
 </html>`))
 
 func (ws *WebServer) EditForm(r pz.Request) pz.Response {
+	return ws.editForm(r, "", "")
+}
+
+// editForm renders the edit form with an optional notice for the user. The
+// form is filled in with `body` or, if it's empty, the comment's body.
+func (ws *WebServer) editForm(r pz.Request, notice, body string) pz.Response {
 	context := struct {
-		Message string        `json:"message"`
-		BaseURL string        `json:"baseURL"`
-		Comment types.Comment `json:"comment"`
-		Error   string        `json:"error,omitempty"`
+		Message   string        `json:"message"`
+		BaseURL   string        `json:"baseURL"`
+		Comment   types.Comment `json:"comment"`
+		Notice    string        `json:"notice,omitempty"`
+		Body      string        `json:"-"`
+		CSRFToken string        `json:"-"`
+		Error     string        `json:"error,omitempty"`
 	}{
 		BaseURL: ws.BaseURL,
 		Comment: types.Comment{
 			Post: types.PostID(r.Vars["post-id"]),
 			ID:   types.CommentID(r.Vars["comment-id"]),
 		},
+		Notice:    notice,
+		Body:      body,
+		CSRFToken: ws.CSRF.Token(r),
 	}
 
 	comment, err := ws.Comments.Comment(
+		types.UserID(r.Headers.Get("User")),
 		context.Comment.Post,
 		context.Comment.ID,
 	)
@@ -448,6 +1005,9 @@ func (ws *WebServer) EditForm(r pz.Request) pz.Response {
 		return pz.HandleError("fetching comment", err, &context)
 	}
 	context.Comment = *comment
+	if context.Body == "" {
+		context.Body = comment.Body
+	}
 
 	return pz.Ok(pz.HTMLTemplate(editTemplate, &context), &context)
 }
@@ -491,23 +1051,501 @@ func (ws *WebServer) Edit(r pz.Request) pz.Response {
 	}
 
 	context.Body = values.Get("body")
-	if err := ws.Comments.Update(&context.CommentUpdate); err != nil {
+	retryAfter, err := ws.RateLimiter.Allow(types.ActionEdit, r)
+	if err != nil {
 		context.Error = err.Error()
-		return pz.HandleError("updating comment", err, &context)
+		return pz.HandleError("checking rate limit", err, &context)
+	}
+	if retryAfter > 0 {
+		return rateLimited(
+			ws.editForm(r, rateLimitNotice(retryAfter), context.Body),
+			retryAfter,
+		)
 	}
 
-	context.Message = "successfully updated comment"
+	err = ws.Comments.UpdateSubmission(
+		types.UserID(r.Headers.Get("User")),
+		&context.CommentUpdate,
+		submission(r, nil),
+	)
+	if errors.Is(err, types.ErrSpam) {
+		form := ws.editForm(r, editSpamNotice, context.Body)
+		if form.Status == http.StatusOK {
+			form.Status = http.StatusBadRequest
+		}
+		return form
+	}
+	if err != nil {
+		context.Error = err.Error()
+		return pz.HandleError("updating comment", err, &context)
+	}
+
+	context.Message = "successfully updated comment"
 	return pz.SeeOther(
-		fmt.Sprintf(
-			"%s/posts/%s/comments/toplevel/replies#%s",
-			ws.BaseURL,
+		ws.redirectURL(
+			fmt.Sprintf(
+				"posts/%s/comments/toplevel/replies#%s",
+				context.Post,
+				context.ID,
+			),
 			context.Post,
-			context.ID,
 		),
 		&context,
 	)
 }
 
+var revisionsTemplate = html.Must(html.New("").Parse(`<html>
+<head></head>
+<body>
+<h1>Edit History</h1>
+<a href="{{.BaseURL}}/posts/{{.Post}}/comments/toplevel/replies#{{.Comment}}">
+	back to comment
+</a>
+{{range .Revisions}}
+<div class="revision">
+	<span class="date">{{.Created}}</span>
+	<span class="editor">edited by {{.Editor}} at {{.Edited}}</span>
+	<p class="body">{{.Body}}</p>
+</div>
+{{else}}
+<p>This comment hasn't been edited.</p>
+{{end}}
+</body>
+</html>`))
+
+// Revisions renders a comment's edit history.
+func (ws *WebServer) Revisions(r pz.Request) pz.Response {
+	context := struct {
+		BaseURL   string            `json:"baseURL"`
+		Post      types.PostID      `json:"post"`
+		Comment   types.CommentID   `json:"comment"`
+		User      types.UserID      `json:"user,omitempty"`
+		Revisions []*types.Revision `json:"-"`
+		Error     string            `json:"error,omitempty"`
+	}{
+		BaseURL: ws.BaseURL,
+		Post:    types.PostID(r.Vars["post-id"]),
+		Comment: types.CommentID(r.Vars["comment-id"]),
+		User:    types.UserID(r.Headers.Get("User")), // empty if anonymous
+	}
+
+	revisions, err := ws.Comments.Revisions(
+		context.User,
+		context.Post,
+		context.Comment,
+	)
+	if err != nil {
+		context.Error = err.Error()
+		return pz.HandleError("fetching revisions", err, &context)
+	}
+	context.Revisions = revisions
+	return pz.Ok(pz.HTMLTemplate(revisionsTemplate, &context), &context)
+}
+
+var reportTemplate = html.Must(html.New("").Parse(`<html>
+<head></head>
+<body>
+	<h1>Report Comment</h1>
+	<p>{{.Comment.Body}}</p>
+	{{if .Notice}}<p class="notice">{{.Notice}}</p>{{end}}
+	<form action="{{.BaseURL}}/posts/{{.Comment.Post}}/comments/{{.Comment.ID}}/report" method="POST">
+		<input type="hidden" name="csrf" value="{{.CSRFToken}}">
+		<label for="reason">Why should a moderator review this comment?</label>
+		<textarea id="reason" name="reason">{{.Reason}}</textarea>
+		<input type="submit" value="Report">
+	</form>
+	<a href="{{.BaseURL}}/posts/{{.Comment.Post}}/comments/{{.Comment.ID}}">Cancel</a>
+</body>
+</html>`))
+
+func (ws *WebServer) ReportForm(r pz.Request) pz.Response {
+	return ws.reportForm(r, "", "")
+}
+
+// reportForm renders the report form with an optional notice for the user.
+// The form is filled in with `reason`.
+func (ws *WebServer) reportForm(
+	r pz.Request,
+	notice string,
+	reason string,
+) pz.Response {
+	context := struct {
+		Message   string        `json:"message"`
+		BaseURL   string        `json:"baseURL"`
+		Comment   types.Comment `json:"comment"`
+		Notice    string        `json:"notice,omitempty"`
+		Reason    string        `json:"-"`
+		CSRFToken string        `json:"-"`
+		Error     string        `json:"error,omitempty"`
+	}{
+		BaseURL: ws.BaseURL,
+		Comment: types.Comment{
+			Post: types.PostID(r.Vars["post-id"]),
+			ID:   types.CommentID(r.Vars["comment-id"]),
+		},
+		Notice:    notice,
+		Reason:    reason,
+		CSRFToken: ws.CSRF.Token(r),
+	}
+
+	comment, err := ws.Comments.Comment(
+		types.UserID(r.Headers.Get("User")),
+		context.Comment.Post,
+		context.Comment.ID,
+	)
+	if err != nil {
+		context.Message = "fetching comment"
+		context.Error = err.Error()
+		return pz.HandleError("fetching comment", err, &context)
+	}
+	context.Comment = *comment
+
+	return pz.Ok(pz.HTMLTemplate(reportTemplate, &context), &context)
+}
+
+func (ws *WebServer) Report(r pz.Request) pz.Response {
+	context := struct {
+		Message  string          `json:"message,omitempty"`
+		Post     types.PostID    `json:"post"`
+		Comment  types.CommentID `json:"comment"`
+		User     types.UserID    `json:"user"`
+		Redirect string          `json:"redirect,omitempty"`
+		Error    string          `json:"error,omitempty"`
+	}{
+		Post:    types.PostID(r.Vars["post-id"]),
+		Comment: types.CommentID(r.Vars["comment-id"]),
+		User:    types.UserID(r.Headers.Get("User")),
+	}
+
+	// limitreader = mitigate dos attack
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, 2056))
+	if err != nil {
+		context.Message = "reading request body"
+		context.Error = err.Error()
+		return pz.InternalServerError(&context)
+	}
+
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		context.Message = "parsing form values"
+		context.Error = err.Error()
+		return pz.BadRequest(nil, &context)
+	}
+
+	_, err = ws.Comments.Report(&types.Report{
+		Post:     context.Post,
+		Comment:  context.Comment,
+		Reporter: context.User,
+		Reason:   values.Get("reason"),
+	})
+	var notice string
+	switch {
+	case errors.Is(err, types.ErrInvalidReport):
+		notice = fmt.Sprintf(
+			"Please give a reason of at most %d characters.",
+			reportReasonMax,
+		)
+	case errors.Is(err, types.ErrReportExists):
+		notice = "You've already reported this comment."
+	}
+	if notice != "" {
+		form := ws.reportForm(r, notice, values.Get("reason"))
+		var httpErr *pz.HTTPError
+		if form.Status == http.StatusOK && errors.As(err, &httpErr) {
+			form.Status = httpErr.Status
+		}
+		return form
+	}
+	if err != nil {
+		return pz.HandleError("reporting comment", err, &context)
+	}
+
+	context.Redirect = ws.redirectURL(
+		fmt.Sprintf(
+			"posts/%s/comments/toplevel/replies#%s",
+			context.Post,
+			context.Comment,
+		),
+		context.Post,
+	)
+	context.Message = "successfully reported comment"
+	return pz.SeeOther(context.Redirect, &context)
+}
+
+var unsubscribeTemplate = html.Must(html.New("").Parse(`<html>
+<head></head>
+<body>
+	{{if .Unsubscribed}}
+	<h1>Unsubscribed</h1>
+	<p>You won't get any more emails about replies to your comments.</p>
+	{{else}}
+	<h1>Unsubscribe</h1>
+	<p>Stop emailing {{.User}} about replies to their comments?</p>
+	<form action="{{.BaseURL}}{{.Path}}" method="POST">
+		<input type="submit" value="Unsubscribe">
+	</form>
+	{{end}}
+</body>
+</html>`))
+
+// UnsubscribeForm asks the user in an email's unsubscribe link to confirm
+// that they want to stop getting reply emails. Unsubscribing takes a POST so
+// that link scanners which follow the link don't unsubscribe anyone.
+func (ws *WebServer) UnsubscribeForm(r pz.Request) pz.Response {
+	return ws.unsubscribe(r, false)
+}
+
+// Unsubscribe turns off reply emails for the user in an email's unsubscribe
+// link. It's also the target of RFC 8058 one-click unsubscribe requests.
+func (ws *WebServer) Unsubscribe(r pz.Request) pz.Response {
+	return ws.unsubscribe(r, true)
+}
+
+func (ws *WebServer) unsubscribe(r pz.Request, confirmed bool) pz.Response {
+	query := queryValues(r)
+	context := struct {
+		Message      string       `json:"message,omitempty"`
+		BaseURL      string       `json:"-"`
+		Path         string       `json:"-"`
+		User         types.UserID `json:"user"`
+		Unsubscribed bool         `json:"unsubscribed"`
+		Error        string       `json:"error,omitempty"`
+	}{
+		BaseURL: ws.BaseURL,
+		Path: unsubscribePath + "?" + url.Values{
+			"user":  {query.Get("user")},
+			"token": {query.Get("token")},
+		}.Encode(),
+		User: types.UserID(query.Get("user")),
+	}
+
+	if ws.UnsubscribeTokens == nil ||
+		!ws.UnsubscribeTokens.Valid(context.User, query.Get("token")) {
+		context.Message = "validating unsubscribe token"
+		context.Error = ErrInvalidUnsubscribeToken.Error()
+		return pz.HandleError(
+			"validating unsubscribe token",
+			ErrInvalidUnsubscribeToken,
+			&context,
+		)
+	}
+
+	if confirmed {
+		settings, err := ws.Comments.NotificationSettings(context.User)
+		if err != nil {
+			return pz.HandleError("unsubscribing", err, &context)
+		}
+		settings.EmailReplies = false
+		if err := ws.Comments.PutNotificationSettings(settings); err != nil {
+			return pz.HandleError("unsubscribing", err, &context)
+		}
+		context.Message = "unsubscribed from reply emails"
+		context.Unsubscribed = true
+	}
+	return pz.Ok(pz.HTMLTemplate(unsubscribeTemplate, &context), &context)
+}
+
+// ThreadSubscription follows (`action=subscribe`) or unfollows
+// (`action=unsubscribe`) a post or subthread on behalf of the user, then
+// redirects back to the thread's replies page. The `toplevel` comment ID
+// refers to the whole post.
+func (ws *WebServer) ThreadSubscription(r pz.Request) pz.Response {
+	context := struct {
+		Message  string          `json:"message,omitempty"`
+		Post     types.PostID    `json:"post"`
+		Comment  types.CommentID `json:"comment"`
+		User     types.UserID    `json:"user"`
+		Action   string          `json:"action"`
+		Redirect string          `json:"redirect,omitempty"`
+		Error    string          `json:"error,omitempty"`
+	}{
+		Post:    types.PostID(r.Vars["post-id"]),
+		Comment: types.CommentID(r.Vars["comment-id"]),
+		User:    types.UserID(r.Headers.Get("User")),
+	}
+
+	// limitreader = mitigate dos attack
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, 2056))
+	if err != nil {
+		context.Message = "reading request body"
+		context.Error = err.Error()
+		return pz.InternalServerError(&context)
+	}
+
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		context.Message = "parsing form values"
+		context.Error = err.Error()
+		return pz.BadRequest(nil, &context)
+	}
+
+	comment := context.Comment
+	if comment == "toplevel" {
+		comment = ""
+	}
+	context.Action = values.Get("action")
+	switch context.Action {
+	case "subscribe":
+		_, err = ws.Comments.Subscribe(context.User, context.Post, comment)
+	case "unsubscribe":
+		err = ws.Comments.Unsubscribe(context.User, context.Post, comment)
+		if errors.Is(err, types.ErrSubscriptionNotFound) {
+			err = nil // there's nothing to remove
+		}
+	default:
+		context.Message = "parsing subscription action"
+		context.Error = fmt.Sprintf("invalid action: `%s`", context.Action)
+		return pz.BadRequest(nil, &context)
+	}
+	if err != nil {
+		return pz.HandleError("updating subscription", err, &context)
+	}
+
+	context.Redirect = ws.redirectURL(
+		fmt.Sprintf(
+			"posts/%s/comments/%s/replies",
+			context.Post,
+			context.Comment,
+		),
+		context.Post,
+	)
+	context.Message = "successfully updated subscription"
+	return pz.SeeOther(context.Redirect, &context)
+}
+
+var inboxTemplate = html.Must(html.New("").Parse(`<html>
+<head></head>
+<body>
+<h1>Notifications</h1>
+{{if .All}}
+<a href="{{.BaseURL}}/notifications">unread only</a>
+{{else}}
+<a href="{{.BaseURL}}/notifications?all=true">show read</a>
+{{end}}
+{{if .Entries}}
+<form class="mark-all-read" action="{{.BaseURL}}/notifications/read" method="POST">
+	<input type="hidden" name="csrf" value="{{.CSRFToken}}">
+	<input type="submit" value="mark all read">
+</form>
+{{else}}
+<p>No new comments in the threads you follow.</p>
+{{end}}
+{{range .Entries}}
+<div class="notification{{if .Notification.Read}} read{{end}}">
+	{{if not .Comment.Deleted}}
+	<span class="author">{{.Comment.Author}}</span>
+	{{end}}
+	<span class="date">{{.Notification.Created}}</span>
+	<a class="permalink" href="{{$.BaseURL}}/posts/{{.Comment.Post}}/comments/{{.Comment.ID}}">
+		permalink
+	</a>
+	{{if .Comment.Deleted}}
+	<p class="body">DELETED</p>
+	{{else}}
+	<div class="body">{{.BodyHTML}}</div>
+	{{end}}
+	{{if not .Notification.Read}}
+	<form class="mark-read" action="{{$.BaseURL}}/notifications/read" method="POST">
+		<input type="hidden" name="csrf" value="{{$.CSRFToken}}">
+		<input type="hidden" name="post" value="{{.Comment.Post}}">
+		<input type="hidden" name="comment" value="{{.Comment.ID}}">
+		<input type="submit" value="mark read">
+	</form>
+	{{end}}
+</div>
+{{end}}
+</body>
+</html>`))
+
+// inboxEntry is an `InboxEntry` for rendering.
+type inboxEntry struct{ *InboxEntry }
+
+// BodyHTML marks the comment's rendered body as safe for the template (see
+// `reply.BodyHTML()`).
+func (e inboxEntry) BodyHTML() html.HTML {
+	return html.HTML(e.Comment.HTML)
+}
+
+// Inbox renders the user's notifications about new comments in the threads
+// they follow. Read notifications are only listed if the `all` query
+// parameter is `true`.
+func (ws *WebServer) Inbox(r pz.Request) pz.Response {
+	context := struct {
+		Message   string       `json:"message,omitempty"`
+		BaseURL   string       `json:"-"`
+		User      types.UserID `json:"user"`
+		All       bool         `json:"all"`
+		CSRFToken string       `json:"-"`
+		Entries   []inboxEntry `json:"-"`
+		Error     string       `json:"error,omitempty"`
+	}{
+		BaseURL:   ws.BaseURL,
+		User:      types.UserID(r.Headers.Get("User")),
+		All:       queryValues(r).Get("all") == "true",
+		CSRFToken: ws.CSRF.Token(r),
+	}
+
+	entries, err := ws.Comments.Inbox(context.User, context.All)
+	if err != nil {
+		return pz.HandleError("fetching notifications", err, &context)
+	}
+	context.Entries = make([]inboxEntry, len(entries))
+	for i, e := range entries {
+		context.Entries[i] = inboxEntry{e}
+	}
+	return pz.Ok(pz.HTMLTemplate(inboxTemplate, &context), &context)
+}
+
+// MarkRead marks the notification about the comment identified by the
+// `post` and `comment` form fields read. If both fields are empty, every
+// notification is marked read.
+func (ws *WebServer) MarkRead(r pz.Request) pz.Response {
+	context := struct {
+		Message  string          `json:"message,omitempty"`
+		User     types.UserID    `json:"user"`
+		Post     types.PostID    `json:"post,omitempty"`
+		Comment  types.CommentID `json:"comment,omitempty"`
+		Redirect string          `json:"redirect,omitempty"`
+		Error    string          `json:"error,omitempty"`
+	}{
+		User: types.UserID(r.Headers.Get("User")),
+	}
+
+	// limitreader = mitigate dos attack
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, 2056))
+	if err != nil {
+		context.Message = "reading request body"
+		context.Error = err.Error()
+		return pz.InternalServerError(&context)
+	}
+
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		context.Message = "parsing form values"
+		context.Error = err.Error()
+		return pz.BadRequest(nil, &context)
+	}
+
+	context.Post = types.PostID(values.Get("post"))
+	context.Comment = types.CommentID(values.Get("comment"))
+	if context.Post == "" && context.Comment == "" {
+		err = ws.Comments.MarkAllRead(context.User)
+	} else {
+		err = ws.Comments.MarkRead(
+			context.User,
+			context.Post,
+			context.Comment,
+		)
+	}
+	if err != nil {
+		return pz.HandleError("marking notifications read", err, &context)
+	}
+
+	context.Redirect = join(ws.BaseURL, inboxPath)
+	context.Message = "successfully marked notifications read"
+	return pz.SeeOther(context.Redirect, &context)
+}
+
 func (ws *WebServer) RepliesRoute() pz.Route {
 	return pz.Route{
 		Method:  "GET",
@@ -516,6 +1554,14 @@ func (ws *WebServer) RepliesRoute() pz.Route {
 	}
 }
 
+func (ws *WebServer) PermalinkRoute() pz.Route {
+	return pz.Route{
+		Method:  "GET",
+		Path:    "/posts/{post-id}/comments/{comment-id}",
+		Handler: ws.Permalink,
+	}
+}
+
 func (ws *WebServer) DeleteConfirmRoute() pz.Route {
 	return pz.Route{
 		Method:  "GET",
@@ -526,7 +1572,7 @@ func (ws *WebServer) DeleteConfirmRoute() pz.Route {
 
 func (ws *WebServer) DeleteRoute() pz.Route {
 	return pz.Route{
-		Method:  "GET",
+		Method:  "POST",
 		Path:    "/posts/{post-id}/comments/{comment-id}/delete",
 		Handler: ws.Delete,
 	}
@@ -548,6 +1594,14 @@ func (ws *WebServer) ReplyRoute() pz.Route {
 	}
 }
 
+func (ws *WebServer) VoteRoute() pz.Route {
+	return pz.Route{
+		Method:  "POST",
+		Path:    "/posts/{post-id}/comments/{comment-id}/vote",
+		Handler: ws.Vote,
+	}
+}
+
 func (ws *WebServer) EditFormRoute() pz.Route {
 	return pz.Route{
 		Method:  "GET",
@@ -564,14 +1618,92 @@ func (ws *WebServer) EditRoute() pz.Route {
 	}
 }
 
+func (ws *WebServer) RevisionsRoute() pz.Route {
+	return pz.Route{
+		Method:  "GET",
+		Path:    "/posts/{post-id}/comments/{comment-id}/revisions",
+		Handler: ws.Revisions,
+	}
+}
+
+func (ws *WebServer) ReportFormRoute() pz.Route {
+	return pz.Route{
+		Method:  "GET",
+		Path:    "/posts/{post-id}/comments/{comment-id}/report",
+		Handler: ws.ReportForm,
+	}
+}
+
+func (ws *WebServer) ReportRoute() pz.Route {
+	return pz.Route{
+		Method:  "POST",
+		Path:    "/posts/{post-id}/comments/{comment-id}/report",
+		Handler: ws.Report,
+	}
+}
+
+func (ws *WebServer) UnsubscribeFormRoute() pz.Route {
+	return pz.Route{
+		Method:  "GET",
+		Path:    unsubscribePath,
+		Handler: ws.UnsubscribeForm,
+	}
+}
+
+func (ws *WebServer) UnsubscribeRoute() pz.Route {
+	return pz.Route{
+		Method:  "POST",
+		Path:    unsubscribePath,
+		Handler: ws.Unsubscribe,
+	}
+}
+
+func (ws *WebServer) ThreadSubscriptionRoute() pz.Route {
+	return pz.Route{
+		Method:  "POST",
+		Path:    "/posts/{post-id}/comments/{comment-id}/subscription",
+		Handler: ws.ThreadSubscription,
+	}
+}
+
+func (ws *WebServer) InboxRoute() pz.Route {
+	return pz.Route{
+		Method:  "GET",
+		Path:    inboxPath,
+		Handler: ws.Inbox,
+	}
+}
+
+func (ws *WebServer) MarkReadRoute() pz.Route {
+	return pz.Route{
+		Method:  "POST",
+		Path:    inboxPath + "/read",
+		Handler: ws.MarkRead,
+	}
+}
+
 func (ws *WebServer) Routes() []pz.Route {
-	return []pz.Route{
+	return frameAncestorsRoutes([]pz.Route{
 		ws.RepliesRoute(),
+		ws.PermalinkRoute(),
 		ws.DeleteConfirmRoute(),
 		ws.DeleteRoute(),
 		ws.ReplyFormRoute(),
 		ws.ReplyRoute(),
+		ws.VoteRoute(),
 		ws.EditFormRoute(),
 		ws.EditRoute(),
-	}
+		ws.RevisionsRoute(),
+		ws.ReportFormRoute(),
+		ws.ReportRoute(),
+		ws.UnsubscribeFormRoute(),
+		ws.UnsubscribeRoute(),
+		ws.ThreadSubscriptionRoute(),
+		ws.InboxRoute(),
+		ws.MarkReadRoute(),
+		ws.EventsRoute(),
+		ws.PostFeedRoute(),
+		ws.UserFeedRoute(),
+		ws.EmbedLoaderRoute(),
+	})
 }
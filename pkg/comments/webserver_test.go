@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/weberc2/comments/pkg/comments/events"
 	"github.com/weberc2/comments/pkg/comments/testsupport"
 	"github.com/weberc2/comments/pkg/comments/types"
 	pz "github.com/weberc2/httpeasy"
@@ -45,6 +46,7 @@ func TestWebServer_Reply(t *testing.T) {
 				Body:     "hello, world",
 				Created:  now,
 				Modified: now,
+				Status:   types.StatusApproved,
 			}},
 			wantedLocation: "https://comments.example.org/posts/post/" +
 				"comments/toplevel/replies#comment",
@@ -87,6 +89,7 @@ func TestWebServer_Reply(t *testing.T) {
 					Body:     "hello, jesse",
 					Created:  now,
 					Modified: now,
+					Status:   types.StatusApproved,
 				},
 			},
 			wantedLocation: "https://comments.example.org/posts/post/" +
@@ -143,6 +146,296 @@ func TestWebServer_Reply(t *testing.T) {
 	}
 }
 
+func TestWebServer_Replies(t *testing.T) {
+	// build a single thread which is one level deeper than the default depth
+	store := testsupport.CommentsStoreFake{"post": {}}
+	var parent types.CommentID
+	for i := 0; i <= threadDepthDefault; i++ {
+		id := types.CommentID(fmt.Sprintf("comment-%d", i))
+		store["post"][id] = &types.Comment{
+			ID:       id,
+			Post:     "post",
+			Parent:   parent,
+			Author:   "adam",
+			Body:     "hello, **world** <b>!</b>",
+			Created:  someTime,
+			Modified: someTime,
+		}
+		parent = id
+	}
+	cutoff := fmt.Sprintf("comment-%d", threadDepthDefault-1)
+
+	for _, testCase := range []struct {
+		name         string
+		parent       string
+		query        string
+		live         bool
+		wantedStatus int
+		wanted       []string
+		unwanted     []string
+	}{
+		{
+			name:         "deep threads are cut off",
+			parent:       "toplevel",
+			wantedStatus: http.StatusOK,
+			wanted: []string{
+				`href="https://comments.example.org/posts/post/comments/` +
+					cutoff + `/replies?sort=oldest"`,
+				"continue this thread (1 more)",
+			},
+			unwanted: []string{
+				fmt.Sprintf(`id="comment-%d"`, threadDepthDefault),
+			},
+		},
+		{
+			name:         "continued thread",
+			parent:       cutoff,
+			wantedStatus: http.StatusOK,
+			wanted: []string{
+				fmt.Sprintf(`id="comment-%d"`, threadDepthDefault),
+			},
+			unwanted: []string{"continue this thread"},
+		},
+		{
+			name:         "explicit depth",
+			parent:       "toplevel",
+			query:        "depth=0",
+			wantedStatus: http.StatusOK,
+			wanted: []string{
+				fmt.Sprintf(`id="comment-%d"`, threadDepthDefault),
+			},
+			unwanted: []string{"continue this thread"},
+		},
+		{
+			name:         "sort is carried into continued threads",
+			parent:       "toplevel",
+			query:        "sort=newest",
+			wantedStatus: http.StatusOK,
+			wanted: []string{
+				`<span class="sort">newest</span>`,
+				`href="https://comments.example.org/posts/post/comments/` +
+					`toplevel/replies?sort=oldest"`,
+				`href="https://comments.example.org/posts/post/comments/` +
+					cutoff + `/replies?sort=newest"`,
+			},
+		},
+		{
+			name:         "bodies are rendered from markdown",
+			parent:       "toplevel",
+			wantedStatus: http.StatusOK,
+			wanted:       []string{"hello, <strong>world</strong> &lt;b&gt;!"},
+			unwanted:     []string{"<b>"},
+		},
+		{
+			name:         "live updates",
+			parent:       "toplevel",
+			live:         true,
+			wantedStatus: http.StatusOK,
+			wanted: []string{
+				`data-events="https://comments.example.org/posts/post/events"`,
+				"<script>",
+			},
+		},
+		{
+			name:         "live updates are disabled",
+			parent:       "toplevel",
+			wantedStatus: http.StatusOK,
+			unwanted:     []string{"<script>"},
+		},
+		{
+			name:         "invalid sort",
+			parent:       "toplevel",
+			query:        "sort=sideways",
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid depth",
+			parent:       "toplevel",
+			query:        "depth=-1",
+			wantedStatus: http.StatusBadRequest,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			webServer := WebServer{
+				Comments: CommentsModel{
+					CommentsStore: store,
+					TimeFunc:      func() time.Time { return now },
+				},
+				LoginURL:   "https://auth.example.org/login",
+				LogoutPath: "logout",
+				BaseURL:    "https://comments.example.org",
+			}
+			if testCase.live {
+				webServer.Bus = &events.Bus{}
+			}
+
+			rsp := webServer.Replies(pz.Request{
+				Vars: map[string]string{
+					"post-id":    "post",
+					"comment-id": testCase.parent,
+				},
+				URL:     &url.URL{RawQuery: testCase.query},
+				Headers: http.Header{},
+			})
+
+			if rsp.Status != testCase.wantedStatus {
+				t.Fatalf(
+					"Response.Status: wanted `%d`; found `%d`",
+					testCase.wantedStatus,
+					rsp.Status,
+				)
+			}
+
+			data, err := readAll(rsp.Data)
+			if err != nil {
+				t.Fatalf("Response.Data: %v", err)
+			}
+			for _, wanted := range testCase.wanted {
+				if !strings.Contains(string(data), wanted) {
+					t.Fatalf("Response.Data: missing `%s`:\n%s", wanted, data)
+				}
+			}
+			for _, unwanted := range testCase.unwanted {
+				if strings.Contains(string(data), unwanted) {
+					t.Fatalf(
+						"Response.Data: unexpected `%s`:\n%s",
+						unwanted,
+						data,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestWebServer_Permalink(t *testing.T) {
+	comment := func(id, parent types.CommentID) *types.Comment {
+		return &types.Comment{
+			ID:       id,
+			Post:     "post",
+			Parent:   parent,
+			Author:   "adam",
+			Body:     "body of " + string(id),
+			Created:  someTime,
+			Modified: someTime,
+		}
+	}
+	pending := comment("pending", "")
+	pending.Status = types.StatusPending
+	store := testsupport.CommentsStoreFake{"post": {
+		"a":       comment("a", ""),
+		"b":       comment("b", "a"),
+		"c":       comment("c", "b"),
+		"d":       comment("d", "c"),
+		"sibling": comment("sibling", "a"),
+		"pending": pending,
+		"child":   comment("child", "pending"),
+	}}
+
+	for _, testCase := range []struct {
+		name         string
+		comment      string
+		user         types.UserID
+		wantedStatus int
+		wanted       []string
+		unwanted     []string
+	}{
+		{
+			name:         "ancestors and replies",
+			comment:      "b",
+			wantedStatus: http.StatusOK,
+			wanted: []string{
+				`id="a"`,
+				"body of a",
+				`id="b"`,
+				`id="c"`,
+				`id="d"`,
+			},
+			unwanted: []string{`id="sibling"`, "/comments/b/edit"},
+		},
+		{
+			name:         "author sees edit and delete links",
+			comment:      "b",
+			user:         "adam",
+			wantedStatus: http.StatusOK,
+			wanted: []string{
+				`href="https://comments.example.org/posts/post/comments/` +
+					`b/edit"`,
+				`href="https://comments.example.org/posts/post/comments/` +
+					`b/delete-confirm"`,
+			},
+		},
+		{
+			name:         "not found",
+			comment:      "not-found",
+			wantedStatus: http.StatusNotFound,
+		},
+		{
+			name:         "hidden ancestor",
+			comment:      "child",
+			user:         "eve",
+			wantedStatus: http.StatusNotFound,
+		},
+		{
+			name:         "hidden ancestor visible to its author",
+			comment:      "child",
+			user:         "adam",
+			wantedStatus: http.StatusOK,
+			wanted:       []string{`id="pending"`, "awaiting moderation"},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			webServer := WebServer{
+				Comments: CommentsModel{
+					CommentsStore: store,
+					TimeFunc:      func() time.Time { return now },
+				},
+				LoginURL:   "https://auth.example.org/login",
+				LogoutPath: "logout",
+				BaseURL:    "https://comments.example.org",
+			}
+
+			rsp := webServer.Permalink(pz.Request{
+				Vars: map[string]string{
+					"post-id":    "post",
+					"comment-id": testCase.comment,
+				},
+				Headers: http.Header{"User": []string{string(testCase.user)}},
+			})
+
+			if rsp.Status != testCase.wantedStatus {
+				t.Fatalf(
+					"Response.Status: wanted `%d`; found `%d`",
+					testCase.wantedStatus,
+					rsp.Status,
+				)
+			}
+			if rsp.Status != http.StatusOK {
+				return
+			}
+
+			data, err := readAll(rsp.Data)
+			if err != nil {
+				t.Fatalf("Response.Data: %v", err)
+			}
+			for _, wanted := range testCase.wanted {
+				if !strings.Contains(string(data), wanted) {
+					t.Fatalf("Response.Data: missing `%s`:\n%s", wanted, data)
+				}
+			}
+			for _, unwanted := range testCase.unwanted {
+				if strings.Contains(string(data), unwanted) {
+					t.Fatalf(
+						"Response.Data: unexpected `%s`:\n%s",
+						unwanted,
+						data,
+					)
+				}
+			}
+		})
+	}
+}
+
 func TestWebServer_Delete(t *testing.T) {
 	now := time.Date(1988, 9, 3, 0, 0, 0, 0, time.UTC)
 	for _, testCase := range []struct {
@@ -173,7 +466,7 @@ func TestWebServer_Delete(t *testing.T) {
 					},
 				},
 			},
-			wantedStatus: http.StatusTemporaryRedirect,
+			wantedStatus: http.StatusSeeOther,
 			wantedComments: []*types.Comment{{
 				Post:     "post",
 				ID:       "comment",
@@ -185,7 +478,7 @@ func TestWebServer_Delete(t *testing.T) {
 			wantedLocation: "https://comments.example.org/foo",
 		},
 		{
-			name:     "redirects to baseurl on redirect parse err",
+			name:     "invalid redirect falls back to replies page",
 			post:     "post",
 			comment:  "comment",
 			redirect: "!@#$%^&*()",
@@ -200,7 +493,35 @@ func TestWebServer_Delete(t *testing.T) {
 					},
 				},
 			},
-			wantedStatus: http.StatusTemporaryRedirect,
+			wantedStatus: http.StatusSeeOther,
+			wantedComments: []*types.Comment{{
+				Post:     "post",
+				ID:       "comment",
+				Author:   "adam",
+				Modified: now,
+				Deleted:  true,
+				Body:     "hello, world",
+			}},
+			wantedLocation: "https://comments.example.org/posts/post/" +
+				"comments/toplevel/replies",
+		},
+		{
+			name:     "offsite redirect falls back to replies page",
+			post:     "post",
+			comment:  "comment",
+			redirect: "//evil.example.org/phish",
+			user:     "adam",
+			store: testsupport.CommentsStoreFake{
+				"post": {
+					"comment": &types.Comment{
+						Post:   "post",
+						ID:     "comment",
+						Author: "adam",
+						Body:   "hello, world",
+					},
+				},
+			},
+			wantedStatus: http.StatusSeeOther,
 			wantedComments: []*types.Comment{{
 				Post:     "post",
 				ID:       "comment",
@@ -209,7 +530,8 @@ func TestWebServer_Delete(t *testing.T) {
 				Deleted:  true,
 				Body:     "hello, world",
 			}},
-			wantedLocation: "https://comments.example.org/",
+			wantedLocation: "https://comments.example.org/posts/post/" +
+				"comments/toplevel/replies",
 		},
 		{
 			name:    "user must be author",
@@ -233,15 +555,46 @@ func TestWebServer_Delete(t *testing.T) {
 				Author: "adam",
 				Body:   "hello, world",
 			}},
-			wantedStatus: http.StatusUnauthorized,
+			wantedStatus: http.StatusForbidden,
+		},
+		{
+			name:     "moderator removes comment",
+			post:     "post",
+			comment:  "comment",
+			redirect: "foo",
+			user:     "moderator",
+			store: testsupport.CommentsStoreFake{
+				"post": {
+					"comment": &types.Comment{
+						Post:   "post",
+						ID:     "comment",
+						Author: "adam",
+						Body:   "hello, world",
+					},
+				},
+			},
+			wantedStatus: http.StatusSeeOther,
+			wantedComments: []*types.Comment{{
+				Post:     "post",
+				ID:       "comment",
+				Author:   "adam",
+				Modified: now,
+				Deleted:  true,
+				Removed:  true,
+				Body:     "hello, world",
+			}},
+			wantedLocation: "https://comments.example.org/foo",
 		},
 	} {
 		t.Run(testCase.name, func(t *testing.T) {
 			webServer := WebServer{
 				Comments: CommentsModel{
 					CommentsStore: testCase.store,
-					TimeFunc:      func() time.Time { return now },
-					IDFunc:        func() types.CommentID { return "comment" },
+					Roles: testsupport.RolesStoreFake{
+						"moderator": types.RoleModerator,
+					},
+					TimeFunc: func() time.Time { return now },
+					IDFunc:   func() types.CommentID { return "comment" },
 				},
 				LoginURL:   "https://auth.example.org/login",
 				LogoutPath: "logout",
@@ -249,7 +602,9 @@ func TestWebServer_Delete(t *testing.T) {
 			}
 
 			rsp := webServer.Delete(pz.Request{
-				URL: &url.URL{RawQuery: "redirect=" + testCase.redirect},
+				Body: strings.NewReader(url.Values{
+					"redirect": []string{testCase.redirect},
+				}.Encode()),
 				Vars: map[string]string{
 					"post-id":    "post",
 					"comment-id": "comment",
@@ -351,7 +706,8 @@ func TestWebServer_Edit(t *testing.T) {
 				"post-id":    string(testCase.post),
 				"comment-id": string(testCase.comment),
 			},
-			Body: testCase.requestBody,
+			Headers: http.Header{"User": []string{"author"}},
+			Body:    testCase.requestBody,
 		})
 
 		if rsp.Status != testCase.wantedStatus {
@@ -381,6 +737,78 @@ func TestWebServer_Edit(t *testing.T) {
 	}
 }
 
+// TestWebServer_HiddenComments checks that the forms for comments which
+// aren't visible to the user aren't found.
+func TestWebServer_HiddenComments(t *testing.T) {
+	webServer := WebServer{
+		Comments: CommentsModel{
+			CommentsStore: moderationState(),
+			TimeFunc:      func() time.Time { return now },
+		},
+		BaseURL: "https://example.org",
+	}
+	for _, form := range []struct {
+		name    string
+		handler pz.Handler
+	}{
+		{name: "reply", handler: webServer.ReplyForm},
+		{name: "edit", handler: webServer.EditForm},
+		{name: "report", handler: webServer.ReportForm},
+		{name: "delete", handler: webServer.DeleteConfirm},
+	} {
+		for _, testCase := range []struct {
+			name         string
+			user         types.UserID
+			comment      types.CommentID
+			wantedStatus int
+		}{
+			{
+				name:         "approved",
+				user:         "other",
+				comment:      "approved",
+				wantedStatus: http.StatusOK,
+			},
+			{
+				name:         "pending",
+				user:         "other",
+				comment:      "pending",
+				wantedStatus: http.StatusNotFound,
+			},
+			{
+				name:         "reply to pending",
+				user:         "reader",
+				comment:      "pending-child",
+				wantedStatus: http.StatusNotFound,
+			},
+			{
+				name:         "author",
+				user:         "author",
+				comment:      "pending",
+				wantedStatus: http.StatusOK,
+			},
+		} {
+			t.Run(form.name+"/"+testCase.name, func(t *testing.T) {
+				rsp := form.handler(pz.Request{
+					Vars: map[string]string{
+						"post-id":    "post",
+						"comment-id": string(testCase.comment),
+					},
+					Headers: http.Header{
+						"User": []string{string(testCase.user)},
+					},
+				})
+				if rsp.Status != testCase.wantedStatus {
+					t.Fatalf(
+						"HTTP Status: wanted `%d`; found `%d`",
+						testCase.wantedStatus,
+						rsp.Status,
+					)
+				}
+			})
+		}
+	}
+}
+
 type Literal string
 
 func (wanted Literal) CompareData(found []byte) error {
@@ -389,3 +817,191 @@ func (wanted Literal) CompareData(found []byte) error {
 	}
 	return nil
 }
+
+func TestWebServer_Vote(t *testing.T) {
+	for _, testCase := range []struct {
+		name           string
+		comment        types.CommentID
+		vote           string
+		votes          map[types.UserID]int
+		wantedStatus   int
+		wantedScore    int
+		wantedLocation string
+	}{
+		{
+			name:         "upvote",
+			comment:      "comment",
+			vote:         "up",
+			wantedStatus: http.StatusSeeOther,
+			wantedScore:  1,
+			wantedLocation: "https://comments.example.org/posts/post/" +
+				"comments/toplevel/replies#comment",
+		},
+		{
+			name:         "downvote",
+			comment:      "comment",
+			vote:         "down",
+			votes:        map[types.UserID]int{"user": types.Upvote},
+			wantedStatus: http.StatusSeeOther,
+			wantedScore:  -1,
+			wantedLocation: "https://comments.example.org/posts/post/" +
+				"comments/toplevel/replies#comment",
+		},
+		{
+			name:         "unvote",
+			comment:      "comment",
+			vote:         "none",
+			votes:        map[types.UserID]int{"user": types.Upvote},
+			wantedStatus: http.StatusSeeOther,
+			wantedScore:  0,
+			wantedLocation: "https://comments.example.org/posts/post/" +
+				"comments/toplevel/replies#comment",
+		},
+		{
+			name:         "unvote without vote",
+			comment:      "comment",
+			vote:         "none",
+			wantedStatus: http.StatusSeeOther,
+			wantedScore:  0,
+			wantedLocation: "https://comments.example.org/posts/post/" +
+				"comments/toplevel/replies#comment",
+		},
+		{
+			name:         "invalid vote",
+			comment:      "comment",
+			vote:         "sideways",
+			wantedStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "deleted comment",
+			comment:      "deleted",
+			vote:         "up",
+			wantedStatus: http.StatusNotFound,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			votes := votesStoreFake(testCase.votes)
+			webServer := WebServer{
+				Comments: CommentsModel{
+					CommentsStore: votes.Comments,
+					VotesStore:    votes,
+					TimeFunc:      func() time.Time { return now },
+				},
+				BaseURL: "https://comments.example.org",
+			}
+
+			rsp := webServer.Vote(pz.Request{
+				Vars: map[string]string{
+					"post-id":    "post",
+					"comment-id": string(testCase.comment),
+				},
+				Headers: http.Header{"User": []string{"user"}},
+				Body: strings.NewReader(
+					url.Values{"vote": []string{testCase.vote}}.Encode(),
+				),
+			})
+
+			if rsp.Status != testCase.wantedStatus {
+				t.Fatalf(
+					"Response.Status: wanted `%d`; found `%d`",
+					testCase.wantedStatus,
+					rsp.Status,
+				)
+			}
+
+			location := rsp.Headers.Get("Location")
+			if location != testCase.wantedLocation {
+				t.Fatalf(
+					"Response.Headers[\"Location\"]: wanted `%s`; found `%s`",
+					testCase.wantedLocation,
+					location,
+				)
+			}
+
+			if score := votes.Comments["post"]["comment"].Score; score !=
+				testCase.wantedScore {
+				t.Fatalf(
+					"Comment.Score: wanted `%d`; found `%d`",
+					testCase.wantedScore,
+					score,
+				)
+			}
+		})
+	}
+}
+
+// TestWebServer_BodyEscaping checks that bodies are stored verbatim by both
+// `Put()` and `Update()` and escaped exactly once when they're rendered.
+func TestWebServer_BodyEscaping(t *testing.T) {
+	const body = `a < b && c > "d"`
+	store := testsupport.CommentsStoreFake{}
+	webServer := WebServer{
+		Comments: CommentsModel{
+			CommentsStore: store,
+			IDFunc:        func() types.CommentID { return "put" },
+			TimeFunc:      func() time.Time { return now },
+		},
+		BaseURL: "https://comments.example.org",
+	}
+
+	if _, err := webServer.Comments.Put(&types.Comment{
+		Post:   "post",
+		Author: "author",
+		Body:   body,
+	}); err != nil {
+		t.Fatalf("Put(): unexpected error: %v", err)
+	}
+
+	webServer.Comments.IDFunc = func() types.CommentID { return "updated" }
+	if _, err := webServer.Comments.Put(&types.Comment{
+		Post:   "post",
+		Author: "author",
+		Body:   "placeholder",
+	}); err != nil {
+		t.Fatalf("Put(): unexpected error: %v", err)
+	}
+	if err := webServer.Comments.Update("author", &CommentUpdate{
+		Post: "post",
+		ID:   "updated",
+		Body: body,
+	}); err != nil {
+		t.Fatalf("Update(): unexpected error: %v", err)
+	}
+
+	for _, id := range []types.CommentID{"put", "updated"} {
+		if found := store["post"][id].Body; found != body {
+			t.Fatalf(
+				"Comment[%s].Body: wanted `%s`; found `%s`",
+				id,
+				body,
+				found,
+			)
+		}
+	}
+
+	rsp := webServer.Replies(pz.Request{
+		Vars: map[string]string{
+			"post-id":    "post",
+			"comment-id": "toplevel",
+		},
+		URL:     &url.URL{},
+		Headers: http.Header{},
+	})
+	data, err := readAll(rsp.Data)
+	if err != nil {
+		t.Fatalf("Response.Data: %v", err)
+	}
+
+	const wanted = "a &lt; b &amp;&amp; c &gt; &#34;d&#34;"
+	if n := strings.Count(string(data), wanted); n != 2 {
+		t.Fatalf(
+			"Response.Data: wanted `%s` twice; found it %d times:\n%s",
+			wanted,
+			n,
+			data,
+		)
+	}
+	if strings.Contains(string(data), "&amp;lt;") {
+		t.Fatalf("Response.Data: body was escaped twice:\n%s", data)
+	}
+}
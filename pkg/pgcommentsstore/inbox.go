@@ -0,0 +1,162 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func (pgcs *PGCommentsStore) PutNotification(n *types.Notification) error {
+	return InboxTable.Insert((*sql.DB)(pgcs), (*notification)(n))
+}
+
+func (pgcs *PGCommentsStore) Notifications(
+	u types.UserID,
+	read bool,
+	limit int,
+) ([]*types.Notification, error) {
+	rows, err := (*sql.DB)(pgcs).Query(
+		`SELECT "user", post, comment, thread, created, read
+FROM notifications
+WHERE "user" = $1 AND ($2 OR NOT read)
+ORDER BY created DESC, post, comment
+LIMIT $3`,
+		u,
+		read,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"querying notifications from postgres: %w",
+			err,
+		)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf(
+				"PGCommentsStore.Notifications(): closing sql.Rows: %v",
+				err,
+			)
+		}
+	}()
+
+	notifications := []*types.Notification{}
+	for rows.Next() {
+		var n types.Notification
+		if err := rows.Scan(
+			&n.User,
+			&n.Post,
+			&n.Comment,
+			&n.Thread,
+			&n.Created,
+			&n.Read,
+		); err != nil {
+			return nil, fmt.Errorf(
+				"scanning postgres row into notification: %w",
+				err,
+			)
+		}
+		notifications = append(notifications, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf(
+			"querying notifications from postgres: %w",
+			err,
+		)
+	}
+	return notifications, nil
+}
+
+func (pgcs *PGCommentsStore) MarkNotificationRead(
+	u types.UserID,
+	p types.PostID,
+	c types.CommentID,
+) error {
+	result, err := (*sql.DB)(pgcs).Exec(
+		`UPDATE notifications SET read = true
+WHERE "user" = $1 AND post = $2 AND comment = $3`,
+		u,
+		p,
+		c,
+	)
+	if err != nil {
+		return fmt.Errorf("marking notification read in postgres: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("marking notification read in postgres: %w", err)
+	}
+	if n < 1 {
+		return types.ErrNotificationNotFound
+	}
+	return nil
+}
+
+func (pgcs *PGCommentsStore) MarkNotificationsRead(u types.UserID) error {
+	if _, err := (*sql.DB)(pgcs).Exec(
+		`UPDATE notifications SET read = true WHERE "user" = $1 AND NOT read`,
+		u,
+	); err != nil {
+		return fmt.Errorf("marking notifications read in postgres: %w", err)
+	}
+	return nil
+}
+
+// Implement `pgutil.Item` for `types.Notification` (see `comment` for the
+// rationale).
+type notification types.Notification
+
+func (n *notification) Values(values []interface{}) {
+	values[0] = n.User
+	values[1] = n.Post
+	values[2] = n.Comment
+	values[3] = n.Thread
+	values[4] = n.Created
+	values[5] = n.Read
+}
+
+func (n *notification) Scan(pointers []interface{}) {
+	pointers[0] = &n.User
+	pointers[1] = &n.Post
+	pointers[2] = &n.Comment
+	pointers[3] = &n.Thread
+	pointers[4] = &n.Created
+	pointers[5] = &n.Read
+}
+
+var (
+	// fail compilation if `notification` doesn't implement the `pgutil.Item`
+	// interface or if `PGCommentsStore` doesn't implement the
+	// `types.InboxStore` interface.
+	_ pgutil.Item      = &notification{}
+	_ types.InboxStore = &PGCommentsStore{}
+
+	InboxTable = pgutil.Table{
+		Name: "notifications",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "user",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "post",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "comment",
+			Type: "VARCHAR(255)",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name: "thread",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "created",
+			Type: "TIMESTAMPTZ",
+		}, {
+			Name:    "read",
+			Type:    "BOOLEAN",
+			Default: pgutil.NewBoolean(false),
+		}},
+		ExistsErr: types.ErrNotificationExists,
+	}
+)
@@ -0,0 +1,102 @@
+package pgcommentsstore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestPGCommentsStore_Inbox(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifications := []*types.Notification{{
+		User:    "adam",
+		Post:    "post",
+		Comment: "first",
+		Created: someDate,
+	}, {
+		User:    "adam",
+		Post:    "post",
+		Comment: "second",
+		Thread:  "first",
+		Created: someDate.Add(time.Hour),
+	}, {
+		User:    "eve",
+		Post:    "post",
+		Comment: "second",
+		Created: someDate.Add(time.Hour),
+	}}
+	for _, n := range notifications {
+		if err := store.PutNotification(n); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := store.PutNotification(
+		notifications[0],
+	); !errors.Is(err, types.ErrNotificationExists) {
+		t.Fatalf("wanted `ErrNotificationExists`; found `%v`", err)
+	}
+
+	check := func(read bool, limit int, wanted ...*types.Notification) {
+		t.Helper()
+		found, err := store.Notifications("adam", read, limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(found) != len(wanted) {
+			t.Fatalf(
+				"len(notifications): wanted `%d`; found `%d`",
+				len(wanted),
+				len(found),
+			)
+		}
+		for i := range wanted {
+			if found[i].Comment != wanted[i].Comment ||
+				found[i].Thread != wanted[i].Thread ||
+				!found[i].Created.Equal(wanted[i].Created) {
+				t.Fatalf(
+					"notifications[%d]: wanted `%+v`; found `%+v`",
+					i,
+					wanted[i],
+					found[i],
+				)
+			}
+		}
+	}
+
+	check(false, 10, notifications[1], notifications[0])
+	check(false, 1, notifications[1])
+
+	if err := store.MarkNotificationRead("adam", "post", "second"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	check(false, 10, notifications[0])
+	check(true, 10, notifications[1], notifications[0])
+
+	if err := store.MarkNotificationRead(
+		"adam",
+		"post",
+		"missing",
+	); !errors.Is(err, types.ErrNotificationNotFound) {
+		t.Fatalf("wanted `ErrNotificationNotFound`; found `%v`", err)
+	}
+
+	if err := store.MarkNotificationsRead("adam"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	check(false, 10)
+
+	// other users' notifications are untouched
+	found, err := store.Notifications("eve", false, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("len(notifications): wanted `1`; found `%d`", len(found))
+	}
+}
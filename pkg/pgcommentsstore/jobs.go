@@ -0,0 +1,136 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// PutJob implements `types.JobsStore`.
+func (pgcs *PGCommentsStore) PutJob(j *types.Job) error {
+	if _, err := (*sql.DB)(pgcs).Exec(
+		`INSERT INTO jobs ("kind", "id", "payload", "attempts", "due")
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT ("kind", "id") DO NOTHING`,
+		j.Kind,
+		j.ID,
+		j.Payload,
+		j.Attempts,
+		j.Due,
+	); err != nil {
+		return fmt.Errorf("inserting job into postgres: %w", err)
+	}
+	return nil
+}
+
+// ClaimJobs implements `types.JobsStore`. Jobs which are being claimed by
+// another transaction are skipped rather than waited for, so several workers
+// can claim jobs at once without claiming the same ones.
+func (pgcs *PGCommentsStore) ClaimJobs(
+	kind string,
+	now time.Time,
+	until time.Time,
+	limit int,
+) ([]*types.Job, error) {
+	rows, err := (*sql.DB)(pgcs).Query(
+		`UPDATE jobs SET "due" = $3
+WHERE ("kind", "id") IN (
+	SELECT "kind", "id" FROM jobs
+	WHERE "kind" = $1 AND "due" <= $2
+	ORDER BY "due"
+	LIMIT $4
+	FOR UPDATE SKIP LOCKED
+) RETURNING "kind", "id", "payload", "attempts", "due"`,
+		kind,
+		now,
+		until,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claiming jobs from postgres: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("ClaimJobs(): closing sql.Rows: %v", err)
+		}
+	}()
+
+	var jobs []*types.Job
+	for rows.Next() {
+		var j types.Job
+		if err := rows.Scan(
+			&j.Kind,
+			&j.ID,
+			&j.Payload,
+			&j.Attempts,
+			&j.Due,
+		); err != nil {
+			return nil, fmt.Errorf("scanning postgres row into job: %w", err)
+		}
+		jobs = append(jobs, &j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("claiming jobs from postgres: %w", err)
+	}
+	return jobs, nil
+}
+
+// UpdateJob implements `types.JobsStore`.
+func (pgcs *PGCommentsStore) UpdateJob(j *types.Job) error {
+	if _, err := (*sql.DB)(pgcs).Exec(
+		`UPDATE jobs SET "attempts" = $3, "due" = $4
+WHERE "kind" = $1 AND "id" = $2`,
+		j.Kind,
+		j.ID,
+		j.Attempts,
+		j.Due,
+	); err != nil {
+		return fmt.Errorf("updating job in postgres: %w", err)
+	}
+	return nil
+}
+
+// DeleteJob implements `types.JobsStore`.
+func (pgcs *PGCommentsStore) DeleteJob(kind, id string) error {
+	if _, err := (*sql.DB)(pgcs).Exec(
+		`DELETE FROM jobs WHERE "kind" = $1 AND "id" = $2`,
+		kind,
+		id,
+	); err != nil {
+		return fmt.Errorf("deleting job from postgres: %w", err)
+	}
+	return nil
+}
+
+var (
+	// fail compilation if `PGCommentsStore` doesn't implement the
+	// `types.JobsStore` interface.
+	_ types.JobsStore = &PGCommentsStore{}
+
+	// JobsTable holds queued jobs (see `jobs.Queue`), e.g., webhook
+	// deliveries, so that they survive restarts.
+	JobsTable = pgutil.Table{
+		Name: "jobs",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "kind",
+			Type: "VARCHAR(64)",
+		}, {
+			Name: "id",
+			Type: "VARCHAR(512)",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name: "payload",
+			Type: "BYTEA",
+		}, {
+			Name: "attempts",
+			Type: "INTEGER",
+		}, {
+			Name: "due",
+			Type: "TIMESTAMPTZ",
+		}},
+	}
+)
@@ -0,0 +1,88 @@
+package pgcommentsstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestPGCommentsStore_Jobs(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, j := range []*types.Job{
+		{Kind: "kind", ID: "later", Due: someDate.Add(time.Minute)},
+		{Kind: "kind", ID: "sooner", Due: someDate, Payload: []byte("a")},
+		{Kind: "other", ID: "sooner", Due: someDate},
+	} {
+		if err := store.PutJob(j); err != nil {
+			t.Fatalf("PutJob(): unexpected error: %v", err)
+		}
+	}
+
+	// queueing a job which is already queued changes nothing
+	if err := store.PutJob(&types.Job{
+		Kind:    "kind",
+		ID:      "sooner",
+		Payload: []byte("b"),
+		Due:     someDate,
+	}); err != nil {
+		t.Fatalf("PutJob(): unexpected error: %v", err)
+	}
+
+	now, until := someDate.Add(time.Minute), someDate.Add(time.Hour)
+	claimed, err := store.ClaimJobs("kind", now, until, 1)
+	if err != nil {
+		t.Fatalf("ClaimJobs(): unexpected error: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != "sooner" ||
+		string(claimed[0].Payload) != "a" || !claimed[0].Due.Equal(until) {
+		t.Fatalf(
+			"ClaimJobs(): wanted job `sooner` with payload `a` due at `%s`; "+
+				"found `%+v`",
+			until,
+			claimed,
+		)
+	}
+
+	// claimed jobs aren't claimed again until `until`
+	claimed, err = store.ClaimJobs("kind", now, until, 2)
+	if err != nil {
+		t.Fatalf("ClaimJobs(): unexpected error: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != "later" {
+		t.Fatalf("ClaimJobs(): wanted job `later`; found `%+v`", claimed)
+	}
+
+	claimed[0].Attempts = 1
+	claimed[0].Due = now
+	if err := store.UpdateJob(claimed[0]); err != nil {
+		t.Fatalf("UpdateJob(): unexpected error: %v", err)
+	}
+	claimed, err = store.ClaimJobs("kind", now, until, 2)
+	if err != nil {
+		t.Fatalf("ClaimJobs(): unexpected error: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].Attempts != 1 {
+		t.Fatalf(
+			"ClaimJobs(): wanted retried job `later`; found `%+v`",
+			claimed,
+		)
+	}
+
+	for _, id := range []string{"sooner", "later"} {
+		if err := store.DeleteJob("kind", id); err != nil {
+			t.Fatalf("DeleteJob(): unexpected error: %v", err)
+		}
+	}
+	claimed, err = store.ClaimJobs("kind", until, until, 2)
+	if err != nil {
+		t.Fatalf("ClaimJobs(): unexpected error: %v", err)
+	}
+	if len(claimed) != 0 {
+		t.Fatalf("ClaimJobs(): wanted no jobs; found `%+v`", claimed)
+	}
+}
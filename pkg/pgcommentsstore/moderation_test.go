@@ -0,0 +1,330 @@
+package pgcommentsstore
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func moderationComments() []*types.Comment {
+	return []*types.Comment{{
+		ID:       "approved",
+		Post:     "post",
+		Author:   "author",
+		Created:  someDate,
+		Modified: someDate,
+		Body:     "body",
+		Status:   types.StatusApproved,
+	}, {
+		ID:       "pending",
+		Post:     "post",
+		Author:   "author",
+		Created:  someDate.Add(time.Hour),
+		Modified: someDate.Add(time.Hour),
+		Body:     "body",
+		Status:   types.StatusPending,
+	}, {
+		ID:       "pending-child",
+		Post:     "post",
+		Parent:   "pending",
+		Author:   "other",
+		Created:  someDate.Add(2 * time.Hour),
+		Modified: someDate.Add(2 * time.Hour),
+		Body:     "body",
+		Status:   types.StatusApproved,
+	}}
+}
+
+func TestPGCommentsStore_CommentsByStatus(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range moderationComments() {
+		if err := store.Put(c); err != nil {
+			t.Fatalf("unexpected error preparing test database state: %v", err)
+		}
+	}
+
+	found, err := store.CommentsByStatus(types.StatusPending)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := types.CompareComments(
+		moderationComments()[1:2],
+		found,
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPGCommentsStore_CommentsByAuthor(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range moderationComments() {
+		if err := store.Put(c); err != nil {
+			t.Fatalf("unexpected error preparing test database state: %v", err)
+		}
+	}
+
+	// newest first, limited
+	found, err := store.CommentsByAuthor("author", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := types.CompareComments(
+		moderationComments()[1:2],
+		found,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err = store.CommentsByAuthor("other", 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := types.CompareComments(
+		moderationComments()[2:],
+		found,
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPGCommentsStore_CommentsByPost(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range append(moderationComments(), &types.Comment{
+		ID:       "elsewhere",
+		Post:     "other-post",
+		Author:   "author",
+		Created:  someDate.Add(3 * time.Hour),
+		Modified: someDate.Add(3 * time.Hour),
+		Body:     "body",
+		Status:   types.StatusApproved,
+	}) {
+		if err := store.Put(c); err != nil {
+			t.Fatalf("unexpected error preparing test database state: %v", err)
+		}
+	}
+
+	// newest first, limited, and only the post's comments
+	found, err := store.CommentsByPost("post", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wanted := moderationComments()
+	if err := types.CompareComments(
+		[]*types.Comment{wanted[2], wanted[1]},
+		found,
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPGCommentsStore_Ancestors(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	grandchild := &types.Comment{
+		ID:       "grandchild",
+		Post:     "post",
+		Parent:   "pending-child",
+		Author:   "author",
+		Created:  someDate.Add(3 * time.Hour),
+		Modified: someDate.Add(3 * time.Hour),
+		Body:     "body",
+		Status:   types.StatusApproved,
+	}
+	for _, c := range append(moderationComments(), grandchild) {
+		if err := store.Put(c); err != nil {
+			t.Fatalf("unexpected error preparing test database state: %v", err)
+		}
+	}
+
+	// a reply on another post whose parent has the same ID as a comment on
+	// `post` mustn't pick up that comment
+	orphan := &types.Comment{ID: "orphan", Post: "other", Parent: "pending"}
+
+	found, err := store.Ancestors(grandchild, moderationComments()[0], orphan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Created.Before(found[j].Created)
+	})
+	if err := types.CompareComments(
+		moderationComments()[1:],
+		found,
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPGCommentsStore_RepliesPage_Moderation(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range moderationComments() {
+		if err := store.Put(c); err != nil {
+			t.Fatalf("unexpected error preparing test database state: %v", err)
+		}
+	}
+
+	for _, testCase := range []struct {
+		name         string
+		viewer       types.UserID
+		allStatuses  bool
+		shadowBanned []types.UserID
+		wanted       []types.CommentID
+	}{
+		{
+			name:   "anonymous",
+			wanted: []types.CommentID{"approved"},
+		},
+		{
+			name:   "author",
+			viewer: "author",
+			wanted: []types.CommentID{"approved", "pending", "pending-child"},
+		},
+		{
+			name:        "all statuses",
+			allStatuses: true,
+			wanted:      []types.CommentID{"approved", "pending", "pending-child"},
+		},
+		{
+			name:         "shadow-banned author",
+			shadowBanned: []types.UserID{"author"},
+			wanted:       []types.CommentID{},
+		},
+		{
+			name:         "shadow-banned author viewing",
+			viewer:       "author",
+			shadowBanned: []types.UserID{"author"},
+			wanted: []types.CommentID{
+				"approved",
+				"pending",
+				"pending-child",
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			page, err := store.RepliesPage(&types.RepliesQuery{
+				Post:         "post",
+				Limit:        10,
+				Viewer:       testCase.viewer,
+				AllStatuses:  testCase.allStatuses,
+				ShadowBanned: testCase.shadowBanned,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(page.Comments) != len(testCase.wanted) {
+				t.Fatalf(
+					"len(page.Comments): wanted `%d`; found `%d`",
+					len(testCase.wanted),
+					len(page.Comments),
+				)
+			}
+			for i, c := range page.Comments {
+				if c.ID != testCase.wanted[i] {
+					t.Fatalf(
+						"page.Comments[%d].ID: wanted `%s`; found `%s`",
+						i,
+						testCase.wanted[i],
+						c.ID,
+					)
+				}
+			}
+		})
+	}
+}
+
+// Comments without a status predate moderation, so `types.Status.Visible()`
+// treats them as approved; the store must agree.
+func TestPGCommentsStore_EmptyStatus(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := &types.Comment{
+		ID:       "legacy",
+		Post:     "post",
+		Author:   "author",
+		Created:  someDate,
+		Modified: someDate,
+		Body:     "body",
+	}
+	if err := store.Put(legacy); err != nil {
+		t.Fatalf("Put(): unexpected error: %v", err)
+	}
+	if err := store.Update(types.NewCommentPatch("legacy", "post").
+		SetStatus(""),
+	); err != nil {
+		t.Fatalf("Update(): unexpected error: %v", err)
+	}
+
+	found, err := store.Comment("post", "legacy")
+	if err != nil {
+		t.Fatalf("Comment(): unexpected error: %v", err)
+	}
+	if found.Status != types.StatusApproved {
+		t.Fatalf(
+			"Comment().Status: wanted `%s`; found `%s`",
+			types.StatusApproved,
+			found.Status,
+		)
+	}
+
+	page, err := store.RepliesPage(&types.RepliesQuery{
+		Post:  "post",
+		Limit: 10,
+	})
+	if err != nil {
+		t.Fatalf("RepliesPage(): unexpected error: %v", err)
+	}
+	if len(page.Comments) != 1 || page.Comments[0].ID != "legacy" {
+		t.Fatalf(
+			"RepliesPage(): wanted visible comment `legacy`; found `%+v`",
+			page.Comments,
+		)
+	}
+}
+
+func TestPGCommentsStore_PostSettings(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wantedErr types.WantedError = types.ErrPostSettingsNotFound
+	_, err = store.PostSettings("post")
+	if err := wantedErr.CompareErr(err); err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := types.PostSettings{Post: "post", Premoderate: true}
+	if err := store.PutPostSettings(&wanted); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found, err := store.PostSettings("post")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *found != wanted {
+		t.Fatalf(
+			"PostSettings: wanted `%+v`; found `%+v`",
+			wanted,
+			*found,
+		)
+	}
+}
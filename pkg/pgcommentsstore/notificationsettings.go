@@ -0,0 +1,67 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func (pgcs *PGCommentsStore) NotificationSettings(
+	user types.UserID,
+) (*types.NotificationSettings, error) {
+	var out notificationSettings
+	if err := NotificationSettingsTable.Get(
+		(*sql.DB)(pgcs),
+		&notificationSettings{User: user},
+		&out,
+	); err != nil {
+		return nil, err
+	}
+	return (*types.NotificationSettings)(&out), nil
+}
+
+func (pgcs *PGCommentsStore) PutNotificationSettings(
+	s *types.NotificationSettings,
+) error {
+	return NotificationSettingsTable.Upsert(
+		(*sql.DB)(pgcs),
+		(*notificationSettings)(s),
+	)
+}
+
+// Implement `pgutil.Item` for `types.NotificationSettings` (see `comment` for
+// the rationale).
+type notificationSettings types.NotificationSettings
+
+func (s *notificationSettings) Values(values []interface{}) {
+	values[0] = s.User
+	values[1] = s.EmailReplies
+}
+
+func (s *notificationSettings) Scan(pointers []interface{}) {
+	pointers[0] = &s.User
+	pointers[1] = &s.EmailReplies
+}
+
+var (
+	// fail compilation if `notificationSettings` doesn't implement the
+	// `pgutil.Item` interface or if `PGCommentsStore` doesn't implement the
+	// `types.NotificationSettingsStore` interface.
+	_ pgutil.Item                     = &notificationSettings{}
+	_ types.NotificationSettingsStore = &PGCommentsStore{}
+
+	NotificationSettingsTable = pgutil.Table{
+		Name: "notification_settings",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "user",
+			Type: "VARCHAR(255)",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name:    "email_replies",
+			Type:    "BOOLEAN",
+			Default: pgutil.NewBoolean(true),
+		}},
+		NotFoundErr: types.ErrNotificationSettingsNotFound,
+	}
+)
@@ -0,0 +1,37 @@
+package pgcommentsstore
+
+import (
+	"testing"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestPGCommentsStore_NotificationSettings(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wantedErr types.WantedError = types.ErrNotificationSettingsNotFound
+	_, err = store.NotificationSettings("user")
+	if err := wantedErr.CompareErr(err); err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := types.NotificationSettings{User: "user", EmailReplies: false}
+	if err := store.PutNotificationSettings(&wanted); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found, err := store.NotificationSettings("user")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *found != wanted {
+		t.Fatalf(
+			"NotificationSettings: wanted `%+v`; found `%+v`",
+			wanted,
+			*found,
+		)
+	}
+}
@@ -0,0 +1,122 @@
+package pgcommentsstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// NotifyChannel is the Postgres channel which carries events between
+// replicas.
+const NotifyChannel = "comment_events"
+
+// Notifier publishes events to every replica's `Listener` via Postgres
+// `NOTIFY`. Since `NOTIFY` payloads are limited to 8000 bytes, only the
+// comment's `Post`, `ID`, `Parent` and `Deleted` fields are sent; listeners
+// fetch the rest of the comment themselves (which also means that they see
+// its latest state).
+//
+// Unlike the outbox, `NOTIFY` is at-most-once: events which are published
+// while a listener is disconnected are lost to that listener. It's meant for
+// live updates, which can afford to miss events.
+type Notifier struct {
+	Store *PGCommentsStore
+}
+
+// Publish implements `types.EventSink`.
+func (n *Notifier) Publish(e *types.Event) error {
+	payload, err := json.Marshal(&types.Event{
+		ID:   e.ID,
+		Type: e.Type,
+		Time: e.Time,
+		Comment: &types.EventComment{
+			Post:    e.Comment.Post,
+			ID:      e.Comment.ID,
+			Parent:  e.Comment.Parent,
+			Deleted: e.Comment.Deleted,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling event `%d`: %w", e.ID, err)
+	}
+	if _, err := (*sql.DB)(n.Store).Exec(
+		"SELECT pg_notify($1, $2)",
+		NotifyChannel,
+		string(payload),
+	); err != nil {
+		return fmt.Errorf("notifying listeners of event `%d`: %w", e.ID, err)
+	}
+	return nil
+}
+
+const (
+	listenerMinReconnect = 10 * time.Second
+	listenerMaxReconnect = time.Minute
+)
+
+// Listener receives the events published by every replica's `Notifier` and
+// publishes them to a (typically in-process) sink.
+type Listener struct {
+	Sink types.EventSink
+
+	listener *pq.Listener
+}
+
+// ListenEnv listens on `NotifyChannel` of the database identified by the
+// `PG_*` environment variables.
+func ListenEnv(sink types.EventSink) (*Listener, error) {
+	listener := pq.NewListener(
+		connInfoEnv(),
+		listenerMinReconnect,
+		listenerMaxReconnect,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Printf("listening for events: %v", err)
+			}
+		},
+	)
+	if err := listener.Listen(NotifyChannel); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("listening for events: %w", err)
+	}
+	return &Listener{Sink: sink, listener: listener}, nil
+}
+
+// Run publishes notifications to the sink until the context is canceled. The
+// sink's errors are logged rather than retried (see `Notifier`).
+func (l *Listener) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-l.listener.Notify:
+			// `nil` means that the connection was re-established, so
+			// events may have been missed.
+			if n == nil {
+				log.Printf("listening for events: reconnected")
+				continue
+			}
+			var e types.Event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				log.Printf("unmarshaling event: %v", err)
+				continue
+			}
+			if err := l.Sink.Publish(&e); err != nil {
+				log.Printf("publishing event `%d`: %v", e.ID, err)
+			}
+		}
+	}
+}
+
+// Close stops listening.
+func (l *Listener) Close() error { return l.listener.Close() }
+
+// fail compilation if `Notifier` doesn't implement the `types.EventSink`
+// interface.
+var _ types.EventSink = &Notifier{}
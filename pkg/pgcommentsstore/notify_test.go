@@ -0,0 +1,67 @@
+package pgcommentsstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestListener(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan *types.Event, 1)
+	listener, err := ListenEnv(sinkFunc(func(e *types.Event) error {
+		received <- e
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go listener.Run(ctx)
+
+	notifier := Notifier{Store: store}
+	if err := notifier.Publish(&types.Event{
+		ID:   1,
+		Type: types.EventCommentCreated,
+		Time: someDate,
+		Comment: &types.EventComment{
+			Post:   "post",
+			ID:     "id",
+			Parent: "parent",
+			Author: "author",
+			Body:   "body",
+		},
+	}); err != nil {
+		t.Fatalf("Publish(): unexpected error: %v", err)
+	}
+
+	select {
+	case e := <-received:
+		if e.ID != 1 || e.Type != types.EventCommentCreated {
+			t.Fatalf(
+				"wanted event `1` (`%s`); found `%d` (`%s`)",
+				types.EventCommentCreated,
+				e.ID,
+				e.Type,
+			)
+		}
+		wanted := types.EventComment{
+			Post:   "post",
+			ID:     "id",
+			Parent: "parent",
+		}
+		if *e.Comment != wanted {
+			t.Fatalf("wanted `%+v`; found `%+v`", wanted, e.Comment)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
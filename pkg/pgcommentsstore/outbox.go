@@ -0,0 +1,224 @@
+package pgcommentsstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// commentColumns are the `comments` table's columns in `scanComment()`
+// order.
+const commentColumns = `id, post, parent, author, created, modified, deleted,
+	body, status, removed, spam_verdict, spam_reason`
+
+// withTx runs `f` in a transaction, committing it if `f` succeeds and rolling
+// it back otherwise.
+func (pgcs *PGCommentsStore) withTx(f func(tx *sql.Tx) error) error {
+	tx, err := (*sql.DB)(pgcs).Begin()
+	if err != nil {
+		return fmt.Errorf("beginning postgres transaction: %w", err)
+	}
+	if err := f(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("rolling back postgres transaction: %v", rollbackErr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing postgres transaction: %w", err)
+	}
+	return nil
+}
+
+// putEvent records the event which describes a change to a comment from
+// `before` to `after` (see `types.NewEvent()`) in the outbox, unless the
+// change isn't an event. It must be called in the transaction which changed
+// the comment, so that the event is recorded if and only if the change is.
+func putEvent(tx *sql.Tx, before, after *types.Comment) error {
+	c := after
+	if c == nil {
+		c = before
+	}
+	now := time.Now().UTC()
+	shadowBanned, err := shadowBannedTx(tx, c.Author, now)
+	if err != nil {
+		return err
+	}
+	e, ok := types.NewEvent(before, after, shadowBanned)
+	if !ok {
+		return nil
+	}
+	e.Time = now
+	if _, err := tx.Exec(
+		`INSERT INTO outbox (event, created) VALUES ($1, $2)`,
+		jsonColumn{e},
+		now,
+	); err != nil {
+		return fmt.Errorf("recording `%s` event in outbox: %w", e.Type, err)
+	}
+	return nil
+}
+
+const (
+	// RelayBatchSizeDefault is the default number of events which are
+	// claimed at a time.
+	RelayBatchSizeDefault = 100
+
+	// RelayIntervalDefault is the default delay between checks of an empty
+	// outbox.
+	RelayIntervalDefault = time.Second
+)
+
+// Relay publishes the events in the outbox to a sink and removes them from
+// the outbox. Delivery is at-least-once (see `types.EventSink`). Several
+// relays can share an outbox since each claims its events with `FOR UPDATE
+// SKIP LOCKED`, but events are only published in order by a lone relay.
+type Relay struct {
+	Store *PGCommentsStore
+	Sink  types.EventSink
+
+	// BatchSize is the number of events which are claimed at a time. It
+	// defaults to `RelayBatchSizeDefault`.
+	BatchSize int
+
+	// Interval is the delay between checks of an empty outbox (or after a
+	// failure). It defaults to `RelayIntervalDefault`.
+	Interval time.Duration
+}
+
+// Run relays events until the context is canceled.
+func (r *Relay) Run(ctx context.Context) {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = RelayIntervalDefault
+	}
+	for {
+		n, err := r.RelayOnce()
+		if err != nil {
+			log.Printf("relaying events: %v", err)
+		}
+
+		// keep going while there's a backlog
+		if err == nil && n >= r.batchSize() {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (r *Relay) batchSize() int {
+	if r.BatchSize < 1 {
+		return RelayBatchSizeDefault
+	}
+	return r.BatchSize
+}
+
+// RelayOnce claims a batch of the oldest events, publishes them in order and
+// removes the published ones from the outbox, returning the number which were
+// published. It stops at the first event which the sink fails to publish;
+// that event and the rest of the batch stay in the outbox for the next call.
+// If the process dies before the removal is committed, the events are
+// published again.
+func (r *Relay) RelayOnce() (int, error) {
+	var published []int64
+	var publishErr error
+	if err := r.Store.withTx(func(tx *sql.Tx) error {
+		events, err := claimEvents(tx, r.batchSize())
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if publishErr = r.Sink.Publish(e); publishErr != nil {
+				publishErr = fmt.Errorf(
+					"publishing event `%d`: %w",
+					e.ID,
+					publishErr,
+				)
+				break
+			}
+			published = append(published, e.ID)
+		}
+		if len(published) < 1 {
+			return nil
+		}
+		if _, err := tx.Exec(
+			`DELETE FROM outbox WHERE id = ANY($1)`,
+			pq.Array(published),
+		); err != nil {
+			return fmt.Errorf("removing published events from outbox: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("relaying events: %w", err)
+	}
+	return len(published), publishErr
+}
+
+// claimEvents locks up to `limit` of the oldest events which aren't locked by
+// another transaction.
+func claimEvents(tx *sql.Tx, limit int) ([]*types.Event, error) {
+	rows, err := tx.Query(
+		`SELECT id, event FROM outbox
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claiming events from outbox: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("claimEvents(): closing sql.Rows: %v", err)
+		}
+	}()
+
+	var events []*types.Event
+	for rows.Next() {
+		var id int64
+		var e types.Event
+		if err := rows.Scan(&id, jsonColumn{&e}); err != nil {
+			return nil, fmt.Errorf(
+				"scanning postgres row into event: %w",
+				err,
+			)
+		}
+		e.ID = id
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("claiming events from outbox: %w", err)
+	}
+	return events, nil
+}
+
+var (
+	// OutboxTable holds the events which haven't been relayed yet. Events
+	// are written in the same transactions as the changes they describe.
+	OutboxTable = pgutil.Table{
+		Name: "outbox",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "id",
+			Type: "BIGSERIAL",
+		}},
+		OtherColumns: []pgutil.Column{{
+			// the event as JSON (without its ID)
+			Name: "event",
+			Type: "TEXT",
+		}, {
+			Name:    "created",
+			Type:    "TIMESTAMPTZ",
+			Default: pgutil.SQL("CURRENT_TIMESTAMP"),
+		}},
+	}
+)
@@ -0,0 +1,335 @@
+package pgcommentsstore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestRelay(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := types.Comment{
+		ID:       "id",
+		Post:     "post",
+		Author:   "author",
+		Created:  someDate,
+		Modified: someDate,
+		Body:     "body",
+	}
+	if err := store.Put(&c); err != nil {
+		t.Fatalf("Put(): unexpected error: %v", err)
+	}
+	if err := store.Put(&c); !errors.Is(err, types.ErrCommentExists) {
+		t.Fatalf("Put(): wanted `ErrCommentExists`; found `%v`", err)
+	}
+	if err := store.Update(
+		types.NewCommentPatch("id", "post").SetBody("edited body"),
+	); err != nil {
+		t.Fatalf("Update(): unexpected error: %v", err)
+	}
+	if err := store.Update(
+		types.NewCommentPatch("missing", "post").SetBody("edited body"),
+	); !errors.Is(err, types.ErrCommentNotFound) {
+		t.Fatalf("Update(): wanted `ErrCommentNotFound`; found `%v`", err)
+	}
+	if err := store.Update(
+		types.NewCommentPatch("id", "post").SetDeleted(true),
+	); err != nil {
+		t.Fatalf("Update(): unexpected error: %v", err)
+	}
+	if err := store.Delete("post", "id"); err != nil {
+		t.Fatalf("Delete(): unexpected error: %v", err)
+	}
+
+	var sink recordingSink
+	relay := Relay{Store: store, Sink: &sink}
+	n, err := relay.RelayOnce()
+	if err != nil {
+		t.Fatalf("RelayOnce(): unexpected error: %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("RelayOnce(): wanted `4` events; found `%d`", n)
+	}
+
+	// failed mutations don't record events
+	for i, wanted := range []struct {
+		eventType types.EventType
+		body      string
+	}{
+		{types.EventCommentCreated, "body"},
+		{types.EventCommentUpdated, "edited body"},
+		{types.EventCommentDeleted, ""},
+		{types.EventCommentDeleted, ""},
+	} {
+		e := sink[i]
+		if e.Type != wanted.eventType {
+			t.Fatalf(
+				"events[%d].Type: wanted `%s`; found `%s`",
+				i,
+				wanted.eventType,
+				e.Type,
+			)
+		}
+		if e.Comment.Body != wanted.body {
+			t.Fatalf(
+				"events[%d].Comment.Body: wanted `%s`; found `%s`",
+				i,
+				wanted.body,
+				e.Comment.Body,
+			)
+		}
+		if i > 0 && e.ID <= sink[i-1].ID {
+			t.Fatalf("events[%d].ID: wanted increasing IDs", i)
+		}
+	}
+	if sink[0].Comment.Author != "author" {
+		t.Fatalf(
+			"events[0].Comment.Author: wanted `author`; found `%s`",
+			sink[0].Comment.Author,
+		)
+	}
+
+	// published events are removed from the outbox
+	if n, err := relay.RelayOnce(); err != nil || n != 0 {
+		t.Fatalf("RelayOnce(): wanted `0, nil`; found `%d, %v`", n, err)
+	}
+}
+
+func TestRelay_Visibility(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// comments which aren't visible aren't created until they're approved
+	if err := store.Put(&types.Comment{
+		ID:       "id",
+		Post:     "post",
+		Author:   "author",
+		Created:  someDate,
+		Modified: someDate,
+		Body:     "body",
+		Status:   types.StatusPending,
+	}); err != nil {
+		t.Fatalf("Put(): unexpected error: %v", err)
+	}
+	for _, patch := range []*types.CommentPatch{
+		types.NewCommentPatch("id", "post").SetBody("edited body"),
+		types.NewCommentPatch("id", "post").SetStatus(types.StatusApproved),
+		types.NewCommentPatch("id", "post").SetStatus(types.StatusPending),
+		types.NewCommentPatch("id", "post").SetStatus(types.StatusRejected),
+	} {
+		if err := store.Update(patch); err != nil {
+			t.Fatalf("Update(): unexpected error: %v", err)
+		}
+	}
+	if err := store.Delete("post", "id"); err != nil {
+		t.Fatalf("Delete(): unexpected error: %v", err)
+	}
+
+	var sink recordingSink
+	relay := Relay{Store: store, Sink: &sink}
+	if _, err := relay.RelayOnce(); err != nil {
+		t.Fatalf("RelayOnce(): unexpected error: %v", err)
+	}
+	if len(sink) != 2 {
+		t.Fatalf("wanted `2` events; found `%d`", len(sink))
+	}
+	for i, wanted := range []struct {
+		eventType types.EventType
+		body      string
+	}{
+		{types.EventCommentCreated, "edited body"},
+		{types.EventCommentDeleted, ""},
+	} {
+		if e := sink[i]; e.Type != wanted.eventType ||
+			e.Comment.Body != wanted.body {
+			t.Fatalf(
+				"events[%d]: wanted `%s` with body `%s`; found `%s` with "+
+					"body `%s`",
+				i,
+				wanted.eventType,
+				wanted.body,
+				e.Type,
+				e.Comment.Body,
+			)
+		}
+	}
+}
+
+func TestRelay_ShadowBan(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutSanction(&types.Sanction{
+		User:    "banned",
+		Kind:    types.SanctionShadowBan,
+		Issuer:  "moderator",
+		Created: someDate,
+	}); err != nil {
+		t.Fatalf("PutSanction(): unexpected error: %v", err)
+	}
+
+	// comments by shadow-banned authors are only visible to their authors,
+	// so changes to them aren't events
+	for _, author := range []types.UserID{"banned", "author"} {
+		id := types.CommentID(author)
+		if err := store.Put(&types.Comment{
+			ID:       id,
+			Post:     "post",
+			Author:   author,
+			Created:  someDate,
+			Modified: someDate,
+			Body:     "body",
+		}); err != nil {
+			t.Fatalf("Put(): unexpected error: %v", err)
+		}
+		if err := store.Update(
+			types.NewCommentPatch(id, "post").SetBody("edited body"),
+		); err != nil {
+			t.Fatalf("Update(): unexpected error: %v", err)
+		}
+		if err := store.Delete("post", id); err != nil {
+			t.Fatalf("Delete(): unexpected error: %v", err)
+		}
+	}
+
+	var sink recordingSink
+	relay := Relay{Store: store, Sink: &sink}
+	if _, err := relay.RelayOnce(); err != nil {
+		t.Fatalf("RelayOnce(): unexpected error: %v", err)
+	}
+	if len(sink) != 3 {
+		t.Fatalf("wanted `3` events; found `%d`", len(sink))
+	}
+	for i, e := range sink {
+		if e.Comment.ID != "author" {
+			t.Fatalf(
+				"events[%d]: wanted event for `author`; found `%s`",
+				i,
+				e.Comment.ID,
+			)
+		}
+	}
+}
+
+func TestRelay_AtLeastOnce(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []types.CommentID{"first", "second", "third"} {
+		if err := store.Put(&types.Comment{
+			ID:       id,
+			Post:     "post",
+			Author:   "author",
+			Created:  someDate,
+			Modified: someDate,
+			Body:     "body",
+		}); err != nil {
+			t.Fatalf("Put(): unexpected error: %v", err)
+		}
+	}
+
+	// The sink receives the second event but fails to acknowledge it (e.g.,
+	// it crashed after handling it), so the second and third events are
+	// published again.
+	var flaky recordingSink
+	relay := Relay{Store: store, Sink: sinkFunc(func(e *types.Event) error {
+		if err := flaky.Publish(e); err != nil {
+			return err
+		}
+		if e.Comment.ID == "second" {
+			return errors.New("sink unavailable")
+		}
+		return nil
+	})}
+	if n, err := relay.RelayOnce(); err == nil || n != 1 {
+		t.Fatalf("RelayOnce(): wanted `1, <error>`; found `%d, %v`", n, err)
+	}
+
+	var sink recordingSink
+	relay.Sink = &sink
+	if n, err := relay.RelayOnce(); err != nil || n != 2 {
+		t.Fatalf("RelayOnce(): wanted `2, nil`; found `%d, %v`", n, err)
+	}
+	if len(flaky) != 2 || len(sink) != 2 {
+		t.Fatalf(
+			"wanted 2 events in each sink; found `%d` and `%d`",
+			len(flaky),
+			len(sink),
+		)
+	}
+
+	// duplicates have the same ID so that sinks can drop them
+	if flaky[1].ID != sink[0].ID || sink[0].Comment.ID != "second" {
+		t.Fatalf(
+			"wanted duplicate of event `%d`; found event `%d` (`%s`)",
+			flaky[1].ID,
+			sink[0].ID,
+			sink[0].Comment.ID,
+		)
+	}
+	if sink[1].Comment.ID != "third" {
+		t.Fatalf("wanted event for `third`; found `%s`", sink[1].Comment.ID)
+	}
+}
+
+func TestRelay_SkipLocked(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(&types.Comment{
+		ID:       "id",
+		Post:     "post",
+		Author:   "author",
+		Created:  someDate,
+		Modified: someDate,
+		Body:     "body",
+	}); err != nil {
+		t.Fatalf("Put(): unexpected error: %v", err)
+	}
+
+	// block the first relay while it holds the event
+	claimed, release := make(chan struct{}), make(chan struct{})
+	first := Relay{Store: store, Sink: sinkFunc(func(*types.Event) error {
+		close(claimed)
+		<-release
+		return nil
+	})}
+	done := make(chan error)
+	go func() {
+		_, err := first.RelayOnce()
+		done <- err
+	}()
+	<-claimed
+
+	var sink recordingSink
+	second := Relay{Store: store, Sink: &sink}
+	n, err := second.RelayOnce()
+	close(release)
+	if err != nil || n != 0 {
+		t.Fatalf("RelayOnce(): wanted `0, nil`; found `%d, %v`", n, err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("RelayOnce(): unexpected error: %v", err)
+	}
+}
+
+type recordingSink []*types.Event
+
+func (rs *recordingSink) Publish(e *types.Event) error {
+	*rs = append(*rs, e)
+	return nil
+}
+
+type sinkFunc func(*types.Event) error
+
+func (f sinkFunc) Publish(e *types.Event) error { return f(e) }
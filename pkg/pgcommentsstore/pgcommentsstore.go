@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/weberc2/auth/pkg/pgutil"
 	"github.com/weberc2/comments/pkg/comments/types"
 )
@@ -17,18 +18,7 @@ import (
 type PGCommentsStore sql.DB
 
 func OpenEnv() (*PGCommentsStore, error) {
-	db, err := sql.Open(
-		"postgres",
-		fmt.Sprintf(
-			"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-			getEnv("PG_HOST", "localhost"),
-			getEnv("PG_PORT", "5432"),
-			getEnv("PG_USER", "postgres"),
-			getEnv("PG_PASS", ""),
-			getEnv("PG_DB_NAME", "postgres"),
-			getEnv("PG_SSL_MODE", "disable"),
-		),
-	)
+	db, err := sql.Open("postgres", connInfoEnv())
 	if err != nil {
 		return nil, fmt.Errorf("opening postgres database: %w", err)
 	}
@@ -40,6 +30,20 @@ func OpenEnv() (*PGCommentsStore, error) {
 	return (*PGCommentsStore)(db), nil
 }
 
+// connInfoEnv returns the connection string for the database identified by the
+// `PG_*` environment variables.
+func connInfoEnv() string {
+	return fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		getEnv("PG_HOST", "localhost"),
+		getEnv("PG_PORT", "5432"),
+		getEnv("PG_USER", "postgres"),
+		getEnv("PG_PASS", ""),
+		getEnv("PG_DB_NAME", "postgres"),
+		getEnv("PG_SSL_MODE", "disable"),
+	)
+}
+
 func getEnv(env, def string) string {
 	x := os.Getenv(env)
 	if x == "" {
@@ -48,51 +52,153 @@ func getEnv(env, def string) string {
 	return x
 }
 
+// tables are the tables managed by `PGCommentsStore`. The `comments` table
+// comes first so that it's created first and dropped last.
+var tables = []*pgutil.Table{
+	&Table,
+	&VotesTable,
+	&PostSettingsTable,
+	&RolesTable,
+	&RevisionsTable,
+	&RateLimitsTable,
+	&ReportsTable,
+	&SanctionsTable,
+	&NotificationSettingsTable,
+	&SubscriptionsTable,
+	&InboxTable,
+	&WebhooksTable,
+	&FailedDeliveriesTable,
+	&OutboxTable,
+	&JobsTable,
+}
+
+// migrations bring tables which were created by older versions of
+// `PGCommentsStore` up to date. `pgutil.Table.Ensure()` doesn't alter existing
+// tables, so every column added after a table's creation needs a migration.
+// Migrations must be idempotent since they're run by every `EnsureTable()`.
+var migrations = []string{
+	`ALTER TABLE comments ADD COLUMN IF NOT EXISTS status VARCHAR(16)
+NOT NULL DEFAULT 'approved'`,
+	`UPDATE comments SET status = 'approved' WHERE status = ''`,
+	`ALTER TABLE comments ADD COLUMN IF NOT EXISTS removed BOOLEAN
+NOT NULL DEFAULT false`,
+	`ALTER TABLE comments ADD COLUMN IF NOT EXISTS spam_verdict VARCHAR(16)
+NOT NULL DEFAULT ''`,
+	`ALTER TABLE comments ADD COLUMN IF NOT EXISTS spam_reason VARCHAR(512)
+NOT NULL DEFAULT ''`,
+}
+
 func (pgcs *PGCommentsStore) DropTable() error {
-	return Table.Drop((*sql.DB)(pgcs))
+	for i := len(tables) - 1; i >= 0; i-- {
+		if err := tables[i].Drop((*sql.DB)(pgcs)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (pgcs *PGCommentsStore) EnsureTable() error {
-	return Table.Ensure((*sql.DB)(pgcs))
+	for _, table := range tables {
+		if err := table.Ensure((*sql.DB)(pgcs)); err != nil {
+			return err
+		}
+	}
+	for _, migration := range migrations {
+		if _, err := (*sql.DB)(pgcs).Exec(migration); err != nil {
+			return fmt.Errorf("migrating postgres tables: %w", err)
+		}
+	}
+	return nil
 }
 
 func (pgcs *PGCommentsStore) ClearTable() error {
-	return Table.Clear((*sql.DB)(pgcs))
+	for i := len(tables) - 1; i >= 0; i-- {
+		if err := tables[i].Clear((*sql.DB)(pgcs)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (pgcs *PGCommentsStore) ResetTable() error {
-	return Table.Reset((*sql.DB)(pgcs))
+	if err := pgcs.DropTable(); err != nil {
+		return err
+	}
+	return pgcs.EnsureTable()
 }
 
+// Put inserts the comment and, if it's visible, records a
+// `types.EventCommentCreated` event in the outbox.
 func (pgcs *PGCommentsStore) Put(c *types.Comment) error {
-	return Table.Insert((*sql.DB)(pgcs), (*comment)(c))
+	values := make([]interface{}, 12)
+	(*comment)(c).Values(values)
+	return pgcs.withTx(func(tx *sql.Tx) error {
+		var created types.Comment
+		if err := scanComment(&created, tx.QueryRow(
+			`INSERT INTO comments (
+	post, id, parent, author, created, modified, deleted, body, status,
+	removed, spam_verdict, spam_reason
+) VALUES (
+	$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+) RETURNING `+commentColumns,
+			values...,
+		)); err != nil {
+			if err, ok := err.(*pq.Error); ok && err.Code == "23505" &&
+				err.Constraint == "comments_pkey" {
+				return fmt.Errorf(
+					"inserting row into postgres table `comments`: %w",
+					types.ErrCommentExists,
+				)
+			}
+			return fmt.Errorf(
+				"inserting row into postgres table `comments`: %w",
+				err,
+			)
+		}
+		return putEvent(tx, nil, &created)
+	})
 }
 
 func (pgcs *PGCommentsStore) Comment(
 	p types.PostID,
 	c types.CommentID,
 ) (*types.Comment, error) {
-	var out comment
-	if err := Table.Get(
-		(*sql.DB)(pgcs),
-		&comment{ID: c, Post: p},
-		&out,
-	); err != nil {
-		return nil, err
+	comments, err := pgcs.commentsQueryExtra(
+		scorePointers,
+		`SELECT
+	comments.id, comments.post, comments.parent, comments.author,
+	comments.created, comments.modified, comments.deleted, comments.body,
+	comments.status, comments.removed, comments.spam_verdict,
+	comments.spam_reason, `+scoreColumns+`
+FROM comments `+scoresJoin("comments")+`
+WHERE comments.post = $1 AND comments.id = $2`,
+		p,
+		c,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying comment from postgres: %w", err)
+	}
+	if len(comments) < 1 {
+		return nil, types.ErrCommentNotFound
 	}
-	return (*types.Comment)(&out), nil
+	return comments[0], nil
 }
 
 func (pgcs *PGCommentsStore) Replies(
 	p types.PostID,
 	parent types.CommentID,
 ) ([]*types.Comment, error) {
-	comments, err := pgcs.commentsQuery(
+	comments, err := pgcs.commentsQueryExtra(
+		scorePointers,
 		`WITH RECURSIVE t AS (
 	SELECT * FROM comments WHERE post = $1 AND parent = $2 UNION
 	SELECT comments.* FROM comments JOIN t ON
 	comments.post = t.post AND comments.parent = t.id
-) SELECT id, post, parent, author, created, modified, deleted, body FROM t`,
+) SELECT
+	t.id, t.post, t.parent, t.author, t.created, t.modified, t.deleted,
+	t.body, t.status, t.removed, t.spam_verdict, t.spam_reason,
+	`+scoreColumns+`
+FROM t `+scoresJoin("t"),
 		p,
 		parent,
 	)
@@ -102,6 +208,304 @@ func (pgcs *PGCommentsStore) Replies(
 	return comments, nil
 }
 
+// repliesOrders maps each sort order to the `ORDER BY` clause which
+// implements it and to the predicate which selects the siblings which come
+// after the cursor (`after`).
+var repliesOrders = map[types.Sort]struct{ orderBy, after string }{
+	types.SortOldest: {
+		orderBy: "created, id",
+		after:   "(siblings.created, siblings.id) > (after.created, after.id)",
+	},
+	types.SortNewest: {
+		orderBy: "created DESC, id DESC",
+		after:   "(siblings.created, siblings.id) < (after.created, after.id)",
+	},
+	types.SortReplies: {
+		orderBy: "replies DESC, created, id",
+		after: "(-siblings.replies, siblings.created, siblings.id) > " +
+			"(-after.key, after.created, after.id)",
+	},
+	types.SortTop: {
+		orderBy: "score DESC, created, id",
+		after: "(-siblings.score, siblings.created, siblings.id) > " +
+			"(-after.key, after.created, after.id)",
+	},
+}
+
+func (pgcs *PGCommentsStore) RepliesPage(
+	q *types.RepliesQuery,
+) (*types.RepliesPage, error) {
+	sort, err := types.ParseSort(string(q.Sort))
+	if err != nil {
+		return nil, err
+	}
+	order := repliesOrders[sort]
+
+	var cursor types.Cursor
+	if q.Cursor != nil {
+		cursor = *q.Cursor
+	}
+
+	// non-nil so that it's passed as an empty array rather than `NULL`
+	shadowBanned := make([]string, len(q.ShadowBanned))
+	for i, user := range q.ShadowBanned {
+		shadowBanned[i] = string(user)
+	}
+
+	// `visible` selects the post's comments which are visible to the viewer
+	// (`$9`): approved comments by authors who aren't shadow-banned (`$11`),
+	// the viewer's own comments, and every comment if `$10` is set. Since
+	// statuses are stored normalized, `status = 'approved'` is the same rule
+	// as `types.Status.Visible()`.
+	//
+	// `threads` selects one more thread than the limit so we can tell whether
+	// there is a next page, but only the first `$3` threads are expanded into
+	// their subtrees. The extra thread (if any) is returned without its
+	// descendants and removed below.
+	//
+	// `t` walks the whole subtree of each thread, tracking each comment's
+	// depth and its "anchor": the comment itself if it's within `$4` (the max
+	// depth; zero means unlimited) or else its ancestor at depth `$4`. Only
+	// comments within the max depth are returned, and each is returned with
+	// the number of deeper comments anchored to it.
+	//
+	// Every row is ordered by the sort order, which orders siblings at every
+	// level of the tree.
+	comments, err := pgcs.commentsQueryExtra(
+		func(c *types.Comment) []interface{} {
+			return append(
+				[]interface{}{&c.HiddenReplies, &c.ReplyCount},
+				scorePointers(c)...,
+			)
+		},
+		fmt.Sprintf(
+			`WITH RECURSIVE visible AS (
+	SELECT * FROM comments
+	WHERE post = $1 AND (
+		(status = 'approved' AND NOT author = ANY($11)) OR
+		($9 <> '' AND author = $9) OR
+		$10
+	)
+), after AS (
+	SELECT
+		$5::BOOLEAN AS valid,
+		$6::BIGINT AS key,
+		$7::TIMESTAMPTZ AS created,
+		$8::TEXT AS id
+), siblings AS (
+	SELECT visible.*, (
+		SELECT count(*) FROM visible AS c
+		WHERE c.post = visible.post AND c.parent = visible.id
+	) AS replies, %[3]s
+	FROM visible %[4]s
+	WHERE visible.parent = $2
+), threads AS (
+	SELECT siblings.* FROM siblings, after
+	WHERE NOT after.valid OR %[2]s
+	ORDER BY %[1]s
+	LIMIT $3 + 1
+), t AS (
+	SELECT
+		page.post, page.id, page.parent, page.author, page.created,
+		page.modified, page.deleted, page.body, page.status, page.removed,
+		page.spam_verdict, page.spam_reason, 1 AS depth,
+		page.id::TEXT AS anchor
+	FROM (SELECT * FROM threads ORDER BY %[1]s LIMIT $3) AS page
+	UNION ALL
+	SELECT
+		visible.post, visible.id, visible.parent, visible.author,
+		visible.created, visible.modified, visible.deleted, visible.body,
+		visible.status, visible.removed, visible.spam_verdict,
+		visible.spam_reason, t.depth + 1, CASE
+			WHEN $4 = 0 OR t.depth < $4 THEN visible.id::TEXT
+			ELSE t.anchor
+		END
+	FROM visible JOIN t ON
+	visible.post = t.post AND visible.parent = t.id
+) SELECT
+	t.id, t.post, t.parent, t.author, t.created, t.modified, t.deleted,
+	t.body, t.status, t.removed, t.spam_verdict, t.spam_reason,
+	COALESCE(hidden.replies, 0) AS hidden_replies,
+	(
+		SELECT count(*) FROM visible AS c
+		WHERE c.post = t.post AND c.parent = t.id
+	) AS replies, %[3]s
+FROM t LEFT JOIN (
+	SELECT anchor, count(*) AS replies FROM t
+	WHERE $4 > 0 AND depth > $4
+	GROUP BY anchor
+) AS hidden ON hidden.anchor = t.id
+%[5]s
+WHERE $4 = 0 OR t.depth <= $4
+UNION ALL (
+	SELECT
+		id, post, parent, author, created, modified, deleted, body, status,
+		removed, spam_verdict, spam_reason, 0, replies, score, upvotes,
+		downvotes
+	FROM threads ORDER BY %[1]s OFFSET $3
+)
+ORDER BY %[1]s`,
+			order.orderBy,
+			order.after,
+			scoreColumns,
+			scoresJoin("visible"),
+			scoresJoin("t"),
+		),
+		q.Post,
+		q.Parent,
+		q.Limit,
+		q.MaxDepth,
+		q.Cursor != nil,
+		cursor.Key,
+		cursor.Created,
+		cursor.ID,
+		q.Viewer,
+		q.AllStatuses,
+		pq.Array(shadowBanned),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying replies page from postgres: %w", err)
+	}
+
+	// The rows are sorted, so the threads are too.
+	var threads []*types.Comment
+	for _, c := range comments {
+		if c.Parent == q.Parent {
+			threads = append(threads, c)
+		}
+	}
+
+	page := types.RepliesPage{Comments: comments}
+	if len(threads) > q.Limit {
+		extra := threads[q.Limit]
+		for i, c := range comments {
+			if c == extra {
+				page.Comments = append(comments[:i], comments[i+1:]...)
+				break
+			}
+		}
+		page.Next = types.CursorFor(sort, threads[q.Limit-1])
+	}
+	return &page, nil
+}
+
+// CommentsByStatus returns every comment with the provided status, oldest
+// first.
+func (pgcs *PGCommentsStore) CommentsByStatus(
+	status types.Status,
+) ([]*types.Comment, error) {
+	comments, err := pgcs.commentsQueryExtra(
+		scorePointers,
+		`SELECT
+	comments.id, comments.post, comments.parent, comments.author,
+	comments.created, comments.modified, comments.deleted, comments.body,
+	comments.status, comments.removed, comments.spam_verdict,
+	comments.spam_reason, `+scoreColumns+`
+FROM comments `+scoresJoin("comments")+`
+WHERE comments.status = $1
+ORDER BY comments.created, comments.post, comments.id`,
+		status,
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"querying comments by status from postgres: %w",
+			err,
+		)
+	}
+	return comments, nil
+}
+
+// CommentsByAuthor returns up to `limit` of the author's comments on every
+// post, newest first.
+func (pgcs *PGCommentsStore) CommentsByAuthor(
+	author types.UserID,
+	limit int,
+) ([]*types.Comment, error) {
+	comments, err := pgcs.commentsQueryExtra(
+		scorePointers,
+		`SELECT
+	comments.id, comments.post, comments.parent, comments.author,
+	comments.created, comments.modified, comments.deleted, comments.body,
+	comments.status, comments.removed, comments.spam_verdict,
+	comments.spam_reason, `+scoreColumns+`
+FROM comments `+scoresJoin("comments")+`
+WHERE comments.author = $1
+ORDER BY comments.created DESC, comments.id DESC
+LIMIT $2`,
+		author,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"querying comments by author from postgres: %w",
+			err,
+		)
+	}
+	return comments, nil
+}
+
+// CommentsByPost returns up to `limit` of the post's comments, newest first.
+func (pgcs *PGCommentsStore) CommentsByPost(
+	post types.PostID,
+	limit int,
+) ([]*types.Comment, error) {
+	comments, err := pgcs.commentsQueryExtra(
+		scorePointers,
+		`SELECT
+	comments.id, comments.post, comments.parent, comments.author,
+	comments.created, comments.modified, comments.deleted, comments.body,
+	comments.status, comments.removed, comments.spam_verdict,
+	comments.spam_reason, `+scoreColumns+`
+FROM comments `+scoresJoin("comments")+`
+WHERE comments.post = $1
+ORDER BY comments.created DESC, comments.id DESC
+LIMIT $2`,
+		post,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"querying comments by post from postgres: %w",
+			err,
+		)
+	}
+	return comments, nil
+}
+
+// Ancestors returns the ancestors of the comments (which may be on different
+// posts) in a single query.
+func (pgcs *PGCommentsStore) Ancestors(
+	comments ...*types.Comment,
+) ([]*types.Comment, error) {
+	posts := make([]string, 0, len(comments))
+	parents := make([]string, 0, len(comments))
+	for _, c := range comments {
+		if c.Parent != "" {
+			posts = append(posts, string(c.Post))
+			parents = append(parents, string(c.Parent))
+		}
+	}
+	if len(parents) < 1 {
+		return []*types.Comment{}, nil
+	}
+	ancestors, err := pgcs.commentsQuery(
+		`WITH RECURSIVE t AS (
+	SELECT comments.* FROM comments
+	JOIN UNNEST($1::VARCHAR(255)[], $2::VARCHAR(255)[]) AS c(post, id)
+	ON comments.post = c.post AND comments.id = c.id
+	UNION
+	SELECT comments.* FROM comments JOIN t ON
+	comments.post = t.post AND comments.id = t.parent
+) SELECT `+commentColumns+` FROM t`,
+		pq.Array(posts),
+		pq.Array(parents),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying ancestors from postgres: %w", err)
+	}
+	return ancestors, nil
+}
+
 func (pgcs *PGCommentsStore) List() ([]*types.Comment, error) {
 	result, err := Table.List((*sql.DB)(pgcs))
 	if err != nil {
@@ -120,9 +524,23 @@ func (pgcs *PGCommentsStore) List() ([]*types.Comment, error) {
 	return comments, nil
 }
 
+// commentsQuery runs a query whose rows are comment columns (`id`, `post`,
+// `parent`, `author`, `created`, `modified`, `deleted`, `body`, `status`,
+// `removed`, `spam_verdict`, and `spam_reason`).
 func (pgcs *PGCommentsStore) commentsQuery(
 	query string,
 	vs ...interface{},
+) ([]*types.Comment, error) {
+	return pgcs.commentsQueryExtra(nil, query, vs...)
+}
+
+// commentsQueryExtra is like `commentsQuery` except that the comment columns
+// may be followed by additional columns. For each row, `extra` (if non-nil)
+// returns pointers into the row's comment which receive those columns.
+func (pgcs *PGCommentsStore) commentsQueryExtra(
+	extra func(*types.Comment) []interface{},
+	query string,
+	vs ...interface{},
 ) ([]*types.Comment, error) {
 	rows, err := (*sql.DB)(pgcs).Query(query, vs...)
 	if err != nil {
@@ -140,7 +558,11 @@ func (pgcs *PGCommentsStore) commentsQuery(
 	out := []*types.Comment{} // every item in `out` points into `buf`
 	for i := 0; rows.Next(); i++ {
 		buf = append(buf, types.Comment{})
-		if err := scanComment(&buf[i], rows); err != nil {
+		var pointers []interface{}
+		if extra != nil {
+			pointers = extra(&buf[i])
+		}
+		if err := scanComment(&buf[i], rows, pointers...); err != nil {
 			return nil, fmt.Errorf(
 				"scanning postgres row into comment: %w",
 				err,
@@ -154,18 +576,26 @@ func (pgcs *PGCommentsStore) commentsQuery(
 func scanComment(
 	c *types.Comment,
 	s interface{ Scan(...interface{}) error },
+	extra ...interface{},
 ) error {
 	var createdString, modifiedString string
-	if err := s.Scan(
-		&c.ID,
-		&c.Post,
-		&c.Parent,
-		&c.Author,
-		&createdString,
-		&modifiedString,
-		&c.Deleted,
-		&c.Body,
-	); err != nil {
+	if err := s.Scan(append(
+		[]interface{}{
+			&c.ID,
+			&c.Post,
+			&c.Parent,
+			&c.Author,
+			&createdString,
+			&modifiedString,
+			&c.Deleted,
+			&c.Body,
+			&c.Status,
+			&c.Removed,
+			&c.SpamVerdict,
+			&c.SpamReason,
+		},
+		extra...,
+	)...); err != nil {
 		return err
 	}
 	created, err := time.Parse(time.RFC3339, createdString)
@@ -204,23 +634,41 @@ func (pgcs *PGCommentsStore) Update(c *types.CommentPatch) error {
 	}
 
 	columns, params := fieldsToColumnsAndParams(c)
-	// The `RETURNING id` is required to provoke a `sql.ErrNoRows` response in
-	// cases where the `(post, id)` tuple is not found. Similarly, the `dummy`
-	// variable is required to prevent the `Scan()` call from failing.
-	var dummy string
-	if err := (*sql.DB)(pgcs).QueryRow(
-		fmt.Sprintf(
-			"UPDATE comments SET %s WHERE id=$1 AND post=$2 RETURNING id",
-			columns,
-		),
-		params...,
-	).Scan(&dummy); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			err = types.ErrCommentNotFound
+	return pgcs.withTx(func(tx *sql.Tx) error {
+		// The event depends on the comment's visibility before and after
+		// the update (see `putEvent()`), so lock the comment until the
+		// update is committed. `sql.ErrNoRows` means the `(post, id)` tuple
+		// is not found.
+		var before types.Comment
+		if err := scanComment(&before, tx.QueryRow(
+			`SELECT `+commentColumns+` FROM comments
+WHERE id = $1 AND post = $2
+FOR UPDATE`,
+			c.ID(),
+			c.Post(),
+		)); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err = types.ErrCommentNotFound
+			}
+			return fmt.Errorf("updating comment in postgres: %w", err)
 		}
-		return fmt.Errorf("updating comment in postgres: %w", err)
-	}
-	return nil
+
+		var updated types.Comment
+		if err := scanComment(&updated, tx.QueryRow(
+			fmt.Sprintf(
+				"UPDATE comments SET %s WHERE id=$1 AND post=$2 RETURNING %s",
+				columns,
+				commentColumns,
+			),
+			params...,
+		)); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err = types.ErrCommentNotFound
+			}
+			return fmt.Errorf("updating comment in postgres: %w", err)
+		}
+		return putEvent(tx, &before, &updated)
+	})
 }
 
 func fieldsToColumnsAndParams(cp *types.CommentPatch) (string, []interface{}) {
@@ -246,11 +694,16 @@ func fieldsToColumnsAndParams(cp *types.CommentPatch) (string, []interface{}) {
 }
 
 func fieldToColumn(field types.Field) string {
-	// At some point in the future, column names and field names might differ
-	// (e.g., if we add a field with multiple words, we'll camel-case the
-	// field name but snake-case the column name since Postgres doesn't do well
-	// with case sensitivity).
-	return field.String()
+	// Multi-word fields are camel-cased but their columns are snake-cased
+	// since Postgres doesn't do well with case sensitivity.
+	switch field {
+	case types.FieldSpamVerdict:
+		return "spam_verdict"
+	case types.FieldSpamReason:
+		return "spam_reason"
+	default:
+		return field.String()
+	}
 }
 
 func fieldToSQLParam(cp *types.CommentPatch, field types.Field) interface{} {
@@ -271,13 +724,40 @@ func fieldToSQLParam(cp *types.CommentPatch, field types.Field) interface{} {
 		return cp.Deleted()
 	case types.FieldBody:
 		return cp.Body()
+	case types.FieldStatus:
+		return cp.Status().Normalize()
+	case types.FieldRemoved:
+		return cp.Removed()
+	case types.FieldSpamVerdict:
+		return cp.SpamVerdict()
+	case types.FieldSpamReason:
+		return cp.SpamReason()
 	default:
 		panic(fmt.Sprintf("invalid field: %d", field))
 	}
 }
 
+// Delete removes the comment and, if it was visible, records a
+// `types.EventCommentDeleted` event in the outbox.
 func (pgcs *PGCommentsStore) Delete(p types.PostID, c types.CommentID) error {
-	return Table.Delete((*sql.DB)(pgcs), &comment{Post: p, ID: c})
+	return pgcs.withTx(func(tx *sql.Tx) error {
+		var deleted types.Comment
+		if err := scanComment(&deleted, tx.QueryRow(
+			`DELETE FROM comments WHERE post = $1 AND id = $2
+RETURNING `+commentColumns,
+			p,
+			c,
+		)); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err = types.ErrCommentNotFound
+			}
+			return fmt.Errorf(
+				"deleting row from postgres table `comments`: %w",
+				err,
+			)
+		}
+		return putEvent(tx, &deleted, nil)
+	})
 }
 
 // Implement `pgutil.Item` for `types.Comment`.
@@ -297,6 +777,10 @@ func (c *comment) Values(values []interface{}) {
 	values[5] = c.Modified
 	values[6] = c.Deleted
 	values[7] = c.Body
+	values[8] = c.Status.Normalize()
+	values[9] = c.Removed
+	values[10] = c.SpamVerdict
+	values[11] = c.SpamReason
 }
 
 func (c *comment) Scan(pointers []interface{}) {
@@ -308,13 +792,21 @@ func (c *comment) Scan(pointers []interface{}) {
 	pointers[5] = &c.Modified
 	pointers[6] = &c.Deleted
 	pointers[7] = &c.Body
+	pointers[8] = &c.Status
+	pointers[9] = &c.Removed
+	pointers[10] = &c.SpamVerdict
+	pointers[11] = &c.SpamReason
 }
 
 var (
 	// fail compilation if `comment` doesn't implement the `pgutil.Item`
 	// interface.
-	_ pgutil.Item         = &comment{}
-	_ types.CommentsStore = new(PGCommentsStore)
+	_ pgutil.Item             = &comment{}
+	_ types.CommentsStore     = new(PGCommentsStore)
+	_ types.VotesStore        = new(PGCommentsStore)
+	_ types.PostSettingsStore = new(PGCommentsStore)
+	_ types.RolesStore        = new(PGCommentsStore)
+	_ types.RevisionsStore    = new(PGCommentsStore)
 
 	Table = pgutil.Table{
 		Name: "comments",
@@ -347,6 +839,22 @@ var (
 		}, {
 			Name: "body",
 			Type: "VARCHAR(5096)",
+		}, {
+			Name:    "status",
+			Type:    "VARCHAR(16)",
+			Default: pgutil.NewString(string(types.StatusApproved)),
+		}, {
+			Name:    "removed",
+			Type:    "BOOLEAN",
+			Default: pgutil.NewBoolean(false),
+		}, {
+			Name:    "spam_verdict",
+			Type:    "VARCHAR(16)",
+			Default: pgutil.NewString(""),
+		}, {
+			Name:    "spam_reason",
+			Type:    "VARCHAR(512)",
+			Default: pgutil.NewString(""),
 		}},
 		ExistsErr:   types.ErrCommentExists,
 		NotFoundErr: types.ErrCommentNotFound,
@@ -96,13 +96,15 @@ func TestPGCommentsStore_Comment(t *testing.T) {
 	}
 
 	input := types.Comment{
-		ID:       "id",
-		Post:     "post",
-		Parent:   "",
-		Author:   "author",
-		Created:  someDate,
-		Modified: someDate,
-		Body:     "body",
+		ID:          "id",
+		Post:        "post",
+		Parent:      "",
+		Author:      "author",
+		Created:     someDate,
+		Modified:    someDate,
+		Body:        "body",
+		SpamVerdict: types.VerdictHold,
+		SpamReason:  "too many links (4 > 3)",
 	}
 
 	if err := store.Put(&input); err != nil {
@@ -516,6 +518,183 @@ func TestPGCommentsStore_Replies(t *testing.T) {
 	}
 }
 
+func TestPGCommentsStore_RepliesPage(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := []*types.Comment{
+		{
+			ID:       "a",
+			Post:     "post",
+			Parent:   "",
+			Author:   "author",
+			Created:  someDate,
+			Modified: someDate,
+			Body:     "body",
+		},
+		{
+			ID:       "a-child",
+			Post:     "post",
+			Parent:   "a",
+			Author:   "author",
+			Created:  someDate.Add(time.Hour),
+			Modified: someDate.Add(time.Hour),
+			Body:     "body",
+		},
+		{
+			ID:       "b",
+			Post:     "post",
+			Parent:   "",
+			Author:   "author",
+			Created:  someDate.Add(time.Hour),
+			Modified: someDate.Add(time.Hour),
+			Body:     "body",
+		},
+		{
+			ID:       "b-child",
+			Post:     "post",
+			Parent:   "b",
+			Author:   "author",
+			Created:  someDate.Add(2 * time.Hour),
+			Modified: someDate.Add(2 * time.Hour),
+			Body:     "body",
+		},
+	}
+
+	for _, testCase := range []struct {
+		name          string
+		query         types.RepliesQuery
+		wantedReplies []*types.Comment
+		votes         []*types.Vote
+		wantedNext    *types.Cursor
+		wantedHidden  map[types.CommentID]int
+	}{
+		{
+			name:          "first page",
+			query:         types.RepliesQuery{Post: "post", Limit: 1},
+			wantedReplies: []*types.Comment{state[0], state[1]},
+			wantedNext:    types.CursorFor(types.SortOldest, state[0]),
+		},
+		{
+			name: "last page",
+			query: types.RepliesQuery{
+				Post:   "post",
+				Limit:  1,
+				Cursor: types.CursorFor(types.SortOldest, state[0]),
+			},
+			wantedReplies: []*types.Comment{state[2], state[3]},
+		},
+		{
+			name: "newest first",
+			query: types.RepliesQuery{
+				Post:  "post",
+				Limit: 1,
+				Sort:  types.SortNewest,
+			},
+			wantedReplies: []*types.Comment{state[2], state[3]},
+			wantedNext:    types.CursorFor(types.SortNewest, state[2]),
+		},
+		{
+			name: "top first",
+			query: types.RepliesQuery{
+				Post:  "post",
+				Limit: 1,
+				Sort:  types.SortTop,
+			},
+			votes: []*types.Vote{
+				{Post: "post", Comment: "b", User: "u1", Value: types.Upvote},
+				{Post: "post", Comment: "a", User: "u1", Value: types.Downvote},
+			},
+			wantedReplies: []*types.Comment{state[2], state[3]},
+			wantedNext: &types.Cursor{
+				Sort:    types.SortTop,
+				Key:     1,
+				Created: state[2].Created,
+				ID:      state[2].ID,
+			},
+		},
+		{
+			name:          "exact fit",
+			query:         types.RepliesQuery{Post: "post", Limit: 2},
+			wantedReplies: state,
+		},
+		{
+			name: "max depth",
+			query: types.RepliesQuery{
+				Post:     "post",
+				Limit:    2,
+				MaxDepth: 1,
+			},
+			wantedReplies: []*types.Comment{state[0], state[2]},
+			wantedHidden:  map[types.CommentID]int{"a": 1, "b": 1},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := store.ClearTable(); err != nil {
+				t.Fatalf("clearing `comments` table: %v", err)
+			}
+
+			for _, comment := range state {
+				if err := store.Put(comment); err != nil {
+					t.Fatalf(
+						"unexpected error preparing test database state: %v",
+						err,
+					)
+				}
+			}
+
+			for _, vote := range testCase.votes {
+				if err := store.PutVote(vote); err != nil {
+					t.Fatalf(
+						"unexpected error preparing test database state: %v",
+						err,
+					)
+				}
+			}
+
+			page, err := store.RepliesPage(&testCase.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if err := types.CompareComments(
+				append([]*types.Comment(nil), testCase.wantedReplies...),
+				page.Comments,
+			); err != nil {
+				t.Fatalf("comparing replies: %v", err)
+			}
+
+			switch {
+			case testCase.wantedNext == nil && page.Next != nil:
+				t.Fatalf("Next: wanted `nil`; found `%v`", page.Next)
+			case testCase.wantedNext != nil && page.Next == nil:
+				t.Fatalf("Next: wanted `%v`; found `nil`", testCase.wantedNext)
+			case testCase.wantedNext != nil &&
+				(!testCase.wantedNext.Created.Equal(page.Next.Created) ||
+					testCase.wantedNext.ID != page.Next.ID):
+				t.Fatalf(
+					"Next: wanted `%v`; found `%v`",
+					testCase.wantedNext,
+					page.Next,
+				)
+			}
+
+			for _, c := range page.Comments {
+				if c.HiddenReplies != testCase.wantedHidden[c.ID] {
+					t.Fatalf(
+						"Comment[%s].HiddenReplies: wanted `%d`; found `%d`",
+						c.ID,
+						testCase.wantedHidden[c.ID],
+						c.HiddenReplies,
+					)
+				}
+			}
+		})
+	}
+}
+
 func testPGCommentsStore() (*PGCommentsStore, error) {
 	pgcs, err := OpenEnv()
 	if err != nil {
@@ -0,0 +1,60 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func (pgcs *PGCommentsStore) PostSettings(
+	p types.PostID,
+) (*types.PostSettings, error) {
+	var out postSettings
+	if err := PostSettingsTable.Get(
+		(*sql.DB)(pgcs),
+		&postSettings{Post: p},
+		&out,
+	); err != nil {
+		return nil, err
+	}
+	return (*types.PostSettings)(&out), nil
+}
+
+func (pgcs *PGCommentsStore) PutPostSettings(s *types.PostSettings) error {
+	return PostSettingsTable.Upsert((*sql.DB)(pgcs), (*postSettings)(s))
+}
+
+// Implement `pgutil.Item` for `types.PostSettings` (see `comment` for the
+// rationale).
+type postSettings types.PostSettings
+
+func (s *postSettings) Values(values []interface{}) {
+	values[0] = s.Post
+	values[1] = s.Premoderate
+}
+
+func (s *postSettings) Scan(pointers []interface{}) {
+	pointers[0] = &s.Post
+	pointers[1] = &s.Premoderate
+}
+
+var (
+	// fail compilation if `postSettings` doesn't implement the `pgutil.Item`
+	// interface.
+	_ pgutil.Item = &postSettings{}
+
+	PostSettingsTable = pgutil.Table{
+		Name: "post_settings",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "post",
+			Type: "VARCHAR(255)",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name:    "premoderate",
+			Type:    "BOOLEAN",
+			Default: pgutil.NewBoolean(false),
+		}},
+		NotFoundErr: types.ErrPostSettingsNotFound,
+	}
+)
@@ -0,0 +1,135 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+// Take implements `types.RateLimitStore`. The buckets' rows are locked for
+// the duration of the transaction so that concurrent requests (including
+// those served by other replicas) can't take the same tokens.
+func (pgcs *PGCommentsStore) Take(
+	keys []string,
+	limit types.Limit,
+	now time.Time,
+) (time.Duration, error) {
+	tx, err := (*sql.DB)(pgcs).Begin()
+	if err != nil {
+		return 0, fmt.Errorf("taking rate limit token: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("PGCommentsStore.Take(): rolling back: %v", err)
+		}
+	}()
+
+	// create full buckets for the keys which don't have one so there are
+	// rows to lock
+	full := limit.Full(now)
+	if _, err := tx.Exec(
+		`INSERT INTO rate_limits ("bucket", "tokens", "updated")
+SELECT "bucket", $2, $3 FROM UNNEST($1::VARCHAR(512)[]) AS "bucket"
+ON CONFLICT ("bucket") DO NOTHING`,
+		pq.Array(keys),
+		full.Tokens,
+		full.Updated,
+	); err != nil {
+		return 0, fmt.Errorf("taking rate limit token: %w", err)
+	}
+
+	// the rows are locked in a consistent order so that concurrent
+	// transactions can't deadlock
+	rows, err := tx.Query(
+		`SELECT "bucket", "tokens", "updated" FROM rate_limits
+WHERE "bucket" = ANY($1)
+ORDER BY "bucket"
+FOR UPDATE`,
+		pq.Array(keys),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("taking rate limit token: %w", err)
+	}
+	defer rows.Close()
+	var found []string
+	var buckets []types.Bucket
+	for rows.Next() {
+		var key string
+		var bucket types.Bucket
+		if err := rows.Scan(
+			&key,
+			&bucket.Tokens,
+			&bucket.Updated,
+		); err != nil {
+			return 0, fmt.Errorf("taking rate limit token: %w", err)
+		}
+		found = append(found, key)
+		buckets = append(buckets, bucket)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("taking rate limit token: %w", err)
+	}
+
+	buckets, retryAfter := limit.TakeAll(buckets, now)
+	for i, key := range found {
+		if _, err := tx.Exec(
+			`UPDATE rate_limits SET "tokens" = $2, "updated" = $3
+WHERE "bucket" = $1`,
+			key,
+			buckets[i].Tokens,
+			buckets[i].Updated,
+		); err != nil {
+			return 0, fmt.Errorf("taking rate limit token: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("taking rate limit token: %w", err)
+	}
+	return retryAfter, nil
+}
+
+// PruneRateLimits deletes the buckets which haven't been used since `before`.
+// Callers should choose a time which is at least as long ago as the longest
+// limit's period so that pruned buckets would have refilled anyway. It
+// returns the number of buckets which were deleted.
+func (pgcs *PGCommentsStore) PruneRateLimits(before time.Time) (int, error) {
+	result, err := (*sql.DB)(pgcs).Exec(
+		`DELETE FROM rate_limits WHERE "updated" < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("pruning rate limits: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("pruning rate limits: %w", err)
+	}
+	return int(n), nil
+}
+
+var (
+	// fail compilation if `PGCommentsStore` doesn't implement the
+	// `types.RateLimitStore` interface.
+	_ types.RateLimitStore = &PGCommentsStore{}
+
+	RateLimitsTable = pgutil.Table{
+		Name: "rate_limits",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "bucket",
+			Type: "VARCHAR(512)",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name: "tokens",
+			Type: "DOUBLE PRECISION",
+		}, {
+			Name: "updated",
+			Type: "TIMESTAMPTZ",
+		}},
+	}
+)
@@ -0,0 +1,87 @@
+package pgcommentsstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestPGCommentsStore_Take(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := types.Limit{Count: 2, Per: time.Minute}
+	for i, wanted := range []struct {
+		key        string
+		now        time.Time
+		retryAfter time.Duration
+	}{
+		{key: "a", now: now},
+		{key: "a", now: now},
+		{key: "a", now: now, retryAfter: 30 * time.Second},
+		{key: "b", now: now}, // buckets are independent
+		{key: "a", now: now.Add(30 * time.Second)},
+		{
+			key:        "a",
+			now:        now.Add(30 * time.Second),
+			retryAfter: 30 * time.Second,
+		},
+	} {
+		retryAfter, err := store.Take([]string{wanted.key}, limit, wanted.now)
+		if err != nil {
+			t.Fatalf("Take() #%d: unexpected error: %v", i, err)
+		}
+		if retryAfter != wanted.retryAfter {
+			t.Fatalf(
+				"Take() #%d: wanted retry after `%s`; found `%s`",
+				i,
+				wanted.retryAfter,
+				retryAfter,
+			)
+		}
+	}
+
+	n, err := store.PruneRateLimits(now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("PruneRateLimits(): unexpected error: %v", err)
+	}
+	if n != 1 { // only `b` hasn't been used since `now`
+		t.Fatalf("PruneRateLimits(): wanted `1`; found `%d`", n)
+	}
+}
+
+func TestPGCommentsStore_Take_AllOrNothing(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := types.Limit{Count: 1, Per: time.Minute}
+	for i, wanted := range []struct {
+		keys       []string
+		retryAfter time.Duration
+	}{
+		{keys: []string{"user"}},
+		{keys: []string{"user", "ip"}, retryAfter: time.Minute},
+		{keys: []string{"ip"}}, // the refused request took no token
+		{keys: []string{"ip"}, retryAfter: time.Minute},
+	} {
+		retryAfter, err := store.Take(wanted.keys, limit, now)
+		if err != nil {
+			t.Fatalf("Take() #%d: unexpected error: %v", i, err)
+		}
+		if retryAfter != wanted.retryAfter {
+			t.Fatalf(
+				"Take() #%d: wanted retry after `%s`; found `%s`",
+				i,
+				wanted.retryAfter,
+				retryAfter,
+			)
+		}
+	}
+}
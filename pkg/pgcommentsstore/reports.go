@@ -0,0 +1,144 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func (pgcs *PGCommentsStore) PutReport(r *types.Report) error {
+	return ReportsTable.Insert((*sql.DB)(pgcs), (*report)(r))
+}
+
+func (pgcs *PGCommentsStore) OpenReports() ([]*types.Report, error) {
+	rows, err := (*sql.DB)(pgcs).Query(
+		`SELECT post, comment, reporter, reason, created, resolved
+FROM reports
+WHERE NOT resolved
+ORDER BY created, post, comment, reporter`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying open reports from postgres: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf(
+				"PGCommentsStore.OpenReports(): closing sql.Rows: %v",
+				err,
+			)
+		}
+	}()
+
+	reports := []*types.Report{}
+	for rows.Next() {
+		var r types.Report
+		if err := rows.Scan(
+			&r.Post,
+			&r.Comment,
+			&r.Reporter,
+			&r.Reason,
+			&r.Created,
+			&r.Resolved,
+		); err != nil {
+			return nil, fmt.Errorf(
+				"scanning postgres row into report: %w",
+				err,
+			)
+		}
+		reports = append(reports, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying open reports from postgres: %w", err)
+	}
+	return reports, nil
+}
+
+func (pgcs *PGCommentsStore) CountOpenReports(
+	p types.PostID,
+	c types.CommentID,
+) (int, error) {
+	var n int
+	if err := (*sql.DB)(pgcs).QueryRow(
+		`SELECT count(*) FROM reports
+WHERE post = $1 AND comment = $2 AND NOT resolved`,
+		p,
+		c,
+	).Scan(&n); err != nil {
+		return 0, fmt.Errorf("counting open reports in postgres: %w", err)
+	}
+	return n, nil
+}
+
+func (pgcs *PGCommentsStore) ResolveReports(
+	p types.PostID,
+	c types.CommentID,
+) error {
+	if _, err := (*sql.DB)(pgcs).Exec(
+		`UPDATE reports SET resolved = true
+WHERE post = $1 AND comment = $2 AND NOT resolved`,
+		p,
+		c,
+	); err != nil {
+		return fmt.Errorf("resolving reports in postgres: %w", err)
+	}
+	return nil
+}
+
+// Implement `pgutil.Item` for `types.Report` (see `comment` for the
+// rationale).
+type report types.Report
+
+func (r *report) Values(values []interface{}) {
+	values[0] = r.Post
+	values[1] = r.Comment
+	values[2] = r.Reporter
+	values[3] = r.Reason
+	values[4] = r.Created
+	values[5] = r.Resolved
+}
+
+func (r *report) Scan(pointers []interface{}) {
+	pointers[0] = &r.Post
+	pointers[1] = &r.Comment
+	pointers[2] = &r.Reporter
+	pointers[3] = &r.Reason
+	pointers[4] = &r.Created
+	pointers[5] = &r.Resolved
+}
+
+var (
+	// fail compilation if `report` doesn't implement the `pgutil.Item`
+	// interface or if `PGCommentsStore` doesn't implement the
+	// `types.ReportsStore` interface.
+	_ pgutil.Item        = &report{}
+	_ types.ReportsStore = &PGCommentsStore{}
+
+	ReportsTable = pgutil.Table{
+		Name: "reports",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "post",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "comment",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "reporter",
+			Type: "VARCHAR(255)",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name: "reason",
+			Type: "VARCHAR(512)",
+		}, {
+			Name: "created",
+			Type: "TIMESTAMPTZ",
+		}, {
+			Name:    "resolved",
+			Type:    "BOOLEAN",
+			Default: pgutil.NewBoolean(false),
+		}},
+		ExistsErr: types.ErrReportExists,
+	}
+)
@@ -0,0 +1,99 @@
+package pgcommentsstore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestPGCommentsStore_Reports(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reports := []*types.Report{{
+		Post:     "post",
+		Comment:  "comment",
+		Reporter: "adam",
+		Reason:   "spam",
+		Created:  someDate.Add(time.Hour),
+	}, {
+		Post:     "post",
+		Comment:  "comment",
+		Reporter: "eve",
+		Reason:   "abusive",
+		Created:  someDate,
+	}, {
+		Post:     "post",
+		Comment:  "other",
+		Reporter: "adam",
+		Reason:   "off topic",
+		Created:  someDate.Add(2 * time.Hour),
+	}}
+	for _, r := range reports {
+		if err := store.PutReport(r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if err := store.PutReport(&types.Report{
+		Post:     "post",
+		Comment:  "comment",
+		Reporter: "adam",
+		Reason:   "again",
+		Created:  someDate.Add(3 * time.Hour),
+	}); !errors.Is(err, types.ErrReportExists) {
+		t.Fatalf("wanted `ErrReportExists`; found `%v`", err)
+	}
+
+	n, err := store.CountOpenReports("post", "comment")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("CountOpenReports(): wanted `2`; found `%d`", n)
+	}
+
+	open, err := store.OpenReports()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkReports(t, []*types.Report{reports[1], reports[0], reports[2]}, open)
+
+	if err := store.ResolveReports("post", "comment"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if open, err = store.OpenReports(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkReports(t, []*types.Report{reports[2]}, open)
+}
+
+func checkReports(t *testing.T, wanted, found []*types.Report) {
+	t.Helper()
+	if len(found) != len(wanted) {
+		t.Fatalf(
+			"len(reports): wanted `%d`; found `%d`",
+			len(wanted),
+			len(found),
+		)
+	}
+	for i := range wanted {
+		if found[i].Post != wanted[i].Post ||
+			found[i].Comment != wanted[i].Comment ||
+			found[i].Reporter != wanted[i].Reporter ||
+			found[i].Reason != wanted[i].Reason ||
+			found[i].Resolved != wanted[i].Resolved ||
+			!found[i].Created.Equal(wanted[i].Created) {
+			t.Fatalf(
+				"reports[%d]: wanted `%+v`; found `%+v`",
+				i,
+				wanted[i],
+				found[i],
+			)
+		}
+	}
+}
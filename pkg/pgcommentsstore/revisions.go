@@ -0,0 +1,111 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func (pgcs *PGCommentsStore) PutRevision(r *types.Revision) error {
+	return RevisionsTable.Insert((*sql.DB)(pgcs), (*revision)(r))
+}
+
+func (pgcs *PGCommentsStore) Revisions(
+	p types.PostID,
+	c types.CommentID,
+) ([]*types.Revision, error) {
+	rows, err := (*sql.DB)(pgcs).Query(
+		`SELECT post, comment, edited, editor, body, created
+FROM revisions
+WHERE post = $1 AND comment = $2
+ORDER BY edited`,
+		p,
+		c,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying revisions from postgres: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("PGCommentsStore.Revisions(): closing sql.Rows: %v", err)
+		}
+	}()
+
+	revisions := []*types.Revision{}
+	for rows.Next() {
+		var r types.Revision
+		if err := rows.Scan(
+			&r.Post,
+			&r.Comment,
+			&r.Edited,
+			&r.Editor,
+			&r.Body,
+			&r.Created,
+		); err != nil {
+			return nil, fmt.Errorf(
+				"scanning postgres row into revision: %w",
+				err,
+			)
+		}
+		revisions = append(revisions, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying revisions from postgres: %w", err)
+	}
+	return revisions, nil
+}
+
+// Implement `pgutil.Item` for `types.Revision` (see `comment` for the
+// rationale).
+type revision types.Revision
+
+func (r *revision) Values(values []interface{}) {
+	values[0] = r.Post
+	values[1] = r.Comment
+	values[2] = r.Edited
+	values[3] = r.Editor
+	values[4] = r.Body
+	values[5] = r.Created
+}
+
+func (r *revision) Scan(pointers []interface{}) {
+	pointers[0] = &r.Post
+	pointers[1] = &r.Comment
+	pointers[2] = &r.Edited
+	pointers[3] = &r.Editor
+	pointers[4] = &r.Body
+	pointers[5] = &r.Created
+}
+
+var (
+	// fail compilation if `revision` doesn't implement the `pgutil.Item`
+	// interface.
+	_ pgutil.Item = &revision{}
+
+	RevisionsTable = pgutil.Table{
+		Name: "revisions",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "post",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "comment",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "edited",
+			Type: "TIMESTAMPTZ",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name: "editor",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "body",
+			Type: "VARCHAR(5096)",
+		}, {
+			Name: "created",
+			Type: "TIMESTAMPTZ",
+		}},
+	}
+)
@@ -0,0 +1,73 @@
+package pgcommentsstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestPGCommentsStore_Revisions(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := []*types.Revision{{
+		Post:    "post",
+		Comment: "comment",
+		Body:    "first",
+		Created: someDate,
+		Edited:  someDate.Add(time.Hour),
+		Editor:  "author",
+	}, {
+		Post:    "post",
+		Comment: "comment",
+		Body:    "second",
+		Created: someDate.Add(time.Hour),
+		Edited:  someDate.Add(2 * time.Hour),
+		Editor:  "moderator",
+	}}
+
+	// put the revisions out of order to check that they're sorted
+	for _, i := range []int{1, 0} {
+		if err := store.PutRevision(wanted[i]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := store.PutRevision(&types.Revision{
+		Post:    "post",
+		Comment: "other",
+		Body:    "other",
+		Created: someDate,
+		Edited:  someDate.Add(time.Hour),
+		Editor:  "author",
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found, err := store.Revisions("post", "comment")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != len(wanted) {
+		t.Fatalf(
+			"len(revisions): wanted `%d`; found `%d`",
+			len(wanted),
+			len(found),
+		)
+	}
+	for i := range wanted {
+		if found[i].Body != wanted[i].Body ||
+			found[i].Editor != wanted[i].Editor ||
+			!found[i].Created.Equal(wanted[i].Created) ||
+			!found[i].Edited.Equal(wanted[i].Edited) {
+			t.Fatalf(
+				"revisions[%d]: wanted `%+v`; found `%+v`",
+				i,
+				wanted[i],
+				found[i],
+			)
+		}
+	}
+}
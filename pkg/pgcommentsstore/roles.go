@@ -0,0 +1,60 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func (pgcs *PGCommentsStore) UserRole(
+	user types.UserID,
+) (*types.UserRole, error) {
+	var out userRole
+	if err := RolesTable.Get(
+		(*sql.DB)(pgcs),
+		&userRole{User: user},
+		&out,
+	); err != nil {
+		return nil, err
+	}
+	return (*types.UserRole)(&out), nil
+}
+
+func (pgcs *PGCommentsStore) PutUserRole(r *types.UserRole) error {
+	return RolesTable.Upsert((*sql.DB)(pgcs), (*userRole)(r))
+}
+
+// Implement `pgutil.Item` for `types.UserRole` (see `comment` for the
+// rationale).
+type userRole types.UserRole
+
+func (r *userRole) Values(values []interface{}) {
+	values[0] = r.User
+	values[1] = r.Role
+}
+
+func (r *userRole) Scan(pointers []interface{}) {
+	pointers[0] = &r.User
+	pointers[1] = &r.Role
+}
+
+var (
+	// fail compilation if `userRole` doesn't implement the `pgutil.Item`
+	// interface.
+	_ pgutil.Item = &userRole{}
+
+	RolesTable = pgutil.Table{
+		Name: "roles",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "user",
+			Type: "VARCHAR(255)",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name:    "role",
+			Type:    "VARCHAR(16)",
+			Default: pgutil.NewString(string(types.RoleUser)),
+		}},
+		NotFoundErr: types.ErrUserRoleNotFound,
+	}
+)
@@ -0,0 +1,35 @@
+package pgcommentsstore
+
+import (
+	"testing"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestPGCommentsStore_UserRole(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wantedErr types.WantedError = types.ErrUserRoleNotFound
+	_, err = store.UserRole("user")
+	if err := wantedErr.CompareErr(err); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, role := range []types.Role{types.RoleModerator, types.RoleAdmin} {
+		wanted := types.UserRole{User: "user", Role: role}
+		if err := store.PutUserRole(&wanted); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		found, err := store.UserRole("user")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *found != wanted {
+			t.Fatalf("UserRole: wanted `%+v`; found `%+v`", wanted, *found)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func (pgcs *PGCommentsStore) PutSanction(s *types.Sanction) error {
+	return SanctionsTable.Upsert((*sql.DB)(pgcs), (*sanction)(s))
+}
+
+func (pgcs *PGCommentsStore) DeleteSanction(
+	user types.UserID,
+	kind types.SanctionKind,
+) error {
+	return SanctionsTable.Delete(
+		(*sql.DB)(pgcs),
+		&sanction{User: user, Kind: kind},
+	)
+}
+
+func (pgcs *PGCommentsStore) UserSanctions(
+	user types.UserID,
+) ([]*types.Sanction, error) {
+	return pgcs.sanctionsQuery(
+		`SELECT "user", kind, reason, issuer, created, expires
+FROM sanctions
+WHERE "user" = $1
+ORDER BY kind`,
+		user,
+	)
+}
+
+func (pgcs *PGCommentsStore) Sanctions() ([]*types.Sanction, error) {
+	return pgcs.sanctionsQuery(
+		`SELECT "user", kind, reason, issuer, created, expires
+FROM sanctions
+ORDER BY "user", kind`,
+	)
+}
+
+func (pgcs *PGCommentsStore) ShadowBanned(
+	now time.Time,
+) ([]types.UserID, error) {
+	rows, err := (*sql.DB)(pgcs).Query(
+		`SELECT "user" FROM sanctions
+WHERE kind = $1 AND (expires IS NULL OR expires > $2)
+ORDER BY "user"`,
+		types.SanctionShadowBan,
+		now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying shadow-bans from postgres: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf(
+				"PGCommentsStore.ShadowBanned(): closing sql.Rows: %v",
+				err,
+			)
+		}
+	}()
+
+	var users []types.UserID
+	for rows.Next() {
+		var user types.UserID
+		if err := rows.Scan(&user); err != nil {
+			return nil, fmt.Errorf(
+				"scanning postgres row into shadow-banned user: %w",
+				err,
+			)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying shadow-bans from postgres: %w", err)
+	}
+	return users, nil
+}
+
+// shadowBannedTx reports whether `user`'s shadow-ban is in effect at `now`.
+func shadowBannedTx(
+	tx *sql.Tx,
+	user types.UserID,
+	now time.Time,
+) (bool, error) {
+	var banned bool
+	if err := tx.QueryRow(
+		`SELECT EXISTS (
+	SELECT 1 FROM sanctions
+	WHERE "user" = $1 AND kind = $2 AND (expires IS NULL OR expires > $3)
+)`,
+		user,
+		types.SanctionShadowBan,
+		now,
+	).Scan(&banned); err != nil {
+		return false, fmt.Errorf(
+			"querying shadow-ban for user `%s` from postgres: %w",
+			user,
+			err,
+		)
+	}
+	return banned, nil
+}
+
+func (pgcs *PGCommentsStore) sanctionsQuery(
+	query string,
+	vs ...interface{},
+) ([]*types.Sanction, error) {
+	rows, err := (*sql.DB)(pgcs).Query(query, vs...)
+	if err != nil {
+		return nil, fmt.Errorf("querying sanctions from postgres: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf(
+				"PGCommentsStore.sanctionsQuery(): closing sql.Rows: %v",
+				err,
+			)
+		}
+	}()
+
+	sanctions := []*types.Sanction{}
+	for rows.Next() {
+		var s types.Sanction
+		if err := rows.Scan(
+			&s.User,
+			&s.Kind,
+			&s.Reason,
+			&s.Issuer,
+			&s.Created,
+			&s.Expires,
+		); err != nil {
+			return nil, fmt.Errorf(
+				"scanning postgres row into sanction: %w",
+				err,
+			)
+		}
+		sanctions = append(sanctions, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying sanctions from postgres: %w", err)
+	}
+	return sanctions, nil
+}
+
+// Implement `pgutil.Item` for `types.Sanction` (see `comment` for the
+// rationale).
+type sanction types.Sanction
+
+func (s *sanction) Values(values []interface{}) {
+	values[0] = s.User
+	values[1] = s.Kind
+	values[2] = s.Reason
+	values[3] = s.Issuer
+	values[4] = s.Created
+	values[5] = s.Expires
+}
+
+func (s *sanction) Scan(pointers []interface{}) {
+	pointers[0] = &s.User
+	pointers[1] = &s.Kind
+	pointers[2] = &s.Reason
+	pointers[3] = &s.Issuer
+	pointers[4] = &s.Created
+	pointers[5] = &s.Expires
+}
+
+var (
+	// fail compilation if `sanction` doesn't implement the `pgutil.Item`
+	// interface or if `PGCommentsStore` doesn't implement the
+	// `types.SanctionsStore` interface.
+	_ pgutil.Item          = &sanction{}
+	_ types.SanctionsStore = &PGCommentsStore{}
+
+	SanctionsTable = pgutil.Table{
+		Name: "sanctions",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "user",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "kind",
+			Type: "VARCHAR(16)",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name: "reason",
+			Type: "VARCHAR(512)",
+		}, {
+			Name: "issuer",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "created",
+			Type: "TIMESTAMPTZ",
+		}, {
+			Name: "expires",
+			Type: "TIMESTAMPTZ",
+			Null: true,
+		}},
+		NotFoundErr: types.ErrSanctionNotFound,
+	}
+)
@@ -0,0 +1,141 @@
+package pgcommentsstore
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestPGCommentsStore_Sanctions(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expires := someDate.Add(time.Hour)
+	sanctions := []*types.Sanction{{
+		User:    "eve",
+		Kind:    types.SanctionShadowBan,
+		Reason:  "spam",
+		Issuer:  "admin",
+		Created: someDate,
+	}, {
+		User:    "adam",
+		Kind:    types.SanctionMute,
+		Reason:  "flame war",
+		Issuer:  "admin",
+		Created: someDate,
+		Expires: &expires,
+	}, {
+		User:    "eve",
+		Kind:    types.SanctionBan,
+		Reason:  "abuse",
+		Issuer:  "admin",
+		Created: someDate,
+	}}
+	for _, s := range sanctions {
+		if err := store.PutSanction(s); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// putting a sanction of the same kind replaces the existing one
+	sanctions[2].Reason = "more abuse"
+	if err := store.PutSanction(sanctions[2]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found, err := store.Sanctions()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkSanctions(
+		t,
+		[]*types.Sanction{sanctions[1], sanctions[2], sanctions[0]},
+		found,
+	)
+
+	if found, err = store.UserSanctions("eve"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkSanctions(t, []*types.Sanction{sanctions[2], sanctions[0]}, found)
+
+	if err := store.DeleteSanction("eve", types.SanctionBan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.DeleteSanction(
+		"eve",
+		types.SanctionBan,
+	); !errors.Is(err, types.ErrSanctionNotFound) {
+		t.Fatalf("wanted `ErrSanctionNotFound`; found `%v`", err)
+	}
+	if found, err = store.UserSanctions("eve"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkSanctions(t, []*types.Sanction{sanctions[0]}, found)
+}
+
+func TestPGCommentsStore_ShadowBanned(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := someDate.Add(-time.Hour)
+	expires := someDate.Add(time.Hour)
+	for _, s := range []*types.Sanction{{
+		User:    "eve",
+		Kind:    types.SanctionShadowBan,
+		Created: someDate,
+	}, {
+		User:    "adam",
+		Kind:    types.SanctionShadowBan,
+		Created: someDate,
+		Expires: &expires,
+	}, {
+		User:    "steve",
+		Kind:    types.SanctionShadowBan,
+		Created: someDate,
+		Expires: &expired,
+	}, {
+		User:    "mallory",
+		Kind:    types.SanctionBan,
+		Created: someDate,
+	}} {
+		if err := store.PutSanction(s); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	users, err := store.ShadowBanned(someDate)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wanted := []types.UserID{"adam", "eve"}
+	if fmt.Sprint(users) != fmt.Sprint(wanted) {
+		t.Fatalf("wanted `%v`; found `%v`", wanted, users)
+	}
+}
+
+func checkSanctions(t *testing.T, wanted, found []*types.Sanction) {
+	t.Helper()
+	if len(found) != len(wanted) {
+		t.Fatalf(
+			"len(sanctions): wanted `%d`; found `%d`",
+			len(wanted),
+			len(found),
+		)
+	}
+	for i := range wanted {
+		w, f := wanted[i], found[i]
+		if w.User != f.User || w.Kind != f.Kind || w.Reason != f.Reason ||
+			w.Issuer != f.Issuer || !w.Created.Equal(f.Created) ||
+			(w.Expires == nil) != (f.Expires == nil) ||
+			(w.Expires != nil && !w.Expires.Equal(*f.Expires)) {
+			t.Fatalf("sanctions[%d]: wanted `%+v`; found `%+v`", i, w, f)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func (pgcs *PGCommentsStore) PutSubscription(s *types.Subscription) error {
+	return SubscriptionsTable.Upsert((*sql.DB)(pgcs), (*subscription)(s))
+}
+
+func (pgcs *PGCommentsStore) DeleteSubscription(
+	u types.UserID,
+	p types.PostID,
+	c types.CommentID,
+) error {
+	return SubscriptionsTable.Delete(
+		(*sql.DB)(pgcs),
+		&subscription{User: u, Post: p, Comment: c},
+	)
+}
+
+func (pgcs *PGCommentsStore) Subscription(
+	u types.UserID,
+	p types.PostID,
+	c types.CommentID,
+) (*types.Subscription, error) {
+	var out subscription
+	if err := SubscriptionsTable.Get(
+		(*sql.DB)(pgcs),
+		&subscription{User: u, Post: p, Comment: c},
+		&out,
+	); err != nil {
+		return nil, err
+	}
+	return (*types.Subscription)(&out), nil
+}
+
+func (pgcs *PGCommentsStore) UserSubscriptions(
+	u types.UserID,
+) ([]*types.Subscription, error) {
+	return pgcs.subscriptionsQuery(
+		`SELECT "user", post, comment, created
+FROM subscriptions
+WHERE "user" = $1
+ORDER BY created DESC, post, comment`,
+		u,
+	)
+}
+
+func (pgcs *PGCommentsStore) PostSubscriptions(
+	p types.PostID,
+) ([]*types.Subscription, error) {
+	return pgcs.subscriptionsQuery(
+		`SELECT "user", post, comment, created
+FROM subscriptions
+WHERE post = $1
+ORDER BY "user", comment`,
+		p,
+	)
+}
+
+func (pgcs *PGCommentsStore) subscriptionsQuery(
+	query string,
+	vs ...interface{},
+) ([]*types.Subscription, error) {
+	rows, err := (*sql.DB)(pgcs).Query(query, vs...)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"querying subscriptions from postgres: %w",
+			err,
+		)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf(
+				"PGCommentsStore.subscriptionsQuery(): closing sql.Rows: %v",
+				err,
+			)
+		}
+	}()
+
+	subscriptions := []*types.Subscription{}
+	for rows.Next() {
+		var s types.Subscription
+		if err := rows.Scan(
+			&s.User,
+			&s.Post,
+			&s.Comment,
+			&s.Created,
+		); err != nil {
+			return nil, fmt.Errorf(
+				"scanning postgres row into subscription: %w",
+				err,
+			)
+		}
+		subscriptions = append(subscriptions, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf(
+			"querying subscriptions from postgres: %w",
+			err,
+		)
+	}
+	return subscriptions, nil
+}
+
+// Implement `pgutil.Item` for `types.Subscription` (see `comment` for the
+// rationale).
+type subscription types.Subscription
+
+func (s *subscription) Values(values []interface{}) {
+	values[0] = s.User
+	values[1] = s.Post
+	values[2] = s.Comment
+	values[3] = s.Created
+}
+
+func (s *subscription) Scan(pointers []interface{}) {
+	pointers[0] = &s.User
+	pointers[1] = &s.Post
+	pointers[2] = &s.Comment
+	pointers[3] = &s.Created
+}
+
+var (
+	// fail compilation if `subscription` doesn't implement the `pgutil.Item`
+	// interface or if `PGCommentsStore` doesn't implement the
+	// `types.SubscriptionsStore` interface.
+	_ pgutil.Item              = &subscription{}
+	_ types.SubscriptionsStore = &PGCommentsStore{}
+
+	SubscriptionsTable = pgutil.Table{
+		Name: "subscriptions",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "user",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "post",
+			Type: "VARCHAR(255)",
+		}, {
+			// empty for subscriptions to the whole post, since primary key
+			// columns can't be null
+			Name: "comment",
+			Type: "VARCHAR(255)",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name: "created",
+			Type: "TIMESTAMPTZ",
+		}},
+		NotFoundErr: types.ErrSubscriptionNotFound,
+	}
+)
@@ -0,0 +1,110 @@
+package pgcommentsstore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestPGCommentsStore_Subscriptions(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscriptions := []*types.Subscription{{
+		User:    "adam",
+		Post:    "post",
+		Created: someDate,
+	}, {
+		User:    "adam",
+		Post:    "post",
+		Comment: "comment",
+		Created: someDate.Add(time.Hour),
+	}, {
+		User:    "eve",
+		Post:    "post",
+		Comment: "comment",
+		Created: someDate,
+	}, {
+		User:    "adam",
+		Post:    "other-post",
+		Created: someDate.Add(2 * time.Hour),
+	}}
+	for _, s := range subscriptions {
+		if err := store.PutSubscription(s); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// subscribing again is a no-op
+	if err := store.PutSubscription(subscriptions[0]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found, err := store.UserSubscriptions("adam")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkSubscriptions(
+		t,
+		[]*types.Subscription{
+			subscriptions[3],
+			subscriptions[1],
+			subscriptions[0],
+		},
+		found,
+	)
+
+	if found, err = store.PostSubscriptions("post"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkSubscriptions(t, subscriptions[:3], found)
+
+	if err := store.DeleteSubscription("adam", "post", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := store.Subscription(
+		"adam",
+		"post",
+		"",
+	); !errors.Is(err, types.ErrSubscriptionNotFound) {
+		t.Fatalf("wanted `ErrSubscriptionNotFound`; found `%v`", err)
+	}
+	if _, err := store.Subscription("adam", "post", "comment"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.DeleteSubscription(
+		"adam",
+		"post",
+		"",
+	); !errors.Is(err, types.ErrSubscriptionNotFound) {
+		t.Fatalf("wanted `ErrSubscriptionNotFound`; found `%v`", err)
+	}
+}
+
+func checkSubscriptions(t *testing.T, wanted, found []*types.Subscription) {
+	t.Helper()
+	if len(found) != len(wanted) {
+		t.Fatalf(
+			"len(subscriptions): wanted `%d`; found `%d`",
+			len(wanted),
+			len(found),
+		)
+	}
+	for i := range wanted {
+		if found[i].User != wanted[i].User ||
+			found[i].Post != wanted[i].Post ||
+			found[i].Comment != wanted[i].Comment ||
+			!found[i].Created.Equal(wanted[i].Created) {
+			t.Fatalf(
+				"subscriptions[%d]: wanted `%+v`; found `%+v`",
+				i,
+				wanted[i],
+				found[i],
+			)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"time"
+)
+
+// UnescapeBodies reverses the HTML escaping which older versions of
+// `CommentsModel.Put()` applied to comment bodies before storing them. Only
+// comments created before `before` (i.e., before bodies were stored verbatim)
+// and never modified since are unescaped; `CommentsModel.Update()` always
+// stored verbatim bodies, so edited comments are left alone.
+//
+// This isn't idempotent: running it twice would unescape bodies which
+// legitimately contain HTML entities. It returns the number of comments which
+// were updated.
+func (pgcs *PGCommentsStore) UnescapeBodies(before time.Time) (int, error) {
+	tx, err := (*sql.DB)(pgcs).Begin()
+	if err != nil {
+		return 0, fmt.Errorf("unescaping comment bodies: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf(
+				"PGCommentsStore.UnescapeBodies(): rolling back: %v",
+				err,
+			)
+		}
+	}()
+
+	rows, err := tx.Query(
+		`SELECT post, id, body FROM comments
+WHERE created < $1 AND modified = created
+FOR UPDATE`,
+		before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("unescaping comment bodies: %w", err)
+	}
+	type row struct{ post, id, body string }
+	var escaped []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.post, &r.id, &r.body); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("unescaping comment bodies: %w", err)
+		}
+		if unescaped := html.UnescapeString(r.body); unescaped != r.body {
+			r.body = unescaped
+			escaped = append(escaped, r)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return 0, fmt.Errorf("unescaping comment bodies: %w", err)
+	}
+
+	for _, r := range escaped {
+		if _, err := tx.Exec(
+			"UPDATE comments SET body = $3 WHERE post = $1 AND id = $2",
+			r.post,
+			r.id,
+			r.body,
+		); err != nil {
+			return 0, fmt.Errorf("unescaping comment bodies: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("unescaping comment bodies: %w", err)
+	}
+	return len(escaped), nil
+}
@@ -0,0 +1,73 @@
+package pgcommentsstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestPGCommentsStore_UnescapeBodies(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixed := someDate.Add(24 * time.Hour)
+	for _, c := range []*types.Comment{{
+		// created before the fix: escaped by `Put()`
+		ID:       "escaped",
+		Post:     "post",
+		Author:   "author",
+		Created:  someDate,
+		Modified: someDate,
+		Body:     "a &lt; b &amp;&amp; c",
+	}, {
+		// edited before the fix: `Update()` stored the body verbatim
+		ID:       "edited",
+		Post:     "post",
+		Author:   "author",
+		Created:  someDate,
+		Modified: someDate.Add(time.Hour),
+		Body:     "a &lt; b",
+	}, {
+		// created after the fix
+		ID:       "verbatim",
+		Post:     "post",
+		Author:   "author",
+		Created:  fixed.Add(time.Hour),
+		Modified: fixed.Add(time.Hour),
+		Body:     "a &lt; b",
+	}} {
+		if err := store.Put(c); err != nil {
+			t.Fatalf("unexpected error preparing test database state: %v", err)
+		}
+	}
+
+	n, err := store.UnescapeBodies(fixed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("updated comments: wanted `1`; found `%d`", n)
+	}
+
+	for id, wanted := range map[types.CommentID]string{
+		"escaped":  "a < b && c",
+		"edited":   "a &lt; b",
+		"verbatim": "a &lt; b",
+	} {
+		c, err := store.Comment("post", id)
+		if err != nil {
+			t.Fatalf("fetching comment `%s`: %v", id, err)
+		}
+		if c.Body != wanted {
+			t.Fatalf(
+				"Comment[%s].Body: wanted `%s`; found `%s`",
+				id,
+				wanted,
+				c.Body,
+			)
+		}
+	}
+}
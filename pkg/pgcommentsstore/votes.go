@@ -0,0 +1,90 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func (pgcs *PGCommentsStore) PutVote(v *types.Vote) error {
+	return VotesTable.Upsert((*sql.DB)(pgcs), (*vote)(v))
+}
+
+func (pgcs *PGCommentsStore) DeleteVote(
+	p types.PostID,
+	c types.CommentID,
+	u types.UserID,
+) error {
+	return VotesTable.Delete(
+		(*sql.DB)(pgcs),
+		&vote{Post: p, Comment: c, User: u},
+	)
+}
+
+// scoreColumns are the columns of the relation joined by `scoresJoin()` in
+// the order expected by `scorePointers()`.
+const scoreColumns = "scores.score, scores.upvotes, scores.downvotes"
+
+// scoresJoin returns a clause which joins each row of the comments relation
+// `comments` with a `scores` relation aggregating the comment's votes.
+func scoresJoin(comments string) string {
+	return fmt.Sprintf(
+		`LEFT JOIN LATERAL (
+	SELECT
+		COALESCE(sum(votes.value), 0) AS score,
+		count(*) FILTER (WHERE votes.value > 0) AS upvotes,
+		count(*) FILTER (WHERE votes.value < 0) AS downvotes
+	FROM votes
+	WHERE votes.post = %[1]s.post AND votes.comment = %[1]s.id
+) AS scores ON TRUE`,
+		comments,
+	)
+}
+
+func scorePointers(c *types.Comment) []interface{} {
+	return []interface{}{&c.Score, &c.Upvotes, &c.Downvotes}
+}
+
+// Implement `pgutil.Item` for `types.Vote` (see `comment` for the rationale).
+type vote types.Vote
+
+func (v *vote) Values(values []interface{}) {
+	values[0] = v.Post
+	values[1] = v.Comment
+	values[2] = v.User
+	values[3] = v.Value
+}
+
+func (v *vote) Scan(pointers []interface{}) {
+	pointers[0] = &v.Post
+	pointers[1] = &v.Comment
+	pointers[2] = &v.User
+	pointers[3] = &v.Value
+}
+
+var (
+	// fail compilation if `vote` doesn't implement the `pgutil.Item`
+	// interface.
+	_ pgutil.Item = &vote{}
+
+	VotesTable = pgutil.Table{
+		Name: "votes",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "post",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "comment",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "user",
+			Type: "VARCHAR(255)",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name: "value",
+			Type: "INTEGER",
+		}},
+		NotFoundErr: types.ErrVoteNotFound,
+	}
+)
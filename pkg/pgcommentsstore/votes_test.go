@@ -0,0 +1,119 @@
+package pgcommentsstore
+
+import (
+	"testing"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestPGCommentsStore_Votes(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Put(&types.Comment{
+		ID:       "comment",
+		Post:     "post",
+		Author:   "author",
+		Created:  someDate,
+		Modified: someDate,
+		Body:     "body",
+	}); err != nil {
+		t.Fatalf("unexpected error preparing test database state: %v", err)
+	}
+
+	for _, step := range []struct {
+		name        string
+		do          func() error
+		wantedErr   types.WantedError
+		wantedScore int
+		wantedUp    int
+		wantedDown  int
+	}{
+		{
+			name: "upvote",
+			do: func() error {
+				return store.PutVote(&types.Vote{
+					Post:    "post",
+					Comment: "comment",
+					User:    "alice",
+					Value:   types.Upvote,
+				})
+			},
+			wantedScore: 1,
+			wantedUp:    1,
+		},
+		{
+			name: "another user downvotes",
+			do: func() error {
+				return store.PutVote(&types.Vote{
+					Post:    "post",
+					Comment: "comment",
+					User:    "bob",
+					Value:   types.Downvote,
+				})
+			},
+			wantedScore: 0,
+			wantedUp:    1,
+			wantedDown:  1,
+		},
+		{
+			name: "change vote",
+			do: func() error {
+				return store.PutVote(&types.Vote{
+					Post:    "post",
+					Comment: "comment",
+					User:    "alice",
+					Value:   types.Downvote,
+				})
+			},
+			wantedScore: -2,
+			wantedDown:  2,
+		},
+		{
+			name: "remove vote",
+			do: func() error {
+				return store.DeleteVote("post", "comment", "bob")
+			},
+			wantedScore: -1,
+			wantedDown:  1,
+		},
+		{
+			name: "remove missing vote",
+			do: func() error {
+				return store.DeleteVote("post", "comment", "bob")
+			},
+			wantedErr:   types.ErrVoteNotFound,
+			wantedScore: -1,
+			wantedDown:  1,
+		},
+	} {
+		if step.wantedErr == nil {
+			step.wantedErr = types.NilError{}
+		}
+		if err := step.wantedErr.CompareErr(step.do()); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		c, err := store.Comment("post", "comment")
+		if err != nil {
+			t.Fatalf("%s: fetching comment: %v", step.name, err)
+		}
+		if c.Score != step.wantedScore ||
+			c.Upvotes != step.wantedUp ||
+			c.Downvotes != step.wantedDown {
+			t.Fatalf(
+				"%s: (Score, Upvotes, Downvotes): wanted `(%d, %d, %d)`; "+
+					"found `(%d, %d, %d)`",
+				step.name,
+				step.wantedScore,
+				step.wantedUp,
+				step.wantedDown,
+				c.Score,
+				c.Upvotes,
+				c.Downvotes,
+			)
+		}
+	}
+}
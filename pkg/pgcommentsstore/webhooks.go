@@ -0,0 +1,316 @@
+package pgcommentsstore
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/weberc2/auth/pkg/pgutil"
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func (pgcs *PGCommentsStore) PutWebhook(w *types.Webhook) error {
+	return WebhooksTable.Upsert((*sql.DB)(pgcs), (*webhook)(w))
+}
+
+func (pgcs *PGCommentsStore) DeleteWebhook(id string) error {
+	return WebhooksTable.Delete((*sql.DB)(pgcs), &webhook{ID: id})
+}
+
+func (pgcs *PGCommentsStore) Webhook(id string) (*types.Webhook, error) {
+	var out webhook
+	if err := WebhooksTable.Get(
+		(*sql.DB)(pgcs),
+		&webhook{ID: id},
+		&out,
+	); err != nil {
+		return nil, err
+	}
+	return (*types.Webhook)(&out), nil
+}
+
+func (pgcs *PGCommentsStore) Webhooks() ([]*types.Webhook, error) {
+	rows, err := (*sql.DB)(pgcs).Query(
+		`SELECT id, url, secret, events, created
+FROM webhooks
+ORDER BY created, id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying webhooks from postgres: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf(
+				"PGCommentsStore.Webhooks(): closing sql.Rows: %v",
+				err,
+			)
+		}
+	}()
+
+	webhooks := []*types.Webhook{}
+	for rows.Next() {
+		var w types.Webhook
+		if err := rows.Scan(
+			&w.ID,
+			&w.URL,
+			&w.Secret,
+			(*eventTypes)(&w.Events),
+			&w.Created,
+		); err != nil {
+			return nil, fmt.Errorf(
+				"scanning postgres row into webhook: %w",
+				err,
+			)
+		}
+		webhooks = append(webhooks, &w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying webhooks from postgres: %w", err)
+	}
+	return webhooks, nil
+}
+
+func (pgcs *PGCommentsStore) PutFailedDelivery(
+	d *types.WebhookDelivery,
+) error {
+	return FailedDeliveriesTable.Upsert(
+		(*sql.DB)(pgcs),
+		(*failedDelivery)(d),
+	)
+}
+
+func (pgcs *PGCommentsStore) DeleteFailedDelivery(id string) error {
+	return FailedDeliveriesTable.Delete(
+		(*sql.DB)(pgcs),
+		&failedDelivery{ID: id},
+	)
+}
+
+func (pgcs *PGCommentsStore) FailedDelivery(
+	id string,
+) (*types.WebhookDelivery, error) {
+	var out failedDelivery
+	if err := FailedDeliveriesTable.Get(
+		(*sql.DB)(pgcs),
+		&failedDelivery{ID: id},
+		&out,
+	); err != nil {
+		return nil, err
+	}
+	return (*types.WebhookDelivery)(&out), nil
+}
+
+func (pgcs *PGCommentsStore) FailedDeliveries() (
+	[]*types.WebhookDelivery,
+	error,
+) {
+	rows, err := (*sql.DB)(pgcs).Query(
+		`SELECT id, webhook, event, attempts, error, failed
+FROM webhook_failed_deliveries
+ORDER BY failed DESC, id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"querying failed deliveries from postgres: %w",
+			err,
+		)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf(
+				"PGCommentsStore.FailedDeliveries(): closing sql.Rows: %v",
+				err,
+			)
+		}
+	}()
+
+	deliveries := []*types.WebhookDelivery{}
+	for rows.Next() {
+		var d types.WebhookDelivery
+		if err := rows.Scan(
+			&d.ID,
+			&d.Webhook,
+			jsonColumn{&d.Event},
+			&d.Attempts,
+			&d.Error,
+			&d.Failed,
+		); err != nil {
+			return nil, fmt.Errorf(
+				"scanning postgres row into failed delivery: %w",
+				err,
+			)
+		}
+		deliveries = append(deliveries, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf(
+			"querying failed deliveries from postgres: %w",
+			err,
+		)
+	}
+	return deliveries, nil
+}
+
+// eventTypes stores a webhook's event types as a comma-separated list.
+type eventTypes []types.EventType
+
+func (ets eventTypes) Value() (driver.Value, error) {
+	ss := make([]string, len(ets))
+	for i, et := range ets {
+		ss[i] = string(et)
+	}
+	return strings.Join(ss, ","), nil
+}
+
+func (ets *eventTypes) Scan(src interface{}) error {
+	var s string
+	switch src := src.(type) {
+	case string:
+		s = src
+	case []byte:
+		s = string(src)
+	default:
+		return fmt.Errorf("scanning event types: unexpected type `%T`", src)
+	}
+	*ets = nil
+	if s == "" {
+		return nil
+	}
+	for _, et := range strings.Split(s, ",") {
+		*ets = append(*ets, types.EventType(et))
+	}
+	return nil
+}
+
+// jsonColumn stores a value as JSON.
+type jsonColumn struct{ v interface{} }
+
+func (jc jsonColumn) Value() (driver.Value, error) {
+	data, err := json.Marshal(jc.v)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling json column: %w", err)
+	}
+	return string(data), nil
+}
+
+func (jc jsonColumn) Scan(src interface{}) error {
+	var data []byte
+	switch src := src.(type) {
+	case string:
+		data = []byte(src)
+	case []byte:
+		data = src
+	default:
+		return fmt.Errorf("scanning json column: unexpected type `%T`", src)
+	}
+	if err := json.Unmarshal(data, jc.v); err != nil {
+		return fmt.Errorf("scanning json column: %w", err)
+	}
+	return nil
+}
+
+// Implement `pgutil.Item` for `types.Webhook` (see `comment` for the
+// rationale).
+type webhook types.Webhook
+
+func (w *webhook) Values(values []interface{}) {
+	values[0] = w.ID
+	values[1] = w.URL
+	values[2] = w.Secret
+	values[3] = eventTypes(w.Events)
+	values[4] = w.Created
+}
+
+func (w *webhook) Scan(pointers []interface{}) {
+	pointers[0] = &w.ID
+	pointers[1] = &w.URL
+	pointers[2] = &w.Secret
+	pointers[3] = (*eventTypes)(&w.Events)
+	pointers[4] = &w.Created
+}
+
+// Implement `pgutil.Item` for `types.WebhookDelivery` (see `comment` for the
+// rationale).
+type failedDelivery types.WebhookDelivery
+
+func (d *failedDelivery) Values(values []interface{}) {
+	values[0] = d.ID
+	values[1] = d.Webhook
+	values[2] = jsonColumn{d.Event}
+	values[3] = d.Attempts
+	values[4] = d.Error
+	values[5] = d.Failed
+}
+
+func (d *failedDelivery) Scan(pointers []interface{}) {
+	pointers[0] = &d.ID
+	pointers[1] = &d.Webhook
+	pointers[2] = jsonColumn{&d.Event}
+	pointers[3] = &d.Attempts
+	pointers[4] = &d.Error
+	pointers[5] = &d.Failed
+}
+
+var (
+	// fail compilation if `webhook` or `failedDelivery` don't implement the
+	// `pgutil.Item` interface or if `PGCommentsStore` doesn't implement the
+	// `types.WebhooksStore` interface.
+	_ pgutil.Item         = &webhook{}
+	_ pgutil.Item         = &failedDelivery{}
+	_ types.WebhooksStore = &PGCommentsStore{}
+
+	WebhooksTable = pgutil.Table{
+		Name: "webhooks",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "id",
+			Type: "VARCHAR(255)",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name: "url",
+			Type: "VARCHAR(2048)",
+		}, {
+			Name: "secret",
+			Type: "VARCHAR(255)",
+		}, {
+			// comma-separated event types (empty for every type)
+			Name: "events",
+			Type: "VARCHAR(255)",
+		}, {
+			Name: "created",
+			Type: "TIMESTAMPTZ",
+		}},
+		NotFoundErr: types.ErrWebhookNotFound,
+	}
+
+	// FailedDeliveriesTable is the dead-letter list of webhook deliveries
+	// which failed every attempt. Failed deliveries outlive their webhooks,
+	// since they may be worth inspecting.
+	FailedDeliveriesTable = pgutil.Table{
+		Name: "webhook_failed_deliveries",
+		PrimaryKeys: []pgutil.Column{{
+			Name: "id",
+			Type: "VARCHAR(255)",
+		}},
+		OtherColumns: []pgutil.Column{{
+			Name: "webhook",
+			Type: "VARCHAR(255)",
+		}, {
+			// the event as JSON
+			Name: "event",
+			Type: "TEXT",
+		}, {
+			Name: "attempts",
+			Type: "INTEGER",
+		}, {
+			Name: "error",
+			Type: "TEXT",
+		}, {
+			Name: "failed",
+			Type: "TIMESTAMPTZ",
+		}},
+		NotFoundErr: types.ErrDeliveryNotFound,
+	}
+)
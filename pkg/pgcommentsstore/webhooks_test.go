@@ -0,0 +1,152 @@
+package pgcommentsstore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weberc2/comments/pkg/comments/types"
+)
+
+func TestPGCommentsStore_Webhooks(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhooks := []*types.Webhook{{
+		ID:      "all",
+		URL:     "https://example.org/hooks/all",
+		Secret:  "secret",
+		Created: someDate,
+	}, {
+		ID:     "deletes",
+		URL:    "https://example.org/hooks/deletes",
+		Secret: "other-secret",
+		Events: []types.EventType{
+			types.EventCommentUpdated,
+			types.EventCommentDeleted,
+		},
+		Created: someDate.Add(time.Hour),
+	}}
+	for _, w := range webhooks {
+		if err := store.PutWebhook(w); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	found, err := store.Webhooks()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != len(webhooks) {
+		t.Fatalf(
+			"len(webhooks): wanted `%d`; found `%d`",
+			len(webhooks),
+			len(found),
+		)
+	}
+	for i := range webhooks {
+		checkWebhook(t, webhooks[i], found[i])
+	}
+
+	w, err := store.Webhook("deletes")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkWebhook(t, webhooks[1], w)
+
+	if err := store.DeleteWebhook("deletes"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := store.Webhook(
+		"deletes",
+	); !errors.Is(err, types.ErrWebhookNotFound) {
+		t.Fatalf("wanted `ErrWebhookNotFound`; found `%v`", err)
+	}
+}
+
+func checkWebhook(t *testing.T, wanted, found *types.Webhook) {
+	t.Helper()
+	if found.ID != wanted.ID ||
+		found.URL != wanted.URL ||
+		found.Secret != wanted.Secret ||
+		len(found.Events) != len(wanted.Events) ||
+		!found.Created.Equal(wanted.Created) {
+		t.Fatalf("webhook: wanted `%+v`; found `%+v`", wanted, found)
+	}
+	for i := range wanted.Events {
+		if found.Events[i] != wanted.Events[i] {
+			t.Fatalf("webhook: wanted `%+v`; found `%+v`", wanted, found)
+		}
+	}
+}
+
+func TestPGCommentsStore_FailedDeliveries(t *testing.T) {
+	store, err := testPGCommentsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries := []*types.WebhookDelivery{{
+		ID:      "older",
+		Webhook: "webhook",
+		Event: &types.Event{
+			Type: types.EventCommentCreated,
+			Time: someDate,
+			Comment: &types.EventComment{
+				ID:     "comment",
+				Post:   "post",
+				Author: "adam",
+				Body:   "Hello, world!",
+			},
+		},
+		Attempts: 5,
+		Error:    "unexpected status: 503 Service Unavailable",
+		Failed:   someDate,
+	}, {
+		ID:      "newer",
+		Webhook: "webhook",
+		Event: &types.Event{
+			Type:    types.EventCommentDeleted,
+			Time:    someDate,
+			Comment: &types.EventComment{ID: "comment", Post: "post"},
+		},
+		Attempts: 5,
+		Error:    "connection refused",
+		Failed:   someDate.Add(time.Hour),
+	}}
+	for _, d := range deliveries {
+		if err := store.PutFailedDelivery(d); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	found, err := store.FailedDeliveries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != 2 || found[0].ID != "newer" || found[1].ID != "older" {
+		t.Fatalf("wanted deliveries `newer`, `older`; found `%+v`", found)
+	}
+
+	d, err := store.FailedDelivery("older")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Event.Type != types.EventCommentCreated ||
+		d.Event.Comment.Body != "Hello, world!" ||
+		d.Attempts != 5 ||
+		!d.Failed.Equal(someDate) {
+		t.Fatalf("delivery: wanted `%+v`; found `%+v`", deliveries[0], d)
+	}
+
+	if err := store.DeleteFailedDelivery("older"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.DeleteFailedDelivery(
+		"older",
+	); !errors.Is(err, types.ErrDeliveryNotFound) {
+		t.Fatalf("wanted `ErrDeliveryNotFound`; found `%v`", err)
+	}
+}